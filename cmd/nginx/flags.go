@@ -27,12 +27,14 @@ import (
 
 	apiv1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/klog"
 
 	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
 	"k8s.io/ingress-nginx/internal/ingress/controller"
 	ngx_config "k8s.io/ingress-nginx/internal/ingress/controller/config"
 	"k8s.io/ingress-nginx/internal/ingress/controller/ingressclass"
+	ngx_template "k8s.io/ingress-nginx/internal/ingress/controller/template"
 	"k8s.io/ingress-nginx/internal/ingress/status"
 	ing_net "k8s.io/ingress-nginx/internal/net"
 	"k8s.io/ingress-nginx/internal/nginx"
@@ -62,6 +64,8 @@ requests to the first port of this Service.`)
 		ingressClassAnnotation = flags.String("ingress-class", ingressclass.DefaultAnnotationValue,
 			`[IN DEPRECATION] Name of the ingress class this controller satisfies.
 The class of an Ingress object is set using the annotation "kubernetes.io/ingress.class" (deprecated).
+A comma separated list of classes is accepted, the first one is used where a single canonical name is needed.
+All ingress classes are satisfied if it is empty.
 The parameter --controller-class has precedence over this.`)
 
 		ingressClassController = flags.String("controller-class", ingressclass.DefaultControllerName,
@@ -99,6 +103,12 @@ either be a port name or number.`)
 		resyncPeriod = flags.Duration("sync-period", 0,
 			`Period at which the controller forces the repopulation of its local object stores. Disabled by default.`)
 
+		configMapSyncDelay = flags.Duration("configmap-sync-delay", 1*time.Second,
+			`Time the changes of the configmaps are coalesced before syncing the ingresses. 0 syncs them on every change.`)
+
+		sslCertCleanupTTL = flags.Duration("ssl-cert-cleanup-ttl", 1*time.Hour,
+			`Time a certificate of the local store is kept after no Ingress or Secret references it. 0 disables the cleanup.`)
+
 		watchNamespace = flags.String("watch-namespace", apiv1.NamespaceAll,
 			`Namespace the controller watches for updates to Kubernetes objects.
 This includes Ingresses, Services and all configuration resources. All
@@ -158,6 +168,9 @@ extension for this to succeed.`)
 		syncRateLimit = flags.Float32("sync-rate-limit", 0.3,
 			`Define the sync frequency upper limit`)
 
+		syncBurst = flags.Int("sync-burst", 1,
+			`Maximum number of syncs allowed in a burst above the sync-rate-limit.`)
+
 		publishStatusAddress = flags.String("publish-status-address", "",
 			`Customized address to set as the load-balancer status of Ingress objects this controller satisfies.
 Requires the update-status parameter.`)
@@ -169,6 +182,10 @@ Requires the update-status parameter.`)
 			`Enables the collection of NGINX metrics`)
 		metricsPerHost = flags.Bool("metrics-per-host", true,
 			`Export metrics per-host`)
+		metricsPerTenant = flags.Bool("metrics-per-tenant", false,
+			`Export metrics per-tenant, the tenant of a host is defined by the metrics-tenant annotation`)
+		metricsMaxTenants = flags.Int("metrics-max-tenants", 100,
+			`Maximum number of tenants exported in metrics, the hosts of other tenants are exported as tenant "other". 0 means no limit`)
 
 		httpPort  = flags.Int("http-port", 80, `Port to use for servicing HTTP traffic.`)
 		httpsPort = flags.Int("https-port", 443, `Port to use for servicing HTTPS traffic.`)
@@ -181,6 +198,13 @@ Requires the update-status parameter.`)
 		disableCatchAll = flags.Bool("disable-catch-all", false,
 			`Disable support for catch-all Ingresses`)
 
+		useEndpointSlices = flags.Bool("use-endpoint-slices", false,
+			`Watch the EndpointSlices of the Services instead of their Endpoints. Requires Kubernetes v1.21.0 or higher.`)
+
+		storageClusterHealthz = flags.String("storage-cluster-healthz", controller.StorageClusterHealthzDegraded,
+			`Check of the connectivity to the cluster storing the ingresses and secrets in the health check.
+"degraded" only logs the failures, "fatal" fails the health check and "disabled" does not check it.`)
+
 		validationWebhook = flags.String("validating-webhook", "",
 			`The address to start an admission controller on to validate incoming ingresses.
 Takes the form "<host>:port". If not provided, no admission controller is started.`)
@@ -221,6 +245,25 @@ https://blog.maxmind.com/2019/12/18/significant-changes-to-accessing-and-using-g
 		return true, nil, nil
 	}
 
+	switch *storageClusterHealthz {
+	case controller.StorageClusterHealthzDisabled, controller.StorageClusterHealthzDegraded, controller.StorageClusterHealthzFatal:
+	default:
+		return false, nil, fmt.Errorf("flag --storage-cluster-healthz must be one of %v, %v or %v",
+			controller.StorageClusterHealthzDisabled, controller.StorageClusterHealthzDegraded, controller.StorageClusterHealthzFatal)
+	}
+
+	if *configMapSyncDelay < 0 {
+		return false, nil, fmt.Errorf("flag --configmap-sync-delay must not be negative")
+	}
+
+	if *sslCertCleanupTTL < 0 {
+		return false, nil, fmt.Errorf("flag --ssl-cert-cleanup-ttl must not be negative")
+	}
+
+	if *syncBurst < 1 {
+		return false, nil, fmt.Errorf("flag --sync-burst must be greater than 0")
+	}
+
 	if *statusUpdateInterval < 5 {
 		klog.Warningf("The defined time to update the Ingress status too low (%v seconds). Adjusting to 5 seconds", *statusUpdateInterval)
 		status.UpdateInterval = 5
@@ -263,6 +306,9 @@ https://blog.maxmind.com/2019/12/18/significant-changes-to-accessing-and-using-g
 	nginx.StreamPort = *streamPort
 	nginx.ProfilerPort = *profilerPort
 
+	ngx_template.ReservedPorts = sets.NewInt(*httpPort, *defServerPort, *healthzPort,
+		*sslProxyPort, *statusPort, *streamPort, *profilerPort)
+
 	if *enableSSLPassthrough && !ing_net.IsPortAvailable(*sslProxyPort) {
 		return false, nil, fmt.Errorf("port %v is already in use. Please check the flag --ssl-passthrough-proxy-port", *sslProxyPort)
 	}
@@ -271,6 +317,10 @@ https://blog.maxmind.com/2019/12/18/significant-changes-to-accessing-and-using-g
 		klog.Warningf("SSL certificate chain completion is disabled (--enable-ssl-chain-completion=false)")
 	}
 
+	if *metricsMaxTenants < 0 {
+		return false, nil, fmt.Errorf("flag --metrics-max-tenants must not be negative")
+	}
+
 	if *publishSvc != "" && *publishStatusAddress != "" {
 		return false, nil, fmt.Errorf("flags --publish-service and --publish-status-address are mutually exclusive")
 	}
@@ -294,6 +344,16 @@ https://blog.maxmind.com/2019/12/18/significant-changes-to-accessing-and-using-g
 		}
 	}
 
+	ingressClasses, err := ingressclass.ParseAnnotationValues(*ingressClassAnnotation)
+	if err != nil {
+		return false, nil, fmt.Errorf("failed to parse --ingress-class=%s, error: %v", *ingressClassAnnotation, err)
+	}
+
+	ingressClass := ""
+	if len(ingressClasses) > 0 {
+		ingressClass = ingressClasses[0]
+	}
+
 	ngx_config.EnableSSLChainCompletion = *enableSSLChainCompletion
 
 	config := &controller.Configuration{
@@ -304,8 +364,12 @@ https://blog.maxmind.com/2019/12/18/significant-changes-to-accessing-and-using-g
 		EnableProfiling:        *profiling,
 		EnableMetrics:          *enableMetrics,
 		MetricsPerHost:         *metricsPerHost,
+		MetricsPerTenant:       *metricsPerTenant,
+		MetricsMaxTenants:      *metricsMaxTenants,
 		EnableSSLPassthrough:   *enableSSLPassthrough,
 		ResyncPeriod:           *resyncPeriod,
+		ConfigMapSyncDelay:     *configMapSyncDelay,
+		SSLCertTTL:             *sslCertCleanupTTL,
 		DefaultService:         *defaultSvc,
 		Namespace:              *watchNamespace,
 		WatchNamespaceSelector: namespaceSelector,
@@ -318,6 +382,7 @@ https://blog.maxmind.com/2019/12/18/significant-changes-to-accessing-and-using-g
 		UpdateStatusOnShutdown: *updateStatusOnShutdown,
 		UseNodeInternalIP:      *useNodeInternalIP,
 		SyncRateLimit:          *syncRateLimit,
+		SyncBurst:              *syncBurst,
 		ListenPorts: &ngx_config.ListenPorts{
 			Default:  *defServerPort,
 			Health:   *healthzPort,
@@ -328,11 +393,14 @@ https://blog.maxmind.com/2019/12/18/significant-changes-to-accessing-and-using-g
 		},
 		IngressClassConfiguration: &ingressclass.IngressClassConfiguration{
 			Controller:         *ingressClassController,
-			AnnotationValue:    *ingressClassAnnotation,
+			AnnotationValue:    ingressClass,
+			AnnotationValues:   ingressClasses,
 			WatchWithoutClass:  *watchWithoutClass,
 			IngressClassByName: *ingressClassByName,
 		},
 		DisableCatchAll:           *disableCatchAll,
+		UseEndpointSlices:         *useEndpointSlices,
+		StorageClusterHealthz:     *storageClusterHealthz,
 		ValidationWebhook:         *validationWebhook,
 		ValidationWebhookCertPath: *validationWebhookCert,
 		ValidationWebhookKeyPath:  *validationWebhookKey,
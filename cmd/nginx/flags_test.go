@@ -75,3 +75,104 @@ func TestFlagConflict(t *testing.T) {
 		t.Fatalf("Expected an error parsing flags but none returned")
 	}
 }
+
+func TestIngressClassList(t *testing.T) {
+	resetForTesting(func() { t.Fatal("Parsing failed") })
+
+	oldArgs := os.Args
+	defer func() { os.Args = oldArgs }()
+	os.Args = []string{"cmd", "--http-port", "0", "--https-port", "0", "--ingress-class", "tengine, nginx"}
+
+	_, conf, err := parseFlags()
+	if err != nil {
+		t.Fatalf("Unexpected error parsing flags: %v", err)
+	}
+
+	icConfig := conf.IngressClassConfiguration
+	if icConfig.AnnotationValue != "tengine" {
+		t.Errorf("Expected canonical ingress class \"tengine\" but got %q", icConfig.AnnotationValue)
+	}
+	if !icConfig.MatchAnnotation("nginx") {
+		t.Errorf("Expected ingress class \"nginx\" to be watched")
+	}
+}
+
+func TestIngressClassListWithEmptyEntry(t *testing.T) {
+	resetForTesting(func() { t.Fatal("Parsing failed") })
+
+	oldArgs := os.Args
+	defer func() { os.Args = oldArgs }()
+	os.Args = []string{"cmd", "--http-port", "0", "--https-port", "0", "--ingress-class", "tengine,,nginx"}
+
+	_, _, err := parseFlags()
+	if err == nil {
+		t.Fatalf("Expected an error parsing flags but none returned")
+	}
+}
+
+func TestStorageClusterHealthz(t *testing.T) {
+	resetForTesting(func() { t.Fatal("Parsing failed") })
+
+	oldArgs := os.Args
+	defer func() { os.Args = oldArgs }()
+	os.Args = []string{"cmd", "--http-port", "0", "--https-port", "0", "--storage-cluster-healthz", "fatal"}
+
+	_, conf, err := parseFlags()
+	if err != nil {
+		t.Fatalf("Unexpected error parsing flags: %v", err)
+	}
+	if conf.StorageClusterHealthz != "fatal" {
+		t.Errorf("Expected the storage cluster healthz \"fatal\" but got %q", conf.StorageClusterHealthz)
+	}
+
+	resetForTesting(func() { t.Fatal("Parsing failed") })
+	os.Args = []string{"cmd", "--http-port", "0", "--https-port", "0", "--storage-cluster-healthz", "strict"}
+
+	if _, _, err := parseFlags(); err == nil {
+		t.Fatalf("Expected an error parsing flags but none returned")
+	}
+}
+
+func TestSyncBurst(t *testing.T) {
+	resetForTesting(func() { t.Fatal("Parsing failed") })
+
+	oldArgs := os.Args
+	defer func() { os.Args = oldArgs }()
+	os.Args = []string{"cmd", "--http-port", "0", "--https-port", "0", "--sync-burst", "5"}
+
+	_, conf, err := parseFlags()
+	if err != nil {
+		t.Fatalf("Unexpected error parsing flags: %v", err)
+	}
+	if conf.SyncBurst != 5 {
+		t.Errorf("Expected the sync burst 5 but got %v", conf.SyncBurst)
+	}
+
+	resetForTesting(func() { t.Fatal("Parsing failed") })
+	os.Args = []string{"cmd", "--http-port", "0", "--https-port", "0", "--sync-burst", "0"}
+
+	if _, _, err := parseFlags(); err == nil {
+		t.Fatalf("Expected an error parsing flags but none returned")
+	}
+}
+
+func TestIngressClassEmpty(t *testing.T) {
+	resetForTesting(func() { t.Fatal("Parsing failed") })
+
+	oldArgs := os.Args
+	defer func() { os.Args = oldArgs }()
+	os.Args = []string{"cmd", "--http-port", "0", "--https-port", "0", "--ingress-class", ""}
+
+	_, conf, err := parseFlags()
+	if err != nil {
+		t.Fatalf("Unexpected error parsing flags: %v", err)
+	}
+
+	icConfig := conf.IngressClassConfiguration
+	if icConfig.AnnotationValue != "" {
+		t.Errorf("Expected no canonical ingress class but got %q", icConfig.AnnotationValue)
+	}
+	if !icConfig.MatchAnnotation("custom") {
+		t.Errorf("Expected all the ingress classes to be watched")
+	}
+}
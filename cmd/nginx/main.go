@@ -19,8 +19,11 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"io"
 	"math/rand"
+	"net"
 	"net/http"
 	"net/http/pprof"
 	"os"
@@ -47,6 +50,7 @@ import (
 	secretcheck "k8s.io/ingress-nginx/internal/checksum/secret/client/clientset/versioned"
 	"k8s.io/ingress-nginx/internal/file"
 	"k8s.io/ingress-nginx/internal/ingress/controller"
+	ngx_config "k8s.io/ingress-nginx/internal/ingress/controller/config"
 	"k8s.io/ingress-nginx/internal/ingress/metric"
 	"k8s.io/ingress-nginx/internal/k8s"
 	"k8s.io/ingress-nginx/internal/net/ssl"
@@ -54,11 +58,22 @@ import (
 	"k8s.io/ingress-nginx/version"
 )
 
+// printDefaultsCommand is the subcommand that prints every configmap key
+// with its type and default value as JSON
+const printDefaultsCommand = "print-defaults"
+
 func main() {
 	klog.InitFlags(nil)
 
 	rand.Seed(time.Now().UnixNano())
 
+	if len(os.Args) > 1 && os.Args[1] == printDefaultsCommand {
+		if err := printDefaults(os.Stdout); err != nil {
+			klog.Fatal(err)
+		}
+		os.Exit(0)
+	}
+
 	fmt.Println(version.String())
 
 	showVersion, conf, err := parseFlags()
@@ -120,6 +135,11 @@ func main() {
 		klog.Fatalf("tengine-ingress requires Kubernetes v1.19.0 or higher")
 	}
 
+	if conf.UseEndpointSlices && !k8s.DiscoveryEndpointSliceAvailable(kubeClient) {
+		klog.Warningf("EndpointSlices v1 require Kubernetes v1.21.0 or higher, using Endpoints")
+		conf.UseEndpointSlices = false
+	}
+
 	_, err = kubeClient.NetworkingV1().IngressClasses().List(context.TODO(), metav1.ListOptions{})
 	if err != nil {
 		if !errors.IsNotFound(err) {
@@ -145,7 +165,7 @@ func main() {
 
 	mc := metric.NewDummyCollector()
 	if conf.EnableMetrics {
-		mc, err = metric.NewCollector(conf.MetricsPerHost, reg)
+		mc, err = metric.NewCollector(conf.MetricsPerHost, conf.MetricsPerTenant, conf.MetricsMaxTenants, reg)
 		if err != nil {
 			klog.Fatalf("Error creating prometheus collector:  %v", err)
 		}
@@ -158,8 +178,13 @@ func main() {
 
 	ngx := controller.NewNGINXController(conf, mc)
 
+	sc := controller.NewStorageClusterChecker(conf)
+	if sc != nil {
+		go sc.Run(wait.NeverStop)
+	}
+
 	mux := http.NewServeMux()
-	registerHealthz(nginx.HealthPath, ngx, mux)
+	registerHealthz(nginx.HealthPath, ngx, sc, mux)
 	registerMetrics(reg, mux)
 
 	go startHTTPServer(conf.ListenPorts.Health, mux)
@@ -178,6 +203,8 @@ func handleSigterm(ngx *controller.NGINXController, exit exiter) {
 	<-signalChan
 	klog.Info("Received SIGTERM, shutting down")
 
+	ngx.Drain()
+
 	exitCode := 0
 	if err := ngx.Stop(); err != nil {
 		klog.Infof("Error during shutdown: %v", err)
@@ -449,13 +476,56 @@ func handleFatalInitError(err error) {
 		err)
 }
 
-func registerHealthz(healthPath string, ic *controller.NGINXController, mux *http.ServeMux) {
+func registerHealthz(healthPath string, ic *controller.NGINXController, sc *controller.StorageClusterChecker, mux *http.ServeMux) {
+	checks := []healthz.HealthChecker{
+		healthz.PingHealthz,
+		ic,
+	}
+	if sc != nil {
+		checks = append(checks, sc)
+	}
+
 	// expose health check endpoint (/healthz)
 	healthz.InstallPathHandler(mux,
 		healthPath,
+		checks...,
+	)
+
+	// expose liveness check endpoint (/livez), which keeps passing
+	// while the controller drains
+	healthz.InstallPathHandler(mux,
+		"/livez",
 		healthz.PingHealthz,
-		ic,
+		ic.LivenessChecker(),
 	)
+
+	// fail the health check from now on, so load balancers stop
+	// sending traffic before the controller receives SIGTERM
+	mux.HandleFunc("/wait-shutdown", waitShutdownHandler(ic))
+
+	// expose the details of the ingress and secret checksum checks
+	mux.HandleFunc("/checksum-status", ic.ChecksumStatusHandler)
+}
+
+// waitShutdownHandler drains the controller on a POST request sent from
+// inside the pod, like the preStop hook, and rejects any other request
+func waitShutdownHandler(ic *controller.NGINXController) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil || !net.ParseIP(host).IsLoopback() {
+			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+			return
+		}
+
+		ic.Drain()
+		w.WriteHeader(http.StatusOK)
+	}
 }
 
 func registerMetrics(reg *prometheus.Registry, mux *http.ServeMux) {
@@ -501,3 +571,14 @@ func startHTTPServer(port int, mux *http.ServeMux) {
 	}
 	klog.Fatal(server.ListenAndServe())
 }
+
+// printDefaults writes the configmap keys of the default configuration as JSON
+func printDefaults(w io.Writer) error {
+	b, err := json.MarshalIndent(ngx_config.Schema(), "", "  ")
+	if err != nil {
+		return fmt.Errorf("unexpected error marshalling the default configuration: %v", err)
+	}
+
+	_, err = fmt.Fprintln(w, string(b))
+	return err
+}
@@ -19,6 +19,8 @@ package main
 import (
 	"context"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"syscall"
@@ -109,6 +111,40 @@ func TestHandleSigterm(t *testing.T) {
 	}
 }
 
+func TestWaitShutdownHandler(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		remoteAddr string
+		status     int
+		draining   bool
+	}{
+		{"get", http.MethodGet, "127.0.0.1:4321", http.StatusMethodNotAllowed, false},
+		{"post from outside the pod", http.MethodPost, "10.0.0.1:4321", http.StatusForbidden, false},
+		{"post from the pod", http.MethodPost, "127.0.0.1:4321", http.StatusOK, true},
+		{"post from the pod over ipv6", http.MethodPost, "[::1]:4321", http.StatusOK, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ngx := &controller.NGINXController{}
+
+			req := httptest.NewRequest(tt.method, "/wait-shutdown", nil)
+			req.RemoteAddr = tt.remoteAddr
+			w := httptest.NewRecorder()
+
+			waitShutdownHandler(ngx)(w, req)
+
+			if w.Code != tt.status {
+				t.Errorf("expected status %v but %v returned", tt.status, w.Code)
+			}
+			if ngx.IsDraining() != tt.draining {
+				t.Errorf("expected draining %v but %v returned", tt.draining, ngx.IsDraining())
+			}
+		})
+	}
+}
+
 func createConfigMap(clientSet kubernetes.Interface, ns string, t *testing.T) string {
 	t.Helper()
 
@@ -36,6 +36,11 @@ func NewParser(r resolver.Resolver) parser.IngressAnnotation {
 	return alias{r}
 }
 
+// Keys returns the keys of the annotations read by the parser
+func (a alias) Keys() []string {
+	return []string{"server-alias"}
+}
+
 // Parse parses the annotations contained in the ingress rule
 // used to add an alias to the provided hosts
 func (a alias) Parse(ing *networking.Ingress) (interface{}, error) {
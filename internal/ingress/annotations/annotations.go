@@ -19,6 +19,7 @@ package annotations
 
 import (
 	"github.com/imdario/mergo"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/cacheconverthead"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/canary"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/modsecurity"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/proxyssl"
@@ -35,42 +36,61 @@ import (
 	"k8s.io/ingress-nginx/internal/ingress/annotations/authreqglobal"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/authtls"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/backendprotocol"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/bodytoolarge"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/checksum"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/clientbodybuffersize"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/connection"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/connectioncoalescing"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/cors"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/customdefaultbackend"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/customhttperrors"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/defaultbackend"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/defaultcert"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/defaultrobots"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/earlyhints"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/fastcgi"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/gray"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/http2"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/http2pushpreload"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/http3"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/influxdb"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/ipwhitelist"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/loadbalancing"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/location"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/log"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/maxurilength"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/metricstenant"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/mirror"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/normalizepath"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/opentracing"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/portinredirect"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/proxy"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/proxycachelock"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/proxyrealipcidr"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/ratelimit"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/redirect"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/referrer"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/requestbodymd5"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/rewrite"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/robots"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/satisfy"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/secureupstream"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/securityheaders"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/sendtimeout"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/serversnippet"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/serviceupstream"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/sessionaffinity"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/snippet"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/ssldhparam"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/sslpassthrough"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/sslprotocols"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/upstreamendpointfilter"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/upstreamhashby"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/upstreamkeepalive"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/upstreamvhost"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/xforwardedprefix"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/xforwardedproto"
 	"k8s.io/ingress-nginx/internal/ingress/errors"
 	"k8s.io/ingress-nginx/internal/ingress/resolver"
 )
@@ -84,6 +104,7 @@ type Ingress struct {
 	BackendProtocol      string
 	Aliases              []string
 	BasicDigestAuth      auth.Config
+	CacheConvertHead     cacheconverthead.Config
 	Canary               canary.Config
 	CertificateAuth      authtls.Config
 	ClientBodyBufferSize string
@@ -112,11 +133,14 @@ type Ingress struct {
 	SSLPassthrough     bool
 	UsePortInRedirects bool
 	UpstreamHashBy     upstreamhashby.Config
+	EndpointFilter     string
 	LoadBalancing      string
 	UpstreamVhost      string
 	Whitelist          ipwhitelist.SourceRange
 	XForwardedPrefix   string
+	XForwardedProto    string
 	SSLCiphers         string
+	SSLCiphersTLS13    string
 	Logs               log.Config
 	InfluxDB           influxdb.Config
 	ModSecurity        modsecurity.Config
@@ -124,10 +148,27 @@ type Ingress struct {
 	Location           location.Config
 	DefaultCert        defaultcert.Config
 	IngGray            gray.Config
-	DisableRobots      bool
+	Robots             robots.Config
 	CheckSum           checksum.Config
 	Referrer           referrer.Config
 	SSLProtocols       string
+	MetricsTenant      string
+	DisableCoalescing  bool
+	SecurityHeaders    securityheaders.Config
+	EarlyHints         []string
+	SendTimeout        string
+	RequestBodyMD5     requestbodymd5.Config
+	HTTP2              *bool
+	EnableHTTP3        *bool
+	SSLDHParam         ssldhparam.Config
+	NormalizePath      bool
+	MaxURILength       int
+	ProxyCacheLock     proxycachelock.Config
+	BodyTooLarge       bodytoolarge.Config
+	ProxyRealIPCIDR    []string
+	UpstreamKeepalive  upstreamkeepalive.Config
+	CustomDefBackend   *apiv1.Service
+	DisableDefRobots   bool
 }
 
 // Extractor defines the annotation parsers to be used in the extraction of annotations
@@ -135,12 +176,22 @@ type Extractor struct {
 	annotations map[string]parser.IngressAnnotation
 }
 
+// register the keys of the annotations read by the parsers as the known ones
+func init() {
+	for _, annotationParser := range NewAnnotationExtractor(nil).annotations {
+		if keys, ok := annotationParser.(parser.AnnotationKeys); ok {
+			parser.RegisterKnownAnnotations(keys.Keys()...)
+		}
+	}
+}
+
 // NewAnnotationExtractor creates a new annotations extractor
 func NewAnnotationExtractor(cfg resolver.Resolver) Extractor {
 	return Extractor{
 		map[string]parser.IngressAnnotation{
 			"Aliases":              alias.NewParser(cfg),
 			"BasicDigestAuth":      auth.NewParser(auth.AuthDirectory, cfg),
+			"CacheConvertHead":     cacheconverthead.NewParser(cfg),
 			"Canary":               canary.NewParser(cfg),
 			"CertificateAuth":      authtls.NewParser(cfg),
 			"ClientBodyBufferSize": clientbodybuffersize.NewParser(cfg),
@@ -167,11 +218,14 @@ func NewAnnotationExtractor(cfg resolver.Resolver) Extractor {
 			"SSLPassthrough":       sslpassthrough.NewParser(cfg),
 			"UsePortInRedirects":   portinredirect.NewParser(cfg),
 			"UpstreamHashBy":       upstreamhashby.NewParser(cfg),
+			"EndpointFilter":       upstreamendpointfilter.NewParser(cfg),
 			"LoadBalancing":        loadbalancing.NewParser(cfg),
 			"UpstreamVhost":        upstreamvhost.NewParser(cfg),
 			"Whitelist":            ipwhitelist.NewParser(cfg),
 			"XForwardedPrefix":     xforwardedprefix.NewParser(cfg),
+			"XForwardedProto":      xforwardedproto.NewParser(cfg),
 			"SSLCiphers":           sslcipher.NewParser(cfg),
+			"SSLCiphersTLS13":      sslcipher.NewTLS13Parser(cfg),
 			"Logs":                 log.NewParser(cfg),
 			"InfluxDB":             influxdb.NewParser(cfg),
 			"BackendProtocol":      backendprotocol.NewParser(cfg),
@@ -180,10 +234,27 @@ func NewAnnotationExtractor(cfg resolver.Resolver) Extractor {
 			"Location":             location.NewParser(cfg),
 			"DefaultCert":          defaultcert.NewParser(cfg),
 			"IngGray":              gray.NewParser(cfg),
-			"DisableRobots":        robots.NewParser(cfg),
+			"Robots":               robots.NewParser(cfg),
 			"CheckSum":             checksum.NewParser(cfg),
 			"Referrer":             referrer.NewParser(cfg),
 			"SSLProtocols":         sslprotocols.NewParser(cfg),
+			"MetricsTenant":        metricstenant.NewParser(cfg),
+			"DisableCoalescing":    connectioncoalescing.NewParser(cfg),
+			"SecurityHeaders":      securityheaders.NewParser(cfg),
+			"EarlyHints":           earlyhints.NewParser(cfg),
+			"SendTimeout":          sendtimeout.NewParser(cfg),
+			"RequestBodyMD5":       requestbodymd5.NewParser(cfg),
+			"HTTP2":                http2.NewParser(cfg),
+			"EnableHTTP3":          http3.NewParser(cfg),
+			"SSLDHParam":           ssldhparam.NewParser(cfg),
+			"NormalizePath":        normalizepath.NewParser(cfg),
+			"MaxURILength":         maxurilength.NewParser(cfg),
+			"ProxyCacheLock":       proxycachelock.NewParser(cfg),
+			"BodyTooLarge":         bodytoolarge.NewParser(cfg),
+			"ProxyRealIPCIDR":      proxyrealipcidr.NewParser(cfg),
+			"UpstreamKeepalive":    upstreamkeepalive.NewParser(cfg),
+			"CustomDefBackend":     customdefaultbackend.NewParser(cfg),
+			"DisableDefRobots":     defaultrobots.NewParser(cfg),
 		},
 	}
 }
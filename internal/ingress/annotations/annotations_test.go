@@ -144,7 +144,7 @@ func TestUpstreamHashBy(t *testing.T) {
 		er          string
 	}{
 		{map[string]string{annotationUpstreamHashBy: "$request_uri"}, "$request_uri"},
-		{map[string]string{annotationUpstreamHashBy: "false"}, "false"},
+		{map[string]string{annotationUpstreamHashBy: "false"}, ""},
 		{map[string]string{annotationUpstreamHashBy + "_no": "true"}, ""},
 		{map[string]string{}, ""},
 		{nil, ""},
@@ -323,3 +323,25 @@ func TestMergeLocationAnnotations(t *testing.T) {
 	}
 }
 */
+
+func TestKnownAnnotations(t *testing.T) {
+	for name, annotationParser := range NewAnnotationExtractor(mockCfg{}).annotations {
+		keys, ok := annotationParser.(parser.AnnotationKeys)
+		if !ok {
+			t.Errorf("expected the parser %v to expose the keys of its annotations", name)
+			continue
+		}
+
+		for _, key := range keys.Keys() {
+			if prefix, known := parser.ExpectedPrefix(key); !known || prefix != parser.AnnotationsPrefix {
+				t.Errorf("expected the annotation %v of the parser %v to be known", key, name)
+			}
+		}
+	}
+
+	for _, key := range []string{"cache-convert-head-to-get", "ssl-ciphers-tls13", "security-header-x-frame-options"} {
+		if _, known := parser.ExpectedPrefix(key); !known {
+			t.Errorf("expected the annotation %v to be known", key)
+		}
+	}
+}
@@ -95,6 +95,11 @@ func NewParser(authDirectory string, r resolver.Resolver) parser.IngressAnnotati
 	return auth{r, authDirectory}
 }
 
+// Keys returns the keys of the annotations read by the parser
+func (a auth) Keys() []string {
+	return []string{"auth-type", "auth-secret-type", "auth-secret", "auth-realm"}
+}
+
 // Parse parses the annotations contained in the ingress
 // rule used to add authentication in the paths defined in the rule
 // and generated an htpasswd compatible file to be used as source
@@ -20,6 +20,7 @@ import (
 	"fmt"
 	"regexp"
 	"strings"
+	"sync"
 
 	"k8s.io/klog"
 
@@ -28,22 +29,28 @@ import (
 	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
 	ing_errors "k8s.io/ingress-nginx/internal/ingress/errors"
 	"k8s.io/ingress-nginx/internal/ingress/resolver"
+	"k8s.io/ingress-nginx/internal/k8s"
 	"k8s.io/ingress-nginx/internal/sets"
 )
 
 // Config returns external authentication configuration for an Ingress rule
 type Config struct {
 	URL string `json:"url"`
+	// URLs contains the authentication URLs tried in order, the first one is URL
+	URLs []string `json:"urls,omitempty"`
 	// Host contains the hostname defined in the URL
 	Host              string            `json:"host"`
 	SigninURL         string            `json:"signinUrl"`
 	Method            string            `json:"method"`
 	ResponseHeaders   []string          `json:"responseHeaders,omitempty"`
+	ResponseCookies   []string          `json:"responseCookies,omitempty"`
 	RequestRedirect   string            `json:"requestRedirect"`
 	AuthSnippet       string            `json:"authSnippet"`
 	AuthCacheKey      string            `json:"authCacheKey"`
 	AuthCacheDuration []string          `json:"authCacheDuration"`
 	ProxySetHeaders   map[string]string `json:"proxySetHeaders,omitempty"`
+	// AuthCacheDurationMap contains the cache durations by status code class, e.g. 2xx
+	AuthCacheDurationMap map[string][]string `json:"authCacheDurationMap,omitempty"`
 }
 
 // DefaultCacheDuration is the fallback value if no cache duration is provided
@@ -60,6 +67,15 @@ func (e1 *Config) Equal(e2 *Config) bool {
 	if e1.URL != e2.URL {
 		return false
 	}
+	// the order of the URLs is the order the fallbacks are tried in
+	if len(e1.URLs) != len(e2.URLs) {
+		return false
+	}
+	for i := range e1.URLs {
+		if e1.URLs[i] != e2.URLs[i] {
+			return false
+		}
+	}
 	if e1.Host != e2.Host {
 		return false
 	}
@@ -75,6 +91,10 @@ func (e1 *Config) Equal(e2 *Config) bool {
 		return false
 	}
 
+	if !sets.StringElementsMatch(e1.ResponseCookies, e2.ResponseCookies) {
+		return false
+	}
+
 	if e1.RequestRedirect != e2.RequestRedirect {
 		return false
 	}
@@ -86,14 +106,29 @@ func (e1 *Config) Equal(e2 *Config) bool {
 		return false
 	}
 
+	if len(e1.AuthCacheDurationMap) != len(e2.AuthCacheDurationMap) {
+		return false
+	}
+	for class, d1 := range e1.AuthCacheDurationMap {
+		d2, ok := e2.AuthCacheDurationMap[class]
+		if !ok {
+			return false
+		}
+		if !sets.StringElementsMatch(d1, d2) {
+			return false
+		}
+	}
+
 	return sets.StringElementsMatch(e1.AuthCacheDuration, e2.AuthCacheDuration)
 }
 
 var (
-	methods         = []string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE", "CONNECT", "OPTIONS", "TRACE"}
-	headerRegexp    = regexp.MustCompile(`^[a-zA-Z\d\-_]+$`)
-	statusCodeRegex = regexp.MustCompile(`^[\d]{3}$`)
-	durationRegex   = regexp.MustCompile(`^[\d]+(ms|s|m|h|d|w|M|y)$`) // see http://nginx.org/en/docs/syntax.html
+	methods          = []string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE", "CONNECT", "OPTIONS", "TRACE"}
+	headerRegexp     = regexp.MustCompile(`^[a-zA-Z\d\-_]+$`)
+	cookieRegexp     = regexp.MustCompile(`^[a-zA-Z\d_]+$`)
+	statusCodeRegex  = regexp.MustCompile(`^[\d]{3}$`)
+	statusClassRegex = regexp.MustCompile(`^[1-5]xx$`)
+	durationRegex    = regexp.MustCompile(`^[\d]+(ms|s|m|h|d|w|M|y)$`) // see http://nginx.org/en/docs/syntax.html
 )
 
 // ValidMethod checks is the provided string a valid HTTP method
@@ -110,11 +145,68 @@ func ValidMethod(method string) bool {
 	return false
 }
 
+// unsupportedMethods are the valid HTTP methods the authentication subrequest cannot use
+var unsupportedMethods = []string{"CONNECT", "TRACE"}
+
+// fallbackMethod replaces the unsupported methods of the authentication subrequest
+const fallbackMethod = "GET"
+
+// SubrequestMethod returns the method of the authentication subrequest for a
+// valid HTTP method. The auth_request module cannot issue CONNECT or TRACE
+// subrequests, they are replaced by GET or rejected when reject is true.
+// Callers compare the result with method to report the replacement.
+func SubrequestMethod(method string, reject bool) (string, error) {
+	for _, m := range unsupportedMethods {
+		if method != m {
+			continue
+		}
+
+		if reject {
+			return "", fmt.Errorf("HTTP method %v cannot be used by the authentication subrequest", method)
+		}
+
+		return fallbackMethod, nil
+	}
+
+	return method, nil
+}
+
+// replacedMethodVersions contains the version of the ingresses warned about
+// the replaced method of their authentication subrequest, as the annotations
+// are parsed again on every sync of the ingress
+var replacedMethodVersions sync.Map
+
+// warnReplacedMethod logs the replacement of the method of the authentication
+// subrequest of the ingress once per change of the ingress
+func warnReplacedMethod(ing *networking.Ingress, method string) {
+	key := k8s.MetaNamespaceKey(ing)
+	if version, ok := replacedMethodVersions.Load(key); ok && version == ing.ResourceVersion {
+		return
+	}
+	replacedMethodVersions.Store(key, ing.ResourceVersion)
+
+	klog.Warningf("HTTP method %v cannot be used by the authentication subrequest of Ingress %v, using %v",
+		method, key, fallbackMethod)
+}
+
 // ValidHeader checks is the provided string satisfies the header's name regex
 func ValidHeader(header string) bool {
 	return headerRegexp.Match([]byte(header))
 }
 
+// ValidHeaderValue checks the provided string is a header value that can be
+// set safely: not empty, in a single line and without quotes, which would
+// allow to add headers or directives to the configuration
+func ValidHeaderValue(value string) bool {
+	return value != "" && !strings.ContainsAny(value, "\r\n'")
+}
+
+// ValidCookie checks is the provided string satisfies the cookie's name regex.
+// Cookie names are used to build NGINX variables so they cannot contain dashes.
+func ValidCookie(cookie string) bool {
+	return cookieRegexp.Match([]byte(cookie))
+}
+
 // ValidCacheDuration checks if the provided string is a valid cache duration
 // spec: [code ...] [time ...];
 // with: code is an http status code
@@ -149,6 +241,23 @@ func NewParser(r resolver.Resolver) parser.IngressAnnotation {
 	return authReq{r}
 }
 
+// Keys returns the keys of the annotations read by the parser
+func (a authReq) Keys() []string {
+	return []string{
+		"auth-url",
+		"auth-method",
+		"auth-signin",
+		"auth-snippet",
+		"auth-cache-key",
+		"auth-cache-duration-map",
+		"auth-cache-duration",
+		"auth-response-headers",
+		"auth-response-cookies",
+		"auth-proxy-set-headers",
+		"auth-request-redirect",
+	}
+}
+
 // ParseAnnotations parses the annotations contained in the ingress
 // rule used to use an Config URL as source for authentication
 func (a authReq) Parse(ing *networking.Ingress) (interface{}, error) {
@@ -158,16 +267,41 @@ func (a authReq) Parse(ing *networking.Ingress) (interface{}, error) {
 		return nil, err
 	}
 
-	authURL, err := parser.StringToURL(urlString)
-	if err != nil {
-		return nil, ing_errors.InvalidContent{Name: err.Error()}
+	// auth-url accepts a comma separated list of URLs used as fallbacks
+	authURLs := []string{}
+	for _, u := range strings.Split(urlString, ",") {
+		u = strings.TrimSpace(u)
+		if len(u) == 0 {
+			continue
+		}
+
+		_, err := parser.StringToURL(u)
+		if err != nil {
+			return nil, ing_errors.InvalidContent{Name: err.Error()}
+		}
+		authURLs = append(authURLs, u)
 	}
 
+	if len(authURLs) == 0 {
+		return nil, ing_errors.InvalidContent{Name: "auth-url does not contain any URL"}
+	}
+
+	authURL, _ := parser.StringToURL(authURLs[0])
+
 	authMethod, _ := parser.GetStringAnnotation("auth-method", ing)
 	if len(authMethod) != 0 && !ValidMethod(authMethod) {
 		return nil, ing_errors.NewLocationDenied("invalid HTTP method")
 	}
 
+	method, err := SubrequestMethod(authMethod, a.r.GetDefaultBackend().RejectUnsupportedAuthMethods)
+	if err != nil {
+		return nil, ing_errors.NewLocationDenied(err.Error())
+	}
+	if method != authMethod {
+		warnReplacedMethod(ing, authMethod)
+		authMethod = method
+	}
+
 	// Optional Parameters
 	signIn, err := parser.GetStringAnnotation("auth-signin", ing)
 	if err != nil {
@@ -184,12 +318,23 @@ func (a authReq) Parse(ing *networking.Ingress) (interface{}, error) {
 		klog.V(3).Infof("auth-cache-key annotation is undefined and will not be set")
 	}
 
+	durmapstr, _ := parser.GetStringAnnotation("auth-cache-duration-map", ing)
+	authCacheDurationMap, err := ParseStringToCacheDurationMap(durmapstr)
+	if err != nil {
+		return nil, err
+	}
+
 	durstr, _ := parser.GetStringAnnotation("auth-cache-duration", ing)
 	authCacheDuration, err := ParseStringToCacheDurations(durstr)
 	if err != nil {
 		return nil, err
 	}
 
+	// the default duration would overlap the durations by status code class
+	if len(durstr) == 0 && len(authCacheDurationMap) > 0 {
+		authCacheDuration = []string{}
+	}
+
 	responseHeaders := []string{}
 	hstr, _ := parser.GetStringAnnotation("auth-response-headers", ing)
 	if len(hstr) != 0 {
@@ -205,6 +350,21 @@ func (a authReq) Parse(ing *networking.Ingress) (interface{}, error) {
 		}
 	}
 
+	responseCookies := []string{}
+	cstr, _ := parser.GetStringAnnotation("auth-response-cookies", ing)
+	if len(cstr) != 0 {
+		carr := strings.Split(cstr, ",")
+		for _, cookie := range carr {
+			cookie = strings.TrimSpace(cookie)
+			if len(cookie) > 0 {
+				if !ValidCookie(cookie) {
+					return nil, ing_errors.NewLocationDenied("invalid cookies list")
+				}
+				responseCookies = append(responseCookies, cookie)
+			}
+		}
+	}
+
 	proxySetHeaderMap, err := parser.GetStringAnnotation("auth-proxy-set-headers", ing)
 	if err != nil {
 		klog.V(3).Infof("auth-set-proxy-headers annotation is undefined and will not be set")
@@ -218,10 +378,18 @@ func (a authReq) Parse(ing *networking.Ingress) (interface{}, error) {
 			return nil, ing_errors.NewLocationDenied(fmt.Sprintf("unable to find configMap %q", proxySetHeaderMap))
 		}
 
-		for header := range proxySetHeadersMapContents.Data {
+		if len(proxySetHeadersMapContents.Data) == 0 {
+			return nil, ing_errors.NewLocationDenied(fmt.Sprintf("configMap %q of proxy-set-headers is empty", proxySetHeaderMap))
+		}
+
+		for header, value := range proxySetHeadersMapContents.Data {
 			if !ValidHeader(header) {
 				return nil, ing_errors.NewLocationDenied("invalid proxy-set-headers in configmap")
 			}
+
+			if !ValidHeaderValue(value) {
+				return nil, ing_errors.NewLocationDenied(fmt.Sprintf("invalid value of the proxy-set-headers header %q in configmap", header))
+			}
 		}
 
 		proxySetHeaders = proxySetHeadersMapContents.Data
@@ -230,16 +398,19 @@ func (a authReq) Parse(ing *networking.Ingress) (interface{}, error) {
 	requestRedirect, _ := parser.GetStringAnnotation("auth-request-redirect", ing)
 
 	return &Config{
-		URL:               urlString,
-		Host:              authURL.Hostname(),
-		SigninURL:         signIn,
-		Method:            authMethod,
-		ResponseHeaders:   responseHeaders,
-		RequestRedirect:   requestRedirect,
-		AuthSnippet:       authSnippet,
-		AuthCacheKey:      authCacheKey,
-		AuthCacheDuration: authCacheDuration,
-		ProxySetHeaders:   proxySetHeaders,
+		URL:                  authURLs[0],
+		URLs:                 authURLs,
+		Host:                 authURL.Hostname(),
+		SigninURL:            signIn,
+		Method:               authMethod,
+		ResponseHeaders:      responseHeaders,
+		ResponseCookies:      responseCookies,
+		RequestRedirect:      requestRedirect,
+		AuthSnippet:          authSnippet,
+		AuthCacheKey:         authCacheKey,
+		AuthCacheDuration:    authCacheDuration,
+		AuthCacheDurationMap: authCacheDurationMap,
+		ProxySetHeaders:      proxySetHeaders,
 	}, nil
 }
 
@@ -267,3 +438,39 @@ func ParseStringToCacheDurations(input string) ([]string, error) {
 	}
 	return authCacheDuration, nil
 }
+
+// ParseStringToCacheDurationMap parses and validates the provided string
+// into cache durations by status code class, e.g. `2xx=10m,4xx=30s`.
+// It returns an empty map when no duration is provided
+func ParseStringToCacheDurationMap(input string) (map[string][]string, error) {
+	authCacheDurationMap := map[string][]string{}
+	if len(input) == 0 {
+		return authCacheDurationMap, nil
+	}
+
+	for _, entry := range strings.Split(input, ",") {
+		entry = strings.TrimSpace(entry)
+		if len(entry) == 0 {
+			continue
+		}
+
+		kv := strings.SplitN(entry, "=", 2)
+		if len(kv) != 2 {
+			return map[string][]string{}, ing_errors.NewLocationDenied(fmt.Sprintf("invalid cache duration: %s", entry))
+		}
+
+		class := strings.TrimSpace(kv[0])
+		if !statusClassRegex.MatchString(class) {
+			return map[string][]string{}, ing_errors.NewLocationDenied(fmt.Sprintf("invalid status code class: %s", class))
+		}
+
+		duration := strings.TrimSpace(kv[1])
+		if !ValidCacheDuration(duration) || statusCodeRegex.MatchString(strings.Fields(duration)[0]) {
+			return map[string][]string{}, ing_errors.NewLocationDenied(fmt.Sprintf("invalid cache duration: %s", entry))
+		}
+
+		authCacheDurationMap[class] = append(authCacheDurationMap[class], duration)
+	}
+
+	return authCacheDurationMap, nil
+}
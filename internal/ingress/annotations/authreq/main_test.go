@@ -25,6 +25,8 @@ import (
 	networking "k8s.io/api/networking/v1"
 	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	"k8s.io/ingress-nginx/internal/ingress/defaults"
+	ing_errors "k8s.io/ingress-nginx/internal/ingress/errors"
 	"k8s.io/ingress-nginx/internal/ingress/resolver"
 
 	"k8s.io/apimachinery/pkg/util/intstr"
@@ -137,6 +139,172 @@ func TestAnnotations(t *testing.T) {
 	}
 }
 
+func TestSubrequestMethod(t *testing.T) {
+	for _, method := range methods {
+		for _, reject := range []bool{false, true} {
+			expected := method
+			expErr := false
+			if method == "CONNECT" || method == "TRACE" {
+				if reject {
+					expected, expErr = "", true
+				} else {
+					expected = "GET"
+				}
+			}
+
+			result, err := SubrequestMethod(method, reject)
+			if expErr != (err != nil) {
+				t.Errorf("%v (reject %v): expected error %v but returned %v", method, reject, expErr, err)
+			}
+			if result != expected {
+				t.Errorf("%v (reject %v): expected \"%v\" but \"%v\" was returned", method, reject, expected, result)
+			}
+		}
+	}
+}
+
+type mockBackend struct {
+	resolver.Mock
+	reject bool
+}
+
+func (m mockBackend) GetDefaultBackend() defaults.Backend {
+	return defaults.Backend{RejectUnsupportedAuthMethods: m.reject}
+}
+
+func TestUnsupportedMethodAnnotations(t *testing.T) {
+	ing := buildIngress()
+
+	tests := []struct {
+		title  string
+		method string
+		reject bool
+		expect string
+		expErr bool
+	}{
+		{"supported method", "POST", true, "POST", false},
+		{"CONNECT replaced", "CONNECT", false, "GET", false},
+		{"TRACE replaced", "TRACE", false, "GET", false},
+		{"CONNECT rejected", "CONNECT", true, "", true},
+		{"TRACE rejected", "TRACE", true, "", true},
+	}
+
+	for _, test := range tests {
+		ing.SetAnnotations(map[string]string{
+			parser.GetAnnotationWithPrefix("auth-url"):    "http://foo.com/external-auth",
+			parser.GetAnnotationWithPrefix("auth-method"): test.method,
+		})
+
+		i, err := NewParser(mockBackend{reject: test.reject}).Parse(ing)
+		if test.expErr {
+			if !ing_errors.IsLocationDenied(err) {
+				t.Errorf("%v: expected a location denied error but returned %v", test.title, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%v: unexpected error: %v", test.title, err)
+			continue
+		}
+
+		if u := i.(*Config); u.Method != test.expect {
+			t.Errorf("%v: expected \"%v\" but \"%v\" was returned", test.title, test.expect, u.Method)
+		}
+	}
+}
+
+func TestReplacedMethodWarnedOncePerVersion(t *testing.T) {
+	ing := buildIngress()
+	ing.SetAnnotations(map[string]string{
+		parser.GetAnnotationWithPrefix("auth-url"):    "http://foo.com/external-auth",
+		parser.GetAnnotationWithPrefix("auth-method"): "TRACE",
+	})
+	key := ing.Namespace + "/" + ing.Name
+	defer replacedMethodVersions.Delete(key)
+
+	for _, version := range []string{"1", "1", "2"} {
+		ing.ResourceVersion = version
+		if _, err := NewParser(mockBackend{}).Parse(ing); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if warned, _ := replacedMethodVersions.Load(key); warned != version {
+			t.Errorf("expected the version %v of the ingress to be warned but got %v", version, warned)
+		}
+	}
+
+	ing.Annotations[parser.GetAnnotationWithPrefix("auth-method")] = "GET"
+	ing.ResourceVersion = "3"
+	if _, err := NewParser(mockBackend{}).Parse(ing); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if warned, _ := replacedMethodVersions.Load(key); warned != "2" {
+		t.Errorf("expected no warning for a supported method but got version %v", warned)
+	}
+}
+
+func TestMultipleURLsAnnotation(t *testing.T) {
+	ing := buildIngress()
+
+	data := map[string]string{}
+	ing.SetAnnotations(data)
+
+	tests := []struct {
+		title   string
+		url     string
+		expURLs []string
+		expHost string
+		expErr  bool
+	}{
+		{"single URL", "http://foo.com/external-auth", []string{"http://foo.com/external-auth"}, "foo.com", false},
+		{"two URLs", "http://foo.com/external-auth, http://bar.com/external-auth", []string{"http://foo.com/external-auth", "http://bar.com/external-auth"}, "foo.com", false},
+		{"trailing comma", "http://foo.com/external-auth,", []string{"http://foo.com/external-auth"}, "foo.com", false},
+		{"invalid fallback", "http://foo.com/external-auth,bar", nil, "", true},
+		{"only commas", ",,", nil, "", true},
+	}
+
+	for _, test := range tests {
+		data[parser.GetAnnotationWithPrefix("auth-url")] = test.url
+
+		i, err := NewParser(&resolver.Mock{}).Parse(ing)
+		if test.expErr {
+			if err == nil {
+				t.Errorf("%v: expected error but returned nil", test.title)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%v: unexpected error: %v", test.title, err)
+			continue
+		}
+
+		u := i.(*Config)
+		if !reflect.DeepEqual(u.URLs, test.expURLs) {
+			t.Errorf("%v: expected \"%v\" but \"%v\" was returned", test.title, test.expURLs, u.URLs)
+		}
+		if u.URL != test.expURLs[0] {
+			t.Errorf("%v: expected \"%v\" but \"%v\" was returned", test.title, test.expURLs[0], u.URL)
+		}
+		if u.Host != test.expHost {
+			t.Errorf("%v: expected \"%v\" but \"%v\" was returned", test.title, test.expHost, u.Host)
+		}
+	}
+}
+
+func TestEqualURLsOrder(t *testing.T) {
+	c1 := &Config{URL: "http://foo.com/auth", URLs: []string{"http://foo.com/auth", "http://bar.com/auth", "http://baz.com/auth"}}
+	c2 := &Config{URL: "http://foo.com/auth", URLs: []string{"http://foo.com/auth", "http://baz.com/auth", "http://bar.com/auth"}}
+
+	if c1.Equal(c2) {
+		t.Errorf("expected the configurations with fallbacks in another order to differ")
+	}
+
+	c2.URLs = []string{"http://foo.com/auth", "http://bar.com/auth", "http://baz.com/auth"}
+	if !c1.Equal(c2) {
+		t.Errorf("expected the configurations with the same fallbacks to be equal")
+	}
+}
+
 func TestHeaderAnnotations(t *testing.T) {
 	ing := buildIngress()
 
@@ -184,6 +352,82 @@ func TestHeaderAnnotations(t *testing.T) {
 	}
 }
 
+func TestCookieAnnotations(t *testing.T) {
+	ing := buildIngress()
+
+	data := map[string]string{}
+	ing.SetAnnotations(data)
+
+	tests := []struct {
+		title         string
+		url           string
+		cookies       string
+		parsedCookies []string
+		expErr        bool
+	}{
+		{"single cookie", "http://goog.url", "session", []string{"session"}, false},
+		{"nothing", "http://goog.url", "", []string{}, false},
+		{"two cookies and empty entries", "http://goog.url", ",session,,token_1,", []string{"session", "token_1"}, false},
+		{"cookie with spaces", "http://goog.url", "a b", []string{}, true},
+		{"cookie with dash", "http://goog.url", "a-b", []string{}, true},
+	}
+
+	for _, test := range tests {
+		data[parser.GetAnnotationWithPrefix("auth-url")] = test.url
+		data[parser.GetAnnotationWithPrefix("auth-response-cookies")] = test.cookies
+
+		i, err := NewParser(&resolver.Mock{}).Parse(ing)
+		if test.expErr {
+			if err == nil {
+				t.Errorf("%v: expected error but retuned nil", test.title)
+			}
+			continue
+		}
+
+		u, ok := i.(*Config)
+		if !ok {
+			t.Errorf("%v: expected an External type", test.title)
+			continue
+		}
+
+		if !reflect.DeepEqual(u.ResponseCookies, test.parsedCookies) {
+			t.Errorf("%v: expected \"%v\" but \"%v\" was returned", test.title, test.parsedCookies, u.ResponseCookies)
+		}
+	}
+}
+
+func TestParseStringToCacheDurationMap(t *testing.T) {
+	tests := []struct {
+		title       string
+		input       string
+		expectedMap map[string][]string
+		expErr      bool
+	}{
+		{"empty", "", map[string][]string{}, false},
+		{"two classes", "2xx=10m, 4xx=30s", map[string][]string{"2xx": {"10m"}, "4xx": {"30s"}}, false},
+		{"composed duration", "2xx=1h 30m", map[string][]string{"2xx": {"1h 30m"}}, false},
+		{"invalid class", "6xx=10m", map[string][]string{}, true},
+		{"status code instead of class", "200=10m", map[string][]string{}, true},
+		{"missing duration", "2xx", map[string][]string{}, true},
+		{"invalid duration", "2xx=10", map[string][]string{}, true},
+		{"status code in duration", "2xx=200 10m", map[string][]string{}, true},
+	}
+
+	for _, test := range tests {
+		dur, err := ParseStringToCacheDurationMap(test.input)
+		if test.expErr && err == nil {
+			t.Errorf("%v: expected error but nil was returned", test.title)
+		}
+		if !test.expErr && err != nil {
+			t.Errorf("%v: unexpected error: %v", test.title, err)
+		}
+
+		if !reflect.DeepEqual(dur, test.expectedMap) {
+			t.Errorf("%v: expected \"%v\" but \"%v\" was returned", test.title, test.expectedMap, dur)
+		}
+	}
+}
+
 func TestCacheDurationAnnotations(t *testing.T) {
 	ing := buildIngress()
 
@@ -278,6 +522,13 @@ func TestProxySetHeaders(t *testing.T) {
 		{"no header map", "http://goog.url", nil, true},
 		{"header with spaces", "http://goog.url", map[string]string{"header": "bad value"}, false},
 		{"header with other bad symbols", "http://goog.url", map[string]string{"header": "bad+value"}, false},
+		{"header with variable", "http://goog.url", map[string]string{"X-Original-Host": "$host"}, false},
+		{"empty header map", "http://goog.url", map[string]string{}, true},
+		{"header with empty value", "http://goog.url", map[string]string{"header": ""}, true},
+		{"header with newline", "http://goog.url", map[string]string{"header": "h1\nX-Injected: true"}, true},
+		{"header with carriage return", "http://goog.url", map[string]string{"header": "h1\r"}, true},
+		{"header with quote", "http://goog.url", map[string]string{"header": "h1'; return 200; '"}, true},
+		{"invalid header name", "http://goog.url", map[string]string{"bad header": "h1"}, true},
 	}
 
 	for _, test := range tests {
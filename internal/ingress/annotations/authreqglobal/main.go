@@ -32,6 +32,11 @@ func NewParser(r resolver.Resolver) parser.IngressAnnotation {
 	return authReqGlobal{r}
 }
 
+// Keys returns the keys of the annotations read by the parser
+func (a authReqGlobal) Keys() []string {
+	return []string{"enable-global-auth"}
+}
+
 // ParseAnnotations parses the annotations contained in the ingress
 // rule used to enable or disable global external authentication
 func (a authReqGlobal) Parse(ing *networking.Ingress) (interface{}, error) {
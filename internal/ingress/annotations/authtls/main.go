@@ -84,6 +84,17 @@ type authTLS struct {
 	r resolver.Resolver
 }
 
+// Keys returns the keys of the annotations read by the parser
+func (a authTLS) Keys() []string {
+	return []string{
+		"auth-tls-secret",
+		"auth-tls-verify-client",
+		"auth-tls-verify-depth",
+		"auth-tls-error-page",
+		"auth-tls-pass-certificate-to-upstream",
+	}
+}
+
 // Parse parses the annotations contained in the ingress
 // rule used to use a Certificate as authentication method
 func (a authTLS) Parse(ing *networking.Ingress) (interface{}, error) {
@@ -43,6 +43,11 @@ func NewParser(r resolver.Resolver) parser.IngressAnnotation {
 	return backendProtocol{r}
 }
 
+// Keys returns the keys of the annotations read by the parser
+func (a backendProtocol) Keys() []string {
+	return []string{"backend-protocol"}
+}
+
 // ParseAnnotations parses the annotations contained in the ingress
 // rule used to indicate the backend protocol.
 func (a backendProtocol) Parse(ing *networking.Ingress) (interface{}, error) {
@@ -0,0 +1,126 @@
+/*
+Copyright 2023 The Alibaba Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bodytoolarge
+
+import (
+	"strings"
+
+	networking "k8s.io/api/networking/v1"
+	"k8s.io/klog"
+
+	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	ing_errors "k8s.io/ingress-nginx/internal/ingress/errors"
+	"k8s.io/ingress-nginx/internal/ingress/resolver"
+)
+
+const (
+	// ActionReject responds with the status code 413, the NGINX default
+	ActionReject = "413"
+	// ActionDrain reads the rest of the request body before closing the connection
+	ActionDrain = "413-drain"
+	// ActionRedirect redirects the client to an information page
+	ActionRedirect = "redirect"
+)
+
+// Config contains the handling of the request bodies larger than client_max_body_size
+type Config struct {
+	// Action is one of 413, 413-drain or redirect
+	Action string `json:"action,omitempty"`
+	// URL is the information page of the redirect action
+	URL string `json:"url,omitempty"`
+}
+
+// Equal tests for equality between two Config types
+func (c1 *Config) Equal(c2 *Config) bool {
+	if c1 == c2 {
+		return true
+	}
+	if c1 == nil || c2 == nil {
+		return false
+	}
+	if c1.Action != c2.Action {
+		return false
+	}
+	if c1.URL != c2.URL {
+		return false
+	}
+
+	return true
+}
+
+type bodyTooLarge struct {
+	r resolver.Resolver
+}
+
+// NewParser creates a new oversized request body annotation parser
+func NewParser(r resolver.Resolver) parser.IngressAnnotation {
+	return bodyTooLarge{r}
+}
+
+// Keys returns the keys of the annotations read by the parser
+func (a bodyTooLarge) Keys() []string {
+	return []string{"body-too-large-action", "body-too-large-url"}
+}
+
+// Parse parses the annotations contained in the ingress rule used to
+// handle the requests with a body larger than client_max_body_size
+func (a bodyTooLarge) Parse(ing *networking.Ingress) (interface{}, error) {
+	action, err := parser.GetStringAnnotation("body-too-large-action", ing)
+	if err != nil {
+		return Config{}, err
+	}
+
+	switch action {
+	case ActionReject, ActionDrain:
+		return Config{Action: action}, nil
+	case ActionRedirect:
+	default:
+		klog.Warningf("Ingress %v/%v has an invalid body-too-large-action %q, expected 413, 413-drain or redirect", ing.Namespace, ing.Name, action)
+		return Config{}, ing_errors.NewInvalidAnnotationContent("body-too-large-action", action)
+	}
+
+	u, err := parser.GetStringAnnotation("body-too-large-url", ing)
+	if err != nil {
+		klog.Warningf("Ingress %v/%v sets body-too-large-action to redirect without body-too-large-url", ing.Namespace, ing.Name)
+		return Config{}, ing_errors.NewInvalidAnnotationConfiguration("body-too-large-action", "body-too-large-url is required to redirect")
+	}
+
+	if !validURL(u) {
+		klog.Warningf("Ingress %v/%v has an invalid body-too-large-url %q", ing.Namespace, ing.Name, u)
+		return Config{}, ing_errors.NewInvalidAnnotationContent("body-too-large-url", u)
+	}
+
+	return Config{
+		Action: ActionRedirect,
+		URL:    u,
+	}, nil
+}
+
+// validURL checks if the URL is an absolute http(s) URL that can be
+// used in the configuration without quoting
+func validURL(u string) bool {
+	if strings.ContainsAny(u, " \t\r\n;'\"{}$\\") {
+		return false
+	}
+
+	parsed, err := parser.StringToURL(u)
+	if err != nil {
+		return false
+	}
+
+	return parsed.Scheme == "http" || parsed.Scheme == "https"
+}
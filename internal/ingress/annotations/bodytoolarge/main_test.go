@@ -0,0 +1,77 @@
+/*
+Copyright 2023 The Alibaba Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bodytoolarge
+
+import (
+	"testing"
+
+	api "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	"k8s.io/ingress-nginx/internal/ingress/resolver"
+)
+
+func TestParse(t *testing.T) {
+	action := parser.GetAnnotationWithPrefix("body-too-large-action")
+	infoURL := parser.GetAnnotationWithPrefix("body-too-large-url")
+
+	ap := NewParser(&resolver.Mock{})
+	if ap == nil {
+		t.Fatalf("expected a parser.IngressAnnotation but returned nil")
+	}
+
+	testCases := []struct {
+		annotations map[string]string
+		expected    Config
+		expErr      bool
+	}{
+		{map[string]string{action: "413"}, Config{Action: ActionReject}, false},
+		{map[string]string{action: "413-drain"}, Config{Action: ActionDrain}, false},
+		{map[string]string{action: "redirect", infoURL: "https://example.com/upload-limits"}, Config{Action: ActionRedirect, URL: "https://example.com/upload-limits"}, false},
+		{map[string]string{action: "413-drain", infoURL: "https://example.com/upload-limits"}, Config{Action: ActionDrain}, false},
+		{map[string]string{action: "redirect"}, Config{}, true},
+		{map[string]string{action: "redirect", infoURL: "/upload-limits"}, Config{}, true},
+		{map[string]string{action: "redirect", infoURL: "ftp://example.com/upload-limits"}, Config{}, true},
+		{map[string]string{action: "redirect", infoURL: "https://example.com/a; return 200"}, Config{}, true},
+		{map[string]string{action: "redirect", infoURL: "https://example.com/$uri"}, Config{}, true},
+		{map[string]string{action: "drain"}, Config{}, true},
+		{map[string]string{action: ""}, Config{}, true},
+		{map[string]string{infoURL: "https://example.com/upload-limits"}, Config{}, true},
+		{nil, Config{}, true},
+	}
+
+	ing := &networking.Ingress{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "foo",
+			Namespace: api.NamespaceDefault,
+		},
+		Spec: networking.IngressSpec{},
+	}
+
+	for _, testCase := range testCases {
+		ing.SetAnnotations(testCase.annotations)
+		result, err := ap.Parse(ing)
+		if testCase.expErr != (err != nil) {
+			t.Errorf("expected error %v but returned %v, annotations: %s", testCase.expErr, err, testCase.annotations)
+		}
+		config := result.(Config)
+		if !config.Equal(&testCase.expected) {
+			t.Errorf("expected %+v but returned %+v, annotations: %s", testCase.expected, config, testCase.annotations)
+		}
+	}
+}
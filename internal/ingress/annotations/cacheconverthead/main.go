@@ -0,0 +1,89 @@
+/*
+Copyright 2023 The Alibaba Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cacheconverthead
+
+import (
+	networking "k8s.io/api/networking/v1"
+	"k8s.io/klog"
+
+	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/proxycachelock"
+	ing_errors "k8s.io/ingress-nginx/internal/ingress/errors"
+	"k8s.io/ingress-nginx/internal/ingress/resolver"
+)
+
+// Config contains the handling of the HEAD requests by the proxy cache
+type Config struct {
+	// Enabled sets proxy_cache_convert_head in the location
+	Enabled bool `json:"enabled"`
+	// Convert serves the HEAD requests from the cached GET responses,
+	// otherwise they are passed through to the upstream
+	Convert bool `json:"convert"`
+}
+
+// Equal tests for equality between two Config types
+func (c1 *Config) Equal(c2 *Config) bool {
+	if c1 == c2 {
+		return true
+	}
+	if c1 == nil || c2 == nil {
+		return false
+	}
+	if c1.Enabled != c2.Enabled {
+		return false
+	}
+	if c1.Convert != c2.Convert {
+		return false
+	}
+
+	return true
+}
+
+type cacheConvertHead struct {
+	r resolver.Resolver
+}
+
+// NewParser creates a new cache convert HEAD annotation parser
+func NewParser(r resolver.Resolver) parser.IngressAnnotation {
+	return cacheConvertHead{r}
+}
+
+// Keys returns the keys of the annotations read by the parser
+func (a cacheConvertHead) Keys() []string {
+	return []string{"cache-convert-head-to-get"}
+}
+
+// Parse parses the annotation contained in the ingress rule used to
+// convert the HEAD requests to GET for the proxy cache of the locations
+func (a cacheConvertHead) Parse(ing *networking.Ingress) (interface{}, error) {
+	convert, err := parser.GetBoolAnnotation("cache-convert-head-to-get", ing)
+	if err != nil {
+		return Config{}, err
+	}
+
+	// the proxy cache of a location can only be enabled by its configuration snippet
+	snippet, _ := parser.GetStringAnnotation("configuration-snippet", ing)
+	if !proxycachelock.ProxyCacheEnabled(snippet) {
+		klog.Warningf("Ignoring cache-convert-head-to-get of Ingress %v/%v: proxy cache is not enabled in its configuration-snippet", ing.Namespace, ing.Name)
+		return Config{}, ing_errors.NewInvalidAnnotationConfiguration("cache-convert-head-to-get", "proxy cache is not enabled")
+	}
+
+	return Config{
+		Enabled: true,
+		Convert: convert,
+	}, nil
+}
@@ -18,7 +18,12 @@ limitations under the License.
 package canary
 
 import (
+	"fmt"
+	"regexp"
+	"strings"
+
 	networking "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/klog"
 
 	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
@@ -43,6 +48,9 @@ const (
 	// Format: <cookie value>[||<cookie value>]*
 	// Default max number cookie value is 20
 	CanaryByCookieVal = "canary-by-cookie-value"
+	// Canary routing based on cookie with a value matching a regular expression
+	// Cannot be used together with canary-by-cookie-value
+	CanaryByCookiePattern = "canary-by-cookie-pattern"
 	// Canary routing based on query with value 'always'
 	CanaryByQuery = "canary-by-query"
 	// Canary routing based on query with specific values
@@ -61,6 +69,16 @@ const (
 	// canary weight total
 	// Default range: [100, 10000]
 	CanaryWeightTotal = "canary-weight-total"
+	// Variables of the request whose value selects the backend of the traffic split by weight
+	// Format: <variable>[<variable>]*, e.g. $remote_addr or $cookie_uid$remote_addr
+	// Default is a random choice for every request
+	CanarySplitKey = "canary-split-key"
+	// Routing of the traffic split by weight
+	// Format: random or deterministic
+	// Deterministic routes the requests whose split key (or client address) hashes to a bucket of
+	// canary-mod-divisor matching canary-mod-relational-operator and canary-mod-remainder
+	// Default is random
+	CanaryWeightMode = "canary-weight-mode"
 	// Add header to request based on canary ingress
 	// Format: <header name>:<header value>[||<header name>:<header value>]*
 	// Default max number header is 2
@@ -86,10 +104,36 @@ const (
 	// Default max number header is 2
 	// If the header is not present on the request, the header will be added to the response.
 	CanaryRespAppendHeader = "canary-response-append-header"
+	// Strip the session affinity cookies of the backends not serving the request from the response
+	CanaryDedupeSetCookie = "canary-dedupe-set-cookie"
 	// Referrer of canary ingress
 	CanaryReferrer = "canary-referrer"
 )
 
+const (
+	// actionDelimiter separates the header actions of a canary ingress
+	actionDelimiter = "||"
+	// headerDelimiter separates the name and the value of a header action
+	headerDelimiter = ":"
+	// queryDelimiter separates the query actions of a canary ingress
+	queryDelimiter = "&"
+	// queryValDelimiter separates the name and the value of a query action
+	queryValDelimiter = "="
+)
+
+const (
+	// WeightModeRandom routes the traffic split by weight by a random choice or the split key
+	WeightModeRandom = "random"
+	// WeightModeDeterministic routes the traffic split by weight by the mod of the split key hash
+	WeightModeDeterministic = "deterministic"
+)
+
+// modRelationalOprs are the operators allowed to compare the mod of a canary value with the remainder
+var modRelationalOprs = sets.NewString("<", "<=", "==", ">=", ">")
+
+// splitKeyRegex matches the variables allowed in a canary split key
+var splitKeyRegex = regexp.MustCompile(`\$(remote_addr|request_id|http_[a-z0-9_]+|cookie_[A-Za-z0-9_]+|arg_[A-Za-z0-9_]+)`)
+
 type canary struct {
 	r resolver.Resolver
 }
@@ -99,10 +143,13 @@ type Config struct {
 	Enabled          bool
 	Weight           int
 	WeightTotal      int
+	SplitKey         []string
+	WeightMode       string
 	Header           string
 	HeaderValue      string
 	Cookie           string
 	CookieValue      string
+	CookiePattern    string
 	Query            string
 	QueryValue       string
 	ModDivisor       int
@@ -113,6 +160,7 @@ type Config struct {
 	ReqAddQuery      string
 	RespAddHeader    string
 	RespAppendHeader string
+	DedupeSetCookie  bool
 	Priority         string
 	Referrer         string
 }
@@ -122,10 +170,65 @@ func NewParser(r resolver.Resolver) parser.IngressAnnotation {
 	return canary{r}
 }
 
+// Keys returns the keys of the annotations read by the parser
+func (c canary) Keys() []string {
+	return []string{
+		CanaryFlag,
+		CanaryWeight,
+		CanaryWeightTotal,
+		CanarySplitKey,
+		CanaryWeightMode,
+		CanaryByHeader,
+		CanaryByHeaderVal,
+		CanaryByCookie,
+		CanaryByCookieVal,
+		CanaryByCookiePattern,
+		CanaryByQuery,
+		CanaryByQueryVal,
+		CanaryModDivisor,
+		CanaryModRelationalOpr,
+		CanaryModRemainder,
+		CanaryReqAddHeader,
+		CanaryReqAppendHeader,
+		CanaryReqAddQuery,
+		CanaryRespAddHeader,
+		CanaryRespAppendHeader,
+		CanaryDedupeSetCookie,
+		CanaryReferrer,
+		CanaryPriorityList,
+	}
+}
+
 // Parse parses the annotations contained in the ingress
 // rule used to indicate if the canary should be enabled and with what config
 func (c canary) Parse(ing *networking.Ingress) (interface{}, error) {
+	config, ignored, err := c.parse(ing)
+	for _, e := range ignored {
+		klog.Warningf("Canary ingress[%v/%v]: %v", ing.Namespace, ing.Name, e)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+// Validate returns an error if Parse ignores a canary annotation of the
+// ingress, so that the admission webhook can reject it
+func Validate(r resolver.Resolver, ing *networking.Ingress) error {
+	_, ignored, _ := canary{r}.parse(ing)
+	if len(ignored) > 0 {
+		return ignored[0]
+	}
+
+	return nil
+}
+
+// parse returns the canary configuration of the ingress together with the
+// errors of the annotations it ignores, in part or entirely
+func (c canary) parse(ing *networking.Ingress) (*Config, []error, error) {
 	config := &Config{}
+	var ignored []error
 	var err error
 
 	config.Enabled, err = parser.GetBoolAnnotation(CanaryFlag, ing)
@@ -143,6 +246,24 @@ func (c canary) Parse(ing *networking.Ingress) (interface{}, error) {
 		config.WeightTotal = 100
 	}
 
+	splitKey, err := parser.GetStringAnnotation(CanarySplitKey, ing)
+	if err == nil {
+		config.SplitKey = parseSplitKey(splitKey)
+		if config.SplitKey == nil {
+			klog.Warningf("Ignoring canary-split-key %q in Ingress %v/%v: expected $remote_addr, $request_id, $http_<name>, $cookie_<name> or $arg_<name> variables",
+				splitKey, ing.Namespace, ing.Name)
+		}
+	}
+
+	config.WeightMode, err = parser.GetStringAnnotation(CanaryWeightMode, ing)
+	if err != nil {
+		config.WeightMode = WeightModeRandom
+	} else if config.WeightMode != WeightModeRandom && config.WeightMode != WeightModeDeterministic {
+		klog.Warningf("Ignoring canary-weight-mode %q in Ingress %v/%v: expected %v or %v",
+			config.WeightMode, ing.Namespace, ing.Name, WeightModeRandom, WeightModeDeterministic)
+		config.WeightMode = WeightModeRandom
+	}
+
 	config.Header, err = parser.GetStringAnnotation(CanaryByHeader, ing)
 	if err != nil {
 		config.Header = ""
@@ -163,6 +284,21 @@ func (c canary) Parse(ing *networking.Ingress) (interface{}, error) {
 		config.CookieValue = ""
 	}
 
+	config.CookiePattern, err = parser.GetStringAnnotation(CanaryByCookiePattern, ing)
+	if err != nil {
+		config.CookiePattern = ""
+	} else {
+		if _, err := regexp.Compile(config.CookiePattern); err != nil {
+			ignored = append(ignored, errors.NewInvalidAnnotationConfiguration(CanaryByCookiePattern,
+				fmt.Sprintf("invalid regular expression %q: %v", config.CookiePattern, err)))
+			config.CookiePattern = ""
+		} else if config.CookieValue != "" {
+			ignored = append(ignored, errors.NewInvalidAnnotationConfiguration(CanaryByCookiePattern,
+				"cannot be used together with canary-by-cookie-value"))
+			config.CookiePattern = ""
+		}
+	}
+
 	config.Query, err = parser.GetStringAnnotation(CanaryByQuery, ing)
 	if err != nil {
 		config.Query = ""
@@ -181,6 +317,10 @@ func (c canary) Parse(ing *networking.Ingress) (interface{}, error) {
 	config.ModRelationalOpr, err = parser.GetStringAnnotation(CanaryModRelationalOpr, ing)
 	if err != nil {
 		config.ModRelationalOpr = ""
+	} else if !modRelationalOprs.Has(config.ModRelationalOpr) {
+		klog.Warningf("Ignoring canary-mod-relational-operator %q in Ingress %v/%v: expected one of %v",
+			config.ModRelationalOpr, ing.Namespace, ing.Name, modRelationalOprs.List())
+		config.ModRelationalOpr = ""
 	}
 
 	config.ModRemainder, err = parser.GetIntAnnotation(CanaryModRemainder, ing)
@@ -188,6 +328,12 @@ func (c canary) Parse(ing *networking.Ingress) (interface{}, error) {
 		config.ModRemainder = 0
 	}
 
+	if config.WeightMode == WeightModeDeterministic && config.ModDivisor <= 0 {
+		ignored = append(ignored, errors.NewInvalidAnnotationConfiguration(CanaryWeightMode,
+			"the deterministic weight mode requires a canary-mod-divisor greater than zero, using the random weight mode"))
+		config.WeightMode = WeightModeRandom
+	}
+
 	config.ReqAddHeader, err = parser.GetStringAnnotation(CanaryReqAddHeader, ing)
 	if err != nil {
 		config.ReqAddHeader = ""
@@ -213,6 +359,34 @@ func (c canary) Parse(ing *networking.Ingress) (interface{}, error) {
 		config.RespAppendHeader = ""
 	}
 
+	limits := c.r.GetCanaryLimits()
+	actions := []struct {
+		annotation    string
+		rule          *string
+		delimiter     string
+		itemDelimiter string
+		max           int
+	}{
+		{CanaryReqAddHeader, &config.ReqAddHeader, actionDelimiter, headerDelimiter, limits.ReqAddHeader},
+		{CanaryReqAppendHeader, &config.ReqAppendHeader, actionDelimiter, headerDelimiter, limits.ReqAppendHeader},
+		{CanaryReqAddQuery, &config.ReqAddQuery, queryDelimiter, queryValDelimiter, limits.ReqAddQuery},
+		{CanaryRespAddHeader, &config.RespAddHeader, actionDelimiter, headerDelimiter, limits.RespAddHeader},
+		{CanaryRespAppendHeader, &config.RespAppendHeader, actionDelimiter, headerDelimiter, limits.RespAppendHeader},
+	}
+	for _, action := range actions {
+		num := countActions(*action.rule, action.delimiter, action.itemDelimiter)
+		if num > action.max {
+			ignored = append(ignored, errors.NewInvalidAnnotationConfiguration(action.annotation,
+				fmt.Sprintf("%v actions exceed the limit %v, only the first %v are used", num, action.max, action.max)))
+			*action.rule = limitActions(*action.rule, action.delimiter, action.itemDelimiter, action.max)
+		}
+	}
+
+	config.DedupeSetCookie, err = parser.GetBoolAnnotation(CanaryDedupeSetCookie, ing)
+	if err != nil {
+		config.DedupeSetCookie = false
+	}
+
 	config.Referrer, err = parser.GetStringAnnotation(CanaryReferrer, ing)
 	if err != nil {
 		config.Referrer = ""
@@ -229,11 +403,64 @@ func (c canary) Parse(ing *networking.Ingress) (interface{}, error) {
 			len(config.HeaderValue) > 0 ||
 			len(config.Cookie) > 0 ||
 			len(config.CookieValue) > 0 ||
+			len(config.CookiePattern) > 0 ||
 			len(config.Query) > 0 ||
 			len(config.QueryValue) > 0) {
 		klog.Warningf("Canary ingress[%v/%v] configured but not enabled, ignored", ing.Namespace, ing.Name)
-		return nil, errors.NewInvalidAnnotationConfiguration("canary", "configured but not enabled")
+		return nil, ignored, errors.NewInvalidAnnotationConfiguration("canary", "configured but not enabled")
 	}
 
-	return config, nil
+	return config, ignored, nil
+}
+
+// parseSplitKey returns the names of the variables of a canary split key,
+// or nil when the key contains other variables or characters
+func parseSplitKey(key string) []string {
+	var names []string
+	matched := ""
+	for _, match := range splitKeyRegex.FindAllStringSubmatch(key, -1) {
+		names = append(names, match[1])
+		matched += match[0]
+	}
+
+	if matched != key {
+		return nil
+	}
+
+	return names
+}
+
+// countActions returns the number of valid <name><itemDelimiter><value>
+// actions of a canary action rule
+func countActions(rule, delimiter, itemDelimiter string) int {
+	num := 0
+	for _, action := range strings.Split(rule, delimiter) {
+		if isValidAction(action, itemDelimiter) {
+			num++
+		}
+	}
+
+	return num
+}
+
+// limitActions returns a canary action rule with the first max valid
+// actions of rule
+func limitActions(rule, delimiter, itemDelimiter string, max int) string {
+	actions := []string{}
+	for _, action := range strings.Split(rule, delimiter) {
+		if len(actions) == max {
+			break
+		}
+		if isValidAction(action, itemDelimiter) {
+			actions = append(actions, action)
+		}
+	}
+
+	return strings.Join(actions, delimiter)
+}
+
+// isValidAction returns true if action has the <name><itemDelimiter><value> format
+func isValidAction(action, itemDelimiter string) bool {
+	items := strings.Split(action, itemDelimiter)
+	return len(items) == 2 && len(items[0]) > 0 && len(items[1]) > 0
 }
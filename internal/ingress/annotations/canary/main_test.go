@@ -17,6 +17,7 @@ limitations under the License.
 package canary
 
 import (
+	"reflect"
 	"testing"
 
 	api "k8s.io/api/core/v1"
@@ -24,6 +25,7 @@ import (
 	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	"k8s.io/ingress-nginx/internal/ingress/errors"
 
 	"strconv"
 
@@ -150,3 +152,322 @@ func TestAnnotations(t *testing.T) {
 		}
 	}
 }
+
+func TestDedupeSetCookie(t *testing.T) {
+	ing := buildIngress()
+
+	data := map[string]string{}
+	data[parser.GetAnnotationWithPrefix("canary")] = "true"
+	ing.SetAnnotations(data)
+
+	i, err := NewParser(&resolver.Mock{}).Parse(ing)
+	if err != nil {
+		t.Fatalf("unexpected error parsing canary annotations: %v", err)
+	}
+	if i.(*Config).DedupeSetCookie {
+		t.Errorf("expected canary-dedupe-set-cookie to be disabled by default")
+	}
+
+	data[parser.GetAnnotationWithPrefix("canary-dedupe-set-cookie")] = "true"
+	ing.SetAnnotations(data)
+
+	i, err = NewParser(&resolver.Mock{}).Parse(ing)
+	if err != nil {
+		t.Fatalf("unexpected error parsing canary annotations: %v", err)
+	}
+	if !i.(*Config).DedupeSetCookie {
+		t.Errorf("expected canary-dedupe-set-cookie to be enabled")
+	}
+}
+
+func TestSplitKey(t *testing.T) {
+	testCases := []struct {
+		splitKey string
+		expected []string
+	}{
+		{"$remote_addr", []string{"remote_addr"}},
+		{"$request_id", []string{"request_id"}},
+		{"$http_x_user_id", []string{"http_x_user_id"}},
+		{"$cookie_uid$remote_addr", []string{"cookie_uid", "remote_addr"}},
+		{"$arg_user", []string{"arg_user"}},
+		{"remote_addr", nil},
+		{"$remote_addr,$request_id", nil},
+		{"$host", nil},
+		{"$http_X-User", nil},
+		{"$remote_addr;", nil},
+		{"", nil},
+	}
+
+	for _, tc := range testCases {
+		ing := buildIngress()
+		ing.SetAnnotations(map[string]string{
+			parser.GetAnnotationWithPrefix("canary"):           "true",
+			parser.GetAnnotationWithPrefix("canary-weight"):    "20",
+			parser.GetAnnotationWithPrefix("canary-split-key"): tc.splitKey,
+		})
+
+		i, err := NewParser(&resolver.Mock{}).Parse(ing)
+		if err != nil {
+			t.Fatalf("unexpected error parsing canary annotations: %v", err)
+		}
+		if splitKey := i.(*Config).SplitKey; !reflect.DeepEqual(splitKey, tc.expected) {
+			t.Errorf("expected the split key %v for %q but got %v", tc.expected, tc.splitKey, splitKey)
+		}
+	}
+}
+
+func TestActionLimits(t *testing.T) {
+	limits := resolver.CanaryLimits{
+		ReqAddHeader:     2,
+		ReqAppendHeader:  2,
+		ReqAddQuery:      2,
+		RespAddHeader:    2,
+		RespAppendHeader: 2,
+	}
+
+	headers := map[int]string{
+		1: "x-a:1",
+		2: "x-a:1||x-b:2",
+		3: "x-a:1||x-b:2||x-c:3",
+	}
+	queries := map[int]string{
+		1: "a=1",
+		2: "a=1&b=2",
+		3: "a=1&b=2&c=3",
+	}
+
+	testCases := []struct {
+		annotation string
+		values     map[int]string
+	}{
+		{CanaryReqAddHeader, headers},
+		{CanaryReqAppendHeader, headers},
+		{CanaryReqAddQuery, queries},
+		{CanaryRespAddHeader, headers},
+		{CanaryRespAppendHeader, headers},
+	}
+
+	for _, tc := range testCases {
+		for num, value := range tc.values {
+			ing := buildIngress()
+			ing.SetAnnotations(map[string]string{
+				parser.GetAnnotationWithPrefix("canary"):      "true",
+				parser.GetAnnotationWithPrefix(tc.annotation): value,
+			})
+
+			i, err := NewParser(&resolver.Mock{CanaryLimits: limits}).Parse(ing)
+			if err != nil {
+				t.Errorf("unexpected error parsing %v %q: %v", tc.annotation, value, err)
+				continue
+			}
+			config := i.(*Config)
+			if !config.Enabled {
+				t.Errorf("expected the canary of %v %q to be enabled", tc.annotation, value)
+			}
+
+			rule := map[string]string{
+				CanaryReqAddHeader:     config.ReqAddHeader,
+				CanaryReqAppendHeader:  config.ReqAppendHeader,
+				CanaryReqAddQuery:      config.ReqAddQuery,
+				CanaryRespAddHeader:    config.RespAddHeader,
+				CanaryRespAppendHeader: config.RespAppendHeader,
+			}[tc.annotation]
+			expected := value
+			if num > 2 {
+				expected = tc.values[2]
+			}
+			if rule != expected {
+				t.Errorf("expected the actions %q of %v %q but got %q", expected, tc.annotation, value, rule)
+			}
+
+			err = Validate(&resolver.Mock{CanaryLimits: limits}, ing)
+			if num > 2 {
+				if !errors.IsInvalidConfiguration(err) {
+					t.Errorf("expected an invalid configuration error for %v %q but got %v", tc.annotation, value, err)
+				}
+				continue
+			}
+			if err != nil {
+				t.Errorf("unexpected error validating %v %q: %v", tc.annotation, value, err)
+			}
+		}
+	}
+
+	ing := buildIngress()
+	ing.SetAnnotations(map[string]string{
+		parser.GetAnnotationWithPrefix("canary"):                    "true",
+		parser.GetAnnotationWithPrefix("canary-request-add-header"): "x-a:1||x-b||:2||||x-c:3",
+	})
+	if err := Validate(&resolver.Mock{CanaryLimits: limits}, ing); err != nil {
+		t.Errorf("expected malformed actions not to be counted but got %v", err)
+	}
+
+	ing.SetAnnotations(map[string]string{
+		parser.GetAnnotationWithPrefix("canary"):                    "true",
+		parser.GetAnnotationWithPrefix("canary-request-add-header"): "x-a:1||x-b||x-c:3||x-d:4",
+	})
+	i, err := NewParser(&resolver.Mock{CanaryLimits: limits}).Parse(ing)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if rule := i.(*Config).ReqAddHeader; rule != "x-a:1||x-c:3" {
+		t.Errorf("expected the first valid actions \"x-a:1||x-c:3\" but got %q", rule)
+	}
+}
+
+func TestWeightMode(t *testing.T) {
+	testCases := []struct {
+		title       string
+		annotations map[string]string
+		expected    string
+		expErr      bool
+	}{
+		{"default mode", map[string]string{}, WeightModeRandom, false},
+		{"random mode", map[string]string{CanaryWeightMode: "random"}, WeightModeRandom, false},
+		{"invalid mode", map[string]string{CanaryWeightMode: "sticky"}, WeightModeRandom, false},
+		{"deterministic mode", map[string]string{CanaryWeightMode: "deterministic", CanaryModDivisor: "10", CanaryModRemainder: "3"}, WeightModeDeterministic, false},
+		{"deterministic mode without divisor", map[string]string{CanaryWeightMode: "deterministic"}, WeightModeRandom, true},
+		{"deterministic mode with zero divisor", map[string]string{CanaryWeightMode: "deterministic", CanaryModDivisor: "0"}, WeightModeRandom, true},
+		{"random mode without divisor", map[string]string{CanaryWeightMode: "random", CanaryModDivisor: "0"}, WeightModeRandom, false},
+	}
+
+	for _, tc := range testCases {
+		ing := buildIngress()
+		data := map[string]string{
+			parser.GetAnnotationWithPrefix("canary"):        "true",
+			parser.GetAnnotationWithPrefix("canary-weight"): "20",
+		}
+		for k, v := range tc.annotations {
+			data[parser.GetAnnotationWithPrefix(k)] = v
+		}
+		ing.SetAnnotations(data)
+
+		i, err := NewParser(&resolver.Mock{}).Parse(ing)
+		if err != nil {
+			t.Errorf("%v: unexpected error: %v", tc.title, err)
+			continue
+		}
+		config := i.(*Config)
+		if !config.Enabled {
+			t.Errorf("%v: expected the canary to be enabled", tc.title)
+		}
+		if config.WeightMode != tc.expected {
+			t.Errorf("%v: expected the weight mode %q but got %q", tc.title, tc.expected, config.WeightMode)
+		}
+
+		err = Validate(&resolver.Mock{}, ing)
+		if tc.expErr && !errors.IsInvalidConfiguration(err) {
+			t.Errorf("%v: expected an invalid configuration error but got %v", tc.title, err)
+		}
+		if !tc.expErr && err != nil {
+			t.Errorf("%v: unexpected error validating canary annotations: %v", tc.title, err)
+		}
+	}
+}
+
+func TestModRelationalOpr(t *testing.T) {
+	testCases := []struct {
+		operator string
+		expected string
+	}{
+		{"<", "<"},
+		{"<=", "<="},
+		{"==", "=="},
+		{">=", ">="},
+		{">", ">"},
+		{"=", ""},
+		{"!=", ""},
+		{"=<", ""},
+		{"lt", ""},
+	}
+
+	for _, tc := range testCases {
+		ing := buildIngress()
+		ing.SetAnnotations(map[string]string{
+			parser.GetAnnotationWithPrefix("canary"):                         "true",
+			parser.GetAnnotationWithPrefix("canary-mod-divisor"):             "10",
+			parser.GetAnnotationWithPrefix("canary-mod-relational-operator"): tc.operator,
+			parser.GetAnnotationWithPrefix("canary-mod-remainder"):           "3",
+		})
+
+		i, err := NewParser(&resolver.Mock{}).Parse(ing)
+		if err != nil {
+			t.Fatalf("unexpected error parsing canary annotations: %v", err)
+		}
+		config := i.(*Config)
+		if config.ModRelationalOpr != tc.expected {
+			t.Errorf("expected the mod operator %q for %q but got %q", tc.expected, tc.operator, config.ModRelationalOpr)
+		}
+		if config.ModDivisor != 10 || config.ModRemainder != 3 {
+			t.Errorf("expected the mod divisor 10 and remainder 3 but got %v and %v", config.ModDivisor, config.ModRemainder)
+		}
+	}
+}
+
+func TestCanaryByQuery(t *testing.T) {
+	ing := buildIngress()
+	ing.SetAnnotations(map[string]string{
+		parser.GetAnnotationWithPrefix("canary"):                "true",
+		parser.GetAnnotationWithPrefix("canary-by-query"):       "canary",
+		parser.GetAnnotationWithPrefix("canary-by-query-value"): "on||beta",
+	})
+
+	i, err := NewParser(&resolver.Mock{}).Parse(ing)
+	if err != nil {
+		t.Fatalf("unexpected error parsing canary annotations: %v", err)
+	}
+	config := i.(*Config)
+	if config.Query != "canary" {
+		t.Errorf("expected the query %q but got %q", "canary", config.Query)
+	}
+	if config.QueryValue != "on||beta" {
+		t.Errorf("expected the query value %q but got %q", "on||beta", config.QueryValue)
+	}
+}
+
+func TestCanaryByCookiePattern(t *testing.T) {
+	testCases := []struct {
+		title       string
+		annotations map[string]string
+		expPattern  string
+		expErr      bool
+	}{
+		{"valid pattern", map[string]string{"canary-by-cookie-pattern": "^bucket-0[0-9]$"}, "^bucket-0[0-9]$", false},
+		{"invalid pattern", map[string]string{"canary-by-cookie-pattern": "^bucket-(0"}, "", true},
+		{"pattern and value", map[string]string{"canary-by-cookie-pattern": "^bucket-0[0-9]$", "canary-by-cookie-value": "bucket-01"}, "", true},
+		{"value only", map[string]string{"canary-by-cookie-value": "bucket-01"}, "", false},
+	}
+
+	for _, tc := range testCases {
+		ing := buildIngress()
+		annotations := map[string]string{
+			parser.GetAnnotationWithPrefix("canary"):           "true",
+			parser.GetAnnotationWithPrefix("canary-by-cookie"): "bucket",
+		}
+		for k, v := range tc.annotations {
+			annotations[parser.GetAnnotationWithPrefix(k)] = v
+		}
+		ing.SetAnnotations(annotations)
+
+		i, err := NewParser(&resolver.Mock{}).Parse(ing)
+		if err != nil {
+			t.Errorf("%v: unexpected error parsing canary annotations: %v", tc.title, err)
+			continue
+		}
+		config := i.(*Config)
+		if !config.Enabled || config.Cookie != "bucket" {
+			t.Errorf("%v: expected the canary by cookie bucket to be enabled", tc.title)
+		}
+		if config.CookiePattern != tc.expPattern {
+			t.Errorf("%v: expected the cookie pattern %q but got %q", tc.title, tc.expPattern, config.CookiePattern)
+		}
+
+		err = Validate(&resolver.Mock{}, ing)
+		if tc.expErr && !errors.IsInvalidConfiguration(err) {
+			t.Errorf("%v: expected an invalid configuration error but got %v", tc.title, err)
+		}
+		if !tc.expErr && err != nil {
+			t.Errorf("%v: unexpected error validating canary annotations: %v", tc.title, err)
+		}
+	}
+}
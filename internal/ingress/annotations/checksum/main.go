@@ -60,6 +60,11 @@ func NewParser(r resolver.Resolver) parser.IngressAnnotation {
 	return checksum{r}
 }
 
+// Keys returns the keys of the annotations read by the parser
+func (a checksum) Keys() []string {
+	return []string{IngressVersion}
+}
+
 // ParseAnnotations parses the annotations contained in the ingress
 // rule used to indicate if is required to configure
 func (a checksum) Parse(ing *networking.Ingress) (interface{}, error) {
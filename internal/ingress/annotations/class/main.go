@@ -18,6 +18,8 @@ limitations under the License.
 package class
 
 import (
+	"strings"
+
 	"k8s.io/klog"
 
 	networking "k8s.io/api/networking/v1"
@@ -37,9 +39,34 @@ var (
 	// IngressClass sets the runtime ingress class to use
 	// An empty string means accept all ingresses without
 	// annotation and the ones configured with class nginx
+	// A comma separated list of classes is accepted, the first one
+	// is used where a single canonical name is needed
 	IngressClass = "nginx"
 )
 
+// Classes returns the list of ingress classes configured in IngressClass
+func Classes() []string {
+	classes := []string{}
+	for _, c := range strings.Split(IngressClass, ",") {
+		c = strings.TrimSpace(c)
+		if c != "" {
+			classes = append(classes, c)
+		}
+	}
+
+	return classes
+}
+
+// Canonical returns the first ingress class configured in IngressClass
+func Canonical() string {
+	classes := Classes()
+	if len(classes) == 0 {
+		return ""
+	}
+
+	return classes[0]
+}
+
 // IsValid returns true if the given Ingress either doesn't specify
 // the ingress.class annotation, or it's set to the configured in the
 // ingress controller.
@@ -56,9 +83,15 @@ func IsValid(ing *networking.Ingress) bool {
 	// and 2 invalid combinations
 	// 3 - ingress with default class | fixed annotation on ingress
 	// 4 - ingress with specific class | different annotation on ingress
-	if ingress == "" && IngressClass == DefaultClass {
+	if ingress == "" && Canonical() == DefaultClass {
 		return true
 	}
 
+	for _, c := range Classes() {
+		if ingress == c {
+			return true
+		}
+	}
+
 	return ingress == IngressClass
 }
@@ -1,5 +1,6 @@
 /*
-Copyright 2017 The Kubernetes Authors.
+Copyright 2015 The Kubernetes Authors.
+Copyright 2022-2023 The Alibaba Authors.
 
 Licensed under the Apache License, Version 2.0 (the "License");
 you may not use this file except in compliance with the License.
@@ -17,6 +18,7 @@ limitations under the License.
 package class
 
 import (
+	"reflect"
 	"testing"
 
 	api "k8s.io/api/core/v1"
@@ -45,6 +47,10 @@ func TestIsValidClass(t *testing.T) {
 		{"custom", "custom", "nginx", true},
 		{"", "killer", "nginx", false},
 		{"custom", "nginx", "nginx", false},
+		{"custom", "nginx,custom", "nginx", true},
+		{"", "nginx,custom", "nginx", true},
+		{"", "custom,nginx", "nginx", false},
+		{"other", "nginx,custom", "nginx", false},
 	}
 
 	ing := &networking.Ingress{
@@ -68,3 +74,37 @@ func TestIsValidClass(t *testing.T) {
 		}
 	}
 }
+
+func TestClasses(t *testing.T) {
+	ic := IngressClass
+	// restore original value after the tests
+	defer func() {
+		IngressClass = ic
+	}()
+
+	tests := []struct {
+		controller string
+		classes    []string
+		canonical  string
+	}{
+		{"", []string{}, ""},
+		{"nginx", []string{"nginx"}, "nginx"},
+		{"nginx,custom", []string{"nginx", "custom"}, "nginx"},
+		{" custom , nginx ", []string{"custom", "nginx"}, "custom"},
+		{",custom,", []string{"custom"}, "custom"},
+	}
+
+	for _, test := range tests {
+		IngressClass = test.controller
+
+		classes := Classes()
+		if !reflect.DeepEqual(classes, test.classes) {
+			t.Errorf("test %v - expected classes %v but %v was returned", test, test.classes, classes)
+		}
+
+		canonical := Canonical()
+		if canonical != test.canonical {
+			t.Errorf("test %v - expected canonical %q but %q was returned", test, test.canonical, canonical)
+		}
+	}
+}
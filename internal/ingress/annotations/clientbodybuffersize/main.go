@@ -32,6 +32,11 @@ func NewParser(r resolver.Resolver) parser.IngressAnnotation {
 	return clientBodyBufferSize{r}
 }
 
+// Keys returns the keys of the annotations read by the parser
+func (cbbs clientBodyBufferSize) Keys() []string {
+	return []string{"client-body-buffer-size"}
+}
+
 // Parse parses the annotations contained in the ingress rule
 // used to add an client-body-buffer-size to the provided locations
 func (cbbs clientBodyBufferSize) Parse(ing *networking.Ingress) (interface{}, error) {
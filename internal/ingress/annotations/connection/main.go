@@ -38,6 +38,11 @@ func NewParser(r resolver.Resolver) parser.IngressAnnotation {
 	return connection{r}
 }
 
+// Keys returns the keys of the annotations read by the parser
+func (a connection) Keys() []string {
+	return []string{"connection-proxy-header"}
+}
+
 // Parse parses the annotations contained in the ingress
 // rule used to indicate if the connection header should be overridden.
 func (a connection) Parse(ing *networking.Ingress) (interface{}, error) {
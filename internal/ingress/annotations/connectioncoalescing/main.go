@@ -0,0 +1,45 @@
+/*
+Copyright 2023 The Alibaba Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package connectioncoalescing
+
+import (
+	networking "k8s.io/api/networking/v1"
+
+	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	"k8s.io/ingress-nginx/internal/ingress/resolver"
+)
+
+type connectionCoalescing struct {
+	r resolver.Resolver
+}
+
+// NewParser creates a new connection coalescing annotation parser
+func NewParser(r resolver.Resolver) parser.IngressAnnotation {
+	return connectionCoalescing{r}
+}
+
+// Keys returns the keys of the annotations read by the parser
+func (a connectionCoalescing) Keys() []string {
+	return []string{"disable-connection-coalescing"}
+}
+
+// Parse parses the annotations contained in the ingress rule
+// used to indicate if the server rejects the requests sent on
+// connections established for other hosts
+func (a connectionCoalescing) Parse(ing *networking.Ingress) (interface{}, error) {
+	return parser.GetBoolAnnotation("disable-connection-coalescing", ing)
+}
@@ -103,6 +103,18 @@ func (c1 *Config) Equal(c2 *Config) bool {
 	return true
 }
 
+// Keys returns the keys of the annotations read by the parser
+func (c cors) Keys() []string {
+	return []string{
+		"enable-cors",
+		"cors-allow-origin",
+		"cors-allow-headers",
+		"cors-allow-methods",
+		"cors-allow-credentials",
+		"cors-max-age",
+	}
+}
+
 // Parse parses the annotations contained in the ingress
 // rule used to indicate if the location/s should allows CORS
 func (c cors) Parse(ing *networking.Ingress) (interface{}, error) {
@@ -0,0 +1,70 @@
+/*
+Copyright 2023 The Alibaba Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package customdefaultbackend
+
+import (
+	"github.com/pkg/errors"
+	networking "k8s.io/api/networking/v1"
+	"k8s.io/klog"
+
+	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	ing_errors "k8s.io/ingress-nginx/internal/ingress/errors"
+	"k8s.io/ingress-nginx/internal/ingress/resolver"
+	"k8s.io/ingress-nginx/internal/k8s"
+)
+
+const customDefaultBackendAnnotation = "custom-default-backend-service"
+
+type backend struct {
+	r resolver.Resolver
+}
+
+// NewParser creates a new custom default backend service annotation parser
+func NewParser(r resolver.Resolver) parser.IngressAnnotation {
+	return backend{r}
+}
+
+// Keys returns the keys of the annotations read by the parser
+func (db backend) Keys() []string {
+	return []string{customDefaultBackendAnnotation}
+}
+
+// Parse parses the annotations contained in the ingress to use the
+// service <namespace>/<name> as the default backend of its locations,
+// regardless of the use-custom-default-backend configuration
+func (db backend) Parse(ing *networking.Ingress) (interface{}, error) {
+	s, err := parser.GetStringAnnotation(customDefaultBackendAnnotation, ing)
+	if err != nil {
+		return nil, err
+	}
+
+	ns, name, err := k8s.ParseNameNS(s)
+	if err != nil || ns == "" || name == "" {
+		klog.Warningf("Ignoring %v %q in Ingress %v/%v: expected the format <namespace>/<name>",
+			customDefaultBackendAnnotation, s, ing.Namespace, ing.Name)
+		return nil, ing_errors.NewInvalidAnnotationContent(customDefaultBackendAnnotation, s)
+	}
+
+	svc, err := db.r.GetService(s)
+	if err != nil {
+		klog.Warningf("Ignoring %v %q in Ingress %v/%v, using the default backend: %v",
+			customDefaultBackendAnnotation, s, ing.Namespace, ing.Name, err)
+		return nil, errors.Wrapf(err, "unexpected error reading service %v", s)
+	}
+
+	return svc, nil
+}
@@ -0,0 +1,99 @@
+/*
+Copyright 2023 The Alibaba Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package customdefaultbackend
+
+import (
+	"testing"
+
+	api "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	"k8s.io/ingress-nginx/internal/ingress/errors"
+	"k8s.io/ingress-nginx/internal/ingress/resolver"
+)
+
+type mockService struct {
+	resolver.Mock
+}
+
+// GetService mocks the GetService call from the customdefaultbackend package
+func (m mockService) GetService(name string) (*api.Service, error) {
+	if name != "fallback/demo-service" {
+		return nil, errors.Errorf("there is no service with name %v", name)
+	}
+
+	return &api.Service{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Namespace: "fallback",
+			Name:      "demo-service",
+		},
+	}, nil
+}
+
+func TestParse(t *testing.T) {
+	ing := &networking.Ingress{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "foo",
+			Namespace: api.NamespaceDefault,
+		},
+		Spec: networking.IngressSpec{},
+	}
+
+	annotation := parser.GetAnnotationWithPrefix(customDefaultBackendAnnotation)
+
+	testCases := []struct {
+		value    string
+		expected string
+		expErr   bool
+	}{
+		{"fallback/demo-service", "demo-service", false},
+		{"fallback/other-service", "", true},
+		{"demo-service", "", true},
+		{"/demo-service", "", true},
+		{"fallback/demo-service/80", "", true},
+	}
+
+	for _, tc := range testCases {
+		ing.SetAnnotations(map[string]string{annotation: tc.value})
+
+		i, err := NewParser(mockService{}).Parse(ing)
+		if tc.expErr {
+			if err == nil {
+				t.Errorf("expected error parsing %q", tc.value)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("unexpected error parsing %q: %v", tc.value, err)
+			continue
+		}
+
+		svc, ok := i.(*api.Service)
+		if !ok {
+			t.Fatalf("expected *api.Service but got %T", i)
+		}
+		if svc.Name != tc.expected {
+			t.Errorf("expected the service %v but got %v", tc.expected, svc.Name)
+		}
+	}
+
+	ing.SetAnnotations(map[string]string{})
+	if _, err := NewParser(mockService{}).Parse(ing); !errors.IsMissingAnnotations(err) {
+		t.Errorf("expected a missing annotation error but got %v", err)
+	}
+}
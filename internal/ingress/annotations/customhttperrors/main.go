@@ -35,6 +35,11 @@ func NewParser(r resolver.Resolver) parser.IngressAnnotation {
 	return customhttperrors{r}
 }
 
+// Keys returns the keys of the annotations read by the parser
+func (e customhttperrors) Keys() []string {
+	return []string{"custom-http-errors"}
+}
+
 // Parse parses the annotations contained in the ingress to use
 // custom http errors
 func (e customhttperrors) Parse(ing *networking.Ingress) (interface{}, error) {
@@ -35,6 +35,11 @@ func NewParser(r resolver.Resolver) parser.IngressAnnotation {
 	return backend{r}
 }
 
+// Keys returns the keys of the annotations read by the parser
+func (db backend) Keys() []string {
+	return []string{"default-backend"}
+}
+
 // Parse parses the annotations contained in the ingress to use
 // a custom default backend
 func (db backend) Parse(ing *networking.Ingress) (interface{}, error) {
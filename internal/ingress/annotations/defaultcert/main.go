@@ -18,11 +18,16 @@ limitations under the License.
 package defaultcert
 
 import (
+	"fmt"
+	"strconv"
+	"strings"
+
 	networking "k8s.io/api/networking/v1"
 	"k8s.io/klog"
 
 	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
 	"k8s.io/ingress-nginx/internal/ingress/resolver"
+	"k8s.io/ingress-nginx/internal/nginx"
 )
 
 type defaultcert struct {
@@ -37,6 +42,13 @@ func NewParser(r resolver.Resolver) parser.IngressAnnotation {
 // Config contains the default cert configuration to be used in the Ingress
 type Config struct {
 	NeedDefault bool
+	// Ports restricts the default cert to these server ports, empty means any port
+	Ports []int
+}
+
+// Keys returns the keys of the annotations read by the parser
+func (a defaultcert) Keys() []string {
+	return []string{"default-cert", "default-cert-ports"}
 }
 
 // Parse parses the annotations contained in the ingress to use a default cert
@@ -55,7 +67,37 @@ func (a defaultcert) Parse(ing *networking.Ingress) (interface{}, error) {
 		config.NeedDefault = false
 	}
 
+	ports, err := parser.GetStringAnnotation("default-cert-ports", ing)
+	if err == nil && config.NeedDefault {
+		config.Ports, err = parsePorts(ports)
+		if err != nil {
+			klog.Warningf("Ignoring default-cert in Ingress %v/%v: %v", ing.Namespace, ing.Name, err)
+			config.NeedDefault = false
+		}
+	}
+
 	klog.V(3).Infof("default cert config: [%v]", config)
 
 	return config, nil
 }
+
+// parsePorts parses a comma separated list of ports, warning about the
+// ports used internally by the controller
+func parsePorts(value string) ([]int, error) {
+	ports := []int{}
+	for _, p := range strings.Split(value, ",") {
+		port, err := strconv.Atoi(strings.TrimSpace(p))
+		if err != nil || port < 1 || port > 65535 {
+			return nil, fmt.Errorf("invalid port %q in default-cert-ports", p)
+		}
+
+		switch port {
+		case nginx.StatusPort, nginx.StreamPort, nginx.ProfilerPort:
+			klog.Warningf("default-cert-ports contains the port %v reserved by the controller", port)
+		}
+
+		ports = append(ports, port)
+	}
+
+	return ports, nil
+}
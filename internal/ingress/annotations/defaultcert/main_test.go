@@ -17,6 +17,7 @@ limitations under the License.
 package defaultcert
 
 import (
+	"reflect"
 	"testing"
 
 	api "k8s.io/api/core/v1"
@@ -110,3 +111,39 @@ func TestParseAnnotations(t *testing.T) {
 		t.Errorf("expected \"true\" but %v returned", val.NeedDefault)
 	}
 }
+
+func TestParseDefaultCertPorts(t *testing.T) {
+	ing := buildIngress()
+
+	testCases := []struct {
+		annotations map[string]string
+		expected    Config
+	}{
+		{map[string]string{"default-cert": "true"}, Config{NeedDefault: true}},
+		{map[string]string{"default-cert": "true", "default-cert-ports": "443,8443"}, Config{NeedDefault: true, Ports: []int{443, 8443}}},
+		{map[string]string{"default-cert": "true", "default-cert-ports": " 443 , 10246 "}, Config{NeedDefault: true, Ports: []int{443, 10246}}},
+		{map[string]string{"default-cert": "true", "default-cert-ports": "443,https"}, Config{}},
+		{map[string]string{"default-cert": "true", "default-cert-ports": "70000"}, Config{}},
+		{map[string]string{"default-cert": "false", "default-cert-ports": "443"}, Config{}},
+	}
+
+	for _, testCase := range testCases {
+		data := map[string]string{}
+		for k, v := range testCase.annotations {
+			data[parser.GetAnnotationWithPrefix(k)] = v
+		}
+		ing.SetAnnotations(data)
+
+		i, err := NewParser(&resolver.Mock{}).Parse(ing)
+		if err != nil {
+			t.Errorf("unexpected error parsing ingress with default-cert: %v", err)
+		}
+		val, ok := i.(*Config)
+		if !ok {
+			t.Errorf("expected a defaultcert.Config type")
+		}
+		if !reflect.DeepEqual(*val, testCase.expected) {
+			t.Errorf("expected %v but %v returned, annotations: %v", testCase.expected, *val, testCase.annotations)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+/*
+Copyright 2023 The Alibaba Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package defaultrobots
+
+import (
+	networking "k8s.io/api/networking/v1"
+
+	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	"k8s.io/ingress-nginx/internal/ingress/resolver"
+)
+
+type defaultRobots struct {
+	r resolver.Resolver
+}
+
+// NewParser creates a new default robots.txt location annotation parser
+func NewParser(r resolver.Resolver) parser.IngressAnnotation {
+	return defaultRobots{r}
+}
+
+// Keys returns the keys of the annotations read by the parser
+func (a defaultRobots) Keys() []string {
+	return []string{"disable-default-robots-location"}
+}
+
+// Parse parses the annotations contained in the ingress rule
+// used to indicate if the server omits the built-in /robots.txt
+// location, e.g. when it is defined in the server-snippet
+func (a defaultRobots) Parse(ing *networking.Ingress) (interface{}, error) {
+	return parser.GetBoolAnnotation("disable-default-robots-location", ing)
+}
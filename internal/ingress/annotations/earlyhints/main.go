@@ -0,0 +1,103 @@
+/*
+Copyright 2023 The Alibaba Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package earlyhints
+
+import (
+	"path"
+	"regexp"
+	"strings"
+
+	networking "k8s.io/api/networking/v1"
+
+	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	ing_errors "k8s.io/ingress-nginx/internal/ingress/errors"
+	"k8s.io/ingress-nginx/internal/ingress/resolver"
+)
+
+// resourceRegex matches an absolute path without the characters
+// that are not valid in the target of a Link header
+var resourceRegex = regexp.MustCompile(`^/[A-Za-z0-9\-._~!$&'()*+=:@/%?]*$`)
+
+// destinations maps the extension of a resource to the
+// destination of the request used to preload it
+var destinations = map[string]string{
+	".css":   "style",
+	".js":    "script",
+	".mjs":   "script",
+	".woff":  "font",
+	".woff2": "font",
+	".ttf":   "font",
+	".otf":   "font",
+	".avif":  "image",
+	".gif":   "image",
+	".ico":   "image",
+	".jpeg":  "image",
+	".jpg":   "image",
+	".png":   "image",
+	".svg":   "image",
+	".webp":  "image",
+}
+
+// Destination returns the destination used to preload the resource,
+// or an empty string if the type of the resource is unknown
+func Destination(resource string) string {
+	if i := strings.IndexByte(resource, '?'); i >= 0 {
+		resource = resource[:i]
+	}
+
+	return destinations[strings.ToLower(path.Ext(resource))]
+}
+
+type earlyHints struct {
+	r resolver.Resolver
+}
+
+// NewParser creates a new early hints annotation parser
+func NewParser(r resolver.Resolver) parser.IngressAnnotation {
+	return earlyHints{r}
+}
+
+// Keys returns the keys of the annotations read by the parser
+func (a earlyHints) Keys() []string {
+	return []string{"early-hints"}
+}
+
+// Parse parses the annotations contained in the ingress rule
+// used to indicate the resources hinted to the clients with a
+// 103 Early Hints response
+func (a earlyHints) Parse(ing *networking.Ingress) (interface{}, error) {
+	val, err := parser.GetStringAnnotation("early-hints", ing)
+	if err != nil {
+		return []string{}, err
+	}
+
+	resources := []string{}
+	for _, resource := range strings.Split(val, ",") {
+		resource = strings.TrimSpace(resource)
+		if resource == "" {
+			continue
+		}
+
+		if !resourceRegex.MatchString(resource) || Destination(resource) == "" {
+			return []string{}, ing_errors.NewInvalidAnnotationContent("early-hints", resource)
+		}
+
+		resources = append(resources, resource)
+	}
+
+	return resources, nil
+}
@@ -0,0 +1,88 @@
+/*
+Copyright 2023 The Alibaba Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package earlyhints
+
+import (
+	"reflect"
+	"testing"
+
+	api "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	"k8s.io/ingress-nginx/internal/ingress/resolver"
+)
+
+func TestParse(t *testing.T) {
+	annotation := parser.GetAnnotationWithPrefix("early-hints")
+
+	ap := NewParser(&resolver.Mock{})
+	if ap == nil {
+		t.Fatalf("expected a parser.IngressAnnotation but returned nil")
+	}
+
+	testCases := []struct {
+		annotations map[string]string
+		expected    []string
+		expErr      bool
+	}{
+		{map[string]string{annotation: "/css/app.css, /js/app.js?v=2,/fonts/a.woff2"}, []string{"/css/app.css", "/js/app.js?v=2", "/fonts/a.woff2"}, false},
+		{map[string]string{annotation: "/img/logo.PNG,"}, []string{"/img/logo.PNG"}, false},
+		{map[string]string{annotation: "css/app.css"}, []string{}, true},
+		{map[string]string{annotation: "/app.css>; rel=preload"}, []string{}, true},
+		{map[string]string{annotation: "/index.html"}, []string{}, true},
+		{map[string]string{}, []string{}, true},
+		{nil, []string{}, true},
+	}
+
+	ing := &networking.Ingress{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "foo",
+			Namespace: api.NamespaceDefault,
+		},
+		Spec: networking.IngressSpec{},
+	}
+
+	for _, testCase := range testCases {
+		ing.SetAnnotations(testCase.annotations)
+		result, err := ap.Parse(ing)
+		if testCase.expErr != (err != nil) {
+			t.Errorf("expected error %v but returned %v, annotations: %s", testCase.expErr, err, testCase.annotations)
+		}
+		if !reflect.DeepEqual(result, testCase.expected) {
+			t.Errorf("expected %v but returned %v, annotations: %s", testCase.expected, result, testCase.annotations)
+		}
+	}
+}
+
+func TestDestination(t *testing.T) {
+	testCases := map[string]string{
+		"/app.css":        "style",
+		"/app.mjs?v=1":    "script",
+		"/font.WOFF2":     "font",
+		"/logo.svg":       "image",
+		"/index.html":     "",
+		"/noextension":    "",
+		"/dir.css/script": "",
+	}
+
+	for resource, expected := range testCases {
+		if actual := Destination(resource); actual != expected {
+			t.Errorf("%v: expected %q but returned %q", resource, expected, actual)
+		}
+	}
+}
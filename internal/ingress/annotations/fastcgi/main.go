@@ -61,6 +61,11 @@ func NewParser(r resolver.Resolver) parser.IngressAnnotation {
 	return fastcgi{r}
 }
 
+// Keys returns the keys of the annotations read by the parser
+func (a fastcgi) Keys() []string {
+	return []string{"fastcgi-index", "fastcgi-params-configmap"}
+}
+
 // ParseAnnotations parses the annotations contained in the ingress
 // rule used to indicate the fastcgiConfig.
 func (a fastcgi) Parse(ing *networking.Ingress) (interface{}, error) {
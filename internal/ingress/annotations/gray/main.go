@@ -18,6 +18,7 @@ package gray
 
 import (
 	networking "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/labels"
 
 	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
 	"k8s.io/ingress-nginx/internal/ingress/resolver"
@@ -36,6 +37,16 @@ const (
 	// from 0 up through N-1, that is unique over the Set.
 	// If pod ordinal is less than index, the pod will process the new ingress.
 	IngressGrayIndex = "ingress-rollout-index-id"
+	// Ingress rollback
+	// If rollback is true, the pod will process the new ingress if its ordinal
+	// is greater than or equal to index, so the new ingress is drained from the
+	// pods with lower ordinal first.
+	IngressGrayRollback = "ingress-rollout-rollback"
+	// Ingress pod label
+	// A label selector, e.g. "version=canary". If set, the pod will process
+	// the new ingress if its labels match the selector instead of using the
+	// ordinal, so pods without ordinal (e.g. of a Deployment) can be used.
+	IngressGrayPodLabel = "ingress-rollout-pod-label"
 )
 
 const (
@@ -51,6 +62,10 @@ type Config struct {
 	IngGrayCurVer string `json:"ingGrayCurVer"`
 	IngGrayNewVer string `json:"ingGrayNewVer"`
 	IngGrayIndex  int    `json:"ingGrayIndex"`
+	// IngGrayRollback inverts the pod ordinal comparison with IngGrayIndex
+	IngGrayRollback bool `json:"ingGrayRollback"`
+	// IngGrayPodLabel selects the pods processing the new ingress by label
+	IngGrayPodLabel string `json:"ingGrayPodLabel"`
 }
 
 type gray struct {
@@ -77,6 +92,12 @@ func (gray1 *Config) Equal(gray2 *Config) bool {
 	if gray1.IngGrayIndex != gray2.IngGrayIndex {
 		return false
 	}
+	if gray1.IngGrayRollback != gray2.IngGrayRollback {
+		return false
+	}
+	if gray1.IngGrayPodLabel != gray2.IngGrayPodLabel {
+		return false
+	}
 
 	return true
 }
@@ -86,6 +107,18 @@ func NewParser(r resolver.Resolver) parser.IngressAnnotation {
 	return gray{r}
 }
 
+// Keys returns the keys of the annotations read by the parser
+func (a gray) Keys() []string {
+	return []string{
+		IngressGrayFlag,
+		IngressGrayCurVer,
+		IngressGrayNewVer,
+		IngressGrayIndex,
+		IngressGrayRollback,
+		IngressGrayPodLabel,
+	}
+}
+
 // ParseAnnotations parses the annotations contained in the ingress
 // rule used to indicate if is required to configure
 func (a gray) Parse(ing *networking.Ingress) (interface{}, error) {
@@ -116,5 +149,20 @@ func (a gray) Parse(ing *networking.Ingress) (interface{}, error) {
 		config.IngGrayIndex = PodIndexEmpty
 	}
 
+	config.IngGrayRollback, err = parser.GetBoolAnnotation(IngressGrayRollback, ing)
+	if err != nil {
+		klog.Infof("Get annotation %s, err: %s", IngressGrayRollback, err)
+		config.IngGrayRollback = false
+	}
+
+	config.IngGrayPodLabel, err = parser.GetStringAnnotation(IngressGrayPodLabel, ing)
+	if err != nil {
+		klog.Infof("Get annotation %s, err: %s", IngressGrayPodLabel, err)
+		config.IngGrayPodLabel = ""
+	} else if _, err = labels.Parse(config.IngGrayPodLabel); err != nil {
+		klog.Warningf("Annotation %s is not a valid label selector, using the pod ordinal instead, err: %s", IngressGrayPodLabel, err)
+		config.IngGrayPodLabel = ""
+	}
+
 	return config, nil
 }
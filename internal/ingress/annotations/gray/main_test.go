@@ -76,17 +76,19 @@ func TestAnnotations(t *testing.T) {
 		ingGrayCurVer string
 		ingGrayNewVer string
 		ingGrayIndex  int
+		ingRollback   bool
 	}{
-		{"active gray ingress and index 0", true, "1.0", "", 0},
-		{"active gray ingress and index 1", true, "1.0", "2.0", 1},
-		{"active gray ingress and index 5", true, "1.0", "2.0", 5},
-		{"active gray ingress and index 10", true, "", "3.0", 10},
-		{"inactive gray ingress and index 0", false, "1.0", "", 0},
-		{"inactive gray ingress and index 1", false, "1.0", "2.0", 1},
-		{"inactive gray ingress and index 5", false, "1.0", "2.0", 5},
-		{"inactive gray ingress and index 10", false, "", "3.0", 10},
-		{"active gray ingress and index -1", true, "1.0", "2.0", -1},
-		{"inactive gray ingress and index -1", false, "1.0", "2.0", -1},
+		{"active gray ingress and index 0", true, "1.0", "", 0, false},
+		{"active gray ingress and index 1", true, "1.0", "2.0", 1, false},
+		{"active gray ingress and index 5", true, "1.0", "2.0", 5, false},
+		{"active gray ingress and index 10", true, "", "3.0", 10, false},
+		{"inactive gray ingress and index 0", false, "1.0", "", 0, false},
+		{"inactive gray ingress and index 1", false, "1.0", "2.0", 1, false},
+		{"inactive gray ingress and index 5", false, "1.0", "2.0", 5, false},
+		{"inactive gray ingress and index 10", false, "", "3.0", 10, false},
+		{"active gray ingress and index -1", true, "1.0", "2.0", -1, false},
+		{"inactive gray ingress and index -1", false, "1.0", "2.0", -1, false},
+		{"active gray ingress rollback and index 5", true, "1.0", "2.0", 5, true},
 	}
 
 	for _, test := range tests {
@@ -94,6 +96,7 @@ func TestAnnotations(t *testing.T) {
 		data[parser.GetAnnotationWithPrefix("ingress-rollout-current-revision")] = test.ingGrayCurVer
 		data[parser.GetAnnotationWithPrefix("ingress-rollout-update-revision")] = test.ingGrayNewVer
 		data[parser.GetAnnotationWithPrefix("ingress-rollout-index-id")] = strconv.Itoa(test.ingGrayIndex)
+		data[parser.GetAnnotationWithPrefix("ingress-rollout-rollback")] = strconv.FormatBool(test.ingRollback)
 
 		i, err := NewParser(&resolver.Mock{}).Parse(ing)
 		if err != nil {
@@ -116,5 +119,8 @@ func TestAnnotations(t *testing.T) {
 		if u.IngGrayIndex != test.ingGrayIndex {
 			t.Errorf("%v: IngGrayIndex expected \"%v\" but \"%v\" was returned", test.title, test.ingGrayIndex, u.IngGrayIndex)
 		}
+		if u.IngGrayRollback != test.ingRollback {
+			t.Errorf("%v: IngGrayRollback expected \"%v\" but \"%v\" was returned", test.title, test.ingRollback, u.IngGrayRollback)
+		}
 	}
 }
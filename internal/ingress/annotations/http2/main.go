@@ -0,0 +1,50 @@
+/*
+Copyright 2023 The Alibaba Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package http2
+
+import (
+	networking "k8s.io/api/networking/v1"
+
+	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	"k8s.io/ingress-nginx/internal/ingress/resolver"
+)
+
+type http2 struct {
+	r resolver.Resolver
+}
+
+// NewParser creates a new HTTP/2 annotation parser
+func NewParser(r resolver.Resolver) parser.IngressAnnotation {
+	return http2{r}
+}
+
+// Keys returns the keys of the annotations read by the parser
+func (a http2) Keys() []string {
+	return []string{"use-http2"}
+}
+
+// Parse parses the annotations contained in the ingress rule
+// used to enable or disable HTTP/2 in the server. It returns
+// nil when the annotation is not set.
+func (a http2) Parse(ing *networking.Ingress) (interface{}, error) {
+	useHTTP2, err := parser.GetBoolAnnotation("use-http2", ing)
+	if err != nil {
+		return (*bool)(nil), err
+	}
+
+	return &useHTTP2, nil
+}
@@ -0,0 +1,67 @@
+/*
+Copyright 2023 The Alibaba Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package http2
+
+import (
+	"testing"
+
+	api "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	"k8s.io/ingress-nginx/internal/ingress/resolver"
+)
+
+func TestParse(t *testing.T) {
+	annotation := parser.GetAnnotationWithPrefix("use-http2")
+
+	ap := NewParser(&resolver.Mock{})
+	if ap == nil {
+		t.Fatalf("expected a parser.IngressAnnotation but returned nil")
+	}
+
+	enabled, disabled := true, false
+
+	testCases := []struct {
+		annotations map[string]string
+		expected    *bool
+	}{
+		{map[string]string{annotation: "true"}, &enabled},
+		{map[string]string{annotation: "false"}, &disabled},
+		{map[string]string{annotation: "foo"}, nil},
+		{map[string]string{}, nil},
+		{nil, nil},
+	}
+
+	ing := &networking.Ingress{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "foo",
+			Namespace: api.NamespaceDefault,
+		},
+		Spec: networking.IngressSpec{},
+	}
+
+	for _, testCase := range testCases {
+		ing.SetAnnotations(testCase.annotations)
+		result, _ := ap.Parse(ing)
+		useHTTP2 := result.(*bool)
+		if (useHTTP2 == nil) != (testCase.expected == nil) ||
+			(useHTTP2 != nil && *useHTTP2 != *testCase.expected) {
+			t.Errorf("expected %v but returned %v, annotations: %s", testCase.expected, useHTTP2, testCase.annotations)
+		}
+	}
+}
@@ -32,6 +32,11 @@ func NewParser(r resolver.Resolver) parser.IngressAnnotation {
 	return http2PushPreload{r}
 }
 
+// Keys returns the keys of the annotations read by the parser
+func (h2pp http2PushPreload) Keys() []string {
+	return []string{"http2-push-preload"}
+}
+
 // Parse parses the annotations contained in the ingress rule
 // used to add http2 push preload to the server
 func (h2pp http2PushPreload) Parse(ing *networking.Ingress) (interface{}, error) {
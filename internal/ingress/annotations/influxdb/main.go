@@ -41,6 +41,17 @@ func NewParser(r resolver.Resolver) parser.IngressAnnotation {
 	return influxdb{r}
 }
 
+// Keys returns the keys of the annotations read by the parser
+func (c influxdb) Keys() []string {
+	return []string{
+		"enable-influxdb",
+		"influxdb-measurement",
+		"influxdb-port",
+		"influxdb-host",
+		"influxdb-server-name",
+	}
+}
+
 // Parse parses the annotations to look for InfluxDB configurations
 func (c influxdb) Parse(ing *networking.Ingress) (interface{}, error) {
 	var err error
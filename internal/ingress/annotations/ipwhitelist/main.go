@@ -17,6 +17,7 @@ limitations under the License.
 package ipwhitelist
 
 import (
+	gonet "net"
 	"sort"
 	"strings"
 
@@ -24,6 +25,7 @@ import (
 
 	networking "k8s.io/api/networking/v1"
 	"k8s.io/ingress-nginx/internal/net"
+	"k8s.io/klog/v2"
 
 	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
 	ing_errors "k8s.io/ingress-nginx/internal/ingress/errors"
@@ -57,33 +59,64 @@ func NewParser(r resolver.Resolver) parser.IngressAnnotation {
 	return ipwhitelist{r}
 }
 
+// Keys returns the keys of the annotations read by the parser
+func (a ipwhitelist) Keys() []string {
+	return []string{"whitelist-source-range", "whitelist-source-range-configmap"}
+}
+
 // ParseAnnotations parses the annotations contained in the ingress
 // rule used to limit access to certain client addresses or networks.
 // Multiple ranges can specified using commas as separator
 // e.g. `18.0.0.0/8,56.0.0.0/8`
+// The ranges can also be read from the configmap referenced by the
+// annotation whitelist-source-range-configmap, they are merged with
+// the ranges of the annotation whitelist-source-range.
 func (a ipwhitelist) Parse(ing *networking.Ingress) (interface{}, error) {
 	defBackend := a.r.GetDefaultBackend()
 	sort.Strings(defBackend.WhitelistSourceRange)
 
+	cidrSet := map[string]struct{}{}
+
 	val, err := parser.GetStringAnnotation("whitelist-source-range", ing)
-	// A missing annotation is not a problem, just use the default
-	if err == ing_errors.ErrMissingAnnotations {
+	hasRanges := err != ing_errors.ErrMissingAnnotations
+	if hasRanges {
+		values := strings.Split(val, ",")
+		ipnets, ips, err := net.ParseIPNets(values...)
+		if err != nil && len(ips) == 0 {
+			return &SourceRange{CIDR: defBackend.WhitelistSourceRange}, ing_errors.LocationDenied{
+				Reason: errors.Wrap(err, "the annotation does not contain a valid IP address or network"),
+			}
+		}
+
+		for k := range ipnets {
+			cidrSet[k] = struct{}{}
+		}
+		for k := range ips {
+			cidrSet[k] = struct{}{}
+		}
+	}
+
+	cmName, _ := parser.GetStringAnnotation("whitelist-source-range-configmap", ing)
+	if cmName == "" && !hasRanges {
+		// A missing annotation is not a problem, just use the default
 		return &SourceRange{CIDR: defBackend.WhitelistSourceRange}, nil
 	}
 
-	values := strings.Split(val, ",")
-	ipnets, ips, err := net.ParseIPNets(values...)
-	if err != nil && len(ips) == 0 {
-		return &SourceRange{CIDR: defBackend.WhitelistSourceRange}, ing_errors.LocationDenied{
-			Reason: errors.Wrap(err, "the annotation does not contain a valid IP address or network"),
+	if cmName != "" {
+		for _, cidr := range a.configMapRanges(cmName) {
+			cidrSet[cidr] = struct{}{}
+		}
+
+		// do not allow every address when the ranges of the configmap are not available
+		if len(cidrSet) == 0 {
+			return &SourceRange{CIDR: defBackend.WhitelistSourceRange}, ing_errors.LocationDenied{
+				Reason: errors.Errorf("the configmap %q does not contain a valid IP network", cmName),
+			}
 		}
 	}
 
 	cidrs := []string{}
-	for k := range ipnets {
-		cidrs = append(cidrs, k)
-	}
-	for k := range ips {
+	for k := range cidrSet {
 		cidrs = append(cidrs, k)
 	}
 
@@ -91,3 +124,38 @@ func (a ipwhitelist) Parse(ing *networking.Ingress) (interface{}, error) {
 
 	return &SourceRange{cidrs}, nil
 }
+
+// configMapRanges returns the valid CIDRs of the configmap with the given name.
+// Every value of the configmap may contain several CIDRs separated by commas.
+// A missing configmap or an invalid CIDR is ignored with a warning.
+func (a ipwhitelist) configMapRanges(name string) []string {
+	cm, err := a.r.GetConfigMap(name)
+	if err != nil {
+		klog.Warningf("Ignoring the whitelist-source-range-configmap %q: %v", name, err)
+		return nil
+	}
+
+	cidrs := []string{}
+	for key, value := range cm.Data {
+		for _, cidr := range strings.Split(value, ",") {
+			cidr = strings.TrimSpace(cidr)
+			if cidr == "" {
+				continue
+			}
+
+			_, ipnet, err := gonet.ParseCIDR(cidr)
+			if err != nil {
+				klog.Warningf("Ignoring the invalid CIDR %q of the key %v in the whitelist-source-range-configmap %q: %v", cidr, key, name, err)
+				continue
+			}
+
+			cidrs = append(cidrs, ipnet.String())
+		}
+	}
+
+	if len(cidrs) == 0 {
+		klog.Warningf("The whitelist-source-range-configmap %q does not contain any valid CIDR", name)
+	}
+
+	return cidrs
+}
@@ -191,6 +191,88 @@ func TestParseAnnotationsWithDefaultConfig(t *testing.T) {
 	}
 }
 
+func TestParseAnnotationsWithConfigMap(t *testing.T) {
+	ing := buildIngress()
+
+	r := &resolver.Mock{
+		ConfigMaps: map[string]*api.ConfigMap{
+			"default/office": {
+				Data: map[string]string{
+					"office":  "10.0.0.0/24, 10.0.1.0/24",
+					"vpn":     "192.168.0.1/32",
+					"invalid": "ww,172.16.0.1",
+				},
+			},
+			"default/invalid": {
+				Data: map[string]string{
+					"office": "ww",
+				},
+			},
+		},
+	}
+
+	tests := map[string]struct {
+		net        string
+		configMap  string
+		expectCidr []string
+		expectErr  bool
+		errOut     string
+	}{
+		"test parse a configmap": {
+			configMap:  "default/office",
+			expectCidr: []string{"10.0.0.0/24", "10.0.1.0/24", "192.168.0.1/32"},
+		},
+		"test merge a configmap and the annotation": {
+			net:        "2.2.2.2/32,10.0.0.0/24",
+			configMap:  "default/office",
+			expectCidr: []string{"10.0.0.0/24", "10.0.1.0/24", "192.168.0.1/32", "2.2.2.2/32"},
+		},
+		"test missing configmap with the annotation": {
+			net:        "2.2.2.2/32",
+			configMap:  "default/missing",
+			expectCidr: []string{"2.2.2.2/32"},
+		},
+		"test missing configmap": {
+			configMap: "default/missing",
+			expectErr: true,
+			errOut:    `the configmap "default/missing" does not contain a valid IP network`,
+		},
+		"test configmap without valid cidr": {
+			configMap: "default/invalid",
+			expectErr: true,
+			errOut:    `the configmap "default/invalid" does not contain a valid IP network`,
+		},
+	}
+
+	for testName, test := range tests {
+		data := map[string]string{}
+		if test.net != "" {
+			data[parser.GetAnnotationWithPrefix("whitelist-source-range")] = test.net
+		}
+		data[parser.GetAnnotationWithPrefix("whitelist-source-range-configmap")] = test.configMap
+		ing.SetAnnotations(data)
+		p := NewParser(r)
+		i, err := p.Parse(ing)
+		if err != nil && !test.expectErr {
+			t.Errorf("%v:unexpected error: %v", testName, err)
+		}
+		if test.expectErr {
+			if err == nil || err.Error() != test.errOut {
+				t.Errorf("%v:expected error: %v but %v return", testName, test.errOut, err)
+			}
+		}
+		if !test.expectErr {
+			sr, ok := i.(*SourceRange)
+			if !ok {
+				t.Errorf("%v:expected a SourceRange type", testName)
+			}
+			if !strsEquals(sr.CIDR, test.expectCidr) {
+				t.Errorf("%v:expected %v CIDR but %v returned", testName, test.expectCidr, sr.CIDR)
+			}
+		}
+	}
+}
+
 func strsEquals(a, b []string) bool {
 	if len(a) != len(b) {
 		return false
@@ -32,6 +32,11 @@ func NewParser(r resolver.Resolver) parser.IngressAnnotation {
 	return loadbalancing{r}
 }
 
+// Keys returns the keys of the annotations read by the parser
+func (a loadbalancing) Keys() []string {
+	return []string{"load-balance"}
+}
+
 // Parse parses the annotations contained in the ingress rule
 // used to indicate if the location/s contains a fragment of
 // configuration to be included inside the paths of the rules
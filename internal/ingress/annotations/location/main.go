@@ -18,6 +18,7 @@ limitations under the License.
 package location
 
 import (
+	"fmt"
 	"regexp"
 	"strings"
 
@@ -25,11 +26,13 @@ import (
 	"k8s.io/klog"
 
 	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	ing_errors "k8s.io/ingress-nginx/internal/ingress/errors"
 	"k8s.io/ingress-nginx/internal/ingress/resolver"
 )
 
 var (
 	validPreceding = regexp.MustCompile(`^(=|~|~\*|\^~)$`)
+	regexPreceding = regexp.MustCompile(`^(~|~\*)$`)
 )
 
 type location struct {
@@ -48,6 +51,11 @@ func NewParser(r resolver.Resolver) parser.IngressAnnotation {
 	return location{r}
 }
 
+// Keys returns the keys of the annotations read by the parser
+func (a location) Keys() []string {
+	return []string{"location-preceding", "location-path-prefix", "location-path-escape"}
+}
+
 // ParseAnnotations parses the annotations contained in the ingress
 // rule used to indicate the location preceding.
 func (a location) Parse(ing *networking.Ingress) (interface{}, error) {
@@ -92,6 +100,43 @@ func (a location) Parse(ing *networking.Ingress) (interface{}, error) {
 		config.LocationPathEscape = false
 	}
 
+	if regexPreceding.MatchString(config.LocationPreceding) {
+		err = validateRegexPaths(ing, config)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	klog.V(3).Infof("location config: [%v]", config)
 	return config, nil
 }
+
+// validateRegexPaths checks the locations built from the paths of the ingress
+// rules are valid regular expressions, in the same way they are rendered in
+// the configuration
+func validateRegexPaths(ing *networking.Ingress, config *Config) error {
+	for _, rule := range ing.Spec.Rules {
+		if rule.HTTP == nil {
+			continue
+		}
+
+		for _, path := range rule.HTTP.Paths {
+			if path.Path == "" || path.Path == "/" {
+				continue
+			}
+
+			regex := path.Path
+			if config.LocationPathEscape && regex[0] == '/' {
+				regex = regex[1:]
+			}
+			regex = config.LocationPathPrefix + regex
+
+			_, err := regexp.Compile(regex)
+			if err != nil {
+				return ing_errors.NewLocationDenied(fmt.Sprintf("invalid regex location %v: %v", regex, err))
+			}
+		}
+	}
+
+	return nil
+}
@@ -130,3 +130,47 @@ func TestParseAnnotations(t *testing.T) {
 		t.Errorf("expected true but %v returned", val.LocationPathEscape)
 	}
 }
+
+func TestParseRegexPaths(t *testing.T) {
+	tests := []struct {
+		title     string
+		preceding string
+		path      string
+		expErr    bool
+	}{
+		{"valid regex", "~", "/foo/(bar|baz)", false},
+		{"valid case insensitive regex", "~*", "/foo/.*", false},
+		{"invalid regex", "~", "/foo/(bar", true},
+		{"invalid case insensitive regex", "~*", "/foo/[bar", true},
+		{"invalid regex without regex preceding", "^~", "/foo/(bar", false},
+		{"root path", "~", "/", false},
+	}
+
+	for _, test := range tests {
+		ing := buildIngress()
+		ing.Spec.Rules = []networking.IngressRule{
+			{
+				Host: "foo.bar.com",
+				IngressRuleValue: networking.IngressRuleValue{
+					HTTP: &networking.HTTPIngressRuleValue{
+						Paths: []networking.HTTPIngressPath{
+							{Path: test.path},
+						},
+					},
+				},
+			},
+		}
+
+		data := map[string]string{}
+		data[parser.GetAnnotationWithPrefix("location-preceding")] = test.preceding
+		ing.SetAnnotations(data)
+
+		_, err := NewParser(&resolver.Mock{}).Parse(ing)
+		if test.expErr && err == nil {
+			t.Errorf("%v: expected error but nil was returned", test.title)
+		}
+		if !test.expErr && err != nil {
+			t.Errorf("%v: unexpected error: %v", test.title, err)
+		}
+	}
+}
@@ -51,6 +51,11 @@ func NewParser(r resolver.Resolver) parser.IngressAnnotation {
 	return log{r}
 }
 
+// Keys returns the keys of the annotations read by the parser
+func (l log) Keys() []string {
+	return []string{"enable-access-log", "disable-access-log", "enable-rewrite-log"}
+}
+
 // Parse parses the annotations contained in the ingress
 // rule used to indicate if the location/s should enable logs
 func (l log) Parse(ing *networking.Ingress) (interface{}, error) {
@@ -62,6 +67,13 @@ func (l log) Parse(ing *networking.Ingress) (interface{}, error) {
 		config.Access = true
 	}
 
+	// disable-access-log takes precedence over enable-access-log. The access
+	// log cannot be enabled by the annotations if it is disabled globally.
+	disabled, err := parser.GetBoolAnnotation("disable-access-log", ing)
+	if err == nil && disabled {
+		config.Access = false
+	}
+
 	config.Rewrite, err = parser.GetBoolAnnotation("enable-rewrite-log", ing)
 	if err != nil {
 		config.Rewrite = false
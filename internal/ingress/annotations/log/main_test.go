@@ -80,6 +80,42 @@ func TestIngressAccessLogConfig(t *testing.T) {
 	}
 }
 
+func TestIngressDisableAccessLogConfig(t *testing.T) {
+	testCases := []struct {
+		title       string
+		annotations map[string]string
+		expected    bool
+	}{
+		{"no annotations", map[string]string{}, true},
+		{"disabled", map[string]string{"disable-access-log": "true"}, false},
+		{"not disabled", map[string]string{"disable-access-log": "false"}, true},
+		{"invalid value", map[string]string{"disable-access-log": "yes please"}, true},
+		{"disabled over enabled", map[string]string{"disable-access-log": "true", "enable-access-log": "true"}, false},
+		{"enabled disabled", map[string]string{"disable-access-log": "false", "enable-access-log": "false"}, false},
+	}
+
+	for _, tc := range testCases {
+		ing := buildIngress()
+
+		data := map[string]string{}
+		for k, v := range tc.annotations {
+			data[parser.GetAnnotationWithPrefix(k)] = v
+		}
+		ing.SetAnnotations(data)
+
+		log, _ := NewParser(&resolver.Mock{}).Parse(ing)
+		nginxLogs, ok := log.(*Config)
+		if !ok {
+			t.Errorf("%v: expected a Config type", tc.title)
+			continue
+		}
+
+		if nginxLogs.Access != tc.expected {
+			t.Errorf("%v: expected access log enabled %v but got %v", tc.title, tc.expected, nginxLogs.Access)
+		}
+	}
+}
+
 func TestIngressRewriteLogConfig(t *testing.T) {
 	ing := buildIngress()
 
@@ -0,0 +1,64 @@
+/*
+Copyright 2023 The Alibaba Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package maxurilength
+
+import (
+	"strconv"
+
+	networking "k8s.io/api/networking/v1"
+	"k8s.io/klog"
+
+	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	ing_errors "k8s.io/ingress-nginx/internal/ingress/errors"
+	"k8s.io/ingress-nginx/internal/ingress/resolver"
+)
+
+// maxLength is the longest URI length that can be checked, the maximum
+// repetition count of the regular expressions of NGINX (PCRE)
+const maxLength = 65535
+
+type maxURILength struct {
+	r resolver.Resolver
+}
+
+// NewParser creates a new maximum URI length annotation parser
+func NewParser(r resolver.Resolver) parser.IngressAnnotation {
+	return maxURILength{r}
+}
+
+// Keys returns the keys of the annotations read by the parser
+func (a maxURILength) Keys() []string {
+	return []string{"max-uri-length"}
+}
+
+// Parse parses the annotations contained in the ingress rule
+// used to limit the length of the URIs of the requests to the server.
+// It returns 0 when the annotation is not set.
+func (a maxURILength) Parse(ing *networking.Ingress) (interface{}, error) {
+	s, err := parser.GetStringAnnotation("max-uri-length", ing)
+	if err != nil {
+		return 0, err
+	}
+
+	length, err := strconv.Atoi(s)
+	if err != nil || length < 1 || length > maxLength {
+		klog.Warningf("Ignoring max-uri-length %q in Ingress %v/%v: expected a number between 1 and %v", s, ing.Namespace, ing.Name, maxLength)
+		return 0, ing_errors.NewInvalidAnnotationContent("max-uri-length", s)
+	}
+
+	return length, nil
+}
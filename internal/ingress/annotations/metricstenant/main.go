@@ -0,0 +1,58 @@
+/*
+Copyright 2023 The Alibaba Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metricstenant
+
+import (
+	"regexp"
+
+	networking "k8s.io/api/networking/v1"
+
+	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	ing_errors "k8s.io/ingress-nginx/internal/ingress/errors"
+	"k8s.io/ingress-nginx/internal/ingress/resolver"
+)
+
+var validTenant = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]{0,62}$`)
+
+type metricsTenant struct {
+	r resolver.Resolver
+}
+
+// NewParser creates a new metrics tenant annotation parser
+func NewParser(r resolver.Resolver) parser.IngressAnnotation {
+	return metricsTenant{r}
+}
+
+// Keys returns the keys of the annotations read by the parser
+func (a metricsTenant) Keys() []string {
+	return []string{"metrics-tenant"}
+}
+
+// Parse parses the annotations contained in the ingress rule
+// used to indicate the tenant the metrics of the server are attributed to
+func (a metricsTenant) Parse(ing *networking.Ingress) (interface{}, error) {
+	tenant, err := parser.GetStringAnnotation("metrics-tenant", ing)
+	if err != nil {
+		return "", err
+	}
+
+	if !validTenant.MatchString(tenant) {
+		return "", ing_errors.NewInvalidAnnotationContent("metrics-tenant", tenant)
+	}
+
+	return tenant, nil
+}
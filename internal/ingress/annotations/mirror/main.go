@@ -66,6 +66,11 @@ func NewParser(r resolver.Resolver) parser.IngressAnnotation {
 	return mirror{r}
 }
 
+// Keys returns the keys of the annotations read by the parser
+func (a mirror) Keys() []string {
+	return []string{"mirror-request-body", "mirror-target"}
+}
+
 // ParseAnnotations parses the annotations contained in the ingress
 // rule used to configure mirror
 func (a mirror) Parse(ing *networking.Ingress) (interface{}, error) {
@@ -63,6 +63,16 @@ type modSecurity struct {
 	r resolver.Resolver
 }
 
+// Keys returns the keys of the annotations read by the parser
+func (a modSecurity) Keys() []string {
+	return []string{
+		"enable-modsecurity",
+		"enable-owasp-core-rules",
+		"modsecurity-transaction-id",
+		"modsecurity-snippet",
+	}
+}
+
 // Parse parses the annotations contained in the ingress
 // rule used to enable ModSecurity in a particular location
 func (a modSecurity) Parse(ing *networking.Ingress) (interface{}, error) {
@@ -51,6 +51,11 @@ func NewParser(r resolver.Resolver) parser.IngressAnnotation {
 	return opentracing{r}
 }
 
+// Keys returns the keys of the annotations read by the parser
+func (s opentracing) Keys() []string {
+	return []string{"enable-opentracing"}
+}
+
 func (s opentracing) Parse(ing *networking.Ingress) (interface{}, error) {
 	enabled, err := parser.GetBoolAnnotation("enable-opentracing", ing)
 	if err != nil {
@@ -19,6 +19,7 @@ package parser
 import (
 	"fmt"
 	"net/url"
+	"regexp"
 	"strconv"
 	"strings"
 
@@ -119,6 +120,21 @@ func GetIntAnnotation(name string, ing *networking.Ingress) (int, error) {
 	return ingAnnotations(ing.GetAnnotations()).parseInt(v)
 }
 
+// refer to http://nginx.org/en/docs/syntax.html
+// Nginx differentiates between size and offset
+// offset directives support gigabytes in addition
+var nginxSizeRegex = regexp.MustCompile("^[0-9]+[kKmM]{0,1}$")
+var nginxOffsetRegex = regexp.MustCompile("^[0-9]+[kKmMgG]{0,1}$")
+
+// IsValidByteSize checks the value is a size, or an offset if isOffset is true, valid in nginx
+func IsValidByteSize(value string, isOffset bool) bool {
+	if isOffset {
+		return nginxOffsetRegex.MatchString(value)
+	}
+
+	return nginxSizeRegex.MatchString(value)
+}
+
 // GetAnnotationWithPrefix returns the prefix of ingress annotations
 func GetAnnotationWithPrefix(suffix string) string {
 	return fmt.Sprintf("%v/%v", AnnotationsPrefix, suffix)
@@ -136,6 +152,7 @@ func normalizeString(input string) string {
 var configmapAnnotations = sets.NewString(
 	"auth-proxy-set-header",
 	"fastcgi-params-configmap",
+	"whitelist-source-range-configmap",
 )
 
 // AnnotationsReferencesConfigmap checks if at least one annotation in the Ingress rule
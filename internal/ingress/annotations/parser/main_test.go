@@ -200,3 +200,25 @@ func TestStringToURL(t *testing.T) {
 		}
 	}
 }
+
+func TestExpectedPrefix(t *testing.T) {
+	RegisterKnownAnnotations("canary", "rewrite-target")
+
+	tests := []struct {
+		key    string
+		prefix string
+		known  bool
+	}{
+		{"canary", AnnotationsPrefix, true},
+		{"rewrite-target", AnnotationsPrefix, true},
+		{"ingress.class", "kubernetes.io", true},
+		{"owner", "", false},
+	}
+
+	for _, test := range tests {
+		prefix, known := ExpectedPrefix(test.key)
+		if prefix != test.prefix || known != test.known {
+			t.Errorf("%v: expected (%v, %v) but got (%v, %v)", test.key, test.prefix, test.known, prefix, known)
+		}
+	}
+}
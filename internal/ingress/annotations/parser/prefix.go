@@ -0,0 +1,59 @@
+/*
+Copyright 2023 The Alibaba Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package parser
+
+import (
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// knownAnnotations are the keys of the annotations read with AnnotationsPrefix,
+// registered from the keys of the annotation parsers
+var knownAnnotations = sets.NewString()
+
+// annotationPrefixes maps the keys of the annotations read with another
+// prefix than AnnotationsPrefix to their prefix
+var annotationPrefixes = map[string]string{
+	// the ingress class annotation of Kubernetes
+	"ingress.class": "kubernetes.io",
+}
+
+// AnnotationKeys is implemented by the annotation parsers to expose the
+// keys of the annotations they read
+type AnnotationKeys interface {
+	Keys() []string
+}
+
+// RegisterKnownAnnotations adds the keys of annotations read with
+// AnnotationsPrefix to the known ones. It is not safe for concurrent use,
+// the keys are registered by the init function of the annotations package.
+func RegisterKnownAnnotations(keys ...string) {
+	knownAnnotations.Insert(keys...)
+}
+
+// ExpectedPrefix returns the prefix the annotation key is read with, and
+// false when the key is not an annotation known by the controller
+func ExpectedPrefix(key string) (string, bool) {
+	if prefix, ok := annotationPrefixes[key]; ok {
+		return prefix, true
+	}
+
+	if knownAnnotations.Has(key) {
+		return AnnotationsPrefix, true
+	}
+
+	return "", false
+}
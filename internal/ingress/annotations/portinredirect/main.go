@@ -32,6 +32,11 @@ func NewParser(r resolver.Resolver) parser.IngressAnnotation {
 	return portInRedirect{r}
 }
 
+// Keys returns the keys of the annotations read by the parser
+func (a portInRedirect) Keys() []string {
+	return []string{"use-port-in-redirects"}
+}
+
 // Parse parses the annotations contained in the ingress
 // rule used to indicate if the redirects must
 func (a portInRedirect) Parse(ing *networking.Ingress) (interface{}, error) {
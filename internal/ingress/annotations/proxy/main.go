@@ -17,12 +17,48 @@ limitations under the License.
 package proxy
 
 import (
+	"fmt"
+	"strings"
+
 	networking "k8s.io/api/networking/v1"
 
 	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	ing_errors "k8s.io/ingress-nginx/internal/ingress/errors"
 	"k8s.io/ingress-nginx/internal/ingress/resolver"
 )
 
+// ignoreHeaders contains the response header fields accepted by the
+// proxy_ignore_headers directive
+// http://nginx.org/en/docs/http/ngx_http_proxy_module.html#proxy_ignore_headers
+var ignoreHeaders = []string{
+	"X-Accel-Redirect",
+	"X-Accel-Expires",
+	"X-Accel-Limit-Rate",
+	"X-Accel-Buffering",
+	"X-Accel-Charset",
+	"Expires",
+	"Cache-Control",
+	"Set-Cookie",
+	"Vary",
+}
+
+// nextUpstreamTokens contains the cases accepted by the proxy_next_upstream directive
+// http://nginx.org/en/docs/http/ngx_http_proxy_module.html#proxy_next_upstream
+var nextUpstreamTokens = []string{
+	"error",
+	"timeout",
+	"invalid_header",
+	"http_500",
+	"http_502",
+	"http_503",
+	"http_504",
+	"http_403",
+	"http_404",
+	"http_429",
+	"non_idempotent",
+	"off",
+}
+
 // Config returns the proxy timeout to use in the upstream server/s
 type Config struct {
 	BodySize             string `json:"bodySize"`
@@ -42,6 +78,11 @@ type Config struct {
 	ProxyBuffering       string `json:"proxyBuffering"`
 	ProxyHTTPVersion     string `json:"proxyHTTPVersion"`
 	ProxyMaxTempFileSize string `json:"proxyMaxTempFileSize"`
+	ProxyIgnoreHeaders   string `json:"proxyIgnoreHeaders"`
+	// ForceContentLength buffers the whole upstream response and disables
+	// chunked transfer encoding towards the client
+	ForceContentLength        bool   `json:"forceContentLength"`
+	ForceContentLengthMaxSize string `json:"forceContentLengthMaxSize"`
 }
 
 // Equal tests for equality between two Configuration types
@@ -105,6 +146,18 @@ func (l1 *Config) Equal(l2 *Config) bool {
 		return false
 	}
 
+	if l1.ProxyIgnoreHeaders != l2.ProxyIgnoreHeaders {
+		return false
+	}
+
+	if l1.ForceContentLength != l2.ForceContentLength {
+		return false
+	}
+
+	if l1.ForceContentLengthMaxSize != l2.ForceContentLengthMaxSize {
+		return false
+	}
+
 	return true
 }
 
@@ -117,6 +170,32 @@ func NewParser(r resolver.Resolver) parser.IngressAnnotation {
 	return proxy{r}
 }
 
+// Keys returns the keys of the annotations read by the parser
+func (a proxy) Keys() []string {
+	return []string{
+		"proxy-connect-timeout",
+		"proxy-send-timeout",
+		"proxy-read-timeout",
+		"proxy-buffers-number",
+		"proxy-buffer-size",
+		"proxy-cookie-path",
+		"proxy-cookie-domain",
+		"proxy-body-size",
+		"proxy-next-upstream",
+		"proxy-next-upstream-timeout",
+		"proxy-next-upstream-tries",
+		"proxy-request-buffering",
+		"proxy-redirect-from",
+		"proxy-redirect-to",
+		"proxy-buffering",
+		"proxy-http-version",
+		"proxy-max-temp-file-size",
+		"proxy-ignore-headers",
+		"proxy-force-content-length",
+		"proxy-force-content-length-max-size",
+	}
+}
+
 // ParseAnnotations parses the annotations contained in the ingress
 // rule used to configure upstream check parameters
 func (a proxy) Parse(ing *networking.Ingress) (interface{}, error) {
@@ -163,11 +242,18 @@ func (a proxy) Parse(ing *networking.Ingress) (interface{}, error) {
 	config.BodySize, err = parser.GetStringAnnotation("proxy-body-size", ing)
 	if err != nil {
 		config.BodySize = defBackend.ProxyBodySize
+	} else if !parser.IsValidByteSize(config.BodySize, true) {
+		return nil, ing_errors.NewLocationDenied(fmt.Sprintf("invalid size %v for proxy-body-size", config.BodySize))
 	}
 
-	config.NextUpstream, err = parser.GetStringAnnotation("proxy-next-upstream", ing)
+	nextUpstream, err := parser.GetStringAnnotation("proxy-next-upstream", ing)
 	if err != nil {
 		config.NextUpstream = defBackend.ProxyNextUpstream
+	} else {
+		config.NextUpstream, err = parseNextUpstream(nextUpstream)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	config.NextUpstreamTimeout, err = parser.GetIntAnnotation("proxy-next-upstream-timeout", ing)
@@ -195,9 +281,11 @@ func (a proxy) Parse(ing *networking.Ingress) (interface{}, error) {
 		config.ProxyRedirectTo = defBackend.ProxyRedirectTo
 	}
 
-	config.ProxyBuffering, err = parser.GetStringAnnotation("proxy-buffering", ing)
+	proxyBuffering, err := parser.GetStringAnnotation("proxy-buffering", ing)
 	if err != nil {
 		config.ProxyBuffering = defBackend.ProxyBuffering
+	} else {
+		config.ProxyBuffering = proxyBuffering
 	}
 
 	config.ProxyHTTPVersion, err = parser.GetStringAnnotation("proxy-http-version", ing)
@@ -210,5 +298,80 @@ func (a proxy) Parse(ing *networking.Ingress) (interface{}, error) {
 		config.ProxyMaxTempFileSize = defBackend.ProxyMaxTempFileSize
 	}
 
+	ignoreHeaders, err := parser.GetStringAnnotation("proxy-ignore-headers", ing)
+	if err == nil {
+		config.ProxyIgnoreHeaders, err = parseIgnoreHeaders(ignoreHeaders)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	config.ForceContentLength, _ = parser.GetBoolAnnotation("proxy-force-content-length", ing)
+	if config.ForceContentLength {
+		// the response can only be delimited by its length if nginx is
+		// allowed to buffer it, an explicit opt-out is a conflict
+		if proxyBuffering == "off" {
+			return nil, ing_errors.NewLocationDenied("proxy-force-content-length requires proxy-buffering to be on")
+		}
+		config.ProxyBuffering = "on"
+
+		config.ForceContentLengthMaxSize, err = parser.GetStringAnnotation("proxy-force-content-length-max-size", ing)
+		if err != nil {
+			config.ForceContentLengthMaxSize = config.ProxyMaxTempFileSize
+		}
+	}
+
 	return config, nil
 }
+
+// parseIgnoreHeaders validates a comma separated list of response header
+// fields and returns them in the format expected by proxy_ignore_headers
+func parseIgnoreHeaders(value string) (string, error) {
+	headers := []string{}
+	for _, h := range strings.Split(value, ",") {
+		h = strings.TrimSpace(h)
+		if h == "" {
+			continue
+		}
+
+		valid := false
+		for _, ih := range ignoreHeaders {
+			if strings.EqualFold(h, ih) {
+				headers = append(headers, ih)
+				valid = true
+				break
+			}
+		}
+
+		if !valid {
+			return "", ing_errors.NewLocationDenied(fmt.Sprintf("invalid header %v for proxy-ignore-headers", h))
+		}
+	}
+
+	return strings.Join(headers, " "), nil
+}
+
+// parseNextUpstream validates a space separated list of proxy_next_upstream
+// cases, unknown cases are rejected instead of being ignored by NGINX
+func parseNextUpstream(value string) (string, error) {
+	tokens := strings.Fields(value)
+	if len(tokens) == 0 {
+		return "", ing_errors.NewLocationDenied("empty proxy-next-upstream")
+	}
+
+	for _, t := range tokens {
+		valid := false
+		for _, nt := range nextUpstreamTokens {
+			if t == nt {
+				valid = true
+				break
+			}
+		}
+
+		if !valid {
+			return "", ing_errors.NewLocationDenied(fmt.Sprintf("invalid case %v for proxy-next-upstream", t))
+		}
+	}
+
+	return strings.Join(tokens, " "), nil
+}
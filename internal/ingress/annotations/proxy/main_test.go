@@ -103,6 +103,7 @@ func TestProxy(t *testing.T) {
 	data[parser.GetAnnotationWithPrefix("proxy-buffering")] = "on"
 	data[parser.GetAnnotationWithPrefix("proxy-http-version")] = "1.0"
 	data[parser.GetAnnotationWithPrefix("proxy-max-temp-file-size")] = "128k"
+	data[parser.GetAnnotationWithPrefix("proxy-ignore-headers")] = "cache-control, Expires"
 	ing.SetAnnotations(data)
 
 	i, err := NewParser(mockBackend{}).Parse(ing)
@@ -152,6 +153,135 @@ func TestProxy(t *testing.T) {
 	if p.ProxyMaxTempFileSize != "128k" {
 		t.Errorf("expected 128k as proxy-max-temp-file-size but returned %v", p.ProxyMaxTempFileSize)
 	}
+	if p.ProxyIgnoreHeaders != "Cache-Control Expires" {
+		t.Errorf("expected Cache-Control Expires as proxy-ignore-headers but returned %v", p.ProxyIgnoreHeaders)
+	}
+}
+
+func TestProxyWithInvalidIgnoreHeaders(t *testing.T) {
+	ing := buildIngress()
+
+	data := map[string]string{}
+	data[parser.GetAnnotationWithPrefix("proxy-ignore-headers")] = "Cache-Control,X-Custom"
+	ing.SetAnnotations(data)
+
+	_, err := NewParser(mockBackend{}).Parse(ing)
+	if err == nil {
+		t.Errorf("expected error parsing an unknown proxy-ignore-headers value")
+	}
+}
+
+func TestProxyNextUpstream(t *testing.T) {
+	testCases := []struct {
+		value    string
+		expected string
+		expErr   bool
+	}{
+		{"error timeout", "error timeout", false},
+		{"error  timeout http_502 http_503 non_idempotent", "error timeout http_502 http_503 non_idempotent", false},
+		{"off", "off", false},
+		{"error timeot", "", true},
+		{"http_500 http_501", "", true},
+		{"error,timeout", "", true},
+		{"ERROR", "", true},
+	}
+
+	for _, tc := range testCases {
+		ing := buildIngress()
+		ing.SetAnnotations(map[string]string{
+			parser.GetAnnotationWithPrefix("proxy-next-upstream"): tc.value,
+		})
+
+		i, err := NewParser(mockBackend{}).Parse(ing)
+		if tc.expErr {
+			if err == nil {
+				t.Errorf("expected error parsing proxy-next-upstream %q", tc.value)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("unexpected error parsing proxy-next-upstream %q: %v", tc.value, err)
+			continue
+		}
+		if p := i.(*Config); p.NextUpstream != tc.expected {
+			t.Errorf("expected %q as next-upstream but returned %q", tc.expected, p.NextUpstream)
+		}
+	}
+}
+
+func TestProxyBodySize(t *testing.T) {
+	testCases := []struct {
+		value    string
+		expected string
+		expErr   bool
+	}{
+		{"10m", "10m", false},
+		{"1g", "1g", false},
+		{"512K", "512K", false},
+		{"0", "0", false},
+		{"10gg", "", true},
+		{"1.5m", "", true},
+		{"-1", "", true},
+		{"10 m", "", true},
+		{"", "3k", false},
+	}
+
+	for _, tc := range testCases {
+		ing := buildIngress()
+		ing.SetAnnotations(map[string]string{
+			parser.GetAnnotationWithPrefix("proxy-body-size"): tc.value,
+		})
+
+		i, err := NewParser(mockBackend{}).Parse(ing)
+		if tc.expErr {
+			if err == nil {
+				t.Errorf("expected error parsing proxy-body-size %q", tc.value)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("unexpected error parsing proxy-body-size %q: %v", tc.value, err)
+			continue
+		}
+		if p := i.(*Config); p.BodySize != tc.expected {
+			t.Errorf("expected %q as body-size but returned %q", tc.expected, p.BodySize)
+		}
+	}
+}
+
+func TestProxyForceContentLength(t *testing.T) {
+	ing := buildIngress()
+
+	data := map[string]string{}
+	data[parser.GetAnnotationWithPrefix("proxy-force-content-length")] = "true"
+	data[parser.GetAnnotationWithPrefix("proxy-force-content-length-max-size")] = "8m"
+	ing.SetAnnotations(data)
+
+	i, err := NewParser(mockBackend{}).Parse(ing)
+	if err != nil {
+		t.Fatalf("unexpected error parsing a valid proxy-force-content-length: %v", err)
+	}
+	p, ok := i.(*Config)
+	if !ok {
+		t.Fatalf("expected a Config type")
+	}
+	if !p.ForceContentLength {
+		t.Errorf("expected proxy-force-content-length to be enabled")
+	}
+	if p.ForceContentLengthMaxSize != "8m" {
+		t.Errorf("expected 8m as proxy-force-content-length-max-size but returned %v", p.ForceContentLengthMaxSize)
+	}
+	if p.ProxyBuffering != "on" {
+		t.Errorf("expected proxy-buffering to be turned on but returned %v", p.ProxyBuffering)
+	}
+
+	data[parser.GetAnnotationWithPrefix("proxy-buffering")] = "off"
+	ing.SetAnnotations(data)
+
+	_, err = NewParser(mockBackend{}).Parse(ing)
+	if err == nil {
+		t.Errorf("expected error combining proxy-force-content-length with proxy-buffering off")
+	}
 }
 
 func TestProxyWithNoAnnotation(t *testing.T) {
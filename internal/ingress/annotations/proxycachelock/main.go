@@ -0,0 +1,117 @@
+/*
+Copyright 2023 The Alibaba Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package proxycachelock
+
+import (
+	"regexp"
+
+	networking "k8s.io/api/networking/v1"
+	"k8s.io/klog"
+
+	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/sendtimeout"
+	ing_errors "k8s.io/ingress-nginx/internal/ingress/errors"
+	"k8s.io/ingress-nginx/internal/ingress/resolver"
+)
+
+// proxyCacheRegex matches a proxy_cache directive enabling a cache zone
+var proxyCacheRegex = regexp.MustCompile(`(^|[;{}\s])proxy_cache\s+([^;\s]+)\s*;`)
+
+// Config contains the configuration of the lock of the proxy cache
+type Config struct {
+	// Enabled collapses the identical requests missing the cache into one upstream request
+	Enabled bool `json:"enabled"`
+	// Timeout is the time the other requests wait for the cache to be populated
+	Timeout string `json:"timeout,omitempty"`
+}
+
+// Equal tests for equality between two Config types
+func (c1 *Config) Equal(c2 *Config) bool {
+	if c1 == c2 {
+		return true
+	}
+	if c1 == nil || c2 == nil {
+		return false
+	}
+	if c1.Enabled != c2.Enabled {
+		return false
+	}
+	if c1.Timeout != c2.Timeout {
+		return false
+	}
+
+	return true
+}
+
+type proxyCacheLock struct {
+	r resolver.Resolver
+}
+
+// NewParser creates a new proxy cache lock annotation parser
+func NewParser(r resolver.Resolver) parser.IngressAnnotation {
+	return proxyCacheLock{r}
+}
+
+// Keys returns the keys of the annotations read by the parser
+func (a proxyCacheLock) Keys() []string {
+	return []string{"proxy-cache-lock", "proxy-cache-lock-timeout"}
+}
+
+// Parse parses the annotations contained in the ingress rule used to
+// collapse the identical requests missing the proxy cache of the locations
+func (a proxyCacheLock) Parse(ing *networking.Ingress) (interface{}, error) {
+	enabled, err := parser.GetBoolAnnotation("proxy-cache-lock", ing)
+	if err != nil || !enabled {
+		return Config{}, err
+	}
+
+	// the proxy cache of a location can only be enabled by its configuration snippet
+	snippet, _ := parser.GetStringAnnotation("configuration-snippet", ing)
+	if !ProxyCacheEnabled(snippet) {
+		klog.Warningf("Ignoring proxy-cache-lock of Ingress %v/%v: proxy cache is not enabled in its configuration-snippet", ing.Namespace, ing.Name)
+		return Config{}, ing_errors.NewInvalidAnnotationConfiguration("proxy-cache-lock", "proxy cache is not enabled")
+	}
+
+	timeout, err := parser.GetStringAnnotation("proxy-cache-lock-timeout", ing)
+	if err != nil {
+		if !ing_errors.IsMissingAnnotations(err) {
+			return Config{}, err
+		}
+		return Config{Enabled: true}, nil
+	}
+
+	if !sendtimeout.ValidTime(timeout) {
+		klog.Warningf("Ingress %v/%v has an invalid proxy-cache-lock-timeout %q, using the default", ing.Namespace, ing.Name, timeout)
+		return Config{Enabled: true}, nil
+	}
+
+	return Config{
+		Enabled: true,
+		Timeout: timeout,
+	}, nil
+}
+
+// ProxyCacheEnabled checks if the snippet enables a proxy cache zone
+func ProxyCacheEnabled(snippet string) bool {
+	for _, matches := range proxyCacheRegex.FindAllStringSubmatch(snippet, -1) {
+		if matches[2] != "off" {
+			return true
+		}
+	}
+
+	return false
+}
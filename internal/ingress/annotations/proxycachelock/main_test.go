@@ -0,0 +1,93 @@
+/*
+Copyright 2023 The Alibaba Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package proxycachelock
+
+import (
+	"testing"
+
+	api "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	"k8s.io/ingress-nginx/internal/ingress/resolver"
+)
+
+func TestParse(t *testing.T) {
+	lock := parser.GetAnnotationWithPrefix("proxy-cache-lock")
+	timeout := parser.GetAnnotationWithPrefix("proxy-cache-lock-timeout")
+	snippet := parser.GetAnnotationWithPrefix("configuration-snippet")
+	cache := "proxy_cache static;\nproxy_cache_valid 200 10m;"
+
+	ap := NewParser(&resolver.Mock{})
+	if ap == nil {
+		t.Fatalf("expected a parser.IngressAnnotation but returned nil")
+	}
+
+	testCases := []struct {
+		annotations map[string]string
+		expected    Config
+		expErr      bool
+	}{
+		{map[string]string{lock: "true", snippet: cache}, Config{Enabled: true}, false},
+		{map[string]string{lock: "true", snippet: cache, timeout: "10s"}, Config{Enabled: true, Timeout: "10s"}, false},
+		{map[string]string{lock: "true", snippet: cache, timeout: "1m30s"}, Config{Enabled: true, Timeout: "1m30s"}, false},
+		{map[string]string{lock: "true", snippet: cache, timeout: "10 s"}, Config{Enabled: true}, false},
+		{map[string]string{lock: "true", timeout: "10s"}, Config{}, true},
+		{map[string]string{lock: "true", snippet: "proxy_cache off;"}, Config{}, true},
+		{map[string]string{lock: "true", snippet: "proxy_cache_valid 200 10m;"}, Config{}, true},
+		{map[string]string{lock: "false", snippet: cache}, Config{}, false},
+		{map[string]string{snippet: cache}, Config{}, true},
+		{nil, Config{}, true},
+	}
+
+	ing := &networking.Ingress{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "foo",
+			Namespace: api.NamespaceDefault,
+		},
+		Spec: networking.IngressSpec{},
+	}
+
+	for _, testCase := range testCases {
+		ing.SetAnnotations(testCase.annotations)
+		result, err := ap.Parse(ing)
+		if testCase.expErr != (err != nil) {
+			t.Errorf("expected error %v but returned %v, annotations: %s", testCase.expErr, err, testCase.annotations)
+		}
+		config := result.(Config)
+		if !config.Equal(&testCase.expected) {
+			t.Errorf("expected %+v but returned %+v, annotations: %s", testCase.expected, config, testCase.annotations)
+		}
+	}
+}
+
+func TestProxyCacheEnabled(t *testing.T) {
+	testCases := map[string]bool{
+		"":                    false,
+		"proxy_cache static;": true,
+		"more_set_headers 'X-A: b'; proxy_cache $zone;": true,
+		"proxy_cache off;":     false,
+		"proxy_cache_lock on;": false,
+		"proxy_cache_path /tmp/cache keys_zone=static:10m;": false,
+	}
+
+	for snippet, expected := range testCases {
+		if v := ProxyCacheEnabled(snippet); v != expected {
+			t.Errorf("expected %v for %q but got %v", expected, snippet, v)
+		}
+	}
+}
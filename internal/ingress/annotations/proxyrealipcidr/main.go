@@ -0,0 +1,75 @@
+/*
+Copyright 2023 The Alibaba Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package proxyrealipcidr
+
+import (
+	"sort"
+	"strings"
+
+	networking "k8s.io/api/networking/v1"
+	"k8s.io/klog"
+
+	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	ing_errors "k8s.io/ingress-nginx/internal/ingress/errors"
+	"k8s.io/ingress-nginx/internal/ingress/resolver"
+	"k8s.io/ingress-nginx/internal/net"
+)
+
+type proxyRealIPCIDR struct {
+	r resolver.Resolver
+}
+
+// NewParser creates a new trusted proxies annotation parser
+func NewParser(r resolver.Resolver) parser.IngressAnnotation {
+	return proxyRealIPCIDR{r}
+}
+
+// Keys returns the keys of the annotations read by the parser
+func (a proxyRealIPCIDR) Keys() []string {
+	return []string{"proxy-real-ip-cidr"}
+}
+
+// Parse parses the annotations contained in the ingress rule
+// used to define the addresses of the proxies trusted to send the
+// client address of the requests to the server, overriding the global
+// proxy-real-ip-cidr. Multiple ranges can be specified using commas
+// as separator e.g. `10.0.0.0/8,192.168.1.1`
+func (a proxyRealIPCIDR) Parse(ing *networking.Ingress) (interface{}, error) {
+	val, err := parser.GetStringAnnotation("proxy-real-ip-cidr", ing)
+	if err != nil {
+		return []string{}, err
+	}
+
+	// every entry must be valid, a typo must not trust a wider range than expected
+	ipnets, ips, err := net.ParseIPNets(strings.Split(val, ",")...)
+	if err != nil {
+		klog.Warningf("Ignoring proxy-real-ip-cidr %q in Ingress %v/%v: %v", val, ing.Namespace, ing.Name, err)
+		return []string{}, ing_errors.NewInvalidAnnotationContent("proxy-real-ip-cidr", val)
+	}
+
+	cidrs := []string{}
+	for k := range ipnets {
+		cidrs = append(cidrs, k)
+	}
+	for k := range ips {
+		cidrs = append(cidrs, k)
+	}
+
+	sort.Strings(cidrs)
+
+	return cidrs, nil
+}
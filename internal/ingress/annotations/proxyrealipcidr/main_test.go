@@ -0,0 +1,74 @@
+/*
+Copyright 2023 The Alibaba Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package proxyrealipcidr
+
+import (
+	"reflect"
+	"testing"
+
+	api "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	"k8s.io/ingress-nginx/internal/ingress/resolver"
+)
+
+func TestParse(t *testing.T) {
+	annotation := parser.GetAnnotationWithPrefix("proxy-real-ip-cidr")
+
+	ap := NewParser(&resolver.Mock{})
+	if ap == nil {
+		t.Fatalf("expected a parser.IngressAnnotation but returned nil")
+	}
+
+	testCases := []struct {
+		annotations map[string]string
+		expected    []string
+		expErr      bool
+	}{
+		{map[string]string{annotation: "10.0.0.0/8"}, []string{"10.0.0.0/8"}, false},
+		{map[string]string{annotation: "192.168.1.1, 10.0.0.0/8"}, []string{"10.0.0.0/8", "192.168.1.1"}, false},
+		{map[string]string{annotation: "10.1.2.3/8"}, []string{"10.0.0.0/8"}, false},
+		{map[string]string{annotation: "2001:db8::/32"}, []string{"2001:db8::/32"}, false},
+		{map[string]string{annotation: "10.0.0.0/8,10.0.0.0/8"}, []string{"10.0.0.0/8"}, false},
+		{map[string]string{annotation: "10.0.0.0/33"}, []string{}, true},
+		{map[string]string{annotation: "10.0.0.0/8,lb.example.com"}, []string{}, true},
+		{map[string]string{annotation: "10.0.0.0/8,"}, []string{}, true},
+		{map[string]string{annotation: ""}, []string{}, true},
+		{map[string]string{}, []string{}, true},
+		{nil, []string{}, true},
+	}
+
+	ing := &networking.Ingress{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "foo",
+			Namespace: api.NamespaceDefault,
+		},
+		Spec: networking.IngressSpec{},
+	}
+
+	for _, testCase := range testCases {
+		ing.SetAnnotations(testCase.annotations)
+		result, err := ap.Parse(ing)
+		if testCase.expErr != (err != nil) {
+			t.Errorf("expected error %v but returned %v, annotations: %s", testCase.expErr, err, testCase.annotations)
+		}
+		if !reflect.DeepEqual(result, testCase.expected) {
+			t.Errorf("expected %v but returned %v, annotations: %s", testCase.expected, result, testCase.annotations)
+		}
+	}
+}
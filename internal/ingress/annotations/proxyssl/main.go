@@ -23,10 +23,12 @@ import (
 
 	"github.com/pkg/errors"
 	networking "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
 	ing_errors "k8s.io/ingress-nginx/internal/ingress/errors"
 	"k8s.io/ingress-nginx/internal/ingress/resolver"
 	"k8s.io/ingress-nginx/internal/k8s"
+	"k8s.io/klog"
 )
 
 const (
@@ -49,6 +51,7 @@ type Config struct {
 	Protocols   string `json:"protocols"`
 	Verify      string `json:"verify"`
 	VerifyDepth int    `json:"verifyDepth"`
+	Name        string `json:"name,omitempty"`
 }
 
 // Equal tests for equality between two Config types
@@ -74,6 +77,9 @@ func (pssl1 *Config) Equal(pssl2 *Config) bool {
 	if pssl1.VerifyDepth != pssl2.VerifyDepth {
 		return false
 	}
+	if pssl1.Name != pssl2.Name {
+		return false
+	}
 	return true
 }
 
@@ -108,14 +114,36 @@ func sortProtocols(protocols string) string {
 	return strings.Join(protolist, " ")
 }
 
+// Keys returns the keys of the annotations read by the parser
+func (p proxySSL) Keys() []string {
+	return []string{
+		"proxy-ssl-name",
+		"proxy-ssl-secret",
+		"proxy-ssl-ciphers",
+		"proxy-ssl-protocols",
+		"proxy-ssl-verify",
+		"proxy-ssl-verify-depth",
+	}
+}
+
 // Parse parses the annotations contained in the ingress
 // rule used to use a Certificate as authentication method
 func (p proxySSL) Parse(ing *networking.Ingress) (interface{}, error) {
 	var err error
 	config := &Config{}
 
+	config.Name, err = parser.GetStringAnnotation("proxy-ssl-name", ing)
+	if err == nil && len(validation.IsDNS1123Subdomain(strings.ToLower(config.Name))) > 0 {
+		klog.Warningf("Ignoring proxy-ssl-name %q of Ingress %v/%v: not a valid hostname", config.Name, ing.Namespace, ing.Name)
+		config.Name = ""
+	}
+
 	proxysslsecret, err := parser.GetStringAnnotation("proxy-ssl-secret", ing)
 	if err != nil {
+		// the server name can be sent without a client certificate
+		if config.Name != "" {
+			return &Config{Name: config.Name}, nil
+		}
 		return &Config{}, err
 	}
 
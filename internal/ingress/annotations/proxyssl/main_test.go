@@ -186,6 +186,50 @@ func TestInvalidAnnotations(t *testing.T) {
 	}
 }
 
+func TestProxySSLName(t *testing.T) {
+	testCases := []struct {
+		title    string
+		secret   string
+		name     string
+		expected string
+		expErr   bool
+	}{
+		{"name with secret", "default/demo-secret", "backend.example.com", "backend.example.com", false},
+		{"name without secret", "", "backend.example.com", "backend.example.com", false},
+		{"invalid name with secret", "default/demo-secret", "$host", "", false},
+		{"invalid name without secret", "", "backend_example.com", "", true},
+		{"no name", "default/demo-secret", "", "", false},
+	}
+
+	for _, tc := range testCases {
+		ing := buildIngress()
+		data := map[string]string{}
+		if tc.secret != "" {
+			data[parser.GetAnnotationWithPrefix("proxy-ssl-secret")] = tc.secret
+		}
+		if tc.name != "" {
+			data[parser.GetAnnotationWithPrefix("proxy-ssl-name")] = tc.name
+		}
+		ing.SetAnnotations(data)
+
+		i, err := NewParser(&mockSecret{}).Parse(ing)
+		if tc.expErr {
+			if err == nil {
+				t.Errorf("%v: expected error but returned nil", tc.title)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%v: unexpected error: %v", tc.title, err)
+			continue
+		}
+
+		if name := i.(*Config).Name; name != tc.expected {
+			t.Errorf("%v: expected the name %q but got %q", tc.title, tc.expected, name)
+		}
+	}
+}
+
 func TestEquals(t *testing.T) {
 	cfg1 := &Config{}
 	cfg2 := &Config{}
@@ -257,6 +301,15 @@ func TestEquals(t *testing.T) {
 	}
 	cfg2.VerifyDepth = 1
 
+	// Different Name
+	cfg1.Name = "backend.example.com"
+	cfg2.Name = "other.example.com"
+	result = cfg1.Equal(cfg2)
+	if result != false {
+		t.Errorf("Expected false")
+	}
+	cfg2.Name = "backend.example.com"
+
 	// Equal Configs
 	result = cfg1.Equal(cfg2)
 	if result != true {
@@ -141,6 +141,18 @@ func NewParser(r resolver.Resolver) parser.IngressAnnotation {
 	return ratelimit{r}
 }
 
+// Keys returns the keys of the annotations read by the parser
+func (a ratelimit) Keys() []string {
+	return []string{
+		"limit-rate",
+		"limit-rate-after",
+		"limit-rpm",
+		"limit-rps",
+		"limit-connections",
+		"limit-whitelist",
+	}
+}
+
 // ParseAnnotations parses the annotations contained in the ingress
 // rule used to rewrite the defined paths
 func (a ratelimit) Parse(ing *networking.Ingress) (interface{}, error) {
@@ -46,6 +46,16 @@ func NewParser(r resolver.Resolver) parser.IngressAnnotation {
 	return redirect{r}
 }
 
+// Keys returns the keys of the annotations read by the parser
+func (r redirect) Keys() []string {
+	return []string{
+		"from-to-www-redirect",
+		"temporal-redirect",
+		"permanent-redirect",
+		"permanent-redirect-code",
+	}
+}
+
 // Parse parses the annotations contained in the ingress
 // rule used to create a redirect in the paths defined in the rule.
 // If the Ingress contains both annotations the execution order is
@@ -17,7 +17,11 @@ limitations under the License.
 package referrer
 
 import (
+	"path"
+	"strings"
+
 	networking "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/klog"
 
 	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
@@ -43,6 +47,11 @@ func NewParser(r resolver.Resolver) parser.IngressAnnotation {
 	return referrer{r}
 }
 
+// Keys returns the keys of the annotations read by the parser
+func (a referrer) Keys() []string {
+	return []string{IngressReferrer}
+}
+
 // ParseAnnotations parses the annotations contained in the ingress
 // rule used to indicate if is required to configure
 func (a referrer) Parse(ing *networking.Ingress) (interface{}, error) {
@@ -57,3 +66,49 @@ func (a referrer) Parse(ing *networking.Ingress) (interface{}, error) {
 
 	return config, nil
 }
+
+// Matcher matches referrers against a comma separated list of allowed
+// referrers, which can be path.Match patterns like team-a-*
+type Matcher struct {
+	exact    sets.String
+	patterns []string
+}
+
+// NewMatcher parses a comma separated list of allowed referrers.
+// Invalid patterns are ignored.
+func NewMatcher(referrers string) *Matcher {
+	m := &Matcher{
+		exact: sets.NewString(),
+	}
+
+	for _, r := range strings.Split(referrers, ",") {
+		if !strings.ContainsAny(r, "*?[") {
+			m.exact.Insert(r)
+			continue
+		}
+
+		if _, err := path.Match(r, ""); err != nil {
+			klog.Warningf("Ignoring invalid referrer pattern %q: %v", r, err)
+			continue
+		}
+
+		m.patterns = append(m.patterns, r)
+	}
+
+	return m
+}
+
+// Match returns true if the referrer is allowed
+func (m *Matcher) Match(referrer string) bool {
+	if m.exact.Has(referrer) {
+		return true
+	}
+
+	for _, p := range m.patterns {
+		if ok, _ := path.Match(p, referrer); ok {
+			return true
+		}
+	}
+
+	return false
+}
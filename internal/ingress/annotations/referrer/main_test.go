@@ -72,3 +72,28 @@ func TestParseAnnotations(t *testing.T) {
 		t.Errorf("expected %v but got %v", "tengine", val)
 	}
 }
+
+func TestMatcher(t *testing.T) {
+	testCases := map[string]struct {
+		referrers string
+		referrer  string
+		expected  bool
+	}{
+		"exact match":               {"tengine,team-b", "team-b", true},
+		"exact mismatch":            {"tengine,team-b", "team-c", false},
+		"no prefix match for exact": {"team-a", "team-a-web", false},
+		"wildcard match":            {"tengine,team-a-*", "team-a-web", true},
+		"wildcard mismatch":         {"team-a-*", "team-b-web", false},
+		"single character pattern":  {"team-?", "team-a", true},
+		"invalid pattern ignored":   {"team-[,tengine", "team-[", false},
+		"empty list":                {"", "tengine", false},
+	}
+
+	for title, tc := range testCases {
+		t.Run(title, func(t *testing.T) {
+			if m := NewMatcher(tc.referrers).Match(tc.referrer); m != tc.expected {
+				t.Errorf("expected %v matching %q against %q but got %v", tc.expected, tc.referrer, tc.referrers, m)
+			}
+		})
+	}
+}
@@ -0,0 +1,124 @@
+/*
+Copyright 2023 The Alibaba Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package requestbodymd5
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	networking "k8s.io/api/networking/v1"
+
+	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	ing_errors "k8s.io/ingress-nginx/internal/ingress/errors"
+	"k8s.io/ingress-nginx/internal/ingress/resolver"
+)
+
+// DefaultBufferSize is the largest request body a checksum is computed for
+// when request-body-md5-buffer-size is not set
+const DefaultBufferSize = "1m"
+
+var bufferSizeRegex = regexp.MustCompile(`^([0-9]+)([kKmM]?)$`)
+
+// Config contains the configuration of the request body checksum header
+type Config struct {
+	// Enabled adds the Content-MD5 header with the checksum of the request body
+	Enabled bool `json:"enabled"`
+	// BufferSize is the largest request body in bytes a checksum is computed for
+	BufferSize int64 `json:"bufferSize"`
+}
+
+// Equal tests for equality between two Config types
+func (c1 *Config) Equal(c2 *Config) bool {
+	if c1 == c2 {
+		return true
+	}
+	if c1 == nil || c2 == nil {
+		return false
+	}
+	if c1.Enabled != c2.Enabled {
+		return false
+	}
+	if c1.BufferSize != c2.BufferSize {
+		return false
+	}
+
+	return true
+}
+
+type requestBodyMD5 struct {
+	r resolver.Resolver
+}
+
+// NewParser creates a new request body checksum annotation parser
+func NewParser(r resolver.Resolver) parser.IngressAnnotation {
+	return requestBodyMD5{r}
+}
+
+// Keys returns the keys of the annotations read by the parser
+func (a requestBodyMD5) Keys() []string {
+	return []string{"add-request-body-md5", "request-body-md5-buffer-size"}
+}
+
+// Parse parses the annotations contained in the ingress rule used
+// to add the checksum of the request body before proxying
+func (a requestBodyMD5) Parse(ing *networking.Ingress) (interface{}, error) {
+	enabled, err := parser.GetBoolAnnotation("add-request-body-md5", ing)
+	if err != nil || !enabled {
+		return Config{}, err
+	}
+
+	size, err := parser.GetStringAnnotation("request-body-md5-buffer-size", ing)
+	if err != nil {
+		if !ing_errors.IsMissingAnnotations(err) {
+			return Config{}, err
+		}
+		size = DefaultBufferSize
+	}
+
+	bufferSize, ok := parseBufferSize(size)
+	if !ok {
+		return Config{}, ing_errors.NewLocationDenied("invalid request-body-md5-buffer-size " + size)
+	}
+
+	return Config{
+		Enabled:    true,
+		BufferSize: bufferSize,
+	}, nil
+}
+
+// parseBufferSize converts a size in NGINX syntax, e.g. 64k, to bytes
+func parseBufferSize(size string) (int64, bool) {
+	matches := bufferSizeRegex.FindStringSubmatch(strings.TrimSpace(size))
+	if matches == nil {
+		return 0, false
+	}
+
+	n, err := strconv.ParseInt(matches[1], 10, 64)
+	if err != nil || n <= 0 {
+		return 0, false
+	}
+
+	switch strings.ToLower(matches[2]) {
+	case "k":
+		n *= 1024
+	case "m":
+		n *= 1024 * 1024
+	}
+
+	return n, true
+}
@@ -73,6 +73,11 @@ func NewParser(r resolver.Resolver) parser.IngressAnnotation {
 	return rewrite{r}
 }
 
+// Keys returns the keys of the annotations read by the parser
+func (a rewrite) Keys() []string {
+	return []string{"rewrite-target", "ssl-redirect", "force-ssl-redirect", "app-root", "use-regex"}
+}
+
 // ParseAnnotations parses the annotations contained in the ingress
 // rule used to rewrite the defined paths
 func (a rewrite) Parse(ing *networking.Ingress) (interface{}, error) {
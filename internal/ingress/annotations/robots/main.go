@@ -17,6 +17,8 @@ limitations under the License.
 package robots
 
 import (
+	"strings"
+
 	networking "k8s.io/api/networking/v1"
 
 	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
@@ -24,6 +26,32 @@ import (
 	"k8s.io/ingress-nginx/internal/ingress/resolver"
 )
 
+// Config describes the robots.txt served by the location
+type Config struct {
+	// Disable serves the default robots.txt disallowing all the crawlers
+	Disable bool `json:"disable"`
+	// Content is the custom robots.txt served for the location
+	Content string `json:"content"`
+}
+
+// Equal tests for equality between two Config types
+func (c1 *Config) Equal(c2 *Config) bool {
+	if c1 == c2 {
+		return true
+	}
+	if c1 == nil || c2 == nil {
+		return false
+	}
+	if c1.Disable != c2.Disable {
+		return false
+	}
+	if c1.Content != c2.Content {
+		return false
+	}
+
+	return true
+}
+
 type robots struct {
 	r resolver.Resolver
 }
@@ -33,12 +61,55 @@ func NewParser(r resolver.Resolver) parser.IngressAnnotation {
 	return robots{r}
 }
 
+// Keys returns the keys of the annotations read by the parser
+func (a robots) Keys() []string {
+	return []string{"disable-robots", "robots-txt-content"}
+}
+
 // ParseAnnotations parses the annotations contained in the ingress
-// rule used to indicate if is required to configure
+// rule used to configure the robots.txt served by the location.
+// When both annotations are present disable-robots takes precedence.
 func (a robots) Parse(ing *networking.Ingress) (interface{}, error) {
 	if ing.GetAnnotations() == nil {
-		return false, ing_errors.ErrMissingAnnotations
+		return Config{}, ing_errors.ErrMissingAnnotations
+	}
+
+	disable, err := parser.GetBoolAnnotation("disable-robots", ing)
+	if err != nil && !ing_errors.IsMissingAnnotations(err) {
+		return Config{}, err
+	}
+
+	content, cerr := parser.GetStringAnnotation("robots-txt-content", ing)
+	if cerr != nil && !ing_errors.IsMissingAnnotations(cerr) {
+		return Config{}, cerr
+	}
+
+	if err != nil && cerr != nil {
+		return Config{}, ing_errors.ErrMissingAnnotations
+	}
+
+	if disable {
+		return Config{Disable: true}, nil
+	}
+
+	return Config{Content: normalizeContent(content)}, nil
+}
+
+// normalizeContent returns the content using LF line endings, without
+// leading or trailing blank lines and terminated by a single newline
+func normalizeContent(content string) string {
+	content = strings.ReplaceAll(content, "\r\n", "\n")
+	content = strings.ReplaceAll(content, "\r", "\n")
+
+	lines := []string{}
+	for _, line := range strings.Split(content, "\n") {
+		lines = append(lines, strings.TrimSpace(line))
+	}
+
+	content = strings.TrimSpace(strings.Join(lines, "\n"))
+	if content == "" {
+		return ""
 	}
 
-	return parser.GetBoolAnnotation("disable-robots", ing)
+	return content + "\n"
 }
@@ -64,11 +64,51 @@ func TestParseAnnotations(t *testing.T) {
 	if err != nil {
 		t.Errorf("expected error parsing ingress with disable-robots")
 	}
-	val, ok := i.(bool)
+	val, ok := i.(Config)
 	if !ok {
-		t.Errorf("expected a bool type")
+		t.Errorf("expected a Config type")
 	}
-	if !val {
+	if !val.Disable {
 		t.Errorf("expected true but false returned")
 	}
 }
+
+func TestParseRobotsContent(t *testing.T) {
+	testCases := []struct {
+		title       string
+		annotations map[string]string
+		expected    Config
+	}{
+		{"content", map[string]string{
+			parser.GetAnnotationWithPrefix("robots-txt-content"): "User-agent: *\nDisallow: /private",
+		}, Config{Content: "User-agent: *\nDisallow: /private\n"}},
+		{"multi-line content", map[string]string{
+			parser.GetAnnotationWithPrefix("robots-txt-content"): "\r\n  User-agent: *  \r\nDisallow: /\r\n\n\n",
+		}, Config{Content: "User-agent: *\nDisallow: /\n"}},
+		{"disable wins over content", map[string]string{
+			parser.GetAnnotationWithPrefix("disable-robots"):     "true",
+			parser.GetAnnotationWithPrefix("robots-txt-content"): "User-agent: *\nAllow: /",
+		}, Config{Disable: true}},
+		{"content without disable", map[string]string{
+			parser.GetAnnotationWithPrefix("disable-robots"):     "false",
+			parser.GetAnnotationWithPrefix("robots-txt-content"): "User-agent: *\nAllow: /",
+		}, Config{Content: "User-agent: *\nAllow: /\n"}},
+	}
+
+	for _, testCase := range testCases {
+		ing := buildIngress()
+		ing.SetAnnotations(testCase.annotations)
+
+		i, err := NewParser(&resolver.Mock{}).Parse(ing)
+		if err != nil {
+			t.Errorf("%v: unexpected error: %v", testCase.title, err)
+		}
+		val, ok := i.(Config)
+		if !ok {
+			t.Errorf("%v: expected a Config type", testCase.title)
+		}
+		if !val.Equal(&testCase.expected) {
+			t.Errorf("%v: expected %+v but returned %+v", testCase.title, testCase.expected, val)
+		}
+	}
+}
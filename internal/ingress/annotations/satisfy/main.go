@@ -20,6 +20,7 @@ import (
 	networking "k8s.io/api/networking/v1"
 
 	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	ing_errors "k8s.io/ingress-nginx/internal/ingress/errors"
 	"k8s.io/ingress-nginx/internal/ingress/resolver"
 )
 
@@ -32,12 +33,25 @@ func NewParser(r resolver.Resolver) parser.IngressAnnotation {
 	return satisfy{r}
 }
 
-// Parse parses annotation contained in the ingress
+// Keys returns the keys of the annotations read by the parser
+func (s satisfy) Keys() []string {
+	return []string{"satisfy"}
+}
+
+// Parse parses annotation contained in the ingress.
+// Invalid values are rejected so the location keeps the default
+// of NGINX, which requires all the access checks to pass.
 func (s satisfy) Parse(ing *networking.Ingress) (interface{}, error) {
 	satisfy, err := parser.GetStringAnnotation("satisfy", ing)
+	if err != nil {
+		if ing_errors.IsMissingAnnotations(err) {
+			return "", nil
+		}
+		return "", err
+	}
 
-	if err != nil || (satisfy != "any" && satisfy != "all") {
-		satisfy = ""
+	if satisfy != "any" && satisfy != "all" {
+		return "", ing_errors.NewInvalidAnnotationContent("satisfy", satisfy)
 	}
 
 	return satisfy, nil
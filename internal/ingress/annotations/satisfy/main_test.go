@@ -66,22 +66,25 @@ func buildIngress() *networking.Ingress {
 func TestSatisfyParser(t *testing.T) {
 	ing := buildIngress()
 
-	data := map[string]string{
-		"any":     "any",
-		"all":     "all",
-		"invalid": "",
-		"":        "",
+	data := map[string]struct {
+		expected string
+		expErr   bool
+	}{
+		"any":     {"any", false},
+		"all":     {"all", false},
+		"invalid": {"", true},
+		"Any":     {"", true},
 	}
 
 	annotations := map[string]string{}
 
-	for input, expected := range data {
+	for input, tc := range data {
 		annotations[parser.GetAnnotationWithPrefix("satisfy")] = input
 		ing.SetAnnotations(annotations)
 
 		satisfyt, err := NewParser(&resolver.Mock{}).Parse(ing)
-		if err != nil {
-			t.Errorf("error parsing annotations: %v", err)
+		if tc.expErr != (err != nil) {
+			t.Errorf("%v: expected error %v but returned %v", input, tc.expErr, err)
 		}
 
 		val, ok := satisfyt.(string)
@@ -89,8 +92,14 @@ func TestSatisfyParser(t *testing.T) {
 			t.Errorf("expected a string type but return %t", satisfyt)
 		}
 
-		if val != expected {
-			t.Errorf("expected %v but returned %v", expected, val)
+		if val != tc.expected {
+			t.Errorf("expected %v but returned %v", tc.expected, val)
 		}
 	}
+
+	ing.SetAnnotations(map[string]string{})
+	satisfyt, err := NewParser(&resolver.Mock{}).Parse(ing)
+	if err != nil || satisfyt != "" {
+		t.Errorf("expected an empty value without the annotation but returned %v (%v)", satisfyt, err)
+	}
 }
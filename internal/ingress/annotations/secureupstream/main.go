@@ -38,6 +38,11 @@ func NewParser(r resolver.Resolver) parser.IngressAnnotation {
 	return su{r}
 }
 
+// Keys returns the keys of the annotations read by the parser
+func (a su) Keys() []string {
+	return []string{"secure-verify-ca-secret"}
+}
+
 // Parse parses the annotations contained in the ingress
 // rule used to indicate if the upstream servers should use SSL
 func (a su) Parse(ing *networking.Ingress) (secure interface{}, err error) {
@@ -0,0 +1,125 @@
+/*
+Copyright 2023 The Alibaba Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package securityheaders
+
+import (
+	"strings"
+
+	networking "k8s.io/api/networking/v1"
+
+	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	ing_errors "k8s.io/ingress-nginx/internal/ingress/errors"
+	"k8s.io/ingress-nginx/internal/ingress/resolver"
+)
+
+// Off removes a default security header from the responses
+const Off = "off"
+
+// DefaultHeaders contains the security headers added to the responses when
+// default-security-headers is enabled, in the order they are rendered
+var DefaultHeaders = []struct {
+	Name       string
+	Value      string
+	Annotation string
+}{
+	{"X-Frame-Options", "SAMEORIGIN", "security-header-x-frame-options"},
+	{"X-Content-Type-Options", "nosniff", "security-header-x-content-type-options"},
+	{"Referrer-Policy", "strict-origin-when-cross-origin", "security-header-referrer-policy"},
+}
+
+// Config contains the per location customization of the default security headers
+type Config struct {
+	// Disable removes all the default security headers from the responses
+	Disable bool `json:"disable"`
+	// Headers overrides the value of the default security headers, by name
+	Headers map[string]string `json:"headers,omitempty"`
+}
+
+// Equal tests for equality between two Config types
+func (c1 *Config) Equal(c2 *Config) bool {
+	if c1 == c2 {
+		return true
+	}
+	if c1 == nil || c2 == nil {
+		return false
+	}
+	if c1.Disable != c2.Disable {
+		return false
+	}
+	if len(c1.Headers) != len(c2.Headers) {
+		return false
+	}
+	for name, value := range c1.Headers {
+		if c2.Headers[name] != value {
+			return false
+		}
+	}
+
+	return true
+}
+
+type securityHeaders struct {
+	r resolver.Resolver
+}
+
+// NewParser creates a new security headers annotation parser
+func NewParser(r resolver.Resolver) parser.IngressAnnotation {
+	return securityHeaders{r}
+}
+
+// Keys returns the keys of the annotations read by the parser
+func (a securityHeaders) Keys() []string {
+	keys := []string{"disable-default-security-headers"}
+	for _, header := range DefaultHeaders {
+		keys = append(keys, header.Annotation)
+	}
+
+	return keys
+}
+
+// Parse parses the annotations contained in the ingress rule used
+// to opt out of or override the default security headers
+func (a securityHeaders) Parse(ing *networking.Ingress) (interface{}, error) {
+	config := Config{}
+
+	disable, err := parser.GetBoolAnnotation("disable-default-security-headers", ing)
+	if err != nil && !ing_errors.IsMissingAnnotations(err) {
+		return config, err
+	}
+	config.Disable = disable
+
+	for _, header := range DefaultHeaders {
+		value, err := parser.GetStringAnnotation(header.Annotation, ing)
+		if err != nil {
+			if ing_errors.IsMissingAnnotations(err) {
+				continue
+			}
+			return Config{}, err
+		}
+
+		if strings.ContainsAny(value, "\"\n") {
+			return Config{}, ing_errors.NewInvalidAnnotationContent(header.Annotation, value)
+		}
+
+		if config.Headers == nil {
+			config.Headers = map[string]string{}
+		}
+		config.Headers[header.Name] = value
+	}
+
+	return config, nil
+}
@@ -0,0 +1,80 @@
+/*
+Copyright 2023 The Alibaba Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package securityheaders
+
+import (
+	"testing"
+
+	api "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	"k8s.io/ingress-nginx/internal/ingress/resolver"
+)
+
+func TestParse(t *testing.T) {
+	disable := parser.GetAnnotationWithPrefix("disable-default-security-headers")
+	frameOptions := parser.GetAnnotationWithPrefix("security-header-x-frame-options")
+	referrerPolicy := parser.GetAnnotationWithPrefix("security-header-referrer-policy")
+
+	ap := NewParser(&resolver.Mock{})
+	if ap == nil {
+		t.Fatalf("expected a parser.IngressAnnotation but returned nil")
+	}
+
+	testCases := []struct {
+		annotations map[string]string
+		expected    Config
+		expErr      bool
+	}{
+		{nil, Config{}, false},
+		{map[string]string{disable: "true"}, Config{Disable: true}, false},
+		{map[string]string{disable: "foo"}, Config{}, true},
+		{map[string]string{frameOptions: "DENY", referrerPolicy: "off"}, Config{Headers: map[string]string{
+			"X-Frame-Options": "DENY",
+			"Referrer-Policy": "off",
+		}}, false},
+		{map[string]string{frameOptions: "DENY\"; foo"}, Config{}, true},
+	}
+
+	ing := &networking.Ingress{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "foo",
+			Namespace: api.NamespaceDefault,
+		},
+		Spec: networking.IngressSpec{},
+	}
+
+	for _, testCase := range testCases {
+		ing.SetAnnotations(testCase.annotations)
+		result, err := ap.Parse(ing)
+		if testCase.expErr {
+			if err == nil {
+				t.Errorf("expected error but returned nil, annotations: %s", testCase.annotations)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("unexpected error: %v, annotations: %s", err, testCase.annotations)
+		}
+
+		config := result.(Config)
+		if !config.Equal(&testCase.expected) {
+			t.Errorf("expected %+v but returned %+v, annotations: %s", testCase.expected, config, testCase.annotations)
+		}
+	}
+}
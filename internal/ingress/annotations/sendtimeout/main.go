@@ -0,0 +1,65 @@
+/*
+Copyright 2023 The Alibaba Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sendtimeout
+
+import (
+	"regexp"
+
+	networking "k8s.io/api/networking/v1"
+
+	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	ing_errors "k8s.io/ingress-nginx/internal/ingress/errors"
+	"k8s.io/ingress-nginx/internal/ingress/resolver"
+)
+
+// validTime matches the time syntax of NGINX, e.g. 60, 30s or 1m30s
+// http://nginx.org/en/docs/syntax.html
+var validTime = regexp.MustCompile(`^([0-9]+|([0-9]+(ms|s|m|h|d|w|M|y))+)$`)
+
+// ValidTime checks if the value is a valid NGINX time
+func ValidTime(value string) bool {
+	return validTime.MatchString(value)
+}
+
+type sendTimeout struct {
+	r resolver.Resolver
+}
+
+// NewParser creates a new send timeout annotation parser
+func NewParser(r resolver.Resolver) parser.IngressAnnotation {
+	return sendTimeout{r}
+}
+
+// Keys returns the keys of the annotations read by the parser
+func (a sendTimeout) Keys() []string {
+	return []string{"send-timeout"}
+}
+
+// Parse parses the annotations contained in the ingress rule
+// used to set the timeout for transmitting a response to the client
+func (a sendTimeout) Parse(ing *networking.Ingress) (interface{}, error) {
+	timeout, err := parser.GetStringAnnotation("send-timeout", ing)
+	if err != nil {
+		return "", err
+	}
+
+	if !ValidTime(timeout) {
+		return "", ing_errors.NewInvalidAnnotationContent("send-timeout", timeout)
+	}
+
+	return timeout, nil
+}
@@ -17,12 +17,24 @@ limitations under the License.
 package serversnippet
 
 import (
+	"fmt"
+	"regexp"
+	"strings"
+
 	networking "k8s.io/api/networking/v1"
+	"k8s.io/klog"
 
 	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	ing_errors "k8s.io/ingress-nginx/internal/ingress/errors"
 	"k8s.io/ingress-nginx/internal/ingress/resolver"
 )
 
+// robotsLocation is the built-in location omitted by disable-default-robots-location
+const robotsLocation = "/robots.txt"
+
+// locationRegex matches the location blocks of a snippet, capturing the modifier and the path
+var locationRegex = regexp.MustCompile(`(?:^|[\s;{}])location\s+(?:(=|~\*|~|\^~)\s*)?("[^"]*"|'[^']*'|[^\s{]+)\s*\{`)
+
 type serverSnippet struct {
 	r resolver.Resolver
 }
@@ -32,9 +44,64 @@ func NewParser(r resolver.Resolver) parser.IngressAnnotation {
 	return serverSnippet{r}
 }
 
+// Keys returns the keys of the annotations read by the parser
+func (a serverSnippet) Keys() []string {
+	return []string{"server-snippet"}
+}
+
 // Parse parses the annotations contained in the ingress rule
 // used to indicate if the location/s contains a fragment of
 // configuration to be included inside the paths of the rules
 func (a serverSnippet) Parse(ing *networking.Ingress) (interface{}, error) {
-	return parser.GetStringAnnotation("server-snippet", ing)
+	snippet, err := parser.GetStringAnnotation("server-snippet", ing)
+	if err != nil {
+		return snippet, err
+	}
+
+	// the built-in locations are rendered only by some servers, like the
+	// robots.txt location any ingress of the host can omit, so they are
+	// checked once the servers are created
+	reserved := withoutLocation(a.r.GetDefaultBackend().ReservedLocations, robotsLocation)
+
+	err = ValidateReservedLocations(snippet, reserved)
+	if err != nil {
+		klog.Warningf("Ignoring the server-snippet of Ingress %v/%v: %v", ing.Namespace, ing.Name, err)
+		return "", err
+	}
+
+	return snippet, nil
+}
+
+// ValidateReservedLocations checks the snippet does not define again a location
+// rendered by the controller in the server, which fails the reload with a
+// duplicate location error. Regular expression locations never collide.
+func ValidateReservedLocations(snippet string, reserved []string) error {
+	for _, match := range locationRegex.FindAllStringSubmatch(snippet, -1) {
+		modifier := match[1]
+		if modifier == "~" || modifier == "~*" {
+			continue
+		}
+
+		path := strings.Trim(match[2], `"'`)
+		for _, location := range reserved {
+			if path == location {
+				return ing_errors.NewInvalidAnnotationConfiguration("server-snippet",
+					fmt.Sprintf("location %v is reserved by the ingress controller", path))
+			}
+		}
+	}
+
+	return nil
+}
+
+// withoutLocation returns the locations except the given one
+func withoutLocation(locations []string, location string) []string {
+	result := make([]string, 0, len(locations))
+	for _, l := range locations {
+		if l != location {
+			result = append(result, l)
+		}
+	}
+
+	return result
 }
@@ -23,6 +23,8 @@ import (
 	networking "k8s.io/api/networking/v1"
 	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	"k8s.io/ingress-nginx/internal/ingress/defaults"
+	"k8s.io/ingress-nginx/internal/ingress/errors"
 	"k8s.io/ingress-nginx/internal/ingress/resolver"
 )
 
@@ -60,3 +62,101 @@ func TestParse(t *testing.T) {
 		}
 	}
 }
+
+type mockBackend struct {
+	resolver.Mock
+}
+
+func (m mockBackend) GetDefaultBackend() defaults.Backend {
+	return defaults.Backend{
+		ReservedLocations: []string{"/robots.txt", "/healthz"},
+	}
+}
+
+func TestParseReservedLocations(t *testing.T) {
+	annotation := parser.GetAnnotationWithPrefix("server-snippet")
+
+	testCases := []struct {
+		snippet string
+		expErr  bool
+	}{
+		{"location /healthz { return 200; }", true},
+		{"location = /healthz { return 200; }", true},
+		{"location ^~ \"/healthz\"{ return 200; }", true},
+		{"more_set_headers \"Foo: bar\";\nlocation /healthz {\n  return 200;\n}", true},
+		{"location ~ /robots.txt { return 200; }", false},
+		{"location /robots.txt.bak { return 200; }", false},
+		{"location /nginx_status { return 200; }", false},
+		{"set $location /robots.txt;", false},
+	}
+
+	ing := &networking.Ingress{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "foo",
+			Namespace: api.NamespaceDefault,
+		},
+		Spec: networking.IngressSpec{},
+	}
+
+	for _, testCase := range testCases {
+		ing.SetAnnotations(map[string]string{annotation: testCase.snippet})
+		_, err := NewParser(mockBackend{}).Parse(ing)
+		if testCase.expErr && !errors.IsInvalidConfiguration(err) {
+			t.Errorf("expected an invalid configuration error for %q but returned %v", testCase.snippet, err)
+		}
+		if !testCase.expErr && err != nil {
+			t.Errorf("unexpected error for %q: %v", testCase.snippet, err)
+		}
+	}
+}
+
+func TestParseRobotsLocation(t *testing.T) {
+	annotation := parser.GetAnnotationWithPrefix("server-snippet")
+
+	ing := &networking.Ingress{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "foo",
+			Namespace: api.NamespaceDefault,
+		},
+		Spec: networking.IngressSpec{},
+	}
+
+	// the built-in location can be omitted by another ingress of the host
+	ing.SetAnnotations(map[string]string{
+		annotation: "location /robots.txt { return 200; }",
+	})
+	if _, err := NewParser(mockBackend{}).Parse(ing); err != nil {
+		t.Errorf("unexpected error defining /robots.txt: %v", err)
+	}
+}
+
+func TestParseDefaultReservedLocations(t *testing.T) {
+	annotation := parser.GetAnnotationWithPrefix("server-snippet")
+
+	ing := &networking.Ingress{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "foo",
+			Namespace: api.NamespaceDefault,
+		},
+		Spec: networking.IngressSpec{},
+	}
+
+	// the health and status locations are rendered only in the default server
+	ing.SetAnnotations(map[string]string{
+		annotation: "location /healthz { return 200; }",
+	})
+	if _, err := NewParser(&resolver.Mock{}).Parse(ing); err != nil {
+		t.Errorf("unexpected error defining /healthz: %v", err)
+	}
+}
+
+func TestValidateReservedLocations(t *testing.T) {
+	snippet := "location /robots.txt { return 200; }"
+
+	if err := ValidateReservedLocations(snippet, []string{"/robots.txt"}); !errors.IsInvalidConfiguration(err) {
+		t.Errorf("expected an invalid configuration error for /robots.txt but returned %v", err)
+	}
+	if err := ValidateReservedLocations(snippet, nil); err != nil {
+		t.Errorf("unexpected error without reserved locations: %v", err)
+	}
+}
@@ -32,6 +32,11 @@ func NewParser(r resolver.Resolver) parser.IngressAnnotation {
 	return serviceUpstream{r}
 }
 
+// Keys returns the keys of the annotations read by the parser
+func (s serviceUpstream) Keys() []string {
+	return []string{"service-upstream"}
+}
+
 func (s serviceUpstream) Parse(ing *networking.Ingress) (interface{}, error) {
 	return parser.GetBoolAnnotation("service-upstream", ing)
 }
@@ -18,10 +18,12 @@ package sessionaffinity
 
 import (
 	"regexp"
+	"strings"
 
 	networking "k8s.io/api/networking/v1"
 	"k8s.io/klog"
 
+	"k8s.io/ingress-nginx/internal/ingress/annotations/authreq"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
 	"k8s.io/ingress-nginx/internal/ingress/resolver"
 )
@@ -54,10 +56,21 @@ const (
 
 	// This is used to control the cookie change after request failure
 	annotationAffinityCookieChangeOnFailure = "session-cookie-change-on-failure"
+
+	// If a request header with this name exists,
+	// its value is hashed to pick one of the available backends.
+	annotationAffinityHeaderName = "session-header-name"
 )
 
 var (
 	affinityCookieExpiresRegex = regexp.MustCompile(`(^0|-?[1-9]\d*$)`)
+
+	// allowed values of the SameSite cookie attribute, indexed by their lowercase form
+	affinityCookieSameSiteValues = map[string]string{
+		"strict": "Strict",
+		"lax":    "Lax",
+		"none":   "None",
+	}
 )
 
 // Config describes the per ingress session affinity config
@@ -67,6 +80,7 @@ type Config struct {
 	// The affinity mode, i.e. how sticky a session is
 	Mode string `json:"mode"`
 	Cookie
+	Header Header `json:"header"`
 }
 
 // Cookie describes the Config of cookie type affinity
@@ -87,6 +101,12 @@ type Cookie struct {
 	ConditionalSameSiteNone bool `json:"conditional-samesite-none"`
 }
 
+// Header describes the Config of header type affinity
+type Header struct {
+	// The name of the request header that will be used in case of header affinity type.
+	Name string `json:"name"`
+}
+
 // cookieAffinityParse gets the annotation values related to Cookie Affinity
 // It also sets default values when no value or incorrect value is found
 func (a affinity) cookieAffinityParse(ing *networking.Ingress) *Cookie {
@@ -120,6 +140,12 @@ func (a affinity) cookieAffinityParse(ing *networking.Ingress) *Cookie {
 	cookie.SameSite, err = parser.GetStringAnnotation(annotationAffinityCookieSameSite, ing)
 	if err != nil {
 		klog.V(3).Infof("Invalid or no annotation value found in Ingress %v: %v. Ignoring it", ing.Name, annotationAffinityCookieSameSite)
+	} else if sameSite, ok := affinityCookieSameSiteValues[strings.ToLower(cookie.SameSite)]; ok {
+		cookie.SameSite = sameSite
+	} else {
+		klog.Warningf("Invalid annotation value %q found in Ingress %v: %v. Allowed values are Strict, Lax and None. Ignoring it",
+			cookie.SameSite, ing.Name, annotationAffinityCookieSameSite)
+		cookie.SameSite = ""
 	}
 
 	cookie.ConditionalSameSiteNone, err = parser.GetBoolAnnotation(annotationAffinityCookieConditionalSameSiteNone, ing)
@@ -140,6 +166,21 @@ func (a affinity) cookieAffinityParse(ing *networking.Ingress) *Cookie {
 	return cookie
 }
 
+// headerAffinityParse gets the annotation values related to Header Affinity
+func (a affinity) headerAffinityParse(ing *networking.Ingress) *Header {
+	var err error
+
+	header := &Header{}
+
+	header.Name, err = parser.GetStringAnnotation(annotationAffinityHeaderName, ing)
+	if err != nil || !authreq.ValidHeader(header.Name) {
+		klog.Warningf("Invalid or no annotation value found in Ingress %v: %v. Ignoring it", ing.Name, annotationAffinityHeaderName)
+		header.Name = ""
+	}
+
+	return header
+}
+
 // NewParser creates a new Affinity annotation parser
 func NewParser(r resolver.Resolver) parser.IngressAnnotation {
 	return affinity{r}
@@ -149,10 +190,27 @@ type affinity struct {
 	r resolver.Resolver
 }
 
+// Keys returns the keys of the annotations read by the parser
+func (a affinity) Keys() []string {
+	return []string{
+		annotationAffinityCookieName,
+		annotationAffinityCookieExpires,
+		annotationAffinityCookieMaxAge,
+		annotationAffinityCookiePath,
+		annotationAffinityCookieSameSite,
+		annotationAffinityCookieConditionalSameSiteNone,
+		annotationAffinityCookieChangeOnFailure,
+		annotationAffinityHeaderName,
+		annotationAffinityType,
+		annotationAffinityMode,
+	}
+}
+
 // ParseAnnotations parses the annotations contained in the ingress
 // rule used to configure the affinity directives
 func (a affinity) Parse(ing *networking.Ingress) (interface{}, error) {
 	cookie := &Cookie{}
+	header := &Header{}
 	// Check the type of affinity that will be used
 	at, err := parser.GetStringAnnotation(annotationAffinityType, ing)
 	if err != nil {
@@ -168,6 +226,8 @@ func (a affinity) Parse(ing *networking.Ingress) (interface{}, error) {
 	switch at {
 	case "cookie":
 		cookie = a.cookieAffinityParse(ing)
+	case "header":
+		header = a.headerAffinityParse(ing)
 	default:
 		klog.V(3).Infof("No default affinity was found for Ingress %v", ing.Name)
 
@@ -177,5 +237,6 @@ func (a affinity) Parse(ing *networking.Ingress) (interface{}, error) {
 		Type:   at,
 		Mode:   am,
 		Cookie: *cookie,
+		Header: *header,
 	}, nil
 }
@@ -113,3 +113,73 @@ func TestIngressAffinityCookieConfig(t *testing.T) {
 		t.Errorf("expected change of failure parameter set to true but returned %v", nginxAffinity.Cookie.ChangeOnFailure)
 	}
 }
+
+func TestIngressAffinityHeaderConfig(t *testing.T) {
+	ing := buildIngress()
+
+	data := map[string]string{}
+	data[parser.GetAnnotationWithPrefix(annotationAffinityType)] = "header"
+	data[parser.GetAnnotationWithPrefix(annotationAffinityHeaderName)] = "X-User-Id"
+	ing.SetAnnotations(data)
+
+	affin, _ := NewParser(&resolver.Mock{}).Parse(ing)
+	nginxAffinity, ok := affin.(*Config)
+	if !ok {
+		t.Errorf("expected a Config type")
+	}
+
+	if nginxAffinity.Type != "header" {
+		t.Errorf("expected header as affinity but returned %v", nginxAffinity.Type)
+	}
+
+	if nginxAffinity.Header.Name != "X-User-Id" {
+		t.Errorf("expected X-User-Id as session-header-name but returned %v", nginxAffinity.Header.Name)
+	}
+
+	data[parser.GetAnnotationWithPrefix(annotationAffinityHeaderName)] = "X-User Id"
+	ing.SetAnnotations(data)
+
+	affin, _ = NewParser(&resolver.Mock{}).Parse(ing)
+	nginxAffinity = affin.(*Config)
+	if nginxAffinity.Header.Name != "" {
+		t.Errorf("expected invalid session-header-name to be ignored but returned %v", nginxAffinity.Header.Name)
+	}
+}
+
+func TestIngressAffinityCookieSameSite(t *testing.T) {
+	testCases := []struct {
+		title    string
+		sameSite string
+		expected string
+	}{
+		{"strict", "Strict", "Strict"},
+		{"lax", "Lax", "Lax"},
+		{"none", "None", "None"},
+		{"lowercase", "strict", "Strict"},
+		{"uppercase", "LAX", "Lax"},
+		{"typo", "Strictt", ""},
+		{"unknown value", "Always", ""},
+		{"empty", "", ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.title, func(t *testing.T) {
+			ing := buildIngress()
+
+			data := map[string]string{}
+			data[parser.GetAnnotationWithPrefix(annotationAffinityType)] = "cookie"
+			data[parser.GetAnnotationWithPrefix(annotationAffinityCookieSameSite)] = tc.sameSite
+			ing.SetAnnotations(data)
+
+			affin, _ := NewParser(&resolver.Mock{}).Parse(ing)
+			nginxAffinity, ok := affin.(*Config)
+			if !ok {
+				t.Fatalf("expected a Config type")
+			}
+
+			if nginxAffinity.Cookie.SameSite != tc.expected {
+				t.Errorf("expected %q as session-cookie-samesite but returned %q", tc.expected, nginxAffinity.Cookie.SameSite)
+			}
+		})
+	}
+}
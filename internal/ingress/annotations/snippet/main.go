@@ -32,6 +32,11 @@ func NewParser(r resolver.Resolver) parser.IngressAnnotation {
 	return snippet{r}
 }
 
+// Keys returns the keys of the annotations read by the parser
+func (a snippet) Keys() []string {
+	return []string{"configuration-snippet"}
+}
+
 // Parse parses the annotations contained in the ingress rule
 // used to indicate if the location/s contains a fragment of
 // configuration to be included inside the paths of the rules
@@ -17,12 +17,18 @@ limitations under the License.
 package sslcipher
 
 import (
+	"regexp"
+
 	networking "k8s.io/api/networking/v1"
 
 	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	ing_errors "k8s.io/ingress-nginx/internal/ingress/errors"
 	"k8s.io/ingress-nginx/internal/ingress/resolver"
 )
 
+// tls13CiphersRegex matches a colon separated list of TLS 1.3 ciphersuites
+var tls13CiphersRegex = regexp.MustCompile(`^[A-Za-z0-9_]+(:[A-Za-z0-9_]+)*$`)
+
 type sslCipher struct {
 	r resolver.Resolver
 }
@@ -32,8 +38,42 @@ func NewParser(r resolver.Resolver) parser.IngressAnnotation {
 	return sslCipher{r}
 }
 
+// Keys returns the keys of the annotations read by the parser
+func (sc sslCipher) Keys() []string {
+	return []string{"ssl-ciphers"}
+}
+
 // Parse parses the annotations contained in the ingress rule
 // used to add ssl-ciphers to the server name
 func (sc sslCipher) Parse(ing *networking.Ingress) (interface{}, error) {
 	return parser.GetStringAnnotation("ssl-ciphers", ing)
 }
+
+type sslCipherTLS13 struct {
+	r resolver.Resolver
+}
+
+// NewTLS13Parser creates a new TLS 1.3 sslCipher annotation parser
+func NewTLS13Parser(r resolver.Resolver) parser.IngressAnnotation {
+	return sslCipherTLS13{r}
+}
+
+// Keys returns the keys of the annotations read by the parser
+func (sc sslCipherTLS13) Keys() []string {
+	return []string{"ssl-ciphers-tls13"}
+}
+
+// Parse parses the annotations contained in the ingress rule
+// used to add ssl-ciphers-tls13 to the server name
+func (sc sslCipherTLS13) Parse(ing *networking.Ingress) (interface{}, error) {
+	ciphers, err := parser.GetStringAnnotation("ssl-ciphers-tls13", ing)
+	if err != nil {
+		return "", err
+	}
+
+	if ciphers != "" && !tls13CiphersRegex.MatchString(ciphers) {
+		return "", ing_errors.NewInvalidAnnotationContent("ssl-ciphers-tls13", ciphers)
+	}
+
+	return ciphers, nil
+}
@@ -61,3 +61,43 @@ func TestParse(t *testing.T) {
 		}
 	}
 }
+
+func TestParseTLS13(t *testing.T) {
+	annotation := parser.GetAnnotationWithPrefix("ssl-ciphers-tls13")
+	ap := NewTLS13Parser(&resolver.Mock{})
+	if ap == nil {
+		t.Fatalf("expected a parser.IngressAnnotation but returned nil")
+	}
+
+	testCases := []struct {
+		annotations map[string]string
+		expected    string
+		expErr      bool
+	}{
+		{map[string]string{annotation: "TLS_AES_256_GCM_SHA384:TLS_CHACHA20_POLY1305_SHA256"}, "TLS_AES_256_GCM_SHA384:TLS_CHACHA20_POLY1305_SHA256", false},
+		{map[string]string{annotation: "TLS_AES_128_GCM_SHA256"}, "TLS_AES_128_GCM_SHA256", false},
+		{map[string]string{annotation: "TLS_AES_128_GCM_SHA256; foo"}, "", true},
+		{map[string]string{annotation: ""}, "", true},
+		{map[string]string{}, "", true},
+		{nil, "", true},
+	}
+
+	ing := &networking.Ingress{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "foo",
+			Namespace: api.NamespaceDefault,
+		},
+		Spec: networking.IngressSpec{},
+	}
+
+	for _, testCase := range testCases {
+		ing.SetAnnotations(testCase.annotations)
+		result, err := ap.Parse(ing)
+		if (err != nil) != testCase.expErr {
+			t.Errorf("expected error %v but returned %v, annotations: %s", testCase.expErr, err, testCase.annotations)
+		}
+		if result != testCase.expected {
+			t.Errorf("expected %v but returned %v, annotations: %s", testCase.expected, result, testCase.annotations)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+/*
+Copyright 2023 The Alibaba Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ssldhparam
+
+import (
+	"encoding/pem"
+	"fmt"
+
+	networking "k8s.io/api/networking/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog"
+
+	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	ing_errors "k8s.io/ingress-nginx/internal/ingress/errors"
+	"k8s.io/ingress-nginx/internal/ingress/resolver"
+)
+
+// DHParamKey is the key of the secret containing the DH parameters, like
+// the secret of the ssl-dh-param configmap key
+const DHParamKey = "dhparam.pem"
+
+// Config contains the DH parameters file of a server
+type Config struct {
+	// Secret is the namespace/name of the secret the DH parameters are read from
+	Secret string `json:"secret"`
+	// FileName is the path of the DH parameters file
+	FileName string `json:"fileName"`
+	// SHA is the SHA1 hash of the DH parameters file
+	SHA string `json:"sha"`
+}
+
+// Equal tests for equality between two Config types
+func (c1 *Config) Equal(c2 *Config) bool {
+	if c1 == c2 {
+		return true
+	}
+	if c1 == nil || c2 == nil {
+		return false
+	}
+	if c1.Secret != c2.Secret {
+		return false
+	}
+	if c1.FileName != c2.FileName {
+		return false
+	}
+	if c1.SHA != c2.SHA {
+		return false
+	}
+
+	return true
+}
+
+type sslDHParam struct {
+	r resolver.Resolver
+}
+
+// NewParser creates a new DH parameters annotation parser
+func NewParser(r resolver.Resolver) parser.IngressAnnotation {
+	return sslDHParam{r}
+}
+
+// Keys returns the keys of the annotations read by the parser
+func (a sslDHParam) Keys() []string {
+	return []string{"ssl-dh-param-secret"}
+}
+
+// Parse parses the annotations contained in the ingress rule used to
+// reference a secret with the DH parameters of the server
+func (a sslDHParam) Parse(ing *networking.Ingress) (interface{}, error) {
+	s, err := parser.GetStringAnnotation("ssl-dh-param-secret", ing)
+	if err != nil {
+		return Config{}, err
+	}
+
+	sns, sname, err := cache.SplitMetaNamespaceKey(s)
+	if err != nil || sname == "" {
+		return Config{}, ing_errors.NewInvalidAnnotationContent("ssl-dh-param-secret", s)
+	}
+
+	if sns == "" {
+		sns = ing.Namespace
+	}
+
+	name := fmt.Sprintf("%v/%v", sns, sname)
+	if err := a.validateDHParam(name); err != nil {
+		klog.Warningf("Ignoring ssl-dh-param-secret in Ingress %v/%v: %v", ing.Namespace, ing.Name, err)
+		return Config{}, ing_errors.NewInvalidAnnotationConfiguration("ssl-dh-param-secret", err.Error())
+	}
+
+	return Config{Secret: name}, nil
+}
+
+// validateDHParam checks the secret contains PEM encoded DH parameters.
+// The file is written by the controller, like the global ssl-dh-param.
+func (a sslDHParam) validateDHParam(name string) error {
+	secret, err := a.r.GetSecret(name)
+	if err != nil {
+		return fmt.Errorf("unexpected error reading secret %v: %v", name, err)
+	}
+	if secret == nil {
+		return fmt.Errorf("secret %v not found", name)
+	}
+
+	dh, ok := secret.Data[DHParamKey]
+	if !ok {
+		return fmt.Errorf("secret %v does not contain the key %v", name, DHParamKey)
+	}
+
+	block, _ := pem.Decode(dh)
+	if block == nil || block.Type != "DH PARAMETERS" {
+		return fmt.Errorf("secret %v does not contain valid DH parameters", name)
+	}
+
+	return nil
+}
@@ -0,0 +1,95 @@
+/*
+Copyright 2023 The Alibaba Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ssldhparam
+
+import (
+	"fmt"
+	"testing"
+
+	api "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	"k8s.io/ingress-nginx/internal/ingress/resolver"
+)
+
+const dhParam = `-----BEGIN DH PARAMETERS-----
+MIGHAoGBAOLRXXpmmQ1IqRPoO6Ow9KMuHaq2pDpMJGRVGpiiWaMiHRUBvB6cXPUq
+WiI5mOlJTqj8+U2PjFMQeP+/KGIgU07BEFFNlpGxd1nlJuHRHr1NnJV1NNH17a4c
+G0sRTZcTtEMGx20A0kfw/7T7SaLg5Am/ctpXnQXXpdQwBmWYgqmrAgEC
+-----END DH PARAMETERS-----`
+
+type mockSecret struct {
+	resolver.Mock
+}
+
+func (m mockSecret) GetSecret(name string) (*api.Secret, error) {
+	switch name {
+	case "default/dhparam":
+		return &api.Secret{Data: map[string][]byte{DHParamKey: []byte(dhParam)}}, nil
+	case "other/dhparam":
+		return &api.Secret{Data: map[string][]byte{DHParamKey: []byte(dhParam)}}, nil
+	case "default/no-key":
+		return &api.Secret{Data: map[string][]byte{"tls.crt": []byte(dhParam)}}, nil
+	case "default/invalid":
+		return &api.Secret{Data: map[string][]byte{DHParamKey: []byte("not a PEM block")}}, nil
+	}
+
+	return nil, fmt.Errorf("there is no secret with name %v", name)
+}
+
+func TestParse(t *testing.T) {
+	annotation := parser.GetAnnotationWithPrefix("ssl-dh-param-secret")
+
+	ap := NewParser(mockSecret{})
+	if ap == nil {
+		t.Fatalf("expected a parser.IngressAnnotation but returned nil")
+	}
+
+	testCases := []struct {
+		annotations map[string]string
+		expected    Config
+		expErr      bool
+	}{
+		{map[string]string{annotation: "dhparam"}, Config{Secret: "default/dhparam"}, false},
+		{map[string]string{annotation: "other/dhparam"}, Config{Secret: "other/dhparam"}, false},
+		{map[string]string{annotation: "default/no-key"}, Config{}, true},
+		{map[string]string{annotation: "default/invalid"}, Config{}, true},
+		{map[string]string{annotation: "default/missing"}, Config{}, true},
+		{map[string]string{annotation: "a/b/c"}, Config{}, true},
+		{map[string]string{}, Config{}, true},
+	}
+
+	ing := &networking.Ingress{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "foo",
+			Namespace: api.NamespaceDefault,
+		},
+		Spec: networking.IngressSpec{},
+	}
+
+	for _, testCase := range testCases {
+		ing.SetAnnotations(testCase.annotations)
+		result, err := ap.Parse(ing)
+		if testCase.expErr != (err != nil) {
+			t.Errorf("expected error %v but returned %v, annotations: %s", testCase.expErr, err, testCase.annotations)
+		}
+		if result != testCase.expected {
+			t.Errorf("expected %v but returned %v, annotations: %s", testCase.expected, result, testCase.annotations)
+		}
+	}
+}
@@ -33,6 +33,11 @@ func NewParser(r resolver.Resolver) parser.IngressAnnotation {
 	return sslpt{r}
 }
 
+// Keys returns the keys of the annotations read by the parser
+func (a sslpt) Keys() []string {
+	return []string{"ssl-passthrough"}
+}
+
 // ParseAnnotations parses the annotations contained in the ingress
 // rule used to indicate if is required to configure
 func (a sslpt) Parse(ing *networking.Ingress) (interface{}, error) {
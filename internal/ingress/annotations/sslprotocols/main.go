@@ -17,12 +17,24 @@ limitations under the License.
 package sslprotocols
 
 import (
+	"fmt"
+	"strings"
+
 	networking "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/klog"
 
 	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	ing_errors "k8s.io/ingress-nginx/internal/ingress/errors"
 	"k8s.io/ingress-nginx/internal/ingress/resolver"
 )
 
+// supportedProtocols contains the protocols accepted by the ssl_protocols directive
+var supportedProtocols = sets.NewString("TLSv1", "TLSv1.1", "TLSv1.2", "TLSv1.3")
+
+// insecureProtocols contains the protocols removed from the annotation
+var insecureProtocols = sets.NewString("SSLv2", "SSLv3")
+
 type sslProtocols struct {
 	r resolver.Resolver
 }
@@ -32,8 +44,72 @@ func NewParser(r resolver.Resolver) parser.IngressAnnotation {
 	return sslProtocols{r}
 }
 
+// Keys returns the keys of the annotations read by the parser
+func (sc sslProtocols) Keys() []string {
+	return []string{"ssl-protocols"}
+}
+
 // Parse parses the annotations contained in the ingress rule
 // used to add ssl-protocols to the server name
 func (sc sslProtocols) Parse(ing *networking.Ingress) (interface{}, error) {
-	return parser.GetStringAnnotation("ssl-protocols", ing)
+	protocols, insecure, err := parse(ing)
+	if len(insecure) > 0 {
+		klog.Warningf("Ingress %v/%v: ignoring the insecure protocols %v of ssl-protocols",
+			ing.Namespace, ing.Name, strings.Join(insecure, " "))
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return protocols, nil
+}
+
+// Validate returns an error if the ssl-protocols annotation of the ingress
+// contains an insecure or unknown protocol, so that the admission webhook
+// can reject it
+func Validate(ing *networking.Ingress) error {
+	_, insecure, err := parse(ing)
+	if len(insecure) > 0 {
+		return ing_errors.NewInvalidAnnotationConfiguration("ssl-protocols",
+			fmt.Sprintf("insecure protocols %v are not allowed", strings.Join(insecure, " ")))
+	}
+	if ing_errors.IsLocationDenied(err) {
+		return err
+	}
+
+	return nil
+}
+
+// parse returns the protocols of the annotation without the insecure ones,
+// together with the insecure ones it removes
+func parse(ing *networking.Ingress) (string, []string, error) {
+	protocols, err := parser.GetStringAnnotation("ssl-protocols", ing)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var secure, insecure []string
+	for _, protocol := range strings.Fields(protocols) {
+		if insecureProtocols.Has(protocol) {
+			insecure = append(insecure, protocol)
+			continue
+		}
+
+		// an unknown protocol would make the reload fail
+		if !supportedProtocols.Has(protocol) {
+			return "", insecure, ing_errors.NewLocationDenied(fmt.Sprintf("invalid protocol %v for ssl-protocols", protocol))
+		}
+
+		secure = append(secure, protocol)
+	}
+
+	if len(insecure) == 0 {
+		return protocols, nil, nil
+	}
+
+	if len(secure) == 0 {
+		return "", insecure, ing_errors.NewInvalidAnnotationContent("ssl-protocols", protocols)
+	}
+
+	return strings.Join(secure, " "), insecure, nil
 }
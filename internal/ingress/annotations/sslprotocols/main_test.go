@@ -23,6 +23,7 @@ import (
 	networking "k8s.io/api/networking/v1"
 	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	ing_errors "k8s.io/ingress-nginx/internal/ingress/errors"
 	"k8s.io/ingress-nginx/internal/ingress/resolver"
 )
 
@@ -36,12 +37,19 @@ func TestParse(t *testing.T) {
 	testCases := []struct {
 		annotations map[string]string
 		expected    string
+		expErr      bool
 	}{
-		{map[string]string{annotation: "TLSv1 TLSv1.1 TLSv1.2 TLSv1.3"}, "TLSv1 TLSv1.1 TLSv1.2 TLSv1.3"},
-		{map[string]string{annotation: "TLSv1.3"}, "TLSv1.3"},
-		{map[string]string{annotation: ""}, ""},
-		{map[string]string{}, ""},
-		{nil, ""},
+		{map[string]string{annotation: "TLSv1 TLSv1.1 TLSv1.2 TLSv1.3"}, "TLSv1 TLSv1.1 TLSv1.2 TLSv1.3", false},
+		{map[string]string{annotation: "TLSv1.3"}, "TLSv1.3", false},
+		{map[string]string{annotation: "TLSv1.2  TLSv1.3"}, "TLSv1.2  TLSv1.3", false},
+		{map[string]string{annotation: "TLSv1.2 TLSv1.4"}, "", true},
+		{map[string]string{annotation: "SSLv3"}, "", true},
+		{map[string]string{annotation: "SSLv2 TLSv1.2 SSLv3 TLSv1.3"}, "TLSv1.2 TLSv1.3", false},
+		{map[string]string{annotation: "SSLv3 TLSv1.4"}, "", true},
+		{map[string]string{annotation: "TLSv1.2; return 200"}, "", true},
+		{map[string]string{annotation: ""}, "", true},
+		{map[string]string{}, "", true},
+		{nil, "", true},
 	}
 
 	ing := &networking.Ingress{
@@ -54,9 +62,50 @@ func TestParse(t *testing.T) {
 
 	for _, testCase := range testCases {
 		ing.SetAnnotations(testCase.annotations)
-		result, _ := ap.Parse(ing)
+		result, err := ap.Parse(ing)
+		if testCase.expErr != (err != nil) {
+			t.Errorf("expected error %v but returned %v, annotations: %s", testCase.expErr, err, testCase.annotations)
+		}
 		if result != testCase.expected {
 			t.Errorf("expected %v but returned %v, annotations: %s", testCase.expected, result, testCase.annotations)
 		}
 	}
 }
+
+func TestValidate(t *testing.T) {
+	annotation := parser.GetAnnotationWithPrefix("ssl-protocols")
+
+	testCases := []struct {
+		annotations map[string]string
+		expErr      bool
+	}{
+		{map[string]string{annotation: "TLSv1.2 TLSv1.3"}, false},
+		{map[string]string{annotation: "SSLv3"}, true},
+		{map[string]string{annotation: "SSLv2 TLSv1.2"}, true},
+		{map[string]string{annotation: "TLSv1.2 TLSv1.4"}, true},
+		{map[string]string{annotation: ""}, false},
+		{nil, false},
+	}
+
+	ing := &networking.Ingress{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "foo",
+			Namespace: api.NamespaceDefault,
+		},
+		Spec: networking.IngressSpec{},
+	}
+
+	for _, testCase := range testCases {
+		ing.SetAnnotations(testCase.annotations)
+		err := Validate(ing)
+		if testCase.expErr != (err != nil) {
+			t.Errorf("expected error %v but returned %v, annotations: %s", testCase.expErr, err, testCase.annotations)
+		}
+	}
+
+	// the insecure protocols are only ignored by the parser
+	ing.SetAnnotations(map[string]string{annotation: "SSLv3"})
+	if _, err := NewParser(&resolver.Mock{}).Parse(ing); ing_errors.IsLocationDenied(err) {
+		t.Errorf("expected the insecure protocols not to deny the locations but returned %v", err)
+	}
+}
@@ -0,0 +1,58 @@
+/*
+Copyright 2023 The Alibaba Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package upstreamendpointfilter
+
+import (
+	networking "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/klog"
+
+	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	ing_errors "k8s.io/ingress-nginx/internal/ingress/errors"
+	"k8s.io/ingress-nginx/internal/ingress/resolver"
+)
+
+type upstreamEndpointFilter struct {
+	r resolver.Resolver
+}
+
+// NewParser creates a new upstream endpoint filter annotation parser
+func NewParser(r resolver.Resolver) parser.IngressAnnotation {
+	return upstreamEndpointFilter{r}
+}
+
+// Keys returns the keys of the annotations read by the parser
+func (a upstreamEndpointFilter) Keys() []string {
+	return []string{"upstream-endpoint-filter"}
+}
+
+// Parse parses the annotations contained in the ingress rule
+// used to only use the endpoints of the pods matching a label selector.
+// It returns an empty string when the annotation is not set.
+func (a upstreamEndpointFilter) Parse(ing *networking.Ingress) (interface{}, error) {
+	s, err := parser.GetStringAnnotation("upstream-endpoint-filter", ing)
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := labels.Parse(s); err != nil {
+		klog.Warningf("Ignoring upstream-endpoint-filter %q in Ingress %v/%v: %v", s, ing.Namespace, ing.Name, err)
+		return "", ing_errors.NewInvalidAnnotationContent("upstream-endpoint-filter", s)
+	}
+
+	return s, nil
+}
@@ -17,12 +17,23 @@ limitations under the License.
 package upstreamhashby
 
 import (
+	"regexp"
+
 	networking "k8s.io/api/networking/v1"
+	"k8s.io/klog"
 
 	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	ing_errors "k8s.io/ingress-nginx/internal/ingress/errors"
 	"k8s.io/ingress-nginx/internal/ingress/resolver"
 )
 
+// defaultSubsetSize is the number of endpoints of a subset when upstream-hash-by-subset-size is not set
+const defaultSubsetSize = 3
+
+// hashByRegex matches a single NGINX variable, like $request_uri or $cookie_user,
+// the only hash key the consistent hash balancers of lua are able to read
+var hashByRegex = regexp.MustCompile(`^\$[a-zA-Z0-9_]+$`)
+
 type upstreamhashby struct {
 	r resolver.Resolver
 }
@@ -39,14 +50,28 @@ func NewParser(r resolver.Resolver) parser.IngressAnnotation {
 	return upstreamhashby{r}
 }
 
+// Keys returns the keys of the annotations read by the parser
+func (a upstreamhashby) Keys() []string {
+	return []string{"upstream-hash-by", "upstream-hash-by-subset", "upstream-hash-by-subset-size"}
+}
+
 // Parse parses the annotations contained in the ingress rule
 func (a upstreamhashby) Parse(ing *networking.Ingress) (interface{}, error) {
 	upstreamHashBy, _ := parser.GetStringAnnotation("upstream-hash-by", ing)
+	if upstreamHashBy != "" && !hashByRegex.MatchString(upstreamHashBy) {
+		klog.Warningf("Ignoring upstream-hash-by %q in Ingress %v/%v: expected a single NGINX variable like $request_uri", upstreamHashBy, ing.Namespace, ing.Name)
+		return &Config{}, ing_errors.NewInvalidAnnotationContent("upstream-hash-by", upstreamHashBy)
+	}
+
 	upstreamHashBySubset, _ := parser.GetBoolAnnotation("upstream-hash-by-subset", ing)
-	upstreamHashbySubsetSize, _ := parser.GetIntAnnotation("upstream-hash-by-subset-size", ing)
 
-	if upstreamHashbySubsetSize == 0 {
-		upstreamHashbySubsetSize = 3
+	upstreamHashbySubsetSize, err := parser.GetIntAnnotation("upstream-hash-by-subset-size", ing)
+	if ing_errors.IsMissingAnnotations(err) {
+		upstreamHashbySubsetSize = defaultSubsetSize
+	} else if err != nil || upstreamHashbySubsetSize < 1 {
+		size, _ := parser.GetStringAnnotation("upstream-hash-by-subset-size", ing)
+		klog.Warningf("Ignoring upstream-hash-by-subset-size %q in Ingress %v/%v: expected a number greater than 0", size, ing.Namespace, ing.Name)
+		return &Config{}, ing_errors.NewInvalidAnnotationContent("upstream-hash-by-subset-size", size)
 	}
 
 	return &Config{upstreamHashBy, upstreamHashBySubset, upstreamHashbySubsetSize}, nil
@@ -39,7 +39,8 @@ func TestParse(t *testing.T) {
 		expected    string
 	}{
 		{map[string]string{annotation: "$request_uri"}, "$request_uri"},
-		{map[string]string{annotation: "false"}, "false"},
+		{map[string]string{annotation: "$cookie_user"}, "$cookie_user"},
+		{map[string]string{annotation: "$http_x_user_id"}, "$http_x_user_id"},
 		{map[string]string{}, ""},
 		{nil, ""},
 	}
@@ -65,3 +66,82 @@ func TestParse(t *testing.T) {
 		}
 	}
 }
+
+func TestParseInvalid(t *testing.T) {
+	ap := NewParser(&resolver.Mock{})
+
+	ing := &networking.Ingress{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "foo",
+			Namespace: api.NamespaceDefault,
+		},
+		Spec: networking.IngressSpec{},
+	}
+
+	testCases := []struct {
+		annotations map[string]string
+		expErr      bool
+	}{
+		{map[string]string{"upstream-hash-by": "false"}, true},
+		{map[string]string{"upstream-hash-by": "request_uri"}, true},
+		{map[string]string{"upstream-hash-by": "$host$request_uri"}, true},
+		{map[string]string{"upstream-hash-by": "$request_uri; rm -rf /"}, true},
+		{map[string]string{"upstream-hash-by": "$(whoami)"}, true},
+		{map[string]string{"upstream-hash-by": "$remote_addr", "upstream-hash-by-subset-size": "0"}, true},
+		{map[string]string{"upstream-hash-by": "$remote_addr", "upstream-hash-by-subset-size": "-1"}, true},
+		{map[string]string{"upstream-hash-by": "$remote_addr", "upstream-hash-by-subset-size": "three"}, true},
+		{map[string]string{"upstream-hash-by": "$remote_addr", "upstream-hash-by-subset-size": "5"}, false},
+	}
+
+	for _, testCase := range testCases {
+		annotations := map[string]string{}
+		for k, v := range testCase.annotations {
+			annotations[parser.GetAnnotationWithPrefix(k)] = v
+		}
+		ing.SetAnnotations(annotations)
+
+		_, err := ap.Parse(ing)
+		if testCase.expErr && err == nil {
+			t.Errorf("expected an error with the annotations %v", testCase.annotations)
+		}
+		if !testCase.expErr && err != nil {
+			t.Errorf("unexpected error with the annotations %v: %v", testCase.annotations, err)
+		}
+	}
+}
+
+func TestParseSubsetSize(t *testing.T) {
+	ap := NewParser(&resolver.Mock{})
+
+	ing := &networking.Ingress{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "foo",
+			Namespace: api.NamespaceDefault,
+		},
+		Spec: networking.IngressSpec{},
+	}
+
+	testCases := []struct {
+		annotations map[string]string
+		expected    int
+	}{
+		{map[string]string{"upstream-hash-by": "$request_uri"}, 3},
+		{map[string]string{"upstream-hash-by": "$request_uri", "upstream-hash-by-subset-size": "5"}, 5},
+	}
+
+	for _, testCase := range testCases {
+		annotations := map[string]string{}
+		for k, v := range testCase.annotations {
+			annotations[parser.GetAnnotationWithPrefix(k)] = v
+		}
+		ing.SetAnnotations(annotations)
+
+		result, err := ap.Parse(ing)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if size := result.(*Config).UpstreamHashBySubsetSize; size != testCase.expected {
+			t.Errorf("expected %v as subset size but returned %v, annotations: %v", testCase.expected, size, testCase.annotations)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+/*
+Copyright 2023 The Alibaba Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package upstreamkeepalive
+
+import (
+	networking "k8s.io/api/networking/v1"
+
+	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	ing_errors "k8s.io/ingress-nginx/internal/ingress/errors"
+	"k8s.io/ingress-nginx/internal/ingress/resolver"
+)
+
+const (
+	connectionsAnnotation = "upstream-keepalive-connections"
+	timeoutAnnotation     = "upstream-keepalive-timeout"
+	requestsAnnotation    = "upstream-keepalive-requests"
+)
+
+// Config contains the keepalive configuration of the upstream connections.
+// A zero value means the global configuration is used.
+type Config struct {
+	// Connections is the maximum number of idle keepalive connections
+	// to the upstream servers cached by every worker
+	Connections int `json:"connections,omitempty"`
+	// Timeout is the time in seconds an idle keepalive connection stays open
+	Timeout int `json:"timeout,omitempty"`
+	// Requests is the maximum number of requests served through one keepalive connection
+	Requests int `json:"requests,omitempty"`
+}
+
+// Equal tests for equality between two Config types
+func (c1 *Config) Equal(c2 *Config) bool {
+	if c1 == c2 {
+		return true
+	}
+	if c1 == nil || c2 == nil {
+		return false
+	}
+
+	return *c1 == *c2
+}
+
+type upstreamKeepalive struct {
+	r resolver.Resolver
+}
+
+// NewParser creates a new upstream keepalive annotation parser
+func NewParser(r resolver.Resolver) parser.IngressAnnotation {
+	return upstreamKeepalive{r}
+}
+
+// Keys returns the keys of the annotations read by the parser
+func (a upstreamKeepalive) Keys() []string {
+	return []string{connectionsAnnotation, timeoutAnnotation, requestsAnnotation}
+}
+
+// Parse parses the annotations contained in the ingress rule used to
+// configure the keepalive connections to the upstream servers
+func (a upstreamKeepalive) Parse(ing *networking.Ingress) (interface{}, error) {
+	config := Config{}
+
+	for _, annotation := range a.Keys() {
+		val, err := parser.GetIntAnnotation(annotation, ing)
+		if err != nil {
+			if ing_errors.IsMissingAnnotations(err) {
+				continue
+			}
+			return Config{}, err
+		}
+
+		if val < 0 {
+			return Config{}, ing_errors.NewInvalidAnnotationContent(annotation, val)
+		}
+
+		switch annotation {
+		case connectionsAnnotation:
+			config.Connections = val
+		case timeoutAnnotation:
+			config.Timeout = val
+		case requestsAnnotation:
+			config.Requests = val
+		}
+	}
+
+	return config, nil
+}
@@ -32,6 +32,11 @@ func NewParser(r resolver.Resolver) parser.IngressAnnotation {
 	return xforwardedprefix{r}
 }
 
+// Keys returns the keys of the annotations read by the parser
+func (cbbs xforwardedprefix) Keys() []string {
+	return []string{"x-forwarded-prefix"}
+}
+
 // Parse parses the annotations contained in the ingress rule
 // used to add an x-forwarded-prefix header to the request
 func (cbbs xforwardedprefix) Parse(ing *networking.Ingress) (interface{}, error) {
@@ -0,0 +1,57 @@
+/*
+Copyright 2023 The Alibaba Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package xforwardedproto
+
+import (
+	networking "k8s.io/api/networking/v1"
+	"k8s.io/klog"
+
+	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	ing_errors "k8s.io/ingress-nginx/internal/ingress/errors"
+	"k8s.io/ingress-nginx/internal/ingress/resolver"
+)
+
+type xforwardedproto struct {
+	r resolver.Resolver
+}
+
+// NewParser creates a new X-Forwarded-Proto override annotation parser
+func NewParser(r resolver.Resolver) parser.IngressAnnotation {
+	return xforwardedproto{r}
+}
+
+// Keys returns the keys of the annotations read by the parser
+func (a xforwardedproto) Keys() []string {
+	return []string{"x-forwarded-proto-override"}
+}
+
+// Parse parses the annotations contained in the ingress rule
+// used to force the value of the X-Forwarded-Proto header passed upstream.
+// It returns an empty string when the annotation is not set.
+func (a xforwardedproto) Parse(ing *networking.Ingress) (interface{}, error) {
+	s, err := parser.GetStringAnnotation("x-forwarded-proto-override", ing)
+	if err != nil {
+		return "", err
+	}
+
+	if s != "http" && s != "https" {
+		klog.Warningf("Ignoring x-forwarded-proto-override %q in Ingress %v/%v: expected http or https", s, ing.Namespace, ing.Name)
+		return "", ing_errors.NewInvalidAnnotationContent("x-forwarded-proto-override", s)
+	}
+
+	return s, nil
+}
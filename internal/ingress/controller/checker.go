@@ -22,9 +22,15 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/ncabatoff/process-exporter/proc"
 	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apiserver/pkg/server/healthz"
+	"k8s.io/client-go/discovery"
+	"k8s.io/klog"
 
 	"k8s.io/ingress-nginx/internal/nginx"
 )
@@ -40,6 +46,29 @@ func (n *NGINXController) Check(_ *http.Request) error {
 		return fmt.Errorf("the ingress controller is shutting down")
 	}
 
+	if n.IsDraining() {
+		return fmt.Errorf("the ingress controller is draining")
+	}
+
+	return n.checkNGINX()
+}
+
+// LivenessChecker returns the check of the nginx process only, which keeps
+// passing while the controller drains, so the liveness probe does not
+// restart the pod after /wait-shutdown
+func (n *NGINXController) LivenessChecker() healthz.HealthChecker {
+	return healthz.NamedCheck(n.Name(), func(_ *http.Request) error {
+		if n.isShuttingDown {
+			return nil
+		}
+
+		return n.checkNGINX()
+	})
+}
+
+// checkNGINX returns if the nginx master process is running and its
+// healthz and dynamic load balancer endpoints are returning ok
+func (n *NGINXController) checkNGINX() error {
 	// check the nginx master process is running
 	fs, err := proc.NewFS("/proc", false)
 	if err != nil {
@@ -81,3 +110,113 @@ func (n *NGINXController) Check(_ *http.Request) error {
 
 	return nil
 }
+
+const (
+	// StorageClusterHealthzDisabled does not check the storage cluster
+	StorageClusterHealthzDisabled = "disabled"
+	// StorageClusterHealthzDegraded logs the failures of the storage cluster
+	// check without failing the health check
+	StorageClusterHealthzDegraded = "degraded"
+	// StorageClusterHealthzFatal fails the health check when the storage
+	// cluster is unreachable
+	StorageClusterHealthzFatal = "fatal"
+
+	// storageClusterCheckTimeout is the timeout of the version request to the storage cluster
+	storageClusterCheckTimeout = 5 * time.Second
+	// storageClusterCheckInterval is the interval between the checks of the storage cluster
+	storageClusterCheckInterval = 10 * time.Second
+)
+
+// StorageClusterChecker checks the connectivity to the dedicated cluster
+// storing the ingresses, the secrets and their checksums, requesting its
+// version. The cluster is checked in the background by Run, so probes only
+// read the last result and neither load nor wait for the cluster.
+type StorageClusterChecker struct {
+	clients  map[string]discovery.ServerVersionInterface
+	fatal    bool
+	timeout  time.Duration
+	interval time.Duration
+
+	mu  sync.Mutex
+	err error
+}
+
+// NewStorageClusterChecker returns the checker of the storage cluster clients
+// of the configuration, or nil if its StorageClusterHealthz is disabled
+func NewStorageClusterChecker(config *Configuration) *StorageClusterChecker {
+	if config.StorageClusterHealthz == StorageClusterHealthzDisabled {
+		return nil
+	}
+
+	clients := make(map[string]discovery.ServerVersionInterface)
+	if config.ClientIng != nil {
+		clients["ingress"] = config.ClientIng.Discovery()
+	}
+	if config.ClientIngCheck != nil {
+		clients["ingress checksum"] = config.ClientIngCheck.Discovery()
+	}
+
+	return &StorageClusterChecker{
+		clients:  clients,
+		fatal:    config.StorageClusterHealthz == StorageClusterHealthzFatal,
+		timeout:  storageClusterCheckTimeout,
+		interval: storageClusterCheckInterval,
+	}
+}
+
+// Name returns the healthcheck name
+func (c *StorageClusterChecker) Name() string {
+	return "storage-cluster"
+}
+
+// Check returns the error of the last check of the storage cluster, only
+// in the fatal mode
+func (c *StorageClusterChecker) Check(_ *http.Request) error {
+	if !c.fatal {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.err
+}
+
+// Run checks the storage cluster every interval until stopCh is closed
+func (c *StorageClusterChecker) Run(stopCh <-chan struct{}) {
+	wait.Until(c.refresh, c.interval, stopCh)
+}
+
+// refresh checks the storage cluster and saves the result for Check
+func (c *StorageClusterChecker) refresh() {
+	err := c.check()
+	if err != nil && !c.fatal {
+		klog.Warningf("The storage cluster is degraded: %v", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.err = err
+}
+
+func (c *StorageClusterChecker) check() error {
+	for name, client := range c.clients {
+		errCh := make(chan error, 1)
+		go func(client discovery.ServerVersionInterface) {
+			_, err := client.ServerVersion()
+			errCh <- err
+		}(client)
+
+		select {
+		case err := <-errCh:
+			if err != nil {
+				return errors.Wrapf(err, "checking the %v client of the storage cluster", name)
+			}
+		case <-time.After(c.timeout):
+			return fmt.Errorf("checking the %v client of the storage cluster: timed out after %v", name, c.timeout)
+		}
+	}
+
+	return nil
+}
@@ -24,8 +24,12 @@ import (
 	"os"
 	"os/exec"
 	"testing"
+	"time"
 
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apiserver/pkg/server/healthz"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
 
 	"k8s.io/ingress-nginx/internal/file"
 	ngx_config "k8s.io/ingress-nginx/internal/ingress/controller/config"
@@ -102,6 +106,7 @@ func TestNginxCheck(t *testing.T) {
 			pidFile.Close()
 
 			healthz.InstallPathHandler(mux, tt.healthzPath, n)
+			healthz.InstallPathHandler(mux, tt.healthzPath+"-live", n.LivenessChecker())
 
 			t.Run("valid request", func(t *testing.T) {
 				if err := callHealthz(false, tt.healthzPath, mux); err != nil {
@@ -109,6 +114,20 @@ func TestNginxCheck(t *testing.T) {
 				}
 			})
 
+			n.Drain()
+
+			t.Run("draining", func(t *testing.T) {
+				if err := callHealthz(true, tt.healthzPath, mux); err == nil {
+					t.Error("expected an error but none returned")
+				}
+			})
+
+			t.Run("liveness while draining", func(t *testing.T) {
+				if err := callHealthz(false, tt.healthzPath+"-live", mux); err != nil {
+					t.Error(err)
+				}
+			})
+
 			// pollute pid file
 			pidFile.Write([]byte(fmt.Sprint("999999")))
 			pidFile.Close()
@@ -145,3 +164,67 @@ func callHealthz(expErr bool, healthzPath string, mux *http.ServeMux) error {
 
 	return nil
 }
+
+func TestStorageClusterChecker(t *testing.T) {
+	if c := NewStorageClusterChecker(&Configuration{StorageClusterHealthz: StorageClusterHealthzDisabled}); c != nil {
+		t.Fatalf("expected no checker when the storage cluster check is disabled")
+	}
+
+	for _, mode := range []string{StorageClusterHealthzDegraded, StorageClusterHealthzFatal} {
+		client := fake.NewSimpleClientset()
+		checker := NewStorageClusterChecker(&Configuration{ClientIng: client, StorageClusterHealthz: mode})
+
+		checker.refresh()
+		if err := checker.Check(nil); err != nil {
+			t.Errorf("%v: expected a reachable storage cluster to be healthy but got %v", mode, err)
+		}
+
+		client.PrependReactor("get", "version", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			return true, nil, fmt.Errorf("connection refused")
+		})
+
+		if err := checker.Check(nil); err != nil {
+			t.Errorf("%v: expected the last result to be returned until the next check but got %v", mode, err)
+		}
+
+		checker.refresh()
+		err := checker.Check(nil)
+		if mode == StorageClusterHealthzFatal && err == nil {
+			t.Errorf("%v: expected an unreachable storage cluster to fail the check", mode)
+		}
+		if mode == StorageClusterHealthzDegraded && err != nil {
+			t.Errorf("%v: expected an unreachable storage cluster not to fail the check but got %v", mode, err)
+		}
+	}
+}
+
+func TestStorageClusterCheckerDoesNotBlock(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	checker := NewStorageClusterChecker(&Configuration{ClientIng: client, StorageClusterHealthz: StorageClusterHealthzFatal})
+	checker.timeout = time.Minute
+
+	unblock := make(chan struct{})
+	defer close(unblock)
+	client.PrependReactor("get", "version", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		<-unblock
+		return true, nil, fmt.Errorf("connection refused")
+	})
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	go checker.Run(stopCh)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- checker.Check(nil)
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("expected the check to return the last result but got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("expected the check not to wait for the storage cluster")
+	}
+}
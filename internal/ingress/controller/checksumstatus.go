@@ -0,0 +1,109 @@
+/*
+Copyright 2023 The Alibaba Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"k8s.io/klog"
+)
+
+// ChecksumState contains the result of the last checksum check of ingresses or secrets
+type ChecksumState struct {
+	// Ready is true when the local checksum matches the checksum objects
+	Ready bool `json:"ready"`
+	// LocalChecksum is the checksum computed from the local objects
+	LocalChecksum string `json:"localChecksum,omitempty"`
+	// ExpectedChecksum is the checksum of the latest checksum object
+	ExpectedChecksum string `json:"expectedChecksum,omitempty"`
+	// LastCheck is the time of the last check
+	LastCheck *time.Time `json:"lastCheck,omitempty"`
+	// LastMismatch is the time of the last check that found a mismatch
+	LastMismatch *time.Time `json:"lastMismatch,omitempty"`
+	// LastError describes the last mismatch
+	LastError string `json:"lastError,omitempty"`
+}
+
+// ChecksumReport contains the checksum status of ingresses and secrets
+type ChecksumReport struct {
+	Ingress ChecksumState `json:"ingress"`
+	Secret  ChecksumState `json:"secret"`
+}
+
+// checksumTracker keeps the details of the checksum checks for the
+// /checksum-status endpoint, which only reads a copy of them.
+type checksumTracker struct {
+	mu     sync.RWMutex
+	report ChecksumReport
+}
+
+func (t *checksumTracker) recordIngress(ready bool, local, expected string, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.report.Ingress.record(ready, local, expected, err)
+}
+
+func (t *checksumTracker) recordSecret(ready bool, local, expected string, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.report.Secret.record(ready, local, expected, err)
+}
+
+func (t *checksumTracker) get() ChecksumReport {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.report
+}
+
+func (s *ChecksumState) record(ready bool, local, expected string, err error) {
+	now := time.Now()
+	s.Ready = ready
+	s.LocalChecksum = local
+	s.ExpectedChecksum = expected
+	s.LastCheck = &now
+	if err != nil {
+		s.LastMismatch = &now
+		s.LastError = err.Error()
+	}
+}
+
+// ChecksumStatus returns a copy of the checksum status of ingresses and secrets
+func (n *NGINXController) ChecksumStatus() ChecksumReport {
+	return n.checksums.get()
+}
+
+// ChecksumStatusHandler serves the checksum status of ingresses and secrets as JSON
+func (n *NGINXController) ChecksumStatusHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if r.Method == http.MethodHead {
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(n.ChecksumStatus()); err != nil {
+		klog.Warningf("Error writing checksum status: %v", err)
+	}
+}
@@ -0,0 +1,75 @@
+/*
+Copyright 2023 The Alibaba Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestChecksumStatusHandler(t *testing.T) {
+	n := &NGINXController{checksums: new(checksumTracker)}
+
+	n.checksums.recordIngress(false, "local", "expected", errors.New("Check Ingress ID: {md5[local]} is wrong"))
+	n.checksums.recordSecret(true, "secret", "secret", nil)
+
+	w := httptest.NewRecorder()
+	n.ChecksumStatusHandler(w, httptest.NewRequest(http.MethodGet, "/checksum-status", nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status code %v but got %v", http.StatusOK, w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("expected application/json content type but got %q", ct)
+	}
+
+	var report ChecksumReport
+	if err := json.Unmarshal(w.Body.Bytes(), &report); err != nil {
+		t.Fatalf("unexpected error decoding the checksum status: %v", err)
+	}
+
+	ing := report.Ingress
+	if ing.Ready || ing.LocalChecksum != "local" || ing.ExpectedChecksum != "expected" {
+		t.Errorf("unexpected ingress checksum status: %+v", ing)
+	}
+	if ing.LastMismatch == nil || ing.LastError == "" {
+		t.Errorf("expected the ingress mismatch to be recorded: %+v", ing)
+	}
+
+	secret := report.Secret
+	if !secret.Ready || secret.LocalChecksum != "secret" || secret.LastCheck == nil {
+		t.Errorf("unexpected secret checksum status: %+v", secret)
+	}
+	if secret.LastMismatch != nil {
+		t.Errorf("expected no secret mismatch but got %v", secret.LastMismatch)
+	}
+
+	// a later match keeps the time of the last mismatch
+	n.checksums.recordIngress(true, "expected", "expected", nil)
+	if s := n.ChecksumStatus().Ingress; !s.Ready || s.LastMismatch == nil {
+		t.Errorf("expected a ready status with the last mismatch but got %+v", s)
+	}
+
+	w = httptest.NewRecorder()
+	n.ChecksumStatusHandler(w, httptest.NewRequest(http.MethodPost, "/checksum-status", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected status code %v but got %v", http.StatusMethodNotAllowed, w.Code)
+	}
+}
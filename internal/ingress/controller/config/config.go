@@ -154,6 +154,11 @@ type Configuration struct {
 	// http://nginx.org/en/docs/http/ngx_http_core_module.html#client_body_timeout
 	ClientBodyTimeout int `json:"client-body-timeout,omitempty"`
 
+	// Defines a timeout for transmitting a response to the client, only
+	// between two successive write operations
+	// http://nginx.org/en/docs/http/ngx_http_core_module.html#send_timeout
+	SendTimeout string `json:"send-timeout,omitempty"`
+
 	// DisableAccessLog disables the Access Log globally from NGINX ingress controller
 	//http://nginx.org/en/docs/http/ngx_http_log_module.html
 	DisableAccessLog bool `json:"disable-access-log,omitempty"`
@@ -470,10 +475,19 @@ type Configuration struct {
 	// Default: false
 	ComputeFullForwardedFor bool `json:"compute-full-forwarded-for,omitempty"`
 
+	// Remove the trailing dot of the Host header, e.g. "foo.bar.com." -> "foo.bar.com",
+	// before it is used to choose the server and sent to the upstream servers
+	// Default: false
+	StripTrailingHostDot bool `json:"strip-trailing-host-dot"`
+
 	// If the request does not have a request-id, should we generate a random value?
 	// Default: true
 	GenerateRequestID bool `json:"generate-request-id,omitempty"`
 
+	// Name of the header with the request-id, read from the request and sent to the backend
+	// Default: X-Request-ID
+	RequestIDHeader string `json:"request-id-header"`
+
 	// Adds an X-Original-Uri header with the original request URI to the backend request
 	// Default: true
 	ProxyAddOriginalURIHeader bool `json:"proxy-add-original-uri-header"`
@@ -596,6 +610,11 @@ type Configuration struct {
 	// Default: empty
 	HideHeaders []string `json:"hide-headers"`
 
+	// DefaultSecurityHeaders adds a default set of security headers to all the responses
+	// unless they are listed in HideHeaders
+	// Default: false
+	DefaultSecurityHeaders bool `json:"default-security-headers"`
+
 	// LimitReqStatusCode Sets the status code to return in response to rejected requests.
 	// http://nginx.org/en/docs/http/ngx_http_limit_req_module.html#limit_req_status
 	// Default: 503
@@ -611,6 +630,15 @@ type Configuration struct {
 	// Default: text/html
 	DefaultType string `json:"default-type"`
 
+	// Serve the 5xx responses of the default backend as a JSON body with the
+	// error and the request-id instead of the page of the default backend
+	// Default: false
+	DefaultBackendJSONErrors bool `json:"default-backend-json-errors"`
+
+	// Content type of the JSON body of the default backend errors
+	// Default: application/json
+	DefBackendJSONErrorsType string `json:"default-backend-json-errors-content-type"`
+
 	// EnableSyslog enables the configuration for remote logging in NGINX
 	EnableSyslog bool `json:"enable-syslog"`
 	// SyslogHost FQDN or IP address where the logs should be sent
@@ -648,6 +676,10 @@ type Configuration struct {
 	// Block all requests with given Referer headers
 	BlockReferers []string `json:"block-referers"`
 
+	// Block all requests from the countries with the given ISO 3166-1 alpha-2
+	// codes. Requires GeoIP2
+	BlockCountries []string `json:"block-countries"`
+
 	// Lua shared dict configuration data / certificate data
 	LuaSharedDicts map[string]int `json:"lua-shared-dicts"`
 
@@ -698,6 +730,16 @@ type Configuration struct {
 	// Enables or disables the secret checksum
 	UseSecretCheckSum bool `json:"use-secret-checksum"`
 
+	// Whether or not an ingress checksum mismatch blocks the reload of the configuration.
+	// When disabled the mismatch is only logged and metered.
+	// Default: true
+	IngChecksumEnforce bool `json:"ingress-checksum-enforce"`
+
+	// Collapses the upstreams with identical endpoints and load-balancing config into one.
+	// The locations use the upstream with the lowest name, which changes the upstream names.
+	// Default: false
+	DedupIdenticalUpstreams bool `json:"dedup-identical-upstreams"`
+
 	// Enables or disables the HTTP3/XQUIC
 	// Default: true
 	UseHTTP3xQUIC bool `json:"use-http3-xquic,omitempty"`
@@ -716,8 +758,18 @@ type Configuration struct {
 	HTTP3xQUICDefaultPort int `json:"http3-xquic-default-port"`
 
 	// Max number of path for the same host
+	// 0 disables the limit
 	MaxHostPathNum int `json:"max-host-path-num"`
 
+	// Max number of SSL certificates loaded for the same host, e.g. ECC and RSA
+	// Default: 2
+	MaxCertsPerServer int `json:"max-certs-per-server"`
+
+	// Number of hours before the expiration of a SSL certificate from which
+	// it is reported as expiring soon
+	// Default: 240
+	SSLCertExpiryWarnHours int `json:"ssl-cert-expiry-warn-hours"`
+
 	// Max canary ingress number
 	MaxCanaryIngNum int `json:"max-canary-ing-num"`
 
@@ -763,9 +815,25 @@ type Configuration struct {
 	// custom-port-domain: "443: xxx.com, 2443: yyy.com"
 	CustomPortDomain map[string]string `json:"custom-port-domain"`
 
+	// Ports which cannot be used by TCP and UDP stream services, in addition to
+	// the ports used by the controller
+	// Value Format: port[, port]*
+	AdditionalReservedPorts []int `json:"additional-reserved-ports"`
+
 	// Sleep time for layer 4 load balancer during stop process
 	// Unit: seconds
 	MaxSleepTimeForStop int `json:"max-stop-sleep-time-for-stop"`
+
+	// Number of attempts to render and reload the configuration during a sync
+	ReloadRetrySteps int `json:"reload-retry-steps"`
+
+	// Max interval between two attempts to reload the configuration
+	// Unit: seconds
+	ReloadRetryMaxInterval int `json:"reload-retry-max-interval"`
+
+	// Number of consecutive failed syncs after which the configuration is considered stuck
+	// 0 disables the detection
+	MaxReloadFailures int `json:"max-reload-failures"`
 }
 
 // NewDefault returns the default nginx configuration
@@ -793,19 +861,23 @@ func NewDefault() Configuration {
 		BlockCIDRs:                       defBlockEntity,
 		BlockUserAgents:                  defBlockEntity,
 		BlockReferers:                    defBlockEntity,
+		BlockCountries:                   defBlockEntity,
 		BrotliLevel:                      4,
 		BrotliTypes:                      brotliTypes,
 		ClientHeaderBufferSize:           "1k",
 		ClientHeaderTimeout:              60,
 		ClientBodyBufferSize:             "8k",
 		ClientBodyTimeout:                60,
+		SendTimeout:                      "60s",
 		EnableUnderscoresInHeaders:       false,
 		ErrorLogLevel:                    errorLevel,
 		UseForwardedHeaders:              false,
 		ForwardedForHeader:               "X-Forwarded-For",
 		ComputeFullForwardedFor:          false,
+		StripTrailingHostDot:             false,
 		ProxyAddOriginalURIHeader:        false,
 		GenerateRequestID:                true,
+		RequestIDHeader:                  "X-Request-ID",
 		HTTP2MaxFieldSize:                "4k",
 		HTTP2MaxHeaderSize:               "16k",
 		HTTP2MaxRequests:                 1000,
@@ -838,6 +910,7 @@ func NewDefault() Configuration {
 		ProxyHeadersHashBucketSize:       64,
 		ProxyStreamResponses:             1,
 		ReusePort:                        true,
+		DefaultSecurityHeaders:           false,
 		ShowServerTokens:                 true,
 		SSLBufferSize:                    sslBufferSize,
 		SSLCiphers:                       sslCiphers,
@@ -882,6 +955,7 @@ func NewDefault() Configuration {
 			ProxyBuffering:           "off",
 			ProxyHTTPVersion:         "1.1",
 			ProxyMaxTempFileSize:     "1024m",
+			ReservedLocations:        []string{},
 		},
 		UpstreamKeepaliveConnections: 32,
 		UpstreamKeepaliveTimeout:     60,
@@ -906,6 +980,7 @@ func NewDefault() Configuration {
 		LimitReqStatusCode:           503,
 		LimitConnStatusCode:          503,
 		DefaultType:                  "text/html",
+		DefBackendJSONErrorsType:     "application/json",
 		SyslogPort:                   514,
 		NoTLSRedirectLocations:       "/.well-known/acme-challenge",
 		NoAuthLocations:              "/.well-known/acme-challenge",
@@ -924,12 +999,16 @@ func NewDefault() Configuration {
 		UseIngStorageCluster:         false,
 		UseIngCheckSum:               false,
 		UseSecretCheckSum:            false,
+		IngChecksumEnforce:           true,
+		DedupIdenticalUpstreams:      false,
 		UseHTTP3xQUIC:                true,
 		UseXQUICxUDP:                 false,
 		HTTP3xQUICDefaultCert:        "",
 		HTTP3xQUICDefaultKey:         "",
 		HTTP3xQUICDefaultPort:        443,
-		MaxHostPathNum:               20,
+		MaxHostPathNum:               0,
+		MaxCertsPerServer:            2,
+		SSLCertExpiryWarnHours:       240,
 		MaxCanaryIngNum:              20,
 		MaxCanaryActionNum:           10,
 		DefaultCanaryWeightTotal:     100,
@@ -944,6 +1023,9 @@ func NewDefault() Configuration {
 		MaxRespAppendHeaderNum:       2,
 		User:                         "root",
 		MaxSleepTimeForStop:          35,
+		ReloadRetrySteps:             3,
+		ReloadRetryMaxInterval:       10,
+		MaxReloadFailures:            5,
 	}
 
 	if klog.V(5) {
@@ -967,6 +1049,7 @@ type TemplateConfig struct {
 	Cfg                      Configuration
 	IsIPV6Enabled            bool
 	IsSSLPassthroughEnabled  bool
+	IsEarlyHintsSupported    bool
 	NginxStatusIpv4Whitelist []string
 	NginxStatusIpv6Whitelist []string
 	RedirectServers          interface{}
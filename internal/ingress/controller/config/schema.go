@@ -0,0 +1,102 @@
+/*
+Copyright 2023 The Alibaba Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Key describes a configuration key accepted in the configuration configmap
+type Key struct {
+	// Name is the key used in the configmap (the json tag of the field)
+	Name string `json:"name"`
+	// Field is the name of the Go field in Configuration
+	Field string `json:"field"`
+	// Type is the Go type of the field
+	Type string `json:"type"`
+	// Default is the value returned by NewDefault
+	Default interface{} `json:"default"`
+}
+
+// Schema returns every configmap key of Configuration with its type and the
+// default value returned by NewDefault, sorted by key name
+func Schema() []Key {
+	keys := []Key{}
+	seen := map[string]bool{}
+	for _, k := range schemaKeys(reflect.ValueOf(NewDefault())) {
+		// like encoding/json, a field of Configuration shadows a field
+		// with the same key in an embedded struct
+		if seen[k.Name] {
+			continue
+		}
+		seen[k.Name] = true
+		keys = append(keys, k)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].Name < keys[j].Name
+	})
+
+	return keys
+}
+
+// schemaKeys returns the keys of the fields of v followed by the keys of
+// its embedded structs
+func schemaKeys(v reflect.Value) []Key {
+	keys := []Key{}
+	embedded := []reflect.Value{}
+
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			// unexported field
+			continue
+		}
+
+		tag := field.Tag.Get("json")
+		name := strings.Split(tag, ",")[0]
+		if name == "-" {
+			continue
+		}
+
+		// embedded structs like defaults.Backend are squashed into the configmap
+		if field.Anonymous && field.Type.Kind() == reflect.Struct && name == "" {
+			embedded = append(embedded, v.Field(i))
+			continue
+		}
+
+		if name == "" {
+			continue
+		}
+
+		keys = append(keys, Key{
+			Name:    name,
+			Field:   field.Name,
+			Type:    field.Type.String(),
+			Default: v.Field(i).Interface(),
+		})
+	}
+
+	for _, e := range embedded {
+		keys = append(keys, schemaKeys(e)...)
+	}
+
+	return keys
+}
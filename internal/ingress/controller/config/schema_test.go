@@ -0,0 +1,75 @@
+/*
+Copyright 2023 The Alibaba Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestSchema(t *testing.T) {
+	keys := Schema()
+
+	byName := map[string]Key{}
+	for _, k := range keys {
+		if _, ok := byName[k.Name]; ok {
+			t.Errorf("duplicated key %v", k.Name)
+		}
+		byName[k.Name] = k
+	}
+
+	testCases := []struct {
+		name  string
+		field string
+		typ   string
+		def   interface{}
+	}{
+		{"send-timeout", "SendTimeout", "string", "60s"},
+		{"keep-alive", "KeepAlive", "int", 75},
+		{"use-gzip", "UseGzip", "bool", true},
+		// squashed from defaults.Backend
+		{"proxy-send-timeout", "ProxySendTimeout", "int", 60},
+	}
+
+	for _, tc := range testCases {
+		k, ok := byName[tc.name]
+		if !ok {
+			t.Errorf("expected key %v in the schema", tc.name)
+			continue
+		}
+
+		if k.Field != tc.field {
+			t.Errorf("%v: expected field %v but returned %v", tc.name, tc.field, k.Field)
+		}
+		if k.Type != tc.typ {
+			t.Errorf("%v: expected type %v but returned %v", tc.name, tc.typ, k.Type)
+		}
+		if k.Default != tc.def {
+			t.Errorf("%v: expected default %v but returned %v", tc.name, tc.def, k.Default)
+		}
+	}
+
+	for _, name := range []string{"", "-", "Checksum"} {
+		if _, ok := byName[name]; ok {
+			t.Errorf("unexpected key %q in the schema", name)
+		}
+	}
+
+	if _, err := json.Marshal(keys); err != nil {
+		t.Errorf("unexpected error marshalling the schema: %v", err)
+	}
+}
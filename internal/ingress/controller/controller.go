@@ -18,10 +18,13 @@ limitations under the License.
 package controller
 
 import (
+	"crypto/x509"
 	"fmt"
+	"reflect"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/mitchellh/hashstructure"
@@ -34,16 +37,24 @@ import (
 	clientset "k8s.io/client-go/kubernetes"
 	ingcheckclient "k8s.io/ingress-nginx/internal/checksum/ingress/client/clientset/versioned"
 	secretcheckclient "k8s.io/ingress-nginx/internal/checksum/secret/client/clientset/versioned"
+	"k8s.io/ingress-nginx/internal/file"
 	"k8s.io/ingress-nginx/internal/ingress"
 	"k8s.io/ingress-nginx/internal/ingress/annotations"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/canary"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/log"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/proxy"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/serversnippet"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/ssldhparam"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/sslprotocols"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/upstreamkeepalive"
 	ngx_config "k8s.io/ingress-nginx/internal/ingress/controller/config"
 	"k8s.io/ingress-nginx/internal/ingress/controller/ingressclass"
+	ing_errors "k8s.io/ingress-nginx/internal/ingress/errors"
 	"k8s.io/ingress-nginx/internal/ingress/inspector"
 	"k8s.io/ingress-nginx/internal/k8s"
 	"k8s.io/ingress-nginx/internal/lock"
+	"k8s.io/ingress-nginx/internal/net/ssl"
 	"k8s.io/ingress-nginx/internal/nginx"
 	"k8s.io/klog"
 )
@@ -52,6 +63,10 @@ const (
 	defUpstreamName = "upstream-default-backend"
 	defServerName   = "_"
 	rootLocation    = "/"
+	robotsLocation  = "/robots.txt"
+
+	// number of goroutines obtaining the Endpoints of the upstreams
+	upstreamWorkers = 16
 )
 
 // Configuration contains all the settings required by an Ingress controller
@@ -68,6 +83,14 @@ type Configuration struct {
 
 	ResyncPeriod time.Duration
 
+	// ConfigMapSyncDelay is the time the changes of the configmaps are
+	// coalesced before syncing the ingresses
+	ConfigMapSyncDelay time.Duration
+
+	// SSLCertTTL is the time a certificate of the local store is kept after
+	// it is no longer referenced, 0 keeps it forever
+	SSLCertTTL time.Duration
+
 	ConfigMapName  string
 	DefaultService string
 
@@ -97,15 +120,26 @@ type Configuration struct {
 
 	EnableProfiling bool
 
-	EnableMetrics  bool
-	MetricsPerHost bool
+	EnableMetrics     bool
+	MetricsPerHost    bool
+	MetricsPerTenant  bool
+	MetricsMaxTenants int
 
 	FakeCertificate *ingress.SSLCert
 
 	SyncRateLimit float32
+	// SyncBurst is the number of syncs allowed at once before SyncRateLimit applies
+	SyncBurst int
 
 	DisableCatchAll bool
 
+	// UseEndpointSlices watches the EndpointSlices of the Services instead of
+	// their Endpoints
+	UseEndpointSlices bool
+
+	// StorageClusterHealthz is the mode of the health check of the storage cluster clients
+	StorageClusterHealthz string
+
 	IngressClassConfiguration *ingressclass.IngressClassConfiguration
 
 	ValidationWebhook         string
@@ -136,59 +170,84 @@ func (n *NGINXController) syncIngress(interface{}) error {
 	}
 
 	ings := n.store.ListIngresses(nil)
-	ready, err0 := ingCheck(n.store.ListIngsWithAnnotation(), n.store.ListLocalIngressCheckSums(nil))
+	ready, local, expected, err0 := ingCheck(n.store.ListIngsWithAnnotation(), n.store.ListLocalIngressCheckSums(nil))
 	cfg := n.store.GetBackendConfiguration()
 	if ready {
 		n.checksumStatus.IngChecksumStatus = true
+		n.checksums.recordIngress(ready, local, expected, nil)
 		n.metricCollector.IncIngChecksumCount()
 		n.metricCollector.ClearIngChecksumErrorCount()
 	} else if err0 != nil {
 		n.checksumStatus.IngChecksumStatus = false
-		if lock.IsFileExists(cfg.StatusTengineFilePath) {
-			klog.Errorf("Ingress ID mismatch and [%v] exists, alarm:\n\n%v", cfg.StatusTengineFilePath, err0)
-			n.metricCollector.IncIngChecksumErrorCount()
-		} else {
-			klog.Infof("Ingress ID mismatch and [%v] does NOT exist, ignoring alarm:\n\n%v", cfg.StatusTengineFilePath, err0)
+		n.checksums.recordIngress(ready, local, expected, err0)
+		if err := n.ingChecksumMismatch(cfg, err0); err != nil {
+			return err
 		}
-		return err0
 	}
 
 	hosts, servers, pcfg := n.getConfiguration(ings)
+	skippedCanaries := limitCanaries(pcfg.Servers, cfg.MaxCanaryIngNum)
 
-	n.metricCollector.SetSSLExpireTime(servers)
+	n.metricCollector.SetSSLExpireTime(servers, n.expiryWarnThreshold())
+	n.metricCollector.SetServerLocationCounts(servers)
 
 	if n.runningConfig.Equal(pcfg) {
 		klog.Infof("No configuration change detected, skipping hot reload.")
 		return nil
 	}
 
-	klog.Infof("Configuration changes detected.")
+	reason := reloadReason(n.runningConfig, pcfg)
+	klog.Infof("Configuration changes detected (%v).", reason)
+
+	for i := 0; i < skippedCanaries; i++ {
+		n.metricCollector.IncCanaryNumLimitExCount()
+	}
 
 	n.metricCollector.SetHosts(hosts)
+	n.metricCollector.SetHostTenants(hostTenants(servers))
 
 	hash, _ := hashstructure.Hash(pcfg, &hashstructure.HashOptions{
 		TagName: "json",
 	})
 
 	pcfg.ConfigurationChecksum = fmt.Sprintf("%v", hash)
+	n.desireConfig(pcfg.ConfigurationChecksum)
+
+	var reloadErr error
+	err := wait.ExponentialBackoff(reloadBackoff(cfg), func() (bool, error) {
+		reloadErr = n.reloadConfiguration(cfg, pcfg)
+		if reloadErr != nil {
+			klog.Warningf("Unexpected failure reloading the backend: %v", reloadErr)
+			return false, nil
+		}
 
-	err := n.OnUpdate(*pcfg)
+		return true, nil
+	})
 	if err != nil {
+		n.reloadFailures++
 		n.metricCollector.IncReloadErrorCount()
 		n.metricCollector.ConfigSuccess(hash, false)
-		klog.Errorf("Unexpected failure reloading the backend:\n%v", err)
-		return err
-	}
+		n.metricCollector.SetReloadConsecutiveErrorCount(n.reloadFailures)
 
-	md5, err := hotReload(n.hotReloadMD5, cfg, *pcfg, false)
-	if err != nil {
-		klog.Errorf("Hot reloading failed:\n%v", err)
-		return err
+		if cfg.MaxReloadFailures > 0 && n.reloadFailures >= cfg.MaxReloadFailures {
+			n.metricCollector.SetConfigStuck(true)
+			klog.Errorf("Configuration is stuck after %v consecutive reload failures, alarm:\n%v", n.reloadFailures, reloadErr)
+		} else {
+			klog.Errorf("Unexpected failure reloading the backend:\n%v", reloadErr)
+		}
+
+		n.configReloadFailed()
+		// once the retries are exhausted the sync queue requeues the sync with rate limiting
+		return reloadErr
 	}
-	n.hotReloadMD5 = md5
+	n.configReloaded()
+
+	n.reloadFailures = 0
+	n.metricCollector.SetReloadConsecutiveErrorCount(0)
+	n.metricCollector.SetConfigStuck(false)
 
 	n.metricCollector.ConfigSuccess(hash, true)
-	n.metricCollector.IncReloadCount()
+	n.metricCollector.IncReloadCount(reason)
 
 	isFirstSync := n.runningConfig.Equal(&ingress.Configuration{})
 	if isFirstSync {
@@ -231,6 +290,95 @@ func (n *NGINXController) syncIngress(interface{}) error {
 	return nil
 }
 
+// desireConfig starts a new configuration generation when the checksum
+// differs from the one of the last configuration a sync tried to reload.
+func (n *NGINXController) desireConfig(checksum string) {
+	if checksum == n.desiredConfigChecksum {
+		return
+	}
+
+	n.desiredConfigGeneration++
+	n.desiredConfigChecksum = checksum
+}
+
+// configReloadFailed records that the previous configuration is still live
+// because the desired one failed to reload.
+func (n *NGINXController) configReloadFailed() {
+	n.configStale = true
+	n.metricCollector.SetConfigStale(true)
+	klog.Warningf("Configuration generation %v (checksum %v) failed to reload, generation %v (checksum %v) is still live",
+		n.desiredConfigGeneration, n.desiredConfigChecksum, n.liveConfigGeneration, n.liveConfigChecksum)
+}
+
+// configReloaded records that the desired configuration is live.
+func (n *NGINXController) configReloaded() {
+	if n.configStale {
+		klog.Infof("Configuration generation %v (checksum %v) is live, replacing the stale generation %v (checksum %v)",
+			n.desiredConfigGeneration, n.desiredConfigChecksum, n.liveConfigGeneration, n.liveConfigChecksum)
+	}
+
+	n.liveConfigGeneration = n.desiredConfigGeneration
+	n.liveConfigChecksum = n.desiredConfigChecksum
+	n.configStale = false
+	n.metricCollector.SetConfigStale(false)
+}
+
+// ingChecksumMismatch logs and meters an ingress checksum mismatch. The
+// mismatch is returned to block the reload unless ingress-checksum-enforce
+// is disabled.
+func (n *NGINXController) ingChecksumMismatch(cfg ngx_config.Configuration, err error) error {
+	if lock.IsFileExists(cfg.StatusTengineFilePath) {
+		klog.Errorf("Ingress ID mismatch and [%v] exists, alarm:\n\n%v", cfg.StatusTengineFilePath, err)
+		n.metricCollector.IncIngChecksumErrorCount()
+	} else {
+		klog.Infof("Ingress ID mismatch and [%v] does NOT exist, ignoring alarm:\n\n%v", cfg.StatusTengineFilePath, err)
+	}
+
+	if cfg.IngChecksumEnforce {
+		return err
+	}
+
+	klog.Warningf("Ingress ID mismatch ignored because ingress-checksum-enforce is disabled, reloading the configuration")
+	return nil
+}
+
+// reloadBackoff returns the backoff used to retry rendering and reloading
+// the configuration, capped to the configured max interval
+func reloadBackoff(cfg ngx_config.Configuration) wait.Backoff {
+	steps := cfg.ReloadRetrySteps
+	if steps < 1 {
+		steps = 1
+	}
+
+	return wait.Backoff{
+		Steps:    steps,
+		Duration: 1 * time.Second,
+		Factor:   2,
+		Jitter:   0.1,
+		Cap:      time.Duration(cfg.ReloadRetryMaxInterval) * time.Second,
+	}
+}
+
+// reloadConfiguration renders and reloads the configuration, then hot
+// reloads it. The rendering is skipped when the configuration was already
+// reloaded by a previous sync whose hot reload failed.
+func (n *NGINXController) reloadConfiguration(cfg ngx_config.Configuration, pcfg *ingress.Configuration) error {
+	if pcfg.ConfigurationChecksum != n.reloadedConfigChecksum {
+		if err := n.OnUpdate(*pcfg); err != nil {
+			return err
+		}
+		n.reloadedConfigChecksum = pcfg.ConfigurationChecksum
+	}
+
+	md5, err := hotReload(n.hotReloadMD5, cfg, *pcfg, false)
+	if err != nil {
+		return fmt.Errorf("hot reloading failed: %v", err)
+	}
+	n.hotReloadMD5 = md5
+
+	return nil
+}
+
 // CheckIngress returns an error in case the provided ingress, when added
 // to the current configuration, generates an invalid configuration
 func (n *NGINXController) CheckIngress(ing *networking.Ingress) error {
@@ -259,18 +407,37 @@ func (n *NGINXController) CheckIngress(ing *networking.Ingress) error {
 		return nil
 	}
 
-	filter := func(toCheck *ingress.Ingress) bool {
-		return toCheck.ObjectMeta.Namespace == ing.ObjectMeta.Namespace &&
-			toCheck.ObjectMeta.Name == ing.ObjectMeta.Name
+	// the extractor ignores an invalid server-snippet, reject it instead
+	if _, err := serversnippet.NewParser(n.store).Parse(ing); ing_errors.IsInvalidConfiguration(err) {
+		n.metricCollector.IncCheckErrorCount(ing.ObjectMeta.Namespace, ing.Name)
+		return err
 	}
 
-	ings := n.store.ListIngresses(filter)
-	ings = append(ings, &ingress.Ingress{
-		Ingress:           *ing,
-		ParsedAnnotations: annotations.NewAnnotationExtractor(n.store).Extract(ing),
-	})
+	// an insecure ssl protocol is ignored and an unknown one only denies the locations, reject them instead
+	if err := sslprotocols.Validate(ing); err != nil {
+		n.metricCollector.IncCheckErrorCount(ing.ObjectMeta.Namespace, ing.Name)
+		return err
+	}
 
-	_, _, pcfg := n.getConfiguration(ings)
+	// an invalid proxy annotation, like a malformed proxy-body-size, only denies the locations, reject it instead
+	if _, err := proxy.NewParser(n.store).Parse(ing); ing_errors.IsLocationDenied(err) {
+		n.metricCollector.IncCheckErrorCount(ing.ObjectMeta.Namespace, ing.Name)
+		return err
+	}
+
+	// the canary parser ignores the invalid canary annotations, reject them instead
+	if err := canary.Validate(n.store, ing); err != nil {
+		n.metricCollector.IncCheckErrorCount(ing.ObjectMeta.Namespace, ing.Name)
+		return err
+	}
+
+	pcfg := n.candidateConfiguration(ing)
+
+	// the server snippet defining a built-in location of its server is skipped, reject it instead
+	if err := checkServerSnippetLocations(ing, pcfg.Servers); err != nil {
+		n.metricCollector.IncCheckErrorCount(ing.ObjectMeta.Namespace, ing.Name)
+		return err
+	}
 
 	cfg := n.store.GetBackendConfiguration()
 	cfg.Resolver = n.resolver
@@ -291,6 +458,60 @@ func (n *NGINXController) CheckIngress(ing *networking.Ingress) error {
 	return err
 }
 
+// DiffIngress returns a unified diff between the Tengine configuration of the
+// running configuration and the one the ingress would produce, without
+// testing or applying it. Values of sensitive directives are redacted.
+func (n *NGINXController) DiffIngress(ing *networking.Ingress) (string, error) {
+	if n == nil {
+		return "", fmt.Errorf("cannot diff ingress on a nil ingress controller")
+	}
+
+	if ing == nil || !ing.DeletionTimestamp.IsZero() {
+		return "", nil
+	}
+
+	if ingressClass, _ := n.store.GetIngressClass(ing, n.cfg.IngressClassConfiguration); ingressClass == "" {
+		return "", nil
+	}
+
+	if n.cfg.Namespace != "" && ing.ObjectMeta.Namespace != n.cfg.Namespace {
+		return "", nil
+	}
+
+	cfg := n.store.GetBackendConfiguration()
+	cfg.Resolver = n.resolver
+
+	running, err := n.generateTemplate(cfg, *n.runningConfig)
+	if err != nil {
+		return "", err
+	}
+
+	candidate, err := n.generateTemplate(cfg, *n.candidateConfiguration(ing))
+	if err != nil {
+		return "", err
+	}
+
+	return diffConfig(redactConfig(running), redactConfig(candidate))
+}
+
+// candidateConfiguration returns the configuration of the ingresses of the
+// store with ing added, replacing the ingress with the same name if any
+func (n *NGINXController) candidateConfiguration(ing *networking.Ingress) *ingress.Configuration {
+	filter := func(toCheck *ingress.Ingress) bool {
+		return toCheck.ObjectMeta.Namespace == ing.ObjectMeta.Namespace &&
+			toCheck.ObjectMeta.Name == ing.ObjectMeta.Name
+	}
+
+	ings := n.store.ListIngresses(filter)
+	ings = append(ings, &ingress.Ingress{
+		Ingress:           *ing,
+		ParsedAnnotations: annotations.NewAnnotationExtractor(n.store).Extract(ing),
+	})
+
+	_, _, pcfg := n.getConfiguration(ings)
+	return pcfg
+}
+
 // GetWarnings returns a list of warnings an Ingress gets when being created.
 // The warnings are going to be used in an admission webhook, and they represent
 // a list of messages that users need to be aware (like deprecation notices)
@@ -308,17 +529,44 @@ func (n *NGINXController) CheckWarning(ing *networking.Ingress) ([]string, error
 		"secure-verify-ca-secret",
 	)
 
+	// annotations Tengine has no directive for
+	var unsupportedAnnotations = sets.NewString(
+		"upstream-send-buffer",
+		"upstream-receive-buffer",
+	)
+
 	// Skip checks if the ingress is marked as deleted
 	if !ing.DeletionTimestamp.IsZero() {
 		return warnings, nil
 	}
 
+	// prefixes commonly mistaken for the one an annotation is read with
+	var mistakenPrefixes = sets.NewString(
+		"tengine.taobao.org",
+		"nginx.ingress.kubernetes.io",
+		"kubernetes.io",
+	)
+
 	anns := ing.GetAnnotations()
 	for k := range anns {
 		trimmedkey := strings.TrimPrefix(k, parser.AnnotationsPrefix+"/")
 		if deprecatedAnnotations.Has(trimmedkey) {
 			warnings = append(warnings, fmt.Sprintf("annotation %s is deprecated", k))
 		}
+		if unsupportedAnnotations.Has(trimmedkey) {
+			warnings = append(warnings, fmt.Sprintf("annotation %s is not supported by Tengine and is ignored", k))
+		}
+
+		prefix, key, found := strings.Cut(k, "/")
+		if !found || !mistakenPrefixes.Has(prefix) {
+			continue
+		}
+
+		expected, known := parser.ExpectedPrefix(key)
+		if known && prefix != expected {
+			warnings = append(warnings, fmt.Sprintf("annotation %s uses an unexpected prefix and is ignored, use %s/%s instead",
+				k, expected, key))
+		}
 	}
 
 	// Add each validation as a single warning
@@ -337,20 +585,37 @@ func (n *NGINXController) CheckWarning(ing *networking.Ingress) ([]string, error
 	return warnings, nil
 }
 
-func (n *NGINXController) getStreamServices(configmapName string, proto apiv1.Protocol) []ingress.L4Service {
+// reasons of the entries of the TCP and UDP ConfigMaps skipped by getStreamServices
+const (
+	streamSkipBadPort      = "bad-port"
+	streamSkipReservedPort = "reserved-port"
+	streamSkipInvalidRef   = "invalid-reference"
+	streamSkipNoEndpoints  = "no-endpoints"
+)
+
+var streamSkipReasons = []string{streamSkipBadPort, streamSkipReservedPort, streamSkipInvalidRef, streamSkipNoEndpoints}
+
+// getStreamServices returns the stream services of the ConfigMap, and the
+// number of entries skipped by reason
+func (n *NGINXController) getStreamServices(configmapName string, proto apiv1.Protocol) ([]ingress.L4Service, map[string]int) {
+	skipped := make(map[string]int, len(streamSkipReasons))
+	for _, reason := range streamSkipReasons {
+		skipped[reason] = 0
+	}
+
 	if configmapName == "" {
-		return []ingress.L4Service{}
+		return []ingress.L4Service{}, skipped
 	}
 	klog.V(3).Infof("Obtaining information about %v stream services from ConfigMap %q", proto, configmapName)
 	_, _, err := k8s.ParseNameNS(configmapName)
 	if err != nil {
 		klog.Warningf("Error parsing ConfigMap reference %q: %v", configmapName, err)
-		return []ingress.L4Service{}
+		return []ingress.L4Service{}, skipped
 	}
 	configmap, err := n.store.GetConfigMap(configmapName)
 	if err != nil {
 		klog.Warningf("Error getting ConfigMap %q: %v", configmapName, err)
-		return []ingress.L4Service{}
+		return []ingress.L4Service{}, skipped
 	}
 
 	var svcs []ingress.L4Service
@@ -368,23 +633,49 @@ func (n *NGINXController) getStreamServices(configmapName string, proto apiv1.Pr
 		nginx.StreamPort,
 	}
 
+	rp = append(rp, n.store.GetBackendConfiguration().AdditionalReservedPorts...)
+
 	reserverdPorts := sets.NewInt(rp...)
-	// svcRef format: <(str)namespace>/<(str)service>:<(intstr)port>[:<("PROXY")decode>:<("PROXY")encode>]
+	// svcRef format: <(str)namespace>/<(str)service>:<(intstr)port>[:<("PROXY")decode>:<("PROXY")encode>][:<options>]
+	// where options is a comma separated list of conn-limit=<count> and the health check fields
 	for port, svcRef := range configmap.Data {
 		externalPort, err := strconv.Atoi(port)
 		if err != nil {
-			klog.Warningf("%q is not a valid %v port number", port, proto)
+			klog.V(3).Infof("%q is not a valid %v port number", port, proto)
+			skipped[streamSkipBadPort]++
 			continue
 		}
 		if reserverdPorts.Has(externalPort) {
-			klog.Warningf("Port %d cannot be used for %v stream services. It is reserved for the Ingress controller.", externalPort, proto)
+			klog.V(3).Infof("Port %d cannot be used for %v stream services. It is reserved for the Ingress controller.", externalPort, proto)
+			skipped[streamSkipReservedPort]++
 			continue
 		}
 		nsSvcPort := strings.Split(svcRef, ":")
 		if len(nsSvcPort) < 2 {
-			klog.Warningf("Invalid Service reference %q for %v port %d", svcRef, proto, externalPort)
+			klog.V(3).Infof("Invalid Service reference %q for %v port %d", svcRef, proto, externalPort)
+			skipped[streamSkipInvalidRef]++
 			continue
 		}
+		var healthCheck *ingress.L4HealthCheck
+		var connLimit int
+		if last := nsSvcPort[len(nsSvcPort)-1]; len(nsSvcPort) > 2 && strings.Contains(last, "=") {
+			var spec string
+			connLimit, spec, err = parseL4ConnLimit(last)
+			if err != nil {
+				klog.V(3).Infof("Invalid connection limit %q for %v port %d: %v", last, proto, externalPort, err)
+				skipped[streamSkipInvalidRef]++
+				continue
+			}
+			if spec != "" {
+				healthCheck, err = parseL4HealthCheck(spec)
+				if err != nil {
+					klog.V(3).Infof("Invalid health check %q for %v port %d: %v", spec, proto, externalPort, err)
+					skipped[streamSkipInvalidRef]++
+					continue
+				}
+			}
+			nsSvcPort = nsSvcPort[:len(nsSvcPort)-1]
+		}
 		nsName := nsSvcPort[0]
 		svcPort := nsSvcPort[1]
 		svcProxyProtocol.Decode = false
@@ -400,12 +691,14 @@ func (n *NGINXController) getStreamServices(configmapName string, proto apiv1.Pr
 		}
 		svcNs, svcName, err := k8s.ParseNameNS(nsName)
 		if err != nil {
-			klog.Warningf("%v", err)
+			klog.V(3).Infof("%v", err)
+			skipped[streamSkipInvalidRef]++
 			continue
 		}
 		svc, err := n.store.GetService(nsName)
 		if err != nil {
-			klog.Warningf("Error getting Service %q: %v", nsName, err)
+			klog.V(3).Infof("Error getting Service %q: %v", nsName, err)
+			skipped[streamSkipInvalidRef]++
 			continue
 		}
 		var endps []ingress.Endpoint
@@ -435,7 +728,8 @@ func (n *NGINXController) getStreamServices(configmapName string, proto apiv1.Pr
 		// stream services cannot contain empty upstreams and there is
 		// no default backend equivalent
 		if len(endps) == 0 {
-			klog.Warningf("Service %q does not have any active Endpoint for %v port %v", nsName, proto, svcPort)
+			klog.V(3).Infof("Service %q does not have any active Endpoint for %v port %v", nsName, proto, svcPort)
+			skipped[streamSkipNoEndpoints]++
 			continue
 		}
 		svcs = append(svcs, ingress.L4Service{
@@ -446,6 +740,8 @@ func (n *NGINXController) getStreamServices(configmapName string, proto apiv1.Pr
 				Port:          intstr.FromString(svcPort),
 				Protocol:      proto,
 				ProxyProtocol: svcProxyProtocol,
+				HealthCheck:   healthCheck,
+				ConnLimit:     connLimit,
 			},
 			Endpoints: endps,
 			Service:   svc,
@@ -453,9 +749,120 @@ func (n *NGINXController) getStreamServices(configmapName string, proto apiv1.Pr
 	}
 	// Keep upstream order sorted to reduce unnecessary nginx config reloads.
 	sort.SliceStable(svcs, func(i, j int) bool {
-		return svcs[i].Port < svcs[j].Port
+		if svcs[i].Port != svcs[j].Port {
+			return svcs[i].Port < svcs[j].Port
+		}
+		return fmt.Sprintf("%v/%v:%v", svcs[i].Backend.Namespace, svcs[i].Backend.Name, svcs[i].Backend.Port.String()) <
+			fmt.Sprintf("%v/%v:%v", svcs[j].Backend.Namespace, svcs[j].Backend.Name, svcs[j].Backend.Port.String())
 	})
-	return svcs
+
+	var summary []string
+	for _, reason := range streamSkipReasons {
+		if skipped[reason] > 0 {
+			summary = append(summary, fmt.Sprintf("%v %v", skipped[reason], reason))
+		}
+	}
+	if len(summary) > 0 {
+		klog.Warningf("Skipped %v stream services of ConfigMap %q: %v", proto, configmapName, strings.Join(summary, ", "))
+	}
+
+	return svcs, skipped
+}
+
+// dropDuplicateStreamServices removes the services of a protocol exposed on
+// a port already used by a previous service, like the ConfigMap keys 53 and
+// 053. The services must be sorted. It returns the remaining services and the
+// dropped ones.
+func dropDuplicateStreamServices(svcs []ingress.L4Service) ([]ingress.L4Service, []ingress.L4Service) {
+	ports := sets.NewInt()
+
+	var kept, dropped []ingress.L4Service
+	for _, svc := range svcs {
+		if ports.Has(svc.Port) {
+			klog.Warningf("Port %d is used by more than one %v service. Ignoring service %v/%v", svc.Port, svc.Backend.Protocol, svc.Backend.Namespace, svc.Backend.Name)
+			dropped = append(dropped, svc)
+			continue
+		}
+		ports.Insert(svc.Port)
+		kept = append(kept, svc)
+	}
+
+	return kept, dropped
+}
+
+// parseL4ConnLimit extracts the conn-limit=<count> field of the options of a
+// stream service reference, returning the limit and the remaining fields.
+func parseL4ConnLimit(spec string) (int, string, error) {
+	limit := 0
+	var rest []string
+	for _, field := range strings.Split(spec, ",") {
+		kv := strings.SplitN(strings.TrimSpace(field), "=", 2)
+		if kv[0] != "conn-limit" {
+			rest = append(rest, field)
+			continue
+		}
+
+		if len(kv) != 2 {
+			return 0, "", fmt.Errorf("invalid field %q, expected conn-limit=<count>", field)
+		}
+		n, err := strconv.Atoi(kv[1])
+		if err != nil || n < 0 {
+			return 0, "", fmt.Errorf("invalid conn-limit %q: must be a number greater or equal than zero", kv[1])
+		}
+		limit = n
+	}
+
+	return limit, strings.Join(rest, ","), nil
+}
+
+const (
+	defaultL4HealthCheckInterval = 10
+	defaultL4HealthCheckTimeout  = 5
+	defaultL4HealthCheckFall     = 3
+)
+
+// parseL4HealthCheck parses the health check of a stream service reference.
+// The format is a comma separated list of hc=<interval>, timeout=<time> and
+// fall=<count> where the times are durations of at least one second (e.g. 5s).
+func parseL4HealthCheck(spec string) (*ingress.L4HealthCheck, error) {
+	hc := &ingress.L4HealthCheck{
+		Interval: defaultL4HealthCheckInterval,
+		Timeout:  defaultL4HealthCheckTimeout,
+		Fall:     defaultL4HealthCheckFall,
+	}
+
+	for _, field := range strings.Split(spec, ",") {
+		kv := strings.SplitN(strings.TrimSpace(field), "=", 2)
+		if len(kv) != 2 || kv[1] == "" {
+			return nil, fmt.Errorf("invalid field %q, expected <key>=<value>", field)
+		}
+
+		switch kv[0] {
+		case "hc", "timeout":
+			d, err := time.ParseDuration(kv[1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid %v %q: %v", kv[0], kv[1], err)
+			}
+			if d < time.Second || d%time.Second != 0 {
+				return nil, fmt.Errorf("invalid %v %q: must be a whole number of seconds", kv[0], kv[1])
+			}
+			if kv[0] == "hc" {
+				hc.Interval = int(d / time.Second)
+			} else {
+				hc.Timeout = int(d / time.Second)
+			}
+		case "fall":
+			fall, err := strconv.Atoi(kv[1])
+			if err != nil || fall < 1 {
+				return nil, fmt.Errorf("invalid fall %q: must be a positive number", kv[1])
+			}
+			hc.Fall = fall
+		default:
+			return nil, fmt.Errorf("unknown key %q", kv[0])
+		}
+	}
+
+	return hc, nil
 }
 
 // getDefaultUpstream returns the upstream associated with the default backend.
@@ -493,6 +900,7 @@ func (n *NGINXController) getDefaultUpstream() *ingress.Backend {
 func (n *NGINXController) getConfiguration(ingresses []*ingress.Ingress) (sets.Set[string], []*ingress.Server, *ingress.Configuration) {
 
 	upstreams, servers := n.getBackendServers(ingresses)
+	n.applyNamespaceConfiguration(servers)
 	var passUpstreams []*ingress.SSLPassthroughBackend
 
 	hosts := sets.New[string]()
@@ -527,17 +935,78 @@ func (n *NGINXController) getConfiguration(ingresses []*ingress.Ingress) (sets.S
 		}
 	}
 
+	tcpEndpoints, tcpSkipped := n.getStreamServices(n.cfg.TCPConfigMapName, apiv1.ProtocolTCP)
+	udpEndpoints, udpSkipped := n.getStreamServices(n.cfg.UDPConfigMapName, apiv1.ProtocolUDP)
+	n.metricCollector.SetStreamServicesSkipped(string(apiv1.ProtocolTCP), tcpSkipped)
+	n.metricCollector.SetStreamServicesSkipped(string(apiv1.ProtocolUDP), udpSkipped)
+	tcpEndpoints, tcpDropped := dropDuplicateStreamServices(tcpEndpoints)
+	for range tcpDropped {
+		n.metricCollector.IncStreamServiceDroppedCount(string(apiv1.ProtocolTCP))
+	}
+	udpEndpoints, udpDropped := dropDuplicateStreamServices(udpEndpoints)
+	for range udpDropped {
+		n.metricCollector.IncStreamServiceDroppedCount(string(apiv1.ProtocolUDP))
+	}
+
 	return hosts, servers, &ingress.Configuration{
 		Backends:              upstreams,
 		Servers:               servers,
-		TCPEndpoints:          n.getStreamServices(n.cfg.TCPConfigMapName, apiv1.ProtocolTCP),
-		UDPEndpoints:          n.getStreamServices(n.cfg.UDPConfigMapName, apiv1.ProtocolUDP),
+		TCPEndpoints:          tcpEndpoints,
+		UDPEndpoints:          udpEndpoints,
 		PassthroughBackends:   passUpstreams,
 		BackendConfigChecksum: n.store.GetBackendConfiguration().Checksum,
 		ControllerPodsCount:   n.store.GetRunningControllerPodsCount(),
 	}
 }
 
+// applyNamespaceConfiguration overrides the settings of the servers and the
+// locations with the configmap overlays of the namespaces of their ingresses.
+// The annotations of the ingresses take precedence over the overlays.
+func (n *NGINXController) applyNamespaceConfiguration(servers []*ingress.Server) {
+	cfg := n.store.GetBackendConfiguration()
+
+	for _, server := range servers {
+		if ns, _, err := k8s.ParseNameNS(server.VirtualService); err == nil {
+			nsCfg := n.store.GetNamespaceConfiguration(ns)
+
+			if server.SSLProtocols == "" && nsCfg.SSLProtocols != cfg.SSLProtocols {
+				server.SSLProtocols = nsCfg.SSLProtocols
+			}
+
+			if nsCfg.UseGzip != cfg.UseGzip || nsCfg.GzipLevel != cfg.GzipLevel {
+				useGzip := nsCfg.UseGzip
+				server.UseGzip = &useGzip
+				server.GzipLevel = nsCfg.GzipLevel
+			}
+		}
+
+		for _, loc := range server.Locations {
+			if loc.Ingress == nil {
+				continue
+			}
+
+			nsCfg := n.store.GetNamespaceConfiguration(loc.Ingress.Namespace)
+			ing := &loc.Ingress.Ingress
+
+			if _, err := parser.GetIntAnnotation("proxy-connect-timeout", ing); err != nil {
+				if nsCfg.ProxyConnectTimeout != cfg.ProxyConnectTimeout {
+					loc.Proxy.ConnectTimeout = nsCfg.ProxyConnectTimeout
+				}
+			}
+			if _, err := parser.GetIntAnnotation("proxy-send-timeout", ing); err != nil {
+				if nsCfg.ProxySendTimeout != cfg.ProxySendTimeout {
+					loc.Proxy.SendTimeout = nsCfg.ProxySendTimeout
+				}
+			}
+			if _, err := parser.GetIntAnnotation("proxy-read-timeout", ing); err != nil {
+				if nsCfg.ProxyReadTimeout != cfg.ProxyReadTimeout {
+					loc.Proxy.ReadTimeout = nsCfg.ProxyReadTimeout
+				}
+			}
+		}
+	}
+}
+
 // getBackendServers returns a list of Upstream and Server to be used by the
 // backend.  An upstream can be used in multiple servers if the namespace,
 // service name and port are the same.
@@ -548,6 +1017,8 @@ func (n *NGINXController) getBackendServers(ingresses []*ingress.Ingress) ([]*in
 
 	var canaryIngresses []*ingress.Ingress
 
+	maxHostPathNum := n.store.GetBackendConfiguration().MaxHostPathNum
+
 	for _, ing := range ingresses {
 
 		ingKey := k8s.MetaNamespaceKey(ing)
@@ -649,6 +1120,14 @@ func (n *NGINXController) getBackendServers(ingresses []*ingress.Ingress) ([]*in
 					}
 				}
 
+				// the server is full, skip the new location
+				if addLoc && maxHostPathNum > 0 && countIngressLocations(server) >= maxHostPathNum {
+					klog.Warningf("Skipping location %q for server %q (Ingress %q): the server already has %v paths, the max-host-path-num limit",
+						nginxPath, server.Hostname, ingKey, maxHostPathNum)
+					n.metricCollector.IncHostPathLimitExCount()
+					continue
+				}
+
 				// new location
 				if addLoc {
 					klog.V(3).Infof("Adding location %q for server %q with upstream %q (Ingress %q)",
@@ -664,6 +1143,12 @@ func (n *NGINXController) getBackendServers(ingresses []*ingress.Ingress) ([]*in
 					}
 					locationApplyAnnotations(loc, anns)
 
+					if dup := findDuplicateLocation(server, loc); dup != nil {
+						klog.Warningf("Skipping location %q for server %q (Ingress %q): it collides with location %q rendered as %q",
+							nginxPath, server.Hostname, ingKey, dup.Path, effectiveLocationPath(loc))
+						continue
+					}
+
 					if loc.Redirect.FromToWWW {
 						server.RedirectFromToWWW = true
 					}
@@ -699,6 +1184,10 @@ func (n *NGINXController) getBackendServers(ingresses []*ingress.Ingress) ([]*in
 					}
 					locs[host] = append(locs[host], path.Path)
 				}
+
+				if anns.SessionAffinity.Type == "header" {
+					ups.SessionAffinity.HeaderSessionAffinity.Name = anns.SessionAffinity.Header.Name
+				}
 			}
 		}
 
@@ -714,11 +1203,34 @@ func (n *NGINXController) getBackendServers(ingresses []*ingress.Ingress) ([]*in
 		}
 	}
 
+	// the paths of the ingresses cannot define again the built-in locations
+	overrideReserved := n.store.GetBackendConfiguration().OverrideReservedLocations
+	for _, server := range servers {
+		resolveReservedLocations(server, overrideReserved)
+	}
+
 	aUpstreams := make([]*ingress.Backend, 0, len(upstreams))
 
 	if !n.store.GetBackendConfiguration().UseCustomDefBackend {
 		for _, upstream := range upstreams {
 			aUpstreams = append(aUpstreams, upstream)
+
+			if upstream.Name == defUpstreamName {
+				continue
+			}
+
+			// custom-default-backend-service is honored even if the feature is disabled globally
+			for _, server := range servers {
+				for _, location := range server.Locations {
+					if !location.CustomDefaultBackend || !shouldCreateUpstreamForLocationDefaultBackend(upstream, location) {
+						continue
+					}
+
+					if nb := n.createLocationDefaultBackendUpstream(upstream, server, location); nb != nil {
+						aUpstreams = append(aUpstreams, nb)
+					}
+				}
+			}
 		}
 	} else {
 		//Add config for LocationDefaultBackend
@@ -737,25 +1249,8 @@ func (n *NGINXController) getBackendServers(ingresses []*ingress.Ingress) ([]*in
 						continue
 					}
 
-					sp := location.DefaultBackend.Spec.Ports[0]
-					endps := getEndpoints(location.DefaultBackend, &sp, apiv1.ProtocolTCP, n.store.GetServiceEndpoints)
-					// custom backend is valid only if contains at least one endpoint
-					if len(endps) > 0 {
-						name := fmt.Sprintf("custom-default-backend-%v", location.DefaultBackend.GetName())
-						klog.V(3).Infof("Creating \"%v\" upstream based on default backend annotation", name)
-
-						nb := upstream.DeepCopy()
-						nb.Name = name
-						nb.Endpoints = endps
+					if nb := n.createLocationDefaultBackendUpstream(upstream, server, location); nb != nil {
 						aUpstreams = append(aUpstreams, nb)
-						location.DefaultBackendUpstreamName = name
-
-						if len(upstream.Endpoints) == 0 {
-							klog.V(3).Infof("Upstream %q has no active Endpoint, so using custom default backend for location %q in server %q (Service \"%v/%v\")",
-								upstream.Name, location.Path, server.Hostname, location.DefaultBackend.Namespace, location.DefaultBackend.Name)
-
-							location.Backend = name
-						}
 					}
 
 					if server.SSLPassthrough {
@@ -776,6 +1271,11 @@ func (n *NGINXController) getBackendServers(ingresses []*ingress.Ingress) ([]*in
 		}
 	}
 
+	if n.store.GetBackendConfiguration().DedupIdenticalUpstreams {
+		aUpstreams = dedupUpstreams(aUpstreams, servers)
+	}
+
+	maxCerts := n.store.GetBackendConfiguration().MaxCertsPerServer
 	aServers := make([]*ingress.Server, 0, len(servers))
 	for _, value := range servers {
 		sort.SliceStable(value.Locations, func(i, j int) bool {
@@ -793,6 +1293,8 @@ func (n *NGINXController) getBackendServers(ingresses []*ingress.Ingress) ([]*in
 		sort.SliceStable(value.SSLCerts, func(i, j int) bool {
 			return len(value.SSLCerts[i].Name) > len(value.SSLCerts[j].Name)
 		})
+		value.SSLCerts = limitSSLCerts(value.SSLCerts, maxCerts)
+		value.SingleKeyType = hasSingleKeyType(value)
 		aServers = append(aServers, value)
 	}
 
@@ -807,12 +1309,80 @@ func (n *NGINXController) getBackendServers(ingresses []*ingress.Ingress) ([]*in
 	return aUpstreams, aServers
 }
 
+// countIngressLocations returns the number of locations of the server
+// defined by Ingress paths, the default root location is not counted
+func countIngressLocations(server *ingress.Server) int {
+	count := 0
+	for _, location := range server.Locations {
+		if !location.IsDefBackend {
+			count++
+		}
+	}
+
+	return count
+}
+
+// upstreamKeepalive returns the keepalive configuration of the upstream
+// connections of an Ingress. Settings not defined by annotations fall back
+// to the global configuration. The zero value is returned when the Ingress
+// does not configure the keepalive connections.
+func (n *NGINXController) upstreamKeepalive(anns *annotations.Ingress) upstreamkeepalive.Config {
+	keepalive := anns.UpstreamKeepalive
+	if keepalive == (upstreamkeepalive.Config{}) {
+		return keepalive
+	}
+
+	cfg := n.store.GetBackendConfiguration()
+	if keepalive.Connections == 0 {
+		keepalive.Connections = cfg.UpstreamKeepaliveConnections
+	}
+	if keepalive.Timeout == 0 {
+		keepalive.Timeout = cfg.UpstreamKeepaliveTimeout
+	}
+	if keepalive.Requests == 0 {
+		keepalive.Requests = cfg.UpstreamKeepaliveRequests
+	}
+
+	return keepalive
+}
+
+// createLocationDefaultBackendUpstream returns a copy of upstream pointing to the
+// endpoints of the default backend of the location, or nil if it has no endpoints.
+func (n *NGINXController) createLocationDefaultBackendUpstream(upstream *ingress.Backend, server *ingress.Server, location *ingress.Location) *ingress.Backend {
+	sp := location.DefaultBackend.Spec.Ports[0]
+	endps := getEndpoints(location.DefaultBackend, &sp, apiv1.ProtocolTCP, n.store.GetServiceEndpoints)
+	// custom backend is valid only if contains at least one endpoint
+	if len(endps) == 0 {
+		return nil
+	}
+
+	name := fmt.Sprintf("custom-default-backend-%v", location.DefaultBackend.GetName())
+	klog.V(3).Infof("Creating \"%v\" upstream based on default backend annotation", name)
+
+	nb := upstream.DeepCopy()
+	nb.Name = name
+	nb.Endpoints = endps
+	location.DefaultBackendUpstreamName = name
+
+	if len(upstream.Endpoints) == 0 {
+		klog.V(3).Infof("Upstream %q has no active Endpoint, so using custom default backend for location %q in server %q (Service \"%v/%v\")",
+			upstream.Name, location.Path, server.Hostname, location.DefaultBackend.Namespace, location.DefaultBackend.Name)
+
+		location.Backend = name
+	}
+
+	return nb
+}
+
 // createUpstreams creates the NGINX upstreams (Endpoints) for each Service
 // referenced in Ingress rules.
 func (n *NGINXController) createUpstreams(data []*ingress.Ingress, du *ingress.Backend) map[string]*ingress.Backend {
 	upstreams := make(map[string]*ingress.Backend)
 	upstreams[defUpstreamName] = du
 
+	// functions obtaining the Endpoints and Service of the upstreams
+	var resolvers []func()
+
 	for _, ing := range data {
 		ingKey := k8s.MetaNamespaceKey(ing)
 		anns := ing.ParsedAnnotations
@@ -833,6 +1403,8 @@ func (n *NGINXController) createUpstreams(data []*ingress.Ingress, du *ingress.B
 				upstreams[defBackend].LoadBalancing = n.store.GetBackendConfiguration().LoadBalancing
 			}
 
+			upstreams[defBackend].UpstreamKeepalive = n.upstreamKeepalive(anns)
+
 			svcKey := fmt.Sprintf("%v/%v", ing.Namespace, ing.Spec.DefaultBackend.Service.Name)
 			// add the service ClusterIP as a single Endpoint instead of individual Endpoints
 			if anns.ServiceUpstream {
@@ -850,20 +1422,23 @@ func (n *NGINXController) createUpstreams(data []*ingress.Ingress, du *ingress.B
 				setTrafficShapingPolicy(anns, &upstreams[defBackend].TrafficShapingPolicy)
 			}
 
-			if len(upstreams[defBackend].Endpoints) == 0 {
-				_, port := upstreamServiceNameAndPort(ing.Spec.DefaultBackend.Service)
-				endps, err := n.serviceEndpoints(svcKey, port.String())
-				upstreams[defBackend].Endpoints = append(upstreams[defBackend].Endpoints, endps...)
-				if err != nil {
-					klog.Warningf("Error creating upstream %q: %v", defBackend, err)
+			ups := upstreams[defBackend]
+			_, port := upstreamServiceNameAndPort(ing.Spec.DefaultBackend.Service)
+			resolvers = append(resolvers, func() {
+				if len(ups.Endpoints) == 0 {
+					endps, err := n.serviceEndpoints(svcKey, port.String(), anns.EndpointFilter)
+					ups.Endpoints = append(ups.Endpoints, endps...)
+					if err != nil {
+						klog.Warningf("Error creating upstream %q: %v", ups.Name, err)
+					}
 				}
-			}
 
-			s, err := n.store.GetService(svcKey)
-			if err != nil {
-				klog.Warningf("Error obtaining Service %q: %v", svcKey, err)
-			}
-			upstreams[defBackend].Service = s
+				s, err := n.store.GetService(svcKey)
+				if err != nil {
+					klog.Warningf("Error obtaining Service %q: %v", svcKey, err)
+				}
+				ups.Service = s
+			})
 		}
 
 		for _, rule := range ing.Spec.Rules {
@@ -897,6 +1472,8 @@ func (n *NGINXController) createUpstreams(data []*ingress.Ingress, du *ingress.B
 					upstreams[name].LoadBalancing = n.store.GetBackendConfiguration().LoadBalancing
 				}
 
+				upstreams[name].UpstreamKeepalive = n.upstreamKeepalive(anns)
+
 				svcKey := fmt.Sprintf("%v/%v", ing.Namespace, svcName)
 				// add the service ClusterIP as a single Endpoint instead of individual Endpoints
 				if anns.ServiceUpstream {
@@ -914,30 +1491,63 @@ func (n *NGINXController) createUpstreams(data []*ingress.Ingress, du *ingress.B
 					setTrafficShapingPolicy(anns, &upstreams[name].TrafficShapingPolicy)
 				}
 
-				if len(upstreams[name].Endpoints) == 0 {
-					_, port := upstreamServiceNameAndPort(path.Backend.Service)
-					endp, err := n.serviceEndpoints(svcKey, port.String())
-					if err != nil {
-						klog.Warningf("Error obtaining Endpoints for Service %q: %v", svcKey, err)
-						continue
+				ups := upstreams[name]
+				port := svcPort
+				resolvers = append(resolvers, func() {
+					if len(ups.Endpoints) == 0 {
+						endp, err := n.serviceEndpoints(svcKey, port.String(), anns.EndpointFilter)
+						if err != nil {
+							klog.Warningf("Error obtaining Endpoints for Service %q: %v", svcKey, err)
+							return
+						}
+						ups.Endpoints = endp
 					}
-					upstreams[name].Endpoints = endp
-				}
 
-				s, err := n.store.GetService(svcKey)
-				if err != nil {
-					klog.Warningf("Error obtaining Service %q: %v", svcKey, err)
-					continue
-				}
+					s, err := n.store.GetService(svcKey)
+					if err != nil {
+						klog.Warningf("Error obtaining Service %q: %v", svcKey, err)
+						return
+					}
 
-				upstreams[name].Service = s
+					ups.Service = s
+				})
 			}
 		}
 	}
 
+	// every resolver only updates its own upstream, so the Endpoints of the
+	// Services are obtained in parallel without locking the upstreams
+	runParallel(upstreamWorkers, resolvers)
+
 	return upstreams
 }
 
+// runParallel runs the functions with at most workers of them at a time and
+// waits for all of them to return
+func runParallel(workers int, fns []func()) {
+	if workers > len(fns) {
+		workers = len(fns)
+	}
+
+	queue := make(chan func())
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wg.Done()
+			for fn := range queue {
+				fn()
+			}
+		}()
+	}
+
+	for _, fn := range fns {
+		queue <- fn
+	}
+	close(queue)
+	wg.Wait()
+}
+
 // getServiceClusterEndpoint returns an Endpoint corresponding to the ClusterIP
 // field of a Service.
 func (n *NGINXController) getServiceClusterEndpoint(svcKey string, backend *networking.IngressBackend) (endpoint ingress.Endpoint, err error) {
@@ -977,7 +1587,7 @@ func (n *NGINXController) getServiceClusterEndpoint(svcKey string, backend *netw
 }
 
 // serviceEndpoints returns the upstream servers (Endpoints) associated with a Service.
-func (n *NGINXController) serviceEndpoints(svcKey, backendPort string) ([]ingress.Endpoint, error) {
+func (n *NGINXController) serviceEndpoints(svcKey, backendPort, endpointFilter string) ([]ingress.Endpoint, error) {
 	var upstreams []ingress.Endpoint
 
 	svc, err := n.store.GetService(svcKey)
@@ -1010,6 +1620,7 @@ func (n *NGINXController) serviceEndpoints(svcKey, backendPort string) ([]ingres
 			if len(endps) == 0 {
 				klog.Warningf("Service %q does not have any active Endpoint.", svcKey)
 			}
+			endps = n.filterEndpoints(svcKey, endps, endpointFilter)
 
 			upstreams = append(upstreams, endps...)
 			break
@@ -1019,6 +1630,44 @@ func (n *NGINXController) serviceEndpoints(svcKey, backendPort string) ([]ingres
 	return upstreams, nil
 }
 
+// filterEndpoints returns the endpoints of the pods matching the label selector
+// endpointFilter, or all of them when none matches
+func (n *NGINXController) filterEndpoints(svcKey string, endps []ingress.Endpoint, endpointFilter string) []ingress.Endpoint {
+	if endpointFilter == "" || len(endps) == 0 {
+		return endps
+	}
+
+	selector, err := labels.Parse(endpointFilter)
+	if err != nil {
+		klog.Warningf("Invalid upstream endpoint filter %q of Service %q: %v", endpointFilter, svcKey, err)
+		return endps
+	}
+
+	filtered := []ingress.Endpoint{}
+	for _, endp := range endps {
+		if endp.Target == nil || endp.Target.Kind != "Pod" {
+			continue
+		}
+
+		pod, err := n.store.GetPod(fmt.Sprintf("%v/%v", endp.Target.Namespace, endp.Target.Name))
+		if err != nil {
+			klog.Warningf("Error obtaining Pod %v/%v of Service %q: %v", endp.Target.Namespace, endp.Target.Name, svcKey, err)
+			continue
+		}
+
+		if selector.Matches(labels.Set(pod.Labels)) {
+			filtered = append(filtered, endp)
+		}
+	}
+
+	if len(filtered) == 0 {
+		klog.Warningf("No Endpoint of Service %q matches the upstream endpoint filter %q, using all of them", svcKey, endpointFilter)
+		return endps
+	}
+
+	return filtered
+}
+
 func (n *NGINXController) getDefaultSSLCertificate() *ingress.SSLCert {
 	// read custom default SSL certificate, fall back to generated default certificate
 	if n.cfg.DefaultSSLCertificate != "" {
@@ -1033,6 +1682,71 @@ func (n *NGINXController) getDefaultSSLCertificate() *ingress.SSLCert {
 	return n.cfg.FakeCertificate
 }
 
+// expiryWarnThreshold returns the time before the expiration of a SSL
+// certificate from which it is reported as expiring soon
+func (n *NGINXController) expiryWarnThreshold() time.Duration {
+	return time.Duration(n.store.GetBackendConfiguration().SSLCertExpiryWarnHours) * time.Hour
+}
+
+// selectDefaultCertForPort returns the certificate used for clients without
+// SNI on a port: the certificate whose CN, possibly a wildcard, matches the
+// domain mapped to the port in custom-port-domain, or the default SSL certificate.
+func (n *NGINXController) selectDefaultCertForPort(port int) *ingress.SSLCert {
+	domain, ok := n.store.GetBackendConfiguration().CustomPortDomain[strconv.Itoa(port)]
+	if !ok {
+		return n.getDefaultSSLCertificate()
+	}
+
+	certs := n.store.ListLocalSSLCerts()
+	sort.SliceStable(certs, func(i, j int) bool {
+		return certs[i].Name < certs[j].Name
+	})
+
+	// prefer a certificate for the exact domain over a wildcard one
+	var wildcard *ingress.SSLCert
+	for _, cert := range certs {
+		for _, cn := range cert.CN {
+			if strings.EqualFold(cn, domain) {
+				return cert
+			}
+		}
+		if wildcard == nil && ssl.IsValidHostname(domain, cert.CN) {
+			wildcard = cert
+		}
+	}
+	if wildcard != nil {
+		return wildcard
+	}
+
+	klog.Warningf("No SSL certificate found for domain %q of port %v, using the default certificate", domain, port)
+	return n.getDefaultSSLCertificate()
+}
+
+// createServerDHParam writes the DH parameters of the secret referenced by
+// the ssl-dh-param-secret annotation to a file, like the global ssl-dh-param
+func (n *NGINXController) createServerDHParam(secretName string) (ssldhparam.Config, error) {
+	secret, err := n.store.GetSecret(secretName)
+	if err != nil {
+		return ssldhparam.Config{}, err
+	}
+
+	dh, ok := secret.Data[ssldhparam.DHParamKey]
+	if !ok {
+		return ssldhparam.Config{}, fmt.Errorf("secret %q does not contain the key %v", secretName, ssldhparam.DHParamKey)
+	}
+
+	pemFileName, err := ssl.AddOrUpdateDHParam("dhparam-"+strings.Replace(secretName, "/", "-", -1), dh)
+	if err != nil {
+		return ssldhparam.Config{}, err
+	}
+
+	return ssldhparam.Config{
+		Secret:   secretName,
+		FileName: pemFileName,
+		SHA:      file.SHA1(pemFileName),
+	}, nil
+}
+
 // createServers builds a map of host name to Server structs from a map of
 // already computed Upstream structs. Each Server is configured with at least
 // one root location, which uses a default backend if left unspecified.
@@ -1044,6 +1758,8 @@ func (n *NGINXController) createServers(data []*ingress.Ingress,
 	allAliases := make(map[string][]string, len(data))
 
 	bdef := n.store.GetDefaultBackend()
+	maxCerts := n.store.GetBackendConfiguration().MaxCertsPerServer
+	expiryWarnThreshold := n.expiryWarnThreshold()
 	ngxProxy := proxy.Config{
 		BodySize:             bdef.ProxyBodySize,
 		ConnectTimeout:       bdef.ProxyConnectTimeout,
@@ -1144,7 +1860,8 @@ func (n *NGINXController) createServers(data []*ingress.Ingress,
 				IsDefBackend:  true,
 				Backend:       un,
 				Service:       &apiv1.Service{},
-				DisableRobots: anns.DisableRobots,
+				DisableRobots: anns.Robots.Disable,
+				RobotsContent: anns.Robots.Content,
 				WeightTotal:   anns.Canary.WeightTotal,
 			}
 			locationApplyAnnotations(loc, anns)
@@ -1155,14 +1872,19 @@ func (n *NGINXController) createServers(data []*ingress.Ingress,
 				Locations: []*ingress.Location{
 					loc,
 				},
-				SSLPassthrough:  anns.SSLPassthrough,
-				SSLCiphers:      anns.SSLCiphers,
-				NeedDefaultCert: anns.DefaultCert.NeedDefault,
-				SSLProtocols:    anns.SSLProtocols,
+				SSLPassthrough:   anns.SSLPassthrough,
+				SSLCiphers:       anns.SSLCiphers,
+				SSLCiphersTLS13:  anns.SSLCiphersTLS13,
+				NeedDefaultCert:  anns.DefaultCert.NeedDefault,
+				DefaultCertPorts: anns.DefaultCert.Ports,
+				SSLProtocols:     anns.SSLProtocols,
 			}
 		}
 	}
 
+	// hosts with an ingress explicitly enabling HTTP/2
+	http2Hosts := sets.NewString()
+
 	// configure default location, alias, and SSL
 	for _, ing := range data {
 		ingKey := k8s.MetaNamespaceKey(ing)
@@ -1197,8 +1919,103 @@ func (n *NGINXController) createServers(data []*ingress.Ingress,
 				}
 			}
 
+			// any ingress of the host can isolate the server from other hosts
+			if !servers[host].DisableCoalescing && anns.DisableCoalescing {
+				servers[host].DisableCoalescing = anns.DisableCoalescing
+			}
+
+			// the path is normalized before matching the locations of every ingress of the host
+			if !servers[host].NormalizePath && anns.NormalizePath {
+				servers[host].NormalizePath = anns.NormalizePath
+			}
+
+			// any ingress of the host can omit the built-in robots.txt location
+			if !servers[host].SuppressDefaultRobots && anns.DisableDefRobots {
+				servers[host].SuppressDefaultRobots = anns.DisableDefRobots
+			}
+
+			// disabling HTTP/2 wins over the ingresses of the host enabling it
+			if anns.HTTP2 != nil {
+				if *anns.HTTP2 {
+					http2Hosts.Insert(host)
+				} else {
+					servers[host].DisableHTTP2 = true
+				}
+
+				if servers[host].DisableHTTP2 && http2Hosts.Has(host) {
+					klog.Warningf("Conflicting use-http2 annotations for server %q, disabling HTTP/2 (Ingress %q)",
+						host, ingKey)
+				}
+			}
+
+			if anns.EnableHTTP3 != nil {
+				if *anns.EnableHTTP3 && !n.store.GetBackendConfiguration().UseHTTP3xQUIC {
+					klog.Warningf("HTTP/3 is disabled in the configuration, ignoring enable-http3 for server %q (Ingress %q)",
+						host, ingKey)
+				} else if servers[host].EnableHTTP3 == nil {
+					servers[host].EnableHTTP3 = anns.EnableHTTP3
+				} else if *servers[host].EnableHTTP3 != *anns.EnableHTTP3 {
+					klog.Warningf("HTTP/3 already configured for server %q, skipping (Ingress %q)",
+						host, ingKey)
+				}
+			}
+
+			if anns.SSLDHParam.Secret != "" {
+				if servers[host].SSLDHParam.Secret == "" {
+					dhParam, err := n.createServerDHParam(anns.SSLDHParam.Secret)
+					if err != nil {
+						klog.Warningf("Error adding DH parameters for server %q (Ingress %q): %v", host, ingKey, err)
+					} else {
+						servers[host].SSLDHParam = dhParam
+					}
+				} else if servers[host].SSLDHParam.Secret != anns.SSLDHParam.Secret {
+					klog.Warningf("DH parameters already configured for server %q, skipping (Ingress %q)",
+						host, ingKey)
+				}
+			}
+
+			if anns.MaxURILength != 0 {
+				if servers[host].MaxURILength == 0 {
+					servers[host].MaxURILength = anns.MaxURILength
+				} else if servers[host].MaxURILength != anns.MaxURILength {
+					klog.Warningf("Maximum URI length already configured for server %q, skipping (Ingress %q)",
+						host, ingKey)
+				}
+			}
+
+			if len(anns.ProxyRealIPCIDR) > 0 {
+				if len(servers[host].ProxyRealIPCIDR) == 0 {
+					servers[host].ProxyRealIPCIDR = anns.ProxyRealIPCIDR
+				} else if !sets.NewString(servers[host].ProxyRealIPCIDR...).Equal(sets.NewString(anns.ProxyRealIPCIDR...)) {
+					klog.Warningf("Trusted proxies already configured for server %q, skipping (Ingress %q)",
+						host, ingKey)
+				}
+			}
+
+			if anns.SendTimeout != "" {
+				if servers[host].SendTimeout == "" {
+					servers[host].SendTimeout = anns.SendTimeout
+				} else if servers[host].SendTimeout != anns.SendTimeout {
+					klog.Warningf("Send timeout already configured for server %q, skipping (Ingress %q)",
+						host, ingKey)
+				}
+			}
+
+			if anns.MetricsTenant != "" {
+				if servers[host].MetricsTenant == "" {
+					servers[host].MetricsTenant = anns.MetricsTenant
+				} else if servers[host].MetricsTenant != anns.MetricsTenant {
+					klog.Warningf("Metrics tenant already configured for server %q, skipping (Ingress %q)",
+						host, ingKey)
+				}
+			}
+
 			if !servers[host].NeedDefaultCert && anns.DefaultCert.NeedDefault {
 				servers[host].NeedDefaultCert = anns.DefaultCert.NeedDefault
+				servers[host].DefaultCertPorts = anns.DefaultCert.Ports
+			} else if anns.DefaultCert.NeedDefault && !reflect.DeepEqual(servers[host].DefaultCertPorts, anns.DefaultCert.Ports) {
+				klog.Warningf("Default cert ports already configured for server %q, skipping (Ingress %q)",
+					host, ingKey)
 			}
 
 			// only add SSL ciphers if the server does not have them previously configured
@@ -1206,8 +2023,12 @@ func (n *NGINXController) createServers(data []*ingress.Ingress,
 				servers[host].SSLCiphers = anns.SSLCiphers
 			}
 
-			// only add certificates if the server does not have both ECC and RSA previously configured
-			if len(servers[host].SSLCerts) > 1 {
+			if servers[host].SSLCiphersTLS13 == "" && anns.SSLCiphersTLS13 != "" {
+				servers[host].SSLCiphersTLS13 = anns.SSLCiphersTLS13
+			}
+
+			// only add certificates if the server does not have enough certificates (e.g. ECC and RSA) previously configured
+			if maxCerts > 0 && len(servers[host].SSLCerts) >= maxCerts {
 				continue
 			}
 
@@ -1247,7 +2068,7 @@ func (n *NGINXController) createServers(data []*ingress.Ingress,
 
 				servers[host].SSLCerts = append(servers[host].SSLCerts, cert)
 
-				if cert.ExpireTime.Before(time.Now().Add(240 * time.Hour)) {
+				if cert.ExpireTime.Before(time.Now().Add(expiryWarnThreshold)) {
 					klog.Warningf("SSL certificate for server %q is about to expire (%v)", host, cert.ExpireTime)
 				}
 			}
@@ -1287,6 +2108,225 @@ func (n *NGINXController) createServers(data []*ingress.Ingress,
 	return servers
 }
 
+// hostTenants returns the tenant the metrics of the hostnames and aliases of
+// the servers are attributed to
+func hostTenants(servers []*ingress.Server) map[string]string {
+	tenants := make(map[string]string)
+	for _, server := range servers {
+		if server.MetricsTenant == "" {
+			continue
+		}
+
+		tenants[server.Hostname] = server.MetricsTenant
+		for _, alias := range server.Aliases {
+			tenants[alias] = server.MetricsTenant
+		}
+	}
+
+	return tenants
+}
+
+// reloadReason returns a coarse reason of the differences between the running
+// and the new configuration. Only the counts of servers and backends, their names
+// and the checksums of the certificates are compared to keep it cheap.
+func reloadReason(running, pcfg *ingress.Configuration) string {
+	if running == nil || running.Equal(&ingress.Configuration{}) {
+		return "initial"
+	}
+
+	if running.BackendConfigChecksum != pcfg.BackendConfigChecksum {
+		return "configmap"
+	}
+
+	if len(pcfg.Servers) > len(running.Servers) {
+		return "servers_added"
+	}
+	if len(pcfg.Servers) < len(running.Servers) {
+		return "servers_removed"
+	}
+
+	runningCerts := make(map[string]string, len(running.Servers))
+	for _, server := range running.Servers {
+		runningCerts[server.Hostname] = certificateChecksums(server)
+	}
+	for _, server := range pcfg.Servers {
+		certs, ok := runningCerts[server.Hostname]
+		if !ok {
+			return "servers_changed"
+		}
+		if certs != certificateChecksums(server) {
+			return "ssl_changed"
+		}
+	}
+
+	if len(pcfg.Backends) != len(running.Backends) {
+		return "backends_changed"
+	}
+	runningBackends := make(map[string]bool, len(running.Backends))
+	for _, backend := range running.Backends {
+		runningBackends[backend.Name] = true
+	}
+	for _, backend := range pcfg.Backends {
+		if !runningBackends[backend.Name] {
+			return "backends_changed"
+		}
+	}
+
+	return "other"
+}
+
+// certificateChecksums returns the checksums of the certificates of the server
+func certificateChecksums(server *ingress.Server) string {
+	checksums := make([]string, 0, len(server.SSLCerts))
+	for _, cert := range server.SSLCerts {
+		if cert != nil {
+			checksums = append(checksums, cert.PemSHA)
+		}
+	}
+
+	return strings.Join(checksums, ",")
+}
+
+// effectiveLocationPath returns the path of the location rendered in the
+// configuration once the location-path-escape and location-path-prefix
+// annotations are applied
+func effectiveLocationPath(loc *ingress.Location) string {
+	path := loc.Path
+	if loc.LocationPathEscape && strings.HasPrefix(path, "/") {
+		path = path[1:]
+	}
+
+	return loc.LocationPathPrefix + path
+}
+
+// findDuplicateLocation returns the location of the server rendered with the
+// same modifier and path than the given location, which Tengine rejects as a
+// duplicate location
+func findDuplicateLocation(server *ingress.Server, loc *ingress.Location) *ingress.Location {
+	path := effectiveLocationPath(loc)
+	for _, l := range server.Locations {
+		if l.LocationPreceding == loc.LocationPreceding && effectiveLocationPath(l) == path {
+			return l
+		}
+	}
+
+	return nil
+}
+
+// collectReservedLocations returns the paths of the built-in prefix locations
+// rendered by the template in the server: the robots.txt location of the
+// servers with a root location and the health and status locations of the
+// default server
+func collectReservedLocations(server *ingress.Server) []string {
+	var reserved []string
+	if server.Hostname == defServerName {
+		reserved = append(reserved, nginx.HealthPath, "/nginx_status", "/traffic_status")
+	}
+
+	if server.SuppressDefaultRobots || usesRegexLocations(server) {
+		return reserved
+	}
+
+	for _, loc := range server.Locations {
+		if loc.LocationPreceding == "" && effectiveLocationPath(loc) == rootLocation {
+			reserved = append(reserved, robotsLocation)
+			break
+		}
+	}
+
+	return reserved
+}
+
+// usesRegexLocations returns true if the locations of the server without
+// modifier are rendered as regular expressions, like enforceRegexModifier
+// of the template
+func usesRegexLocations(server *ingress.Server) bool {
+	for _, loc := range server.Locations {
+		if loc.Rewrite.UseRegex || (loc.Rewrite.Target != "" && loc.Rewrite.Target != loc.Path) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// resolveReservedLocations skips the locations and the snippet of the server
+// rendered with the same path than a built-in location, which Tengine rejects
+// as a duplicate location. With override the built-in robots.txt location is
+// omitted instead of the locations.
+func resolveReservedLocations(server *ingress.Server, override bool) {
+	reserved := sets.NewString(collectReservedLocations(server)...)
+	if reserved.Len() == 0 {
+		return
+	}
+
+	regex := usesRegexLocations(server)
+	locations := make([]*ingress.Location, 0, len(server.Locations))
+	for _, loc := range server.Locations {
+		// exact and regular expression locations never collide
+		modifier := loc.LocationPreceding
+		if (modifier != "" && modifier != "^~") || (modifier == "" && regex) {
+			locations = append(locations, loc)
+			continue
+		}
+
+		path := effectiveLocationPath(loc)
+		if !reserved.Has(path) {
+			locations = append(locations, loc)
+			continue
+		}
+
+		if override && path == robotsLocation {
+			klog.Infof("Location %q for server %q (Ingress %q) replaces the built-in location %q",
+				loc.Path, server.Hostname, k8s.MetaNamespaceKey(loc.Ingress), path)
+			server.SuppressDefaultRobots = true
+			locations = append(locations, loc)
+			continue
+		}
+
+		klog.Warningf("Skipping location %q for server %q (Ingress %q): it collides with the built-in location %q",
+			loc.Path, server.Hostname, k8s.MetaNamespaceKey(loc.Ingress), path)
+	}
+
+	server.Locations = locations
+
+	// the snippet can define the robots.txt location only when the host omits the built-in one
+	if err := serversnippet.ValidateReservedLocations(server.ServerSnippet, collectReservedLocations(server)); err != nil {
+		klog.Warningf("Skipping the server snippet of server %q: %v", server.Hostname, err)
+		server.ServerSnippet = ""
+	}
+}
+
+// checkServerSnippetLocations checks the server-snippet of the ingress does
+// not define again a built-in location of the servers of its hosts
+func checkServerSnippetLocations(ing *networking.Ingress, servers []*ingress.Server) error {
+	snippet, err := parser.GetStringAnnotation("server-snippet", ing)
+	if err != nil || snippet == "" {
+		return nil
+	}
+
+	hosts := sets.NewString()
+	for _, rule := range ing.Spec.Rules {
+		host := rule.Host
+		if host == "" {
+			host = defServerName
+		}
+		hosts.Insert(host)
+	}
+
+	for _, server := range servers {
+		if !hosts.Has(server.Hostname) {
+			continue
+		}
+
+		if err := serversnippet.ValidateReservedLocations(snippet, collectReservedLocations(server)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 func locationApplyAnnotations(loc *ingress.Location, anns *annotations.Ingress) {
 	loc.BasicDigestAuth = anns.BasicDigestAuth
 	loc.ClientBodyBufferSize = anns.ClientBodyBufferSize
@@ -1305,21 +2345,32 @@ func locationApplyAnnotations(loc *ingress.Location, anns *annotations.Ingress)
 	loc.Whitelist = anns.Whitelist
 	loc.Denied = anns.Denied
 	loc.XForwardedPrefix = anns.XForwardedPrefix
+	loc.XForwardedProto = anns.XForwardedProto
 	loc.UsePortInRedirects = anns.UsePortInRedirects
 	loc.Connection = anns.Connection
 	loc.Logs = anns.Logs
 	loc.InfluxDB = anns.InfluxDB
 	loc.DefaultBackend = anns.DefaultBackend
+	if anns.CustomDefBackend != nil {
+		loc.DefaultBackend = anns.CustomDefBackend
+		loc.CustomDefaultBackend = true
+	}
 	loc.BackendProtocol = anns.BackendProtocol
 	loc.FastCGI = anns.FastCGI
 	loc.CustomHTTPErrors = anns.CustomHTTPErrors
 	loc.ModSecurity = anns.ModSecurity
 	loc.Satisfy = anns.Satisfy
 	loc.Mirror = anns.Mirror
+	loc.CacheConvertHead = anns.CacheConvertHead
 	loc.DefaultBackendUpstreamName = defUpstreamName
 	loc.LocationPreceding = anns.Location.LocationPreceding
 	loc.LocationPathPrefix = anns.Location.LocationPathPrefix
 	loc.LocationPathEscape = anns.Location.LocationPathEscape
+	loc.SecurityHeaders = anns.SecurityHeaders
+	loc.EarlyHints = anns.EarlyHints
+	loc.RequestBodyMD5 = anns.RequestBodyMD5
+	loc.ProxyCacheLock = anns.ProxyCacheLock
+	loc.BodyTooLarge = anns.BodyTooLarge
 }
 
 // OK to merge canary ingresses iff there exists one or more ingresses to potentially merge into
@@ -1357,6 +2408,38 @@ func mergeAlternativeBackend(priUps *ingress.Backend, altUps *ingress.Backend) b
 	return true
 }
 
+// limitCanaries keeps the first max canaries of every location by routing
+// priority, the order of the routes of the ingress gateway, and returns the
+// number of skipped canaries. A max of 0 disables the limit.
+func limitCanaries(servers []*ingress.Server, max int) int {
+	if max <= 0 {
+		return 0
+	}
+
+	skipped := 0
+	for _, server := range servers {
+		for _, loc := range server.Locations {
+			if len(loc.Canaries) <= max {
+				continue
+			}
+
+			setCanaryPriority(&loc.Canaries)
+			if len(loc.Canaries) <= max {
+				continue
+			}
+
+			for _, canary := range loc.Canaries[max:] {
+				klog.Warningf("Skipping canary %v of location %v%v: the location has %v canaries, over the max-canary-ing-num limit %v",
+					canary.Target, server.Hostname, loc.Path, len(loc.Canaries), max)
+			}
+			skipped += len(loc.Canaries) - max
+			loc.Canaries = loc.Canaries[:max]
+		}
+	}
+
+	return skipped
+}
+
 // Compares an Ingress of a potential alternative backend's rules with each existing server and finds matching host + path pairs.
 // If a match is found, we know that this server should back the alternative backend and add the alternative backend
 // to a backend's alternative list.
@@ -1522,6 +2605,53 @@ func extractTLSSecretName(host string, ing *ingress.Ingress,
 	return secretNames
 }
 
+// limitSSLCerts returns at most max SSL certificates. The certificates are
+// expected to be sorted from the most to the least specific name, so the
+// most specific ones are kept. A max lower than 1 disables the limit.
+func limitSSLCerts(certs []*ingress.SSLCert, max int) []*ingress.SSLCert {
+	if max < 1 || len(certs) <= max {
+		return certs
+	}
+
+	klog.Warningf("Server has %d SSL certificates, keeping the first %d", len(certs), max)
+	return certs[:max]
+}
+
+// keyTypeOf returns the type of the public key of a SSL certificate,
+// ECC or RSA, or an empty string when the type is unknown
+func keyTypeOf(cert *ingress.SSLCert) string {
+	if cert == nil || cert.Certificate == nil {
+		return ""
+	}
+
+	switch cert.Certificate.PublicKeyAlgorithm {
+	case x509.ECDSA:
+		return "ECC"
+	case x509.RSA:
+		return "RSA"
+	}
+
+	return ""
+}
+
+// hasSingleKeyType returns true when the SSL certificates of a server use
+// only one of the ECC and RSA key types
+func hasSingleKeyType(server *ingress.Server) bool {
+	keyTypes := sets.NewString()
+	for _, cert := range server.SSLCerts {
+		if keyType := keyTypeOf(cert); keyType != "" {
+			keyTypes.Insert(keyType)
+		}
+	}
+
+	if keyTypes.Len() != 1 {
+		return false
+	}
+
+	klog.V(2).Infof("SSL certificates of server %q only use the %v key type", server.Hostname, keyTypes.List()[0])
+	return true
+}
+
 // getRemovedHosts returns a list of the hostsnames
 // that are not associated anymore to the NGINX configuration.
 func getRemovedHosts(rucfg, newcfg *ingress.Configuration) []string {
@@ -1636,12 +2766,8 @@ func (n *NGINXController) verifyCanaryReferrer(ingKey string, anns *annotations.
 		return true
 	}
 
-	cfg := n.store.GetBackendConfiguration()
-	canaryReferrers := strings.Split(cfg.CanaryReferrer, ",")
-	for _, canaryReferrer := range canaryReferrers {
-		if canaryReferrer == anns.Canary.Referrer {
-			return true
-		}
+	if n.store.GetCanaryReferrerMatcher().Match(anns.Canary.Referrer) {
+		return true
 	}
 
 	n.metricCollector.IncCanaryReferInvalidCount()
@@ -1652,10 +2778,13 @@ func (n *NGINXController) verifyCanaryReferrer(ingKey string, anns *annotations.
 func setTrafficShapingPolicy(anns *annotations.Ingress, policy *ingress.TrafficShapingPolicy) {
 	*policy = ingress.TrafficShapingPolicy{
 		Weight:           anns.Canary.Weight,
+		SplitKey:         anns.Canary.SplitKey,
+		WeightMode:       anns.Canary.WeightMode,
 		Header:           anns.Canary.Header,
 		HeaderValue:      anns.Canary.HeaderValue,
 		Cookie:           anns.Canary.Cookie,
 		CookieValue:      anns.Canary.CookieValue,
+		CookiePattern:    anns.Canary.CookiePattern,
 		Query:            anns.Canary.Query,
 		QueryValue:       anns.Canary.QueryValue,
 		ModDivisor:       uint64(anns.Canary.ModDivisor),
@@ -1666,5 +2795,6 @@ func setTrafficShapingPolicy(anns *annotations.Ingress, policy *ingress.TrafficS
 		ReqAddQuery:      anns.Canary.ReqAddQuery,
 		RespAddHeader:    anns.Canary.RespAddHeader,
 		RespAppendHeader: anns.Canary.RespAppendHeader,
+		DedupeSetCookie:  anns.Canary.DedupeSetCookie,
 	}
 }
@@ -27,7 +27,10 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"reflect"
+	"sort"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -43,11 +46,18 @@ import (
 	"k8s.io/ingress-nginx/internal/ingress"
 	"k8s.io/ingress-nginx/internal/ingress/annotations"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/canary"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/location"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/proxy"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/referrer"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/rewrite"
 	"k8s.io/ingress-nginx/internal/ingress/controller/config"
 	ngx_config "k8s.io/ingress-nginx/internal/ingress/controller/config"
 	"k8s.io/ingress-nginx/internal/ingress/controller/ingressclass"
 	"k8s.io/ingress-nginx/internal/ingress/controller/store"
+	ngx_template "k8s.io/ingress-nginx/internal/ingress/controller/template"
 	"k8s.io/ingress-nginx/internal/ingress/defaults"
+	ing_errors "k8s.io/ingress-nginx/internal/ingress/errors"
 	"k8s.io/ingress-nginx/internal/ingress/metric"
 	"k8s.io/ingress-nginx/internal/ingress/resolver"
 	"k8s.io/ingress-nginx/internal/k8s"
@@ -69,6 +79,14 @@ func (fakeIngressStore) GetBackendConfiguration() ngx_config.Configuration {
 	return ngx_config.Configuration{}
 }
 
+func (fakeIngressStore) GetNamespaceConfiguration(namespace string) ngx_config.Configuration {
+	return ngx_config.Configuration{}
+}
+
+func (fakeIngressStore) GetCanaryReferrerMatcher() *referrer.Matcher {
+	return referrer.NewMatcher("")
+}
+
 func (fakeIngressStore) GetConfigMap(key string) (*corev1.ConfigMap, error) {
 	return nil, fmt.Errorf("test error")
 }
@@ -85,6 +103,10 @@ func (fakeIngressStore) GetServiceEndpoints(key string) (*corev1.Endpoints, erro
 	return nil, fmt.Errorf("test error")
 }
 
+func (fakeIngressStore) GetPod(key string) (*corev1.Pod, error) {
+	return nil, fmt.Errorf("test error")
+}
+
 func (fis fakeIngressStore) ListIngresses(store.IngressFilterFunc) []*ingress.Ingress {
 	return fis.ingresses
 }
@@ -109,6 +131,10 @@ func (fakeIngressStore) GetDefaultBackend() defaults.Backend {
 	return defaults.Backend{}
 }
 
+func (fakeIngressStore) GetCanaryLimits() resolver.CanaryLimits {
+	return resolver.CanaryLimits{}
+}
+
 func (fakeIngressStore) GetIngressCheckSum(key string) (*ingcheckv1.IngressCheckSum, error) {
 	return nil, fmt.Errorf("test error")
 }
@@ -301,6 +327,69 @@ func TestCheckIngress(t *testing.T) {
 	})
 }
 
+func TestDiffIngress(t *testing.T) {
+	var nginx *NGINXController
+	if _, err := nginx.DiffIngress(nil); err == nil {
+		t.Errorf("expected an error diffing an ingress on a nil controller")
+	}
+
+	nginx = &NGINXController{}
+	diff, err := nginx.DiffIngress(nil)
+	if err != nil || diff != "" {
+		t.Errorf("expected no diff and no error for a nil ingress but got %q and %v", diff, err)
+	}
+}
+
+func TestCheckWarning(t *testing.T) {
+	nginx := &NGINXController{}
+
+	ing := &networking.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-ingress-warning",
+			Namespace: "user-namespace",
+			Annotations: map[string]string{
+				"tengine.taobao.org/canary":                 "true",
+				"nginx.ingress.kubernetes.io/canary-weight": "10",
+				"tengine.taobao.org/owner":                  "team-a",
+				"kubernetes.io/ingress.class":               "nginx",
+			},
+		},
+	}
+
+	warnings, err := nginx.CheckWarning(ing)
+	if err != nil {
+		t.Fatalf("no error should be returned, got %v", err)
+	}
+	if len(warnings) != 1 {
+		t.Fatalf("expected one warning, got %v", warnings)
+	}
+	if !strings.Contains(warnings[0], "nginx.ingress.kubernetes.io/canary ") {
+		t.Errorf("expected warning to suggest the nginx.ingress.kubernetes.io prefix, got %v", warnings[0])
+	}
+
+	ing.Annotations = map[string]string{
+		"nginx.ingress.kubernetes.io/ingress.class": "nginx",
+	}
+	warnings, err = nginx.CheckWarning(ing)
+	if err != nil {
+		t.Fatalf("no error should be returned, got %v", err)
+	}
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "kubernetes.io/ingress.class instead") {
+		t.Errorf("expected a warning suggesting the kubernetes.io prefix, got %v", warnings)
+	}
+
+	ing.Annotations = map[string]string{
+		"nginx.ingress.kubernetes.io/upstream-send-buffer": "256k",
+	}
+	warnings, err = nginx.CheckWarning(ing)
+	if err != nil {
+		t.Fatalf("no error should be returned, got %v", err)
+	}
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "is not supported by Tengine") {
+		t.Errorf("expected a warning about the unsupported annotation, got %v", warnings)
+	}
+}
+
 func TestMergeAlternativeBackends(t *testing.T) {
 	testCases := map[string]struct {
 		ingress      *ingress.Ingress
@@ -866,121 +955,748 @@ func TestExtractTLSSecretName(t *testing.T) {
 	}
 }
 
-func TestGetBackendServers(t *testing.T) {
-	ctl := newNGINXController(t)
+func TestLimitSSLCerts(t *testing.T) {
+	certs := []*ingress.SSLCert{
+		{Name: "www.example.com"},
+		{Name: "*.example.com"},
+		{Name: "example.com"},
+	}
 
-	testCases := []struct {
-		Ingresses []*ingress.Ingress
-		Validate  func(upstreams []*ingress.Backend, servers []*ingress.Server)
+	testCases := map[string]struct {
+		max      int
+		expNames []string
 	}{
-		{
-			Ingresses: []*ingress.Ingress{
-				{
-					Ingress: networking.Ingress{
-						ObjectMeta: metav1.ObjectMeta{
-							Namespace: "example",
-						},
-						Spec: networking.IngressSpec{
-							Backend: &networking.IngressBackend{
-								ServiceName: "http-svc-canary",
-								ServicePort: intstr.IntOrString{
-									IntVal: 80,
+		"limit of one certificate": {
+			1,
+			[]string{"www.example.com"},
+		},
+		"limit of two certificates": {
+			2,
+			[]string{"www.example.com", "*.example.com"},
+		},
+		"limit of three certificates": {
+			3,
+			[]string{"www.example.com", "*.example.com", "example.com"},
+		},
+		"no limit": {
+			0,
+			[]string{"www.example.com", "*.example.com", "example.com"},
+		},
+	}
+
+	for title, tc := range testCases {
+		t.Run(title, func(t *testing.T) {
+			limited := limitSSLCerts(certs, tc.max)
+			if len(limited) != len(tc.expNames) {
+				t.Fatalf("Expected %d certificates (got %d)", len(tc.expNames), len(limited))
+			}
+			for i, cert := range limited {
+				if cert.Name != tc.expNames[i] {
+					t.Errorf("Expected certificate %q at position %d (got %q)", tc.expNames[i], i, cert.Name)
+				}
+			}
+		})
+	}
+}
+
+type fakeSSLCertStore struct {
+	fakeEndpointsStore
+	maxCertsPerServer int
+}
+
+func (s fakeSSLCertStore) GetBackendConfiguration() ngx_config.Configuration {
+	cfg := ngx_config.NewDefault()
+	cfg.MaxCertsPerServer = s.maxCertsPerServer
+	return cfg
+}
+
+func (fakeSSLCertStore) GetLocalSSLCert(name string) (*ingress.SSLCert, error) {
+	return &ingress.SSLCert{
+		Name:        name,
+		Certificate: &x509.Certificate{DNSNames: []string{"example.com"}},
+		ExpireTime:  time.Now().Add(365 * 24 * time.Hour),
+	}, nil
+}
+
+func TestGetBackendServersSSLCertLimit(t *testing.T) {
+	ing := &ingress.Ingress{
+		Ingress: networking.Ingress{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "many-certs"},
+			Spec: networking.IngressSpec{
+				TLS: []networking.IngressTLS{
+					{Hosts: []string{"example.com"}, SecretName: "rsa"},
+					{Hosts: []string{"example.com"}, SecretName: "ecc-long"},
+					{Hosts: []string{"example.com"}, SecretName: "ecc"},
+				},
+				Rules: []networking.IngressRule{
+					{
+						Host: "example.com",
+						IngressRuleValue: networking.IngressRuleValue{
+							HTTP: &networking.HTTPIngressRuleValue{
+								Paths: []networking.HTTPIngressPath{
+									{
+										Path: "/",
+										Backend: networking.IngressBackend{
+											Service: &networking.IngressServiceBackend{
+												Name: "web",
+												Port: networking.ServiceBackendPort{Number: 80},
+											},
+										},
+									},
 								},
 							},
 						},
 					},
-					ParsedAnnotations: &annotations.Ingress{
-						Canary: canary.Config{
-							Enabled:  true,
-							Referrer: "tengine",
-						},
-					},
 				},
 			},
-			Validate: func(upstreams []*ingress.Backend, servers []*ingress.Server) {
-				if len(servers) != 1 {
-					t.Errorf("servers count should be 1, got %d", len(servers))
-					return
-				}
+		},
+		ParsedAnnotations: &annotations.Ingress{},
+	}
 
-				s := servers[0]
-				if s.Hostname != "_" {
-					t.Errorf("server hostname should be '_', got '%s'", s.Hostname)
-				}
-				if !s.Locations[0].IsDefBackend {
-					t.Errorf("server location 0 should be default backend")
+	testCases := map[string]struct {
+		max      int
+		expNames []string
+	}{
+		"limit of one certificate": {
+			1,
+			[]string{"default/ecc-long"},
+		},
+		"limit of two certificates": {
+			2,
+			[]string{"default/ecc-long", "default/rsa"},
+		},
+		"no limit": {
+			0,
+			[]string{"default/ecc-long", "default/rsa", "default/ecc"},
+		},
+	}
+
+	for title, tc := range testCases {
+		t.Run(title, func(t *testing.T) {
+			n := &NGINXController{
+				store:           fakeSSLCertStore{maxCertsPerServer: tc.max},
+				metricCollector: metric.DummyCollector{},
+				cfg: &Configuration{
+					ListenPorts: &ngx_config.ListenPorts{Default: 80},
+				},
+			}
+
+			_, servers := n.getBackendServers([]*ingress.Ingress{ing})
+
+			var server *ingress.Server
+			for _, s := range servers {
+				if s.Hostname == "example.com" {
+					server = s
 				}
+			}
+			if server == nil {
+				t.Fatalf("expected the server example.com")
+			}
 
-				if s.Locations[0].Backend != defUpstreamName {
-					t.Errorf("location backend should be '%s', got '%s'", defUpstreamName, s.Locations[0].Backend)
+			names := []string{}
+			for _, cert := range server.SSLCerts {
+				names = append(names, cert.Name)
+			}
+			if !reflect.DeepEqual(names, tc.expNames) {
+				t.Errorf("expected the certificates %v but got %v", tc.expNames, names)
+			}
+		})
+	}
+}
+
+func TestHasSingleKeyType(t *testing.T) {
+	ecc := &ingress.SSLCert{Certificate: &x509.Certificate{PublicKeyAlgorithm: x509.ECDSA}}
+	rsa := &ingress.SSLCert{Certificate: &x509.Certificate{PublicKeyAlgorithm: x509.RSA}}
+	unknown := &ingress.SSLCert{}
+
+	if keyType := keyTypeOf(ecc); keyType != "ECC" {
+		t.Errorf("Expected the ECC key type (got %q)", keyType)
+	}
+	if keyType := keyTypeOf(rsa); keyType != "RSA" {
+		t.Errorf("Expected the RSA key type (got %q)", keyType)
+	}
+	if keyType := keyTypeOf(unknown); keyType != "" {
+		t.Errorf("Expected an unknown key type (got %q)", keyType)
+	}
+
+	testCases := map[string]struct {
+		certs    []*ingress.SSLCert
+		expected bool
+	}{
+		"only ECC":         {[]*ingress.SSLCert{ecc}, true},
+		"only RSA":         {[]*ingress.SSLCert{rsa, unknown}, true},
+		"ECC and RSA":      {[]*ingress.SSLCert{ecc, rsa}, false},
+		"unknown key type": {[]*ingress.SSLCert{unknown}, false},
+		"no certificates":  {nil, false},
+	}
+
+	for title, tc := range testCases {
+		t.Run(title, func(t *testing.T) {
+			server := &ingress.Server{Hostname: "example.com", SSLCerts: tc.certs}
+			if single := hasSingleKeyType(server); single != tc.expected {
+				t.Errorf("Expected single key type %v (got %v)", tc.expected, single)
+			}
+		})
+	}
+}
+
+func TestFindDuplicateLocation(t *testing.T) {
+	server := &ingress.Server{
+		Hostname: "example.com",
+		Locations: []*ingress.Location{
+			{Path: "/", IsDefBackend: true},
+			{Path: "/api", LocationPathPrefix: "/v1"},
+			{Path: "/exact", LocationPreceding: "="},
+		},
+	}
+
+	testCases := map[string]struct {
+		loc    *ingress.Location
+		expDup string
+	}{
+		"same prefix and path": {
+			&ingress.Location{Path: "/api", LocationPathPrefix: "/v1"},
+			"/api",
+		},
+		"different prefixes collapsing to the same path": {
+			&ingress.Location{Path: "/1/api", LocationPathPrefix: "/v", LocationPathEscape: true},
+			"/api",
+		},
+		"escaped path colliding with the root location": {
+			&ingress.Location{Path: "/", LocationPathPrefix: "/", LocationPathEscape: true},
+			"/",
+		},
+		"same path with a different modifier": {
+			&ingress.Location{Path: "/exact"},
+			"",
+		},
+		"no collision": {
+			&ingress.Location{Path: "/api", LocationPathPrefix: "/v2"},
+			"",
+		},
+	}
+
+	for title, tc := range testCases {
+		t.Run(title, func(t *testing.T) {
+			dup := findDuplicateLocation(server, tc.loc)
+			if tc.expDup == "" {
+				if dup != nil {
+					t.Errorf("Expected no duplicate location (got %q)", dup.Path)
 				}
-			},
+				return
+			}
+
+			if dup == nil {
+				t.Fatalf("Expected duplicate location %q (got none)", tc.expDup)
+			}
+			if dup.Path != tc.expDup {
+				t.Errorf("Expected duplicate location %q (got %q)", tc.expDup, dup.Path)
+			}
+		})
+	}
+}
+
+func TestCollectReservedLocations(t *testing.T) {
+	testCases := map[string]struct {
+		server   *ingress.Server
+		expected []string
+	}{
+		"server with a root location": {
+			&ingress.Server{Hostname: "example.com", Locations: []*ingress.Location{{Path: "/"}}},
+			[]string{"/robots.txt"},
 		},
-		{
-			Ingresses: []*ingress.Ingress{
-				{
-					Ingress: networking.Ingress{
-						ObjectMeta: metav1.ObjectMeta{
-							Namespace: "example",
-						},
-						Spec: networking.IngressSpec{
-							Backend: &networking.IngressBackend{
-								ServiceName: "http-svc-canary",
-								ServicePort: intstr.IntOrString{
-									IntVal: 80,
-								},
-							},
-						},
-					},
-					ParsedAnnotations: &annotations.Ingress{
-						Canary: canary.Config{
-							Enabled:  true,
-							Referrer: "tengine",
-						},
-					},
+		"server without a root location": {
+			&ingress.Server{Hostname: "example.com", Locations: []*ingress.Location{{Path: "/api"}}},
+			nil,
+		},
+		"default robots.txt location disabled": {
+			&ingress.Server{Hostname: "example.com", SuppressDefaultRobots: true, Locations: []*ingress.Location{{Path: "/"}}},
+			nil,
+		},
+		"regular expression locations": {
+			&ingress.Server{Hostname: "example.com", Locations: []*ingress.Location{
+				{Path: "/"},
+				{Path: "/api", Rewrite: rewrite.Config{UseRegex: true}},
+			}},
+			nil,
+		},
+		"default server": {
+			&ingress.Server{Hostname: "_", Locations: []*ingress.Location{{Path: "/"}}},
+			[]string{"/healthz", "/nginx_status", "/traffic_status", "/robots.txt"},
+		},
+	}
+
+	for title, tc := range testCases {
+		t.Run(title, func(t *testing.T) {
+			reserved := collectReservedLocations(tc.server)
+			if !reflect.DeepEqual(reserved, tc.expected) {
+				t.Errorf("Expected reserved locations %v (got %v)", tc.expected, reserved)
+			}
+		})
+	}
+}
+
+type fakeReservedLocationsStore struct {
+	fakeEndpointsStore
+	override bool
+}
+
+func (s fakeReservedLocationsStore) GetBackendConfiguration() ngx_config.Configuration {
+	cfg := ngx_config.NewDefault()
+	cfg.OverrideReservedLocations = s.override
+	return cfg
+}
+
+func TestGetBackendServersReservedLocations(t *testing.T) {
+	newPath := func(path string) networking.HTTPIngressPath {
+		return networking.HTTPIngressPath{
+			Path: path,
+			Backend: networking.IngressBackend{
+				Service: &networking.IngressServiceBackend{
+					Name: "web",
+					Port: networking.ServiceBackendPort{Number: 80},
 				},
-				{
-					Ingress: networking.Ingress{
-						ObjectMeta: metav1.ObjectMeta{
-							Namespace: "example",
-						},
-						Spec: networking.IngressSpec{
-							Backend: &networking.IngressBackend{
-								ServiceName: "http-svc",
-								ServicePort: intstr.IntOrString{
-									IntVal: 80,
-								},
+			},
+		}
+	}
+	newIngress := func(name, host string, anns *annotations.Ingress, paths ...string) *ingress.Ingress {
+		httpPaths := []networking.HTTPIngressPath{}
+		for _, p := range paths {
+			httpPaths = append(httpPaths, newPath(p))
+		}
+
+		return &ingress.Ingress{
+			Ingress: networking.Ingress{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name},
+				Spec: networking.IngressSpec{
+					Rules: []networking.IngressRule{
+						{
+							Host: host,
+							IngressRuleValue: networking.IngressRuleValue{
+								HTTP: &networking.HTTPIngressRuleValue{Paths: httpPaths},
 							},
 						},
 					},
-					ParsedAnnotations: &annotations.Ingress{
-						Canary: canary.Config{
-							Enabled:  false,
-							Referrer: "tengine",
-						},
-					},
 				},
 			},
-			Validate: func(upstreams []*ingress.Backend, servers []*ingress.Server) {
-				if len(servers) != 1 {
-					t.Errorf("servers count should be 1, got %d", len(servers))
-					return
-				}
-
-				s := servers[0]
-				if s.Hostname != "_" {
-					t.Errorf("server hostname should be '_', got '%s'", s.Hostname)
-				}
-				if s.Locations[0].IsDefBackend {
-					t.Errorf("server location 0 should not be default backend")
-				}
+			ParsedAnnotations: anns,
+		}
+	}
 
-				if s.Locations[0].Backend != "example-http-svc-80" {
-					t.Errorf("location backend should be 'example-http-svc-80', got '%s'", s.Locations[0].Backend)
-				}
-			},
+	testCases := map[string]struct {
+		ingresses        []*ingress.Ingress
+		override         bool
+		host             string
+		expectedPaths    []string
+		expectedSuppress bool
+	}{
+		"robots.txt path skipped": {
+			[]*ingress.Ingress{newIngress("web", "example.com", &annotations.Ingress{}, "/", "/robots.txt")},
+			false, "example.com", []string{"/"}, false,
 		},
-		{
+		"robots.txt path replacing the built-in location": {
+			[]*ingress.Ingress{newIngress("web", "example.com", &annotations.Ingress{}, "/", "/robots.txt")},
+			true, "example.com", []string{"/", "/robots.txt"}, true,
+		},
+		"robots.txt path with the built-in location disabled": {
+			[]*ingress.Ingress{newIngress("web", "example.com", &annotations.Ingress{DisableDefRobots: true}, "/", "/robots.txt")},
+			false, "example.com", []string{"/", "/robots.txt"}, true,
+		},
+		"robots.txt path with regular expressions": {
+			[]*ingress.Ingress{newIngress("web", "example.com", &annotations.Ingress{Rewrite: rewrite.Config{UseRegex: true}}, "/", "/robots.txt")},
+			false, "example.com", []string{"/", "/robots.txt"}, false,
+		},
+		"robots.txt path with an exact modifier": {
+			[]*ingress.Ingress{newIngress("web", "example.com", &annotations.Ingress{Location: location.Config{LocationPreceding: "="}}, "/robots.txt")},
+			false, "example.com", []string{"/", "/robots.txt"}, false,
+		},
+		"health check path of the default server": {
+			[]*ingress.Ingress{newIngress("web", "", &annotations.Ingress{}, "/healthz", "/api")},
+			true, "_", []string{"/", "/api"}, false,
+		},
+	}
+
+	for title, tc := range testCases {
+		t.Run(title, func(t *testing.T) {
+			n := &NGINXController{
+				store:           fakeReservedLocationsStore{override: tc.override},
+				metricCollector: metric.DummyCollector{},
+				cfg: &Configuration{
+					ListenPorts: &ngx_config.ListenPorts{Default: 80},
+				},
+			}
+
+			_, servers := n.getBackendServers(tc.ingresses)
+
+			var server *ingress.Server
+			for _, s := range servers {
+				if s.Hostname == tc.host {
+					server = s
+				}
+			}
+			if server == nil {
+				t.Fatalf("expected the server %v", tc.host)
+			}
+
+			paths := []string{}
+			for _, location := range server.Locations {
+				paths = append(paths, location.Path)
+			}
+			sort.Strings(paths)
+			if !reflect.DeepEqual(paths, tc.expectedPaths) {
+				t.Errorf("expected the paths %v but got %v", tc.expectedPaths, paths)
+			}
+			if server.SuppressDefaultRobots != tc.expectedSuppress {
+				t.Errorf("expected the built-in robots.txt location suppressed %v but got %v", tc.expectedSuppress, server.SuppressDefaultRobots)
+			}
+		})
+	}
+}
+
+func TestResolveReservedLocationsServerSnippet(t *testing.T) {
+	snippet := "location /robots.txt { return 200; }"
+
+	testCases := map[string]struct {
+		server   *ingress.Server
+		expected string
+	}{
+		"built-in robots.txt location": {
+			&ingress.Server{Hostname: "example.com", ServerSnippet: snippet, Locations: []*ingress.Location{{Path: "/"}}},
+			"",
+		},
+		"built-in robots.txt location disabled by the host": {
+			&ingress.Server{Hostname: "example.com", ServerSnippet: snippet, SuppressDefaultRobots: true, Locations: []*ingress.Location{{Path: "/"}}},
+			snippet,
+		},
+		"server without a root location": {
+			&ingress.Server{Hostname: "example.com", ServerSnippet: snippet, Locations: []*ingress.Location{{Path: "/api"}}},
+			snippet,
+		},
+	}
+
+	for title, tc := range testCases {
+		t.Run(title, func(t *testing.T) {
+			resolveReservedLocations(tc.server, false)
+			if tc.server.ServerSnippet != tc.expected {
+				t.Errorf("Expected the server snippet %q (got %q)", tc.expected, tc.server.ServerSnippet)
+			}
+		})
+	}
+}
+
+func TestCheckServerSnippetLocations(t *testing.T) {
+	servers := []*ingress.Server{
+		{Hostname: defServerName},
+		{Hostname: "example.com", Locations: []*ingress.Location{{Path: "/"}}},
+		{Hostname: "api.example.com", Locations: []*ingress.Location{{Path: "/api"}}},
+	}
+
+	testCases := map[string]struct {
+		host    string
+		snippet string
+		expErr  bool
+	}{
+		"health location of a host":             {"example.com", "location /healthz { return 200; }", false},
+		"health location of the default server": {"", "location /healthz { return 200; }", true},
+		"robots.txt location of a root path":    {"example.com", "location /robots.txt { return 200; }", true},
+		"robots.txt location without root path": {"api.example.com", "location /robots.txt { return 200; }", false},
+		"without snippet":                       {"example.com", "", false},
+	}
+
+	for title, tc := range testCases {
+		t.Run(title, func(t *testing.T) {
+			ing := &networking.Ingress{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "example",
+					Namespace: "default",
+					Annotations: map[string]string{
+						parser.GetAnnotationWithPrefix("server-snippet"): tc.snippet,
+					},
+				},
+				Spec: networking.IngressSpec{
+					Rules: []networking.IngressRule{{Host: tc.host}},
+				},
+			}
+
+			err := checkServerSnippetLocations(ing, servers)
+			if tc.expErr && !ing_errors.IsInvalidConfiguration(err) {
+				t.Errorf("Expected an invalid configuration error (got %v)", err)
+			}
+			if !tc.expErr && err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestReloadReason(t *testing.T) {
+	newConfig := func(checksum, pemSHA string, hosts ...string) *ingress.Configuration {
+		cfg := &ingress.Configuration{BackendConfigChecksum: checksum}
+		for _, host := range hosts {
+			cfg.Servers = append(cfg.Servers, &ingress.Server{
+				Hostname: host,
+				SSLCerts: []*ingress.SSLCert{{PemSHA: pemSHA}},
+			})
+			cfg.Backends = append(cfg.Backends, &ingress.Backend{Name: host + "-80"})
+		}
+		return cfg
+	}
+
+	running := newConfig("1", "sha", "a.com", "b.com")
+
+	testCases := map[string]struct {
+		running  *ingress.Configuration
+		pcfg     *ingress.Configuration
+		expected string
+	}{
+		"first sync": {
+			&ingress.Configuration{},
+			newConfig("1", "sha", "a.com"),
+			"initial",
+		},
+		"configmap changed": {
+			running,
+			newConfig("2", "sha", "a.com", "b.com"),
+			"configmap",
+		},
+		"server added": {
+			running,
+			newConfig("1", "sha", "a.com", "b.com", "c.com"),
+			"servers_added",
+		},
+		"server removed": {
+			running,
+			newConfig("1", "sha", "a.com"),
+			"servers_removed",
+		},
+		"server replaced": {
+			running,
+			newConfig("1", "sha", "a.com", "c.com"),
+			"servers_changed",
+		},
+		"certificate changed": {
+			running,
+			newConfig("1", "new-sha", "a.com", "b.com"),
+			"ssl_changed",
+		},
+		"backend replaced": {
+			running,
+			func() *ingress.Configuration {
+				cfg := newConfig("1", "sha", "a.com", "b.com")
+				cfg.Backends[1].Name = "b.com-8080"
+				return cfg
+			}(),
+			"backends_changed",
+		},
+		"locations changed": {
+			running,
+			func() *ingress.Configuration {
+				cfg := newConfig("1", "sha", "a.com", "b.com")
+				cfg.Servers[0].Locations = []*ingress.Location{{Path: "/"}}
+				return cfg
+			}(),
+			"other",
+		},
+	}
+
+	for title, tc := range testCases {
+		t.Run(title, func(t *testing.T) {
+			reason := reloadReason(tc.running, tc.pcfg)
+			if reason != tc.expected {
+				t.Errorf("Expected reason %q (got %q)", tc.expected, reason)
+			}
+		})
+	}
+}
+
+func TestReloadBackoff(t *testing.T) {
+	testCases := map[string]struct {
+		steps       int
+		maxInterval int
+		expSteps    int
+		expCap      time.Duration
+	}{
+		"default":        {3, 10, 3, 10 * time.Second},
+		"without steps":  {0, 10, 1, 10 * time.Second},
+		"custom capping": {5, 4, 5, 4 * time.Second},
+	}
+
+	for title, tc := range testCases {
+		t.Run(title, func(t *testing.T) {
+			cfg := ngx_config.NewDefault()
+			cfg.ReloadRetrySteps = tc.steps
+			cfg.ReloadRetryMaxInterval = tc.maxInterval
+
+			backoff := reloadBackoff(cfg)
+			if backoff.Steps != tc.expSteps {
+				t.Errorf("Expected %v steps (got %v)", tc.expSteps, backoff.Steps)
+			}
+			if backoff.Cap != tc.expCap {
+				t.Errorf("Expected the cap %v (got %v)", tc.expCap, backoff.Cap)
+			}
+		})
+	}
+}
+
+func TestDropDuplicateStreamServices(t *testing.T) {
+	svcs := func(ports ...int) []ingress.L4Service {
+		var l4 []ingress.L4Service
+		for i, port := range ports {
+			l4 = append(l4, ingress.L4Service{
+				Port:    port,
+				Backend: ingress.L4Backend{Namespace: "default", Name: fmt.Sprintf("svc-%d-%d", port, i)},
+			})
+		}
+		return l4
+	}
+	names := func(l4 []ingress.L4Service) []string {
+		n := []string{}
+		for _, svc := range l4 {
+			n = append(n, svc.Backend.Name)
+		}
+		return n
+	}
+
+	testCases := map[string]struct {
+		svcs       []ingress.L4Service
+		expKept    []string
+		expDropped []string
+	}{
+		"no duplicates": {
+			svcs(53, 9000, 9001),
+			[]string{"svc-53-0", "svc-9000-1", "svc-9001-2"},
+			[]string{},
+		},
+		"duplicate ports": {
+			svcs(53, 53, 9000, 9000, 9000),
+			[]string{"svc-53-0", "svc-9000-2"},
+			[]string{"svc-53-1", "svc-9000-3", "svc-9000-4"},
+		},
+		"no services": {
+			nil,
+			[]string{},
+			[]string{},
+		},
+	}
+
+	for title, tc := range testCases {
+		t.Run(title, func(t *testing.T) {
+			kept, dropped := dropDuplicateStreamServices(tc.svcs)
+			if !reflect.DeepEqual(names(kept), tc.expKept) {
+				t.Errorf("Expected kept services %v (got %v)", tc.expKept, names(kept))
+			}
+			if !reflect.DeepEqual(names(dropped), tc.expDropped) {
+				t.Errorf("Expected dropped services %v (got %v)", tc.expDropped, names(dropped))
+			}
+		})
+	}
+
+	// a TCP and an UDP service can share a port
+	tcp, _ := dropDuplicateStreamServices(svcs(53))
+	udp, _ := dropDuplicateStreamServices(svcs(53))
+	if len(tcp) != 1 || len(udp) != 1 {
+		t.Errorf("Expected the TCP and the UDP services of port 53 to be kept (got %v and %v)", names(tcp), names(udp))
+	}
+}
+
+func TestParseL4HealthCheck(t *testing.T) {
+	testCases := map[string]struct {
+		spec   string
+		expHC  *ingress.L4HealthCheck
+		expErr bool
+	}{
+		"interval and fall": {
+			"hc=5s,fall=3",
+			&ingress.L4HealthCheck{Interval: 5, Timeout: defaultL4HealthCheckTimeout, Fall: 3},
+			false,
+		},
+		"every field": {
+			"hc=1m, timeout=2s, fall=1",
+			&ingress.L4HealthCheck{Interval: 60, Timeout: 2, Fall: 1},
+			false,
+		},
+		"defaults": {
+			"fall=2",
+			&ingress.L4HealthCheck{Interval: defaultL4HealthCheckInterval, Timeout: defaultL4HealthCheckTimeout, Fall: 2},
+			false,
+		},
+		"unknown key":           {"hc=5s,rise=2", nil, true},
+		"missing value":         {"hc=", nil, true},
+		"missing separator":     {"hc=5s,fall", nil, true},
+		"invalid duration":      {"hc=5", nil, true},
+		"sub-second duration":   {"timeout=500ms", nil, true},
+		"fractional duration":   {"hc=1500ms", nil, true},
+		"invalid fall":          {"fall=three", nil, true},
+		"non positive fall":     {"fall=0", nil, true},
+		"negative timeout":      {"timeout=-1s", nil, true},
+		"trailing empty fields": {"hc=5s,", nil, true},
+	}
+
+	for title, tc := range testCases {
+		t.Run(title, func(t *testing.T) {
+			hc, err := parseL4HealthCheck(tc.spec)
+			if tc.expErr {
+				if err == nil {
+					t.Errorf("Expected an error parsing %q (got %+v)", tc.spec, hc)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Unexpected error parsing %q: %v", tc.spec, err)
+			}
+			if !hc.Equal(tc.expHC) {
+				t.Errorf("Expected %+v (got %+v)", tc.expHC, hc)
+			}
+		})
+	}
+}
+
+func TestParseL4ConnLimit(t *testing.T) {
+	testCases := map[string]struct {
+		spec     string
+		expLimit int
+		expRest  string
+		expErr   bool
+	}{
+		"only conn-limit":    {"conn-limit=100", 100, "", false},
+		"with health check":  {"hc=5s, conn-limit=10,fall=2", 10, "hc=5s,fall=2", false},
+		"without conn-limit": {"hc=5s,fall=2", 0, "hc=5s,fall=2", false},
+		"zero":               {"conn-limit=0", 0, "", false},
+		"negative":           {"conn-limit=-1", 0, "", true},
+		"not a number":       {"conn-limit=ten", 0, "", true},
+		"missing value":      {"conn-limit=", 0, "", true},
+		"missing separator":  {"conn-limit", 0, "", true},
+	}
+
+	for title, tc := range testCases {
+		t.Run(title, func(t *testing.T) {
+			limit, rest, err := parseL4ConnLimit(tc.spec)
+			if tc.expErr {
+				if err == nil {
+					t.Errorf("Expected an error parsing %q (got %v)", tc.spec, limit)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Unexpected error parsing %q: %v", tc.spec, err)
+			}
+			if limit != tc.expLimit || rest != tc.expRest {
+				t.Errorf("Expected %v and %q (got %v and %q)", tc.expLimit, tc.expRest, limit, rest)
+			}
+		})
+	}
+}
+
+func TestGetBackendServers(t *testing.T) {
+	ctl := newNGINXController(t)
+
+	testCases := []struct {
+		Ingresses []*ingress.Ingress
+		Validate  func(upstreams []*ingress.Backend, servers []*ingress.Server)
+	}{
+		{
 			Ingresses: []*ingress.Ingress{
 				{
 					Ingress: networking.Ingress{
@@ -988,25 +1704,10 @@ func TestGetBackendServers(t *testing.T) {
 							Namespace: "example",
 						},
 						Spec: networking.IngressSpec{
-							Rules: []networking.IngressRule{
-								{
-									Host: "example.com",
-									IngressRuleValue: networking.IngressRuleValue{
-										HTTP: &networking.HTTPIngressRuleValue{
-											Paths: []networking.HTTPIngressPath{
-												{
-													Path: "/",
-													Backend: networking.IngressBackend{
-														ServiceName: "http-svc-canary",
-														ServicePort: intstr.IntOrString{
-															Type:   intstr.Int,
-															IntVal: 80,
-														},
-													},
-												},
-											},
-										},
-									},
+							Backend: &networking.IngressBackend{
+								ServiceName: "http-svc-canary",
+								ServicePort: intstr.IntOrString{
+									IntVal: 80,
 								},
 							},
 						},
@@ -1043,29 +1744,34 @@ func TestGetBackendServers(t *testing.T) {
 				{
 					Ingress: networking.Ingress{
 						ObjectMeta: metav1.ObjectMeta{
-							Name:      "example",
 							Namespace: "example",
 						},
 						Spec: networking.IngressSpec{
-							Rules: []networking.IngressRule{
-								{
-									Host: "example.com",
-									IngressRuleValue: networking.IngressRuleValue{
-										HTTP: &networking.HTTPIngressRuleValue{
-											Paths: []networking.HTTPIngressPath{
-												{
-													Path: "/",
-													Backend: networking.IngressBackend{
-														ServiceName: "http-svc",
-														ServicePort: intstr.IntOrString{
-															Type:   intstr.Int,
-															IntVal: 80,
-														},
-													},
-												},
-											},
-										},
-									},
+							Backend: &networking.IngressBackend{
+								ServiceName: "http-svc-canary",
+								ServicePort: intstr.IntOrString{
+									IntVal: 80,
+								},
+							},
+						},
+					},
+					ParsedAnnotations: &annotations.Ingress{
+						Canary: canary.Config{
+							Enabled:  true,
+							Referrer: "tengine",
+						},
+					},
+				},
+				{
+					Ingress: networking.Ingress{
+						ObjectMeta: metav1.ObjectMeta{
+							Namespace: "example",
+						},
+						Spec: networking.IngressSpec{
+							Backend: &networking.IngressBackend{
+								ServiceName: "http-svc",
+								ServicePort: intstr.IntOrString{
+									IntVal: 80,
 								},
 							},
 						},
@@ -1077,10 +1783,31 @@ func TestGetBackendServers(t *testing.T) {
 						},
 					},
 				},
+			},
+			Validate: func(upstreams []*ingress.Backend, servers []*ingress.Server) {
+				if len(servers) != 1 {
+					t.Errorf("servers count should be 1, got %d", len(servers))
+					return
+				}
+
+				s := servers[0]
+				if s.Hostname != "_" {
+					t.Errorf("server hostname should be '_', got '%s'", s.Hostname)
+				}
+				if s.Locations[0].IsDefBackend {
+					t.Errorf("server location 0 should not be default backend")
+				}
+
+				if s.Locations[0].Backend != "example-http-svc-80" {
+					t.Errorf("location backend should be 'example-http-svc-80', got '%s'", s.Locations[0].Backend)
+				}
+			},
+		},
+		{
+			Ingresses: []*ingress.Ingress{
 				{
 					Ingress: networking.Ingress{
 						ObjectMeta: metav1.ObjectMeta{
-							Name:      "example-canary",
 							Namespace: "example",
 						},
 						Spec: networking.IngressSpec{
@@ -1116,8 +1843,8 @@ func TestGetBackendServers(t *testing.T) {
 				},
 			},
 			Validate: func(upstreams []*ingress.Backend, servers []*ingress.Server) {
-				if len(servers) != 2 {
-					t.Errorf("servers count should be 2, got %d", len(servers))
+				if len(servers) != 1 {
+					t.Errorf("servers count should be 1, got %d", len(servers))
 					return
 				}
 
@@ -1132,7 +1859,103 @@ func TestGetBackendServers(t *testing.T) {
 				if s.Locations[0].Backend != defUpstreamName {
 					t.Errorf("location backend should be '%s', got '%s'", defUpstreamName, s.Locations[0].Backend)
 				}
-
+			},
+		},
+		{
+			Ingresses: []*ingress.Ingress{
+				{
+					Ingress: networking.Ingress{
+						ObjectMeta: metav1.ObjectMeta{
+							Name:      "example",
+							Namespace: "example",
+						},
+						Spec: networking.IngressSpec{
+							Rules: []networking.IngressRule{
+								{
+									Host: "example.com",
+									IngressRuleValue: networking.IngressRuleValue{
+										HTTP: &networking.HTTPIngressRuleValue{
+											Paths: []networking.HTTPIngressPath{
+												{
+													Path: "/",
+													Backend: networking.IngressBackend{
+														ServiceName: "http-svc",
+														ServicePort: intstr.IntOrString{
+															Type:   intstr.Int,
+															IntVal: 80,
+														},
+													},
+												},
+											},
+										},
+									},
+								},
+							},
+						},
+					},
+					ParsedAnnotations: &annotations.Ingress{
+						Canary: canary.Config{
+							Enabled:  false,
+							Referrer: "tengine",
+						},
+					},
+				},
+				{
+					Ingress: networking.Ingress{
+						ObjectMeta: metav1.ObjectMeta{
+							Name:      "example-canary",
+							Namespace: "example",
+						},
+						Spec: networking.IngressSpec{
+							Rules: []networking.IngressRule{
+								{
+									Host: "example.com",
+									IngressRuleValue: networking.IngressRuleValue{
+										HTTP: &networking.HTTPIngressRuleValue{
+											Paths: []networking.HTTPIngressPath{
+												{
+													Path: "/",
+													Backend: networking.IngressBackend{
+														ServiceName: "http-svc-canary",
+														ServicePort: intstr.IntOrString{
+															Type:   intstr.Int,
+															IntVal: 80,
+														},
+													},
+												},
+											},
+										},
+									},
+								},
+							},
+						},
+					},
+					ParsedAnnotations: &annotations.Ingress{
+						Canary: canary.Config{
+							Enabled:  true,
+							Referrer: "tengine",
+						},
+					},
+				},
+			},
+			Validate: func(upstreams []*ingress.Backend, servers []*ingress.Server) {
+				if len(servers) != 2 {
+					t.Errorf("servers count should be 2, got %d", len(servers))
+					return
+				}
+
+				s := servers[0]
+				if s.Hostname != "_" {
+					t.Errorf("server hostname should be '_', got '%s'", s.Hostname)
+				}
+				if !s.Locations[0].IsDefBackend {
+					t.Errorf("server location 0 should be default backend")
+				}
+
+				if s.Locations[0].Backend != defUpstreamName {
+					t.Errorf("location backend should be '%s', got '%s'", defUpstreamName, s.Locations[0].Backend)
+				}
+
 				s = servers[1]
 				if s.Hostname != "example.com" {
 					t.Errorf("server hostname should be 'example.com', got '%s'", s.Hostname)
@@ -1533,3 +2356,832 @@ func fakeX509Cert(dnsNames []string) *x509.Certificate {
 		},
 	}
 }
+
+type fakeReferrerStore struct {
+	fakeIngressStore
+	canaryReferrers string
+}
+
+func (s fakeReferrerStore) GetCanaryReferrerMatcher() *referrer.Matcher {
+	return referrer.NewMatcher(s.canaryReferrers)
+}
+
+func TestVerifyCanaryReferrer(t *testing.T) {
+	n := &NGINXController{
+		store:           fakeReferrerStore{canaryReferrers: "tengine,team-a-*"},
+		metricCollector: metric.DummyCollector{},
+	}
+
+	testCases := map[string]struct {
+		referrer string
+		expected bool
+	}{
+		"empty referrer": {"", true},
+		"exact match":    {"tengine", true},
+		"wildcard match": {"team-a-web", true},
+		"not allowed":    {"team-b-web", false},
+	}
+
+	for title, tc := range testCases {
+		t.Run(title, func(t *testing.T) {
+			anns := &annotations.Ingress{}
+			anns.Canary.Referrer = tc.referrer
+			if v := n.verifyCanaryReferrer("default/canary", anns); v != tc.expected {
+				t.Errorf("expected %v but got %v", tc.expected, v)
+			}
+		})
+	}
+}
+
+type countingCollector struct {
+	metric.DummyCollector
+	ingChecksumErrors int
+}
+
+func (c *countingCollector) IncIngChecksumErrorCount() {
+	c.ingChecksumErrors++
+}
+
+func TestIngChecksumMismatch(t *testing.T) {
+	statusFile := filepath.Join(t.TempDir(), "status.tengine")
+	if err := os.WriteFile(statusFile, []byte{}, 0644); err != nil {
+		t.Fatalf("unexpected error creating %v: %v", statusFile, err)
+	}
+
+	mismatch := fmt.Errorf("Check Ingress ID: {md5[local]} is wrong")
+
+	testCases := map[string]struct {
+		enforce    bool
+		statusFile string
+		expectErr  bool
+		expectInc  int
+	}{
+		"enforce blocks the reload":       {true, statusFile, true, 1},
+		"enforce without status file":     {true, "/nonexistent/status.tengine", true, 0},
+		"warn-only reloads and meters":    {false, statusFile, false, 1},
+		"warn-only reloads without meter": {false, "/nonexistent/status.tengine", false, 0},
+	}
+
+	for title, tc := range testCases {
+		t.Run(title, func(t *testing.T) {
+			mc := &countingCollector{}
+			n := &NGINXController{metricCollector: mc}
+
+			cfg := ngx_config.NewDefault()
+			cfg.IngChecksumEnforce = tc.enforce
+			cfg.StatusTengineFilePath = tc.statusFile
+
+			err := n.ingChecksumMismatch(cfg, mismatch)
+			if tc.expectErr && err != mismatch {
+				t.Errorf("expected the mismatch error but got %v", err)
+			}
+			if !tc.expectErr && err != nil {
+				t.Errorf("expected no error but got %v", err)
+			}
+			if mc.ingChecksumErrors != tc.expectInc {
+				t.Errorf("expected %v checksum errors metered but got %v", tc.expectInc, mc.ingChecksumErrors)
+			}
+		})
+	}
+}
+
+type staleCollector struct {
+	metric.DummyCollector
+	stale bool
+}
+
+func (c *staleCollector) SetConfigStale(stale bool) {
+	c.stale = stale
+}
+
+func TestConfigGenerations(t *testing.T) {
+	mc := &staleCollector{}
+	n := &NGINXController{metricCollector: mc}
+
+	n.desireConfig("1")
+	n.configReloaded()
+	if n.liveConfigGeneration != 1 || n.liveConfigChecksum != "1" || mc.stale {
+		t.Fatalf("expected generation 1 to be live but got %v (%v), stale %v", n.liveConfigGeneration, n.liveConfigChecksum, mc.stale)
+	}
+
+	// a failed reload keeps the previous generation live
+	n.desireConfig("2")
+	n.configReloadFailed()
+	if n.desiredConfigGeneration != 2 || n.liveConfigGeneration != 1 || !n.configStale || !mc.stale {
+		t.Fatalf("expected generation 1 to be stale but got desired %v, live %v, stale %v", n.desiredConfigGeneration, n.liveConfigGeneration, mc.stale)
+	}
+
+	// retrying the same configuration does not start a new generation
+	n.desireConfig("2")
+	if n.desiredConfigGeneration != 2 {
+		t.Errorf("expected desired generation 2 but got %v", n.desiredConfigGeneration)
+	}
+
+	n.configReloaded()
+	if n.liveConfigGeneration != 2 || n.liveConfigChecksum != "2" || n.configStale || mc.stale {
+		t.Errorf("expected generation 2 to be live but got %v (%v), stale %v", n.liveConfigGeneration, n.liveConfigChecksum, mc.stale)
+	}
+}
+
+type fakeEndpointsStore struct {
+	fakeIngressStore
+}
+
+func (fakeEndpointsStore) GetService(key string) (*corev1.Service, error) {
+	ns, name, _ := k8s.ParseNameNS(key)
+	return &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Namespace: ns, Name: name},
+		Spec: corev1.ServiceSpec{
+			ClusterIP: "10.0.0.1",
+			Ports: []corev1.ServicePort{
+				{Name: "http", Port: 80, TargetPort: intstr.FromInt(8080), Protocol: corev1.ProtocolTCP},
+			},
+		},
+	}, nil
+}
+
+func (fakeEndpointsStore) GetServiceEndpoints(key string) (*corev1.Endpoints, error) {
+	return &corev1.Endpoints{
+		Subsets: []corev1.EndpointSubset{
+			{
+				Addresses: []corev1.EndpointAddress{{IP: "10.1.0.1"}, {IP: "10.1.0.2"}, {IP: "10.1.0.3"}},
+				Ports:     []corev1.EndpointPort{{Name: "http", Port: 8080, Protocol: corev1.ProtocolTCP}},
+			},
+		},
+	}, nil
+}
+
+// fakeServiceIngresses returns ingresses with a path for each of count Services
+func fakeServiceIngresses(count int) []*ingress.Ingress {
+	ings := make([]*ingress.Ingress, 0, count)
+	for i := 0; i < count; i++ {
+		ings = append(ings, &ingress.Ingress{
+			Ingress: networking.Ingress{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: fmt.Sprintf("ing-%d", i)},
+				Spec: networking.IngressSpec{
+					Rules: []networking.IngressRule{
+						{
+							Host: fmt.Sprintf("host-%d.example.com", i),
+							IngressRuleValue: networking.IngressRuleValue{
+								HTTP: &networking.HTTPIngressRuleValue{
+									Paths: []networking.HTTPIngressPath{
+										{
+											Path: "/",
+											Backend: networking.IngressBackend{
+												Service: &networking.IngressServiceBackend{
+													Name: fmt.Sprintf("svc-%d", i),
+													Port: networking.ServiceBackendPort{Number: 80},
+												},
+											},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			ParsedAnnotations: &annotations.Ingress{},
+		})
+	}
+
+	return ings
+}
+
+func TestCreateUpstreamsEndpoints(t *testing.T) {
+	n := &NGINXController{
+		store:           fakeEndpointsStore{},
+		metricCollector: metric.DummyCollector{},
+	}
+
+	upstreams := n.createUpstreams(fakeServiceIngresses(100), newUpstream(defUpstreamName))
+	if len(upstreams) != 101 {
+		t.Fatalf("expected 101 upstreams but got %v", len(upstreams))
+	}
+
+	for i := 0; i < 100; i++ {
+		name := fmt.Sprintf("default-svc-%d-80", i)
+		ups, ok := upstreams[name]
+		if !ok {
+			t.Fatalf("expected the upstream %v", name)
+		}
+		if len(ups.Endpoints) != 3 {
+			t.Errorf("expected 3 endpoints in the upstream %v but got %v", name, len(ups.Endpoints))
+		}
+		if ups.Service == nil || ups.Service.Name != fmt.Sprintf("svc-%d", i) {
+			t.Errorf("expected the Service svc-%d in the upstream %v but got %v", i, name, ups.Service)
+		}
+	}
+}
+
+// fakeFilterStore serves the endpoints of three pods, with the label version
+// v1, v2 and none, the last one missing from the store
+type fakeFilterStore struct {
+	fakeEndpointsStore
+}
+
+func (fakeFilterStore) GetServiceEndpoints(key string) (*corev1.Endpoints, error) {
+	return &corev1.Endpoints{
+		Subsets: []corev1.EndpointSubset{
+			{
+				Addresses: []corev1.EndpointAddress{
+					{IP: "10.1.0.1", TargetRef: &corev1.ObjectReference{Kind: "Pod", Namespace: "default", Name: "pod-v1"}},
+					{IP: "10.1.0.2", TargetRef: &corev1.ObjectReference{Kind: "Pod", Namespace: "default", Name: "pod-v2"}},
+					{IP: "10.1.0.3", TargetRef: &corev1.ObjectReference{Kind: "Pod", Namespace: "default", Name: "pod-missing"}},
+					{IP: "10.1.0.4"},
+				},
+				Ports: []corev1.EndpointPort{{Name: "http", Port: 8080, Protocol: corev1.ProtocolTCP}},
+			},
+		},
+	}, nil
+}
+
+func (fakeFilterStore) GetPod(key string) (*corev1.Pod, error) {
+	switch key {
+	case "default/pod-v1":
+		return &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"version": "v1"}}}, nil
+	case "default/pod-v2":
+		return &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"version": "v2"}}}, nil
+	}
+
+	return nil, fmt.Errorf("pod %v not found", key)
+}
+
+func TestServiceEndpointsFilter(t *testing.T) {
+	n := &NGINXController{
+		store:           fakeFilterStore{},
+		metricCollector: metric.DummyCollector{},
+	}
+
+	testCases := map[string]struct {
+		filter   string
+		expected []string
+	}{
+		"no filter":         {"", []string{"10.1.0.1", "10.1.0.2", "10.1.0.3", "10.1.0.4"}},
+		"single match":      {"version=v2", []string{"10.1.0.2"}},
+		"set match":         {"version in (v1,v2)", []string{"10.1.0.1", "10.1.0.2"}},
+		"no match":          {"version=v3", []string{"10.1.0.1", "10.1.0.2", "10.1.0.3", "10.1.0.4"}},
+		"invalid selector":  {"version in (v1", []string{"10.1.0.1", "10.1.0.2", "10.1.0.3", "10.1.0.4"}},
+		"label not present": {"!version", []string{"10.1.0.1", "10.1.0.2", "10.1.0.3", "10.1.0.4"}},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			endps, err := n.serviceEndpoints("default/svc", "80", tc.filter)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			addresses := []string{}
+			for _, endp := range endps {
+				addresses = append(addresses, endp.Address)
+			}
+			if !reflect.DeepEqual(addresses, tc.expected) {
+				t.Errorf("expected the endpoints %v but got %v", tc.expected, addresses)
+			}
+		})
+	}
+}
+
+func TestRunParallel(t *testing.T) {
+	var mu sync.Mutex
+	running, maxRunning, done := 0, 0, 0
+
+	fns := make([]func(), 0, 50)
+	for i := 0; i < 50; i++ {
+		fns = append(fns, func() {
+			mu.Lock()
+			running++
+			if running > maxRunning {
+				maxRunning = running
+			}
+			mu.Unlock()
+
+			time.Sleep(time.Millisecond)
+
+			mu.Lock()
+			running--
+			done++
+			mu.Unlock()
+		})
+	}
+
+	runParallel(4, fns)
+
+	if done != 50 {
+		t.Errorf("expected 50 functions to run but got %v", done)
+	}
+	if maxRunning > 4 {
+		t.Errorf("expected at most 4 functions at a time but got %v", maxRunning)
+	}
+
+	// no functions and more workers than functions
+	runParallel(4, nil)
+	runParallel(8, fns[:1])
+}
+
+func BenchmarkCreateUpstreams(b *testing.B) {
+	n := &NGINXController{
+		store:           fakeEndpointsStore{},
+		metricCollector: metric.DummyCollector{},
+	}
+	ings := fakeServiceIngresses(5000)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		n.createUpstreams(ings, newUpstream(defUpstreamName))
+	}
+}
+
+type fakePathLimitStore struct {
+	fakeEndpointsStore
+	maxHostPathNum int
+}
+
+func (s fakePathLimitStore) GetBackendConfiguration() ngx_config.Configuration {
+	cfg := ngx_config.NewDefault()
+	cfg.MaxHostPathNum = s.maxHostPathNum
+	return cfg
+}
+
+type pathLimitCollector struct {
+	metric.DummyCollector
+	hostPathLimitExceeded int
+}
+
+func (c *pathLimitCollector) IncHostPathLimitExCount() {
+	c.hostPathLimitExceeded++
+}
+
+func TestGetBackendServersHostPathLimit(t *testing.T) {
+	paths := []networking.HTTPIngressPath{}
+	for _, p := range []string{"/", "/a", "/b", "/c", "/d"} {
+		paths = append(paths, networking.HTTPIngressPath{
+			Path: p,
+			Backend: networking.IngressBackend{
+				Service: &networking.IngressServiceBackend{
+					Name: "web",
+					Port: networking.ServiceBackendPort{Number: 80},
+				},
+			},
+		})
+	}
+
+	ing := &ingress.Ingress{
+		Ingress: networking.Ingress{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "many-paths"},
+			Spec: networking.IngressSpec{
+				Rules: []networking.IngressRule{
+					{
+						Host: "example.com",
+						IngressRuleValue: networking.IngressRuleValue{
+							HTTP: &networking.HTTPIngressRuleValue{Paths: paths},
+						},
+					},
+				},
+			},
+		},
+		ParsedAnnotations: &annotations.Ingress{},
+	}
+
+	testCases := map[string]struct {
+		limit            int
+		expectedPaths    []string
+		expectedExceeded int
+	}{
+		"under the limit": {10, []string{"/", "/a", "/b", "/c", "/d"}, 0},
+		"over the limit":  {3, []string{"/", "/a", "/b"}, 2},
+		"disabled":        {0, []string{"/", "/a", "/b", "/c", "/d"}, 0},
+	}
+
+	for title, tc := range testCases {
+		t.Run(title, func(t *testing.T) {
+			mc := &pathLimitCollector{}
+			n := &NGINXController{
+				store:           fakePathLimitStore{maxHostPathNum: tc.limit},
+				metricCollector: mc,
+				cfg: &Configuration{
+					ListenPorts: &ngx_config.ListenPorts{Default: 80},
+				},
+			}
+
+			_, servers := n.getBackendServers([]*ingress.Ingress{ing})
+
+			var server *ingress.Server
+			for _, s := range servers {
+				if s.Hostname == "example.com" {
+					server = s
+				}
+			}
+			if server == nil {
+				t.Fatalf("expected the server example.com")
+			}
+
+			paths := []string{}
+			for _, location := range server.Locations {
+				paths = append(paths, location.Path)
+			}
+			sort.Strings(paths)
+			if !reflect.DeepEqual(paths, tc.expectedPaths) {
+				t.Errorf("expected the paths %v but got %v", tc.expectedPaths, paths)
+			}
+			if mc.hostPathLimitExceeded != tc.expectedExceeded {
+				t.Errorf("expected %v skipped paths metered but got %v", tc.expectedExceeded, mc.hostPathLimitExceeded)
+			}
+		})
+	}
+}
+
+func TestLimitCanaries(t *testing.T) {
+	newCanaries := func() []*ingress.Canary {
+		return []*ingress.Canary{
+			{Target: "weight", TrafficShapingPolicy: ingress.TrafficShapingPolicy{Weight: 10}},
+			{Target: "query", TrafficShapingPolicy: ingress.TrafficShapingPolicy{Query: "canary"}},
+			{Target: "cookie", TrafficShapingPolicy: ingress.TrafficShapingPolicy{Cookie: "canary"}},
+			{Target: "header", TrafficShapingPolicy: ingress.TrafficShapingPolicy{Header: "canary"}},
+		}
+	}
+
+	testCases := map[string]struct {
+		limit            int
+		expectedCanaries []string
+		expectedSkipped  int
+	}{
+		"under the limit": {
+			10,
+			[]string{"weight", "query", "cookie", "header"},
+			0,
+		},
+		"over the limit": {
+			2,
+			[]string{"header", "cookie"},
+			2,
+		},
+		"disabled": {
+			0,
+			[]string{"weight", "query", "cookie", "header"},
+			0,
+		},
+	}
+
+	for title, tc := range testCases {
+		t.Run(title, func(t *testing.T) {
+			loc := &ingress.Location{Path: "/", Backend: "example-http-svc-80", Canaries: newCanaries()}
+			servers := []*ingress.Server{
+				{Hostname: "example.com", Locations: []*ingress.Location{loc}},
+			}
+
+			skipped := limitCanaries(servers, tc.limit)
+
+			canaries := []string{}
+			for _, canary := range loc.Canaries {
+				canaries = append(canaries, canary.Target)
+			}
+			if !reflect.DeepEqual(canaries, tc.expectedCanaries) {
+				t.Errorf("expected the canaries %v but got %v", tc.expectedCanaries, canaries)
+			}
+			if skipped != tc.expectedSkipped {
+				t.Errorf("expected %v skipped canaries but got %v", tc.expectedSkipped, skipped)
+			}
+		})
+	}
+}
+
+type fakePortCertStore struct {
+	fakeIngressStore
+	customPortDomain map[string]string
+	certs            []*ingress.SSLCert
+}
+
+func (s fakePortCertStore) GetBackendConfiguration() ngx_config.Configuration {
+	cfg := ngx_config.NewDefault()
+	cfg.CustomPortDomain = s.customPortDomain
+	return cfg
+}
+
+func (s fakePortCertStore) ListLocalSSLCerts() []*ingress.SSLCert {
+	return s.certs
+}
+
+func TestSelectDefaultCertForPort(t *testing.T) {
+	defaultCert := &ingress.SSLCert{Name: "default"}
+	certs := []*ingress.SSLCert{
+		{Name: "default/a-wildcard", CN: []string{"*.example.com"}},
+		{Name: "default/example", CN: []string{"example.com", "www.example.com"}},
+		{Name: "default/wildcard", CN: []string{"*.example.org"}},
+	}
+
+	testCases := map[string]struct {
+		customPortDomain map[string]string
+		port             int
+		expected         string
+	}{
+		"configured mapping": {
+			map[string]string{"8443": "example.com"},
+			8443,
+			"default/example",
+		},
+		"configured wildcard mapping": {
+			map[string]string{"8443": "*.example.org"},
+			8443,
+			"default/wildcard",
+		},
+		"wildcard certificate": {
+			map[string]string{"8443": "www.example.org"},
+			8443,
+			"default/wildcard",
+		},
+		"exact certificate preferred over wildcard": {
+			map[string]string{"8443": "www.example.com"},
+			8443,
+			"default/example",
+		},
+		"missing certificate": {
+			map[string]string{"8443": "example.net"},
+			8443,
+			"default",
+		},
+		"no mapping": {
+			map[string]string{"8443": "example.com"},
+			9443,
+			"default",
+		},
+	}
+
+	for title, tc := range testCases {
+		t.Run(title, func(t *testing.T) {
+			n := &NGINXController{
+				store: fakePortCertStore{
+					customPortDomain: tc.customPortDomain,
+					certs:            certs,
+				},
+				cfg: &Configuration{FakeCertificate: defaultCert},
+			}
+
+			cert := n.selectDefaultCertForPort(tc.port)
+			if cert == nil || cert.Name != tc.expected {
+				t.Errorf("Expected the certificate %q for port %v (got %v)", tc.expected, tc.port, cert)
+			}
+		})
+	}
+}
+
+type fakeStreamStore struct {
+	fakeIngressStore
+	additionalReservedPorts []int
+	configmap               *corev1.ConfigMap
+}
+
+func (s fakeStreamStore) GetBackendConfiguration() ngx_config.Configuration {
+	cfg := ngx_config.NewDefault()
+	cfg.AdditionalReservedPorts = s.additionalReservedPorts
+	return cfg
+}
+
+func (s fakeStreamStore) GetConfigMap(key string) (*corev1.ConfigMap, error) {
+	return s.configmap, nil
+}
+
+func (fakeStreamStore) GetService(key string) (*corev1.Service, error) {
+	return &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "tcp-svc"},
+		Spec: corev1.ServiceSpec{
+			Ports: []corev1.ServicePort{
+				{Port: 9000, TargetPort: intstr.FromInt(9000), Protocol: corev1.ProtocolTCP},
+			},
+		},
+	}, nil
+}
+
+func (fakeStreamStore) GetServiceEndpoints(key string) (*corev1.Endpoints, error) {
+	return &corev1.Endpoints{
+		Subsets: []corev1.EndpointSubset{
+			{
+				Addresses: []corev1.EndpointAddress{{IP: "10.0.0.1"}},
+				Ports:     []corev1.EndpointPort{{Port: 9000, Protocol: corev1.ProtocolTCP}},
+			},
+		},
+	}, nil
+}
+
+func TestGetStreamServicesReservedPorts(t *testing.T) {
+	configmap := &corev1.ConfigMap{
+		Data: map[string]string{
+			"80":   "default/tcp-svc:9000",
+			"2000": "default/tcp-svc:9000",
+			"3000": "default/tcp-svc:9000",
+		},
+	}
+
+	testCases := map[string]struct {
+		additionalReservedPorts []int
+		expected                []int
+	}{
+		"controller ports only": {
+			nil,
+			[]int{2000, 3000},
+		},
+		"additional reserved ports": {
+			[]int{3000, 4000},
+			[]int{2000},
+		},
+	}
+
+	for title, tc := range testCases {
+		t.Run(title, func(t *testing.T) {
+			n := &NGINXController{
+				store: fakeStreamStore{
+					additionalReservedPorts: tc.additionalReservedPorts,
+					configmap:               configmap,
+				},
+				cfg: &Configuration{ListenPorts: &ngx_config.ListenPorts{HTTP: 80, HTTPS: 443}},
+			}
+
+			var ports []int
+			svcs, _ := n.getStreamServices("default/tcp-services", corev1.ProtocolTCP)
+			for _, svc := range svcs {
+				ports = append(ports, svc.Port)
+			}
+			if !reflect.DeepEqual(ports, tc.expected) {
+				t.Errorf("Expected the stream service ports %v but got %v", tc.expected, ports)
+			}
+		})
+	}
+}
+
+func TestGetStreamServicesSkipped(t *testing.T) {
+	n := &NGINXController{
+		store: fakeStreamStore{
+			configmap: &corev1.ConfigMap{
+				Data: map[string]string{
+					"abc":  "default/tcp-svc:9000",
+					"80":   "default/tcp-svc:9000",
+					"2000": "default",
+					"2001": "default/tcp-svc:9000:conn-limit=abc",
+					"2002": "default/tcp-svc:9999",
+					"3000": "default/tcp-svc:9000",
+				},
+			},
+		},
+		cfg: &Configuration{ListenPorts: &ngx_config.ListenPorts{HTTP: 80, HTTPS: 443}},
+	}
+
+	svcs, skipped := n.getStreamServices("default/tcp-services", corev1.ProtocolTCP)
+	if len(svcs) != 1 || svcs[0].Port != 3000 {
+		t.Errorf("Expected only the stream service of port 3000 but got %v", svcs)
+	}
+
+	expected := map[string]int{
+		"bad-port":          1,
+		"reserved-port":     1,
+		"invalid-reference": 2,
+		"no-endpoints":      1,
+	}
+	if !reflect.DeepEqual(skipped, expected) {
+		t.Errorf("Expected the skipped entries %v but got %v", expected, skipped)
+	}
+
+	_, skipped = n.getStreamServices("", corev1.ProtocolUDP)
+	for reason, count := range skipped {
+		if count != 0 {
+			t.Errorf("Expected no skipped entries without ConfigMap but got %v for %v", count, reason)
+		}
+	}
+}
+
+func TestSetTrafficShapingPolicy(t *testing.T) {
+	anns := &annotations.Ingress{
+		Canary: canary.Config{
+			Enabled:          true,
+			Weight:           20,
+			Header:           "X-Canary",
+			HeaderValue:      "on",
+			Cookie:           "canary",
+			CookiePattern:    "^bucket-[0-4]$",
+			Query:            "canary",
+			QueryValue:       "on||beta",
+			ModDivisor:       10,
+			ModRelationalOpr: "<",
+			ModRemainder:     3,
+			ReqAddHeader:     "x-a:1",
+			ReqAppendHeader:  "x-b:2",
+			ReqAddQuery:      "c=3",
+			RespAddHeader:    "x-d:4",
+			RespAppendHeader: "x-e:5",
+		},
+	}
+
+	policy := ingress.TrafficShapingPolicy{Weight: 50, ModRelationalOpr: "=="}
+	setTrafficShapingPolicy(anns, &policy)
+
+	expected := ingress.TrafficShapingPolicy{
+		Weight:           20,
+		Header:           "X-Canary",
+		HeaderValue:      "on",
+		Cookie:           "canary",
+		CookiePattern:    "^bucket-[0-4]$",
+		Query:            "canary",
+		QueryValue:       "on||beta",
+		ModDivisor:       10,
+		ModRelationalOpr: "<",
+		ModRemainder:     3,
+		ReqAddHeader:     "x-a:1",
+		ReqAppendHeader:  "x-b:2",
+		ReqAddQuery:      "c=3",
+		RespAddHeader:    "x-d:4",
+		RespAppendHeader: "x-e:5",
+	}
+	if !policy.Equal(expected) {
+		t.Errorf("expected the traffic shaping policy %+v but got %+v", expected, policy)
+	}
+}
+
+type fakeNamespaceStore struct {
+	fakeIngressStore
+	namespaceConfigs map[string]map[string]string
+}
+
+func (fakeNamespaceStore) GetBackendConfiguration() ngx_config.Configuration {
+	return ngx_config.NewDefault()
+}
+
+func (s fakeNamespaceStore) GetNamespaceConfiguration(namespace string) ngx_config.Configuration {
+	return ngx_template.ReadNamespaceConfig(ngx_config.NewDefault(), s.namespaceConfigs[namespace])
+}
+
+func TestApplyNamespaceConfiguration(t *testing.T) {
+	cfg := ngx_config.NewDefault()
+	newServer := func(namespace string, annotations map[string]string) *ingress.Server {
+		ing := &ingress.Ingress{
+			Ingress: networking.Ingress{
+				ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: "app", Annotations: annotations},
+			},
+		}
+		return &ingress.Server{
+			VirtualService: namespace + "/app",
+			Hostname:       namespace + ".example.com",
+			Locations: []*ingress.Location{
+				{
+					Path:    "/",
+					Ingress: ing,
+					Proxy: proxy.Config{
+						ConnectTimeout: cfg.ProxyConnectTimeout,
+						SendTimeout:    cfg.ProxySendTimeout,
+						ReadTimeout:    cfg.ProxyReadTimeout,
+					},
+				},
+			},
+		}
+	}
+
+	n := &NGINXController{
+		store: fakeNamespaceStore{
+			namespaceConfigs: map[string]map[string]string{
+				"tenant-a": {
+					"proxy-connect-timeout": "2",
+					"proxy-read-timeout":    "300",
+					"ssl-protocols":         "TLSv1.3",
+					"use-gzip":              "false",
+				},
+			},
+		},
+	}
+
+	annotated := newServer("tenant-a", map[string]string{
+		parser.GetAnnotationWithPrefix("proxy-read-timeout"): "30",
+	})
+	annotated.Locations[0].Proxy.ReadTimeout = 30
+	annotated.SSLProtocols = "TLSv1.2"
+
+	servers := []*ingress.Server{newServer("tenant-a", nil), annotated, newServer("tenant-b", nil)}
+	n.applyNamespaceConfiguration(servers)
+
+	testCases := []struct {
+		title          string
+		server         *ingress.Server
+		connectTimeout int
+		sendTimeout    int
+		readTimeout    int
+		sslProtocols   string
+		useGzip        *bool
+	}{
+		{"overlay", servers[0], 2, cfg.ProxySendTimeout, 300, "TLSv1.3", new(bool)},
+		{"annotations over the overlay", servers[1], 2, cfg.ProxySendTimeout, 30, "TLSv1.2", new(bool)},
+		{"namespace without overlay", servers[2], cfg.ProxyConnectTimeout, cfg.ProxySendTimeout, cfg.ProxyReadTimeout, "", nil},
+	}
+
+	for _, tc := range testCases {
+		p := tc.server.Locations[0].Proxy
+		if p.ConnectTimeout != tc.connectTimeout || p.SendTimeout != tc.sendTimeout || p.ReadTimeout != tc.readTimeout {
+			t.Errorf("%v: expected the timeouts %v/%v/%v but got %v/%v/%v", tc.title,
+				tc.connectTimeout, tc.sendTimeout, tc.readTimeout, p.ConnectTimeout, p.SendTimeout, p.ReadTimeout)
+		}
+		if tc.server.SSLProtocols != tc.sslProtocols {
+			t.Errorf("%v: expected the ssl protocols %q but got %q", tc.title, tc.sslProtocols, tc.server.SSLProtocols)
+		}
+		if !reflect.DeepEqual(tc.server.UseGzip, tc.useGzip) {
+			t.Errorf("%v: expected use-gzip %v but got %v", tc.title, tc.useGzip, tc.server.UseGzip)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+/*
+Copyright 2023 The Alibaba Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/klog"
+
+	"k8s.io/ingress-nginx/internal/ingress"
+)
+
+// endpointsHash returns a hash of the endpoints, independent of their order
+func endpointsHash(endpoints []ingress.Endpoint) string {
+	addrs := make([]string, 0, len(endpoints))
+	for _, ep := range endpoints {
+		addrs = append(addrs, net.JoinHostPort(ep.Address, ep.Port))
+	}
+	sort.Strings(addrs)
+
+	h := sha256.New()
+	for _, addr := range addrs {
+		h.Write([]byte(addr))
+		h.Write([]byte{0})
+	}
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// canDedupUpstream checks if the upstream is only referenced by the locations
+// using it and is balanced by its endpoints and load-balancing config alone
+func canDedupUpstream(ups *ingress.Backend) bool {
+	if ups.Name == defUpstreamName || strings.HasPrefix(ups.Name, "custom-default-backend-") {
+		return false
+	}
+
+	// canary upstreams and their primaries are referenced by name
+	if ups.NoServer || len(ups.AlternativeBackends) > 0 {
+		return false
+	}
+
+	if ups.SSLPassthrough || ups.SessionAffinity.AffinityType != "" || len(ups.Endpoints) == 0 {
+		return false
+	}
+
+	// the endpoints of ExternalName services are resolved by name
+	return ups.Service == nil || ups.Service.Spec.Type != apiv1.ServiceTypeExternalName
+}
+
+// dedupUpstreams collapses the upstreams with identical endpoints,
+// load-balancing and keepalive config into the one with the lowest name, and rewrites
+// the locations using the others to it
+func dedupUpstreams(upstreams []*ingress.Backend, servers map[string]*ingress.Server) []*ingress.Backend {
+	sort.SliceStable(upstreams, func(a, b int) bool {
+		return upstreams[a].Name < upstreams[b].Name
+	})
+
+	canonical := make(map[string]string)
+	renamed := make(map[string]string)
+	deduped := make([]*ingress.Backend, 0, len(upstreams))
+	for _, ups := range upstreams {
+		if !canDedupUpstream(ups) {
+			deduped = append(deduped, ups)
+			continue
+		}
+
+		key := fmt.Sprintf("%v|%v|%v|%v|%v|%+v", endpointsHash(ups.Endpoints), ups.LoadBalancing,
+			ups.UpstreamHashBy.UpstreamHashBy, ups.UpstreamHashBy.UpstreamHashBySubset, ups.UpstreamHashBy.UpstreamHashBySubsetSize,
+			ups.UpstreamKeepalive)
+		if name, ok := canonical[key]; ok {
+			klog.V(3).Infof("Upstream %q has the same endpoints as upstream %q, using it instead", ups.Name, name)
+			renamed[ups.Name] = name
+			continue
+		}
+
+		canonical[key] = ups.Name
+		deduped = append(deduped, ups)
+	}
+
+	if len(renamed) == 0 {
+		return deduped
+	}
+
+	for _, server := range servers {
+		for _, location := range server.Locations {
+			if name, ok := renamed[location.Backend]; ok {
+				location.Backend = name
+			}
+		}
+	}
+
+	klog.Infof("Removed %v upstreams with the same endpoints as other upstreams", len(renamed))
+	return deduped
+}
@@ -0,0 +1,113 @@
+/*
+Copyright 2023 The Alibaba Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+
+	apiv1 "k8s.io/api/core/v1"
+
+	"k8s.io/ingress-nginx/internal/ingress"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/upstreamkeepalive"
+)
+
+func TestEndpointsHash(t *testing.T) {
+	a := []ingress.Endpoint{{Address: "10.0.0.1", Port: "8080"}, {Address: "10.0.0.2", Port: "8080"}}
+	b := []ingress.Endpoint{{Address: "10.0.0.2", Port: "8080"}, {Address: "10.0.0.1", Port: "8080"}}
+	c := []ingress.Endpoint{{Address: "10.0.0.1", Port: "8080"}, {Address: "10.0.0.2", Port: "8081"}}
+	d := []ingress.Endpoint{{Address: "10.0.0.1", Port: "80"}, {Address: "80.10.0.0", Port: "2"}}
+	e := []ingress.Endpoint{{Address: "10.0.0.1", Port: "8080"}}
+
+	if endpointsHash(a) != endpointsHash(b) {
+		t.Errorf("expected the same hash for the endpoints in a different order")
+	}
+	for _, other := range [][]ingress.Endpoint{c, d, e, nil} {
+		if endpointsHash(a) == endpointsHash(other) {
+			t.Errorf("expected a different hash for the endpoints %v", other)
+		}
+	}
+}
+
+func TestDedupUpstreams(t *testing.T) {
+	endpoints := func(addrs ...string) []ingress.Endpoint {
+		eps := []ingress.Endpoint{}
+		for _, addr := range addrs {
+			eps = append(eps, ingress.Endpoint{Address: addr, Port: "8080"})
+		}
+		return eps
+	}
+
+	upstreams := []*ingress.Backend{
+		{Name: defUpstreamName, Endpoints: endpoints("10.0.0.1")},
+		{Name: "default-web-b-80", Endpoints: endpoints("10.0.0.2", "10.0.0.1")},
+		{Name: "default-web-a-80", Endpoints: endpoints("10.0.0.1", "10.0.0.2")},
+		{Name: "default-web-c-80", Endpoints: endpoints("10.0.0.1", "10.0.0.2"), LoadBalancing: "ewma"},
+		{Name: "default-web-d-80", Endpoints: endpoints("10.0.0.1", "10.0.0.2"), NoServer: true},
+		{Name: "default-web-e-80", Endpoints: endpoints("10.0.0.1", "10.0.0.2"), AlternativeBackends: []string{"default-web-d-80"}},
+		{Name: "default-web-f-80", Endpoints: endpoints("10.0.0.1", "10.0.0.2"), SessionAffinity: ingress.SessionAffinityConfig{AffinityType: "cookie"}},
+		{Name: "default-web-g-80", Endpoints: endpoints("10.0.0.1", "10.0.0.2"), Service: &apiv1.Service{Spec: apiv1.ServiceSpec{Type: apiv1.ServiceTypeExternalName}}},
+		{Name: "default-empty-a-80", Endpoints: endpoints()},
+		{Name: "default-empty-b-80", Endpoints: endpoints()},
+		{Name: "default-web-h-80", Endpoints: endpoints("10.0.0.2", "10.0.0.1")},
+		{Name: "default-web-i-80", Endpoints: endpoints("10.0.0.1", "10.0.0.2"), UpstreamKeepalive: upstreamkeepalive.Config{Connections: 16}},
+	}
+
+	servers := map[string]*ingress.Server{
+		"example.com": {
+			Hostname: "example.com",
+			Locations: []*ingress.Location{
+				{Path: "/a", Backend: "default-web-a-80"},
+				{Path: "/b", Backend: "default-web-b-80"},
+				{Path: "/c", Backend: "default-web-c-80"},
+				{Path: "/h", Backend: "default-web-h-80"},
+				{Path: "/i", Backend: "default-web-i-80"},
+				{Path: "/empty", Backend: "default-empty-b-80"},
+				{Path: "/", Backend: defUpstreamName},
+			},
+		},
+	}
+
+	deduped := dedupUpstreams(upstreams, servers)
+
+	names := map[string]bool{}
+	for _, ups := range deduped {
+		names[ups.Name] = true
+	}
+	for _, removed := range []string{"default-web-b-80", "default-web-h-80"} {
+		if names[removed] {
+			t.Errorf("expected upstream %v to be removed", removed)
+		}
+	}
+	if len(deduped) != len(upstreams)-2 {
+		t.Errorf("expected %v upstreams but got %v", len(upstreams)-2, len(deduped))
+	}
+
+	expected := map[string]string{
+		"/a":     "default-web-a-80",
+		"/b":     "default-web-a-80",
+		"/c":     "default-web-c-80",
+		"/h":     "default-web-a-80",
+		"/i":     "default-web-i-80",
+		"/empty": "default-empty-b-80",
+		"/":      defUpstreamName,
+	}
+	for _, location := range servers["example.com"].Locations {
+		if location.Backend != expected[location.Path] {
+			t.Errorf("expected location %v to use upstream %v but got %v", location.Path, expected[location.Path], location.Backend)
+		}
+	}
+}
@@ -102,6 +102,9 @@ const (
 	// Metadata disable robots
 	MetaDisableRobots = "disable-robots"
 
+	// Metadata robots.txt content
+	MetaRobotsContent = "robots-txt-content"
+
 	// Matadata CORS enable-cors
 	MetaEnableCors = "enable-cors"
 
@@ -285,11 +288,6 @@ func createHostRouter(cfg ngx_config.Configuration, ingType route.HostType, serv
 			canaryUps := make([]*route.Upstream, 0, len(loc.Canaries))
 			setCanaryPriority(&loc.Canaries)
 			for i, canary := range loc.Canaries {
-				if i == cfg.MaxCanaryIngNum {
-					klog.Errorf("Loc[%v%v] exceeds the canary limit [%v], ignored",
-						server.Hostname, loc.Path, cfg.MaxCanaryIngNum)
-					break
-				}
 				canaryService := &route.VirtualService{}
 				tagRouter := &route.TagRouter{}
 				policy := canary.TrafficShapingPolicy
@@ -395,6 +393,10 @@ func createMetaData(server *ingress.Server, loc *ingress.Location) []*route.Meta
 			Key:   MetaDisableRobots,
 			Value: strconv.FormatBool(loc.DisableRobots),
 		},
+		&route.Metadata{
+			Key:   MetaRobotsContent,
+			Value: loc.RobotsContent,
+		},
 		&route.Metadata{
 			Key:   MetaEnableCors,
 			Value: strconv.FormatBool(loc.CorsConfig.CorsEnabled),
@@ -634,6 +636,10 @@ func createCookieCanary(seq int, cfg ngx_config.Configuration, server *ingress.S
 	policy := canary.TrafficShapingPolicy
 	klog.Infof("Loc[%v%v], cookie=[%v], value=[%v], modDivisor=[%v], modOpr=[%v], modRemainder=[%v]",
 		server.Hostname, loc.Path, policy.Cookie, policy.CookieValue, policy.ModDivisor, policy.ModRelationalOpr, policy.ModRemainder)
+	if policy.CookiePattern != "" {
+		klog.Warningf("Loc[%v%v], cookie pattern [%v] is only applied by the Lua balancer, the dynamic route matches the cookie value %v",
+			server.Hostname, loc.Path, policy.CookiePattern, Always)
+	}
 
 	return createCanary(seq, route.LocHttpCookie, policy.Cookie, policy.CookieValue, cfg.MaxCanaryCookieValNum, cfg, server, loc, canary)
 }
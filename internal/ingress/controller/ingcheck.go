@@ -35,15 +35,17 @@ const (
 	IngFlag = ","
 )
 
-func ingCheck(ingresses []*ingress.Ingress, ingCheckSums []*ingcheckv1.IngressCheckSum) (bool, error) {
+// ingCheck compares the checksum of the ingresses with the IngressCheckSum
+// objects and also returns the local and expected checksums that were compared.
+func ingCheck(ingresses []*ingress.Ingress, ingCheckSums []*ingcheckv1.IngressCheckSum) (bool, string, string, error) {
 	if len(ingCheckSums) == 0 {
 		klog.Infof("Check Ingress ID ignored for empty IngressCheckSum")
-		return true, nil
+		return true, "", "", nil
 	}
 
 	if len(ingresses) == 0 {
 		klog.Infof("Check Ingress ID ignored for empty ingresses")
-		return false, nil
+		return false, "", "", nil
 	}
 
 	ingIDs := make([]string, 0)
@@ -65,12 +67,12 @@ func ingCheck(ingresses []*ingress.Ingress, ingCheckSums []*ingcheckv1.IngressCh
 		klog.Infof("Check Ingress ID: {md5[%v]} with IngressCheckSum [%v/%v]{checksum[%v], timestamp[%v]}", md5str, ingCheckSum.Namespace, ingCheckSum.Name, ingCheckSum.Spec.Checksum, ingCheckSum.Spec.Timestamp)
 		if md5str == ingCheckSum.Spec.Checksum {
 			klog.Infof("Check Ingress ID: {md5[%v]} is same as the IngressCheckSum [%v/%v]{checksum[%v], timestamp[%v]}", md5str, ingCheckSum.Namespace, ingCheckSum.Name, ingCheckSum.Spec.Checksum, ingCheckSum.Spec.Timestamp)
-			return true, nil
+			return true, md5str, ingCheckSum.Spec.Checksum, nil
 		}
 	}
 
 	diff := ingDiff(md5str, ingIDs, ingCheckSums)
-	return false, errors.New(fmt.Sprintf("Check Ingress ID: {md5[%v]} is wrong, diff: {%v}", md5str, diff))
+	return false, md5str, ingCheckSums[0].Spec.Checksum, errors.New(fmt.Sprintf("Check Ingress ID: {md5[%v]} is wrong, diff: {%v}", md5str, diff))
 }
 
 func ingDiff(md5str string, ingIDs []string, ingCheckSums []*ingcheckv1.IngressCheckSum) string {
@@ -17,6 +17,11 @@ limitations under the License.
 
 package ingressclass
 
+import (
+	"fmt"
+	"strings"
+)
+
 const (
 	// IngressKey picks a specific "class" for the Ingress.
 	// The controller only processes Ingresses with this annotation either
@@ -39,7 +44,11 @@ type IngressClassConfiguration struct {
 	// AnnotationValue defines the annotation value this Controller watch to, in case of the
 	// ingressSpecName is not found but the annotation is.
 	// The Annotation is deprecated and should not be used in future releases
+	// When more than one value is configured this is the first one, used where a
+	// single canonical name is needed (e.g. status updates)
 	AnnotationValue string
+	// AnnotationValues defines all the annotation values this Controller watch to
+	AnnotationValues []string
 	// WatchWithoutClass defines if Controller should watch to Ingress Objects that does
 	// not contain an IngressClass configuration
 	WatchWithoutClass bool
@@ -50,3 +59,39 @@ type IngressClassConfiguration struct {
 	// .metadata.name together with .spec.Controller
 	IngressClassByName bool
 }
+
+// ParseAnnotationValues parses a comma separated list of ingress classes.
+// An empty value means all the ingress classes, empty entries are not allowed.
+func ParseAnnotationValues(value string) ([]string, error) {
+	values := []string{}
+	if strings.TrimSpace(value) == "" {
+		return values, nil
+	}
+
+	for _, v := range strings.Split(value, ",") {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			return nil, fmt.Errorf("ingress class list %q contains an empty entry", value)
+		}
+		values = append(values, v)
+	}
+
+	return values, nil
+}
+
+// MatchAnnotation returns true if the given annotation value is one of the
+// ingress classes this Controller watch to. Without any ingress class
+// configured all the values match.
+func (c *IngressClassConfiguration) MatchAnnotation(value string) bool {
+	if len(c.AnnotationValues) == 0 {
+		return c.AnnotationValue == "" || value == c.AnnotationValue
+	}
+
+	for _, v := range c.AnnotationValues {
+		if value == v {
+			return true
+		}
+	}
+
+	return false
+}
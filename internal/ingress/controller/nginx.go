@@ -28,10 +28,12 @@ import (
 	"os/exec"
 	"path/filepath"
 	"reflect"
+	"regexp"
 	"runtime"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"text/template"
 	"time"
@@ -51,6 +53,7 @@ import (
 	"k8s.io/ingress-nginx/internal/file"
 	"k8s.io/ingress-nginx/internal/ingress"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/class"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/upstreamkeepalive"
 	ngx_config "k8s.io/ingress-nginx/internal/ingress/controller/config"
 	"k8s.io/ingress-nginx/internal/ingress/controller/process"
 	"k8s.io/ingress-nginx/internal/ingress/controller/store"
@@ -87,11 +90,12 @@ func NewNGINXController(config *Configuration, mc metric.Collector) *NGINXContro
 	}
 
 	n := &NGINXController{
-		isIPV6Enabled: ing_net.IsIPv6Enabled(),
+		isIPV6Enabled:         ing_net.IsIPv6Enabled(),
+		isEarlyHintsSupported: nginx.SupportsEarlyHints(),
 
 		resolver:        h,
 		cfg:             config,
-		syncRateLimiter: flowcontrol.NewTokenBucketRateLimiter(config.SyncRateLimit, 1),
+		syncRateLimiter: newSyncRateLimiter(config),
 
 		recorder: eventBroadcaster.NewRecorder(scheme.Scheme, apiv1.EventSource{
 			Component: "tengine-ingress-controller",
@@ -113,6 +117,11 @@ func NewNGINXController(config *Configuration, mc metric.Collector) *NGINXContro
 		command: NewNginxCommand(),
 
 		checksumStatus: new(ingress.ChecksumStatus),
+		checksums:      new(checksumTracker),
+	}
+
+	if !n.isEarlyHintsSupported {
+		klog.Warningf("Tengine does not support the early_hints directive, the early-hints annotation is ignored")
 	}
 
 	if n.cfg.ValidationWebhook != "" {
@@ -136,6 +145,8 @@ func NewNGINXController(config *Configuration, mc metric.Collector) *NGINXContro
 		config.UDPConfigMapName,
 		config.DefaultSSLCertificate,
 		config.ResyncPeriod,
+		config.ConfigMapSyncDelay,
+		config.SSLCertTTL,
 		config.Client,
 		config.ClientIng,
 		config.ClientIngCheck,
@@ -144,6 +155,7 @@ func NewNGINXController(config *Configuration, mc metric.Collector) *NGINXContro
 		n.updateCh,
 		k8s.IngressPodDetails,
 		config.DisableCatchAll,
+		config.UseEndpointSlices,
 		n.checksumStatus,
 		config.IngressClassConfiguration)
 
@@ -223,6 +235,17 @@ Error loading new template: %v
 	return n
 }
 
+// newSyncRateLimiter returns the rate limiter of the syncs of the configuration,
+// allowing SyncBurst syncs at once before limiting them to SyncRateLimit per second
+func newSyncRateLimiter(config *Configuration) flowcontrol.RateLimiter {
+	burst := config.SyncBurst
+	if burst < 1 {
+		burst = 1
+	}
+
+	return flowcontrol.NewTokenBucketRateLimiter(config.SyncRateLimit, burst)
+}
+
 // NGINXController describes a Tengine Ingress controller.
 type NGINXController struct {
 	podInfo *k8s.PodInfo
@@ -257,8 +280,14 @@ type NGINXController struct {
 
 	isIPV6Enabled bool
 
+	isEarlyHintsSupported bool
+
 	isShuttingDown bool
 
+	// drainState is set to 1 once the controller is draining,
+	// accessed atomically because the health check runs concurrently
+	drainState int32
+
 	Proxy *TCPProxy
 
 	store store.Storer
@@ -275,7 +304,24 @@ type NGINXController struct {
 
 	checksumStatus *ingress.ChecksumStatus
 
+	// details of the checksum checks served by /checksum-status
+	checksums *checksumTracker
+
 	hotReloadMD5 string
+
+	// number of consecutive syncs that failed to reload the configuration
+	reloadFailures int
+
+	// checksum of the last configuration written and reloaded by OnUpdate
+	reloadedConfigChecksum string
+
+	// generation and checksum of the last configuration a sync tried to reload
+	// and of the live one, they differ after a failed reload
+	configStale             bool
+	desiredConfigGeneration int64
+	desiredConfigChecksum   string
+	liveConfigGeneration    int64
+	liveConfigChecksum      string
 }
 
 // Start starts a new Tengine master process running in the foreground.
@@ -287,8 +333,8 @@ func (n *NGINXController) Start() {
 	// we need to use the defined ingress class to allow multiple leaders
 	// in order to update information about ingress status
 	electionID := fmt.Sprintf("%v-%v", n.cfg.ElectionID, class.DefaultClass)
-	if class.IngressClass != "" {
-		electionID = fmt.Sprintf("%v-%v", n.cfg.ElectionID, class.IngressClass)
+	if class.Canonical() != "" {
+		electionID = fmt.Sprintf("%v-%v", n.cfg.ElectionID, class.Canonical())
 	}
 
 	setupLeaderElection(&leaderElectionConfig{
@@ -302,7 +348,8 @@ func (n *NGINXController) Start() {
 			n.metricCollector.OnStartedLeading(electionID)
 			// manually update SSL expiration metrics
 			// (to not wait for a reload)
-			n.metricCollector.SetSSLExpireTime(n.runningConfig.Servers)
+			n.metricCollector.SetSSLExpireTime(n.runningConfig.Servers, n.expiryWarnThreshold())
+			n.metricCollector.SetServerLocationCounts(n.runningConfig.Servers)
 		},
 		OnStoppedLeading: func() {
 			n.metricCollector.OnStoppedLeading(electionID)
@@ -410,6 +457,19 @@ func (n *NGINXController) Start() {
 	}
 }
 
+// Drain marks the controller as draining. The health check fails from then
+// on so load balancers stop sending new traffic before Tengine is stopped.
+func (n *NGINXController) Drain() {
+	if atomic.CompareAndSwapInt32(&n.drainState, 0, 1) {
+		klog.Info("Draining ingress controller, the health check will fail from now on")
+	}
+}
+
+// IsDraining returns true once Drain has been called
+func (n *NGINXController) IsDraining() bool {
+	return atomic.LoadInt32(&n.drainState) == 1
+}
+
 // Stop gracefully stops the Tengine master process.
 func (n *NGINXController) Stop() error {
 	n.isShuttingDown = true
@@ -662,6 +722,7 @@ func (n NGINXController) generateTemplate(cfg ngx_config.Configuration, ingressC
 			NginxStatusIpv6Whitelist: cfg.NginxStatusIpv6Whitelist,
 			RedirectServers:          buildRedirects(ingServers),
 			IsSSLPassthroughEnabled:  n.cfg.EnableSSLPassthrough,
+			IsEarlyHintsSupported:    n.isEarlyHintsSupported,
 			ListenPorts:              n.cfg.ListenPorts,
 			PublishService:           n.GetPublishService(),
 			EnableMetrics:            n.cfg.EnableMetrics,
@@ -690,6 +751,7 @@ func (n NGINXController) generateTemplate(cfg ngx_config.Configuration, ingressC
 			NginxStatusIpv6Whitelist: cfg.NginxStatusIpv6Whitelist,
 			RedirectServers:          buildRedirects(ingressCfg.Servers),
 			IsSSLPassthroughEnabled:  n.cfg.EnableSSLPassthrough,
+			IsEarlyHintsSupported:    n.isEarlyHintsSupported,
 			ListenPorts:              n.cfg.ListenPorts,
 			PublishService:           n.GetPublishService(),
 			EnableMetrics:            n.cfg.EnableMetrics,
@@ -741,6 +803,57 @@ Error: %v
 	return nil
 }
 
+// sensitiveDirectiveRegex matches the directives whose first argument names a
+// credential, e.g. proxy_set_header Authorization "Basic ...", capturing
+// everything up to the value
+var sensitiveDirectiveRegex = regexp.MustCompile(`(?im)^(\s*\S+\s+\S*(?:authorization|password|passwd|secret|token|api[_-]?key)\S*\s+)[^;\n]+;`)
+
+// redactConfig replaces the values of the sensitive directives of a Tengine
+// configuration so it can be shown outside of the controller
+func redactConfig(cfg []byte) []byte {
+	return sensitiveDirectiveRegex.ReplaceAll(cfg, []byte("${1}<redacted>;"))
+}
+
+// diffConfig returns a unified diff between two Tengine configurations
+// running the command "diff" on temporal files.
+func diffConfig(running, candidate []byte) (string, error) {
+	if bytes.Equal(running, candidate) {
+		return "", nil
+	}
+
+	files := make([]string, 0, 2)
+	defer func() {
+		for _, name := range files {
+			os.Remove(name)
+		}
+	}()
+
+	for _, content := range [][]byte{running, candidate} {
+		tmpfile, err := os.CreateTemp("", "diff-nginx-cfg")
+		if err != nil {
+			return "", err
+		}
+		tmpfile.Close()
+		files = append(files, tmpfile.Name())
+
+		err = os.WriteFile(tmpfile.Name(), content, file.ReadWriteByUser)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	out, err := exec.Command("diff", "-I", "^# Configuration.*", "-u",
+		"--label", "running", "--label", "candidate", files[0], files[1]).CombinedOutput()
+	if err != nil {
+		// diff exits with 1 when the files differ
+		if exitError, ok := err.(*exec.ExitError); !ok || exitError.ExitCode() != 1 {
+			return "", fmt.Errorf("failed to diff the Tengine configurations: %v: %s", err, out)
+		}
+	}
+
+	return string(out), nil
+}
+
 // OnUpdate is called by the synchronization loop whenever configuration
 // changes were detected. The received backend Configuration is merged with the
 // configuration ConfigMap before generating the final configuration file.
@@ -940,6 +1053,13 @@ func clearL4serviceEndpoints(config *ingress.Configuration) {
 // IsDynamicConfigurationEnough returns whether a Configuration can be
 // dynamically applied, without reloading the backend.
 func (n *NGINXController) IsDynamicConfigurationEnough(pcfg *ingress.Configuration) bool {
+	// the upstreams of backends with keepalive connections configured
+	// per ingress are rendered in the configuration file
+	if !reflect.DeepEqual(upstreamKeepalives(n.runningConfig.Backends), upstreamKeepalives(pcfg.Backends)) {
+		klog.V(2).Infof("Keepalive configuration of the upstreams changed")
+		return false
+	}
+
 	copyOfRunningConfig := *n.runningConfig
 	copyOfPcfg := *pcfg
 
@@ -960,6 +1080,19 @@ func (n *NGINXController) IsDynamicConfigurationEnough(pcfg *ingress.Configurati
 	return isDynamicConfigurationEnough
 }
 
+// upstreamKeepalives returns the keepalive configuration of the backends
+// served by a dedicated upstream, indexed by backend name
+func upstreamKeepalives(backends []*ingress.Backend) map[string]upstreamkeepalive.Config {
+	keepalives := make(map[string]upstreamkeepalive.Config)
+	for _, backend := range backends {
+		if backend.UpstreamKeepalive.Connections > 0 {
+			keepalives[backend.Name] = backend.UpstreamKeepalive
+		}
+	}
+
+	return keepalives
+}
+
 // configureDynamically encodes new Backends in JSON format and POSTs the
 // payload to an internal HTTP endpoint handled by Lua.
 func (n *NGINXController) configureDynamically(pcfg *ingress.Configuration) error {
@@ -997,17 +1130,19 @@ func (n *NGINXController) configureDynamically(pcfg *ingress.Configuration) erro
 			return nil
 		}
 
-		ready, err0 := secretCheck(n.store.ListSecretsWithAnnotation(), n.store.ListLocalSecretCheckSums(nil))
+		ready, local, expected, err0 := secretCheck(n.store.ListSecretsWithAnnotation(), n.store.ListLocalSecretCheckSums(nil))
 		if ready {
 			n.checksumStatus.SecretChecksumStatus = true
+			n.checksums.recordSecret(ready, local, expected, nil)
 			n.metricCollector.IncSecretChecksumCount()
 			n.metricCollector.ClearSecretChecksumErrorCount()
-			err := configureCertificates(pcfg.Servers, n.store.GetBackendConfiguration())
+			err := configureCertificates(pcfg.Servers, n.store.GetBackendConfiguration(), n.selectDefaultCertForPort)
 			if err0 != nil {
 				return err
 			}
 		} else if err0 != nil {
 			n.checksumStatus.SecretChecksumStatus = false
+			n.checksums.recordSecret(ready, local, expected, err0)
 			klog.Errorf("Secret ID mismatch, alarm:\n\n%v", err0)
 			n.metricCollector.IncSecretChecksumErrorCount()
 			return err0
@@ -1121,8 +1256,9 @@ type sslConfiguration struct {
 }
 
 // configureCertificates JSON encodes certificates and POSTs it to an internal HTTP endpoint
-// that is handled by Lua
-func configureCertificates(rawServers []*ingress.Server, cfg ngx_config.Configuration) error {
+// that is handled by Lua. defaultCertForPort selects the certificate of the ports of
+// custom-port-domain without a server certificate of the mapped domain.
+func configureCertificates(rawServers []*ingress.Server, cfg ngx_config.Configuration, defaultCertForPort func(int) *ingress.SSLCert) error {
 	configuration := &sslConfiguration{
 		Certificates: map[string]string{},
 		Servers:      map[string][]string{},
@@ -1204,6 +1340,28 @@ func configureCertificates(rawServers []*ingress.Server, cfg ngx_config.Configur
 		}
 	}
 
+	for port := range cfg.CustomPortDomain {
+		if len(configuration.Servers[port]) != 0 {
+			continue
+		}
+
+		p, err := strconv.Atoi(port)
+		if err != nil {
+			continue
+		}
+
+		sslCert := defaultCertForPort(p)
+		if sslCert == nil {
+			continue
+		}
+
+		if _, ok := configuration.Certificates[sslCert.UID]; !ok {
+			configuration.Certificates[sslCert.UID] = sslCert.PemCertKey
+		}
+		klog.V(3).Infof("Save port[%v] with default secret UID[%v] to certificate shared dict", port, sslCert.UID)
+		configuration.Servers[port] = append(configuration.Servers[port], sslCert.UID)
+	}
+
 	statusCode, _, err := nginx.NewPostStatusRequest("/configuration/servers", "application/json", configuration)
 	if err != nil {
 		return err
@@ -1420,6 +1578,12 @@ func buildDefaultServers(servers []*ingress.Server, defaultCertPorts string) []*
 			continue
 		}
 
+		if len(srv.DefaultCertPorts) > 0 && !containsPort(srv.DefaultCertPorts, int(port)) {
+			klog.Warningf("buildDefaultServers: port %v of cert [%v] is not in the default-cert-ports of server %v", port, sslCert.Name, srv.Hostname)
+			srv.NeedDefaultCert = false
+			continue
+		}
+
 		srv.DefaultCertPort = int(port)
 		klog.Warningf("buildDefaultServers: srv %v, port %v", sslCert.Name, srv.DefaultCertPort)
 
@@ -1478,3 +1642,13 @@ func buildDefaultServers(servers []*ingress.Server, defaultCertPorts string) []*
 
 	return defaultServers
 }
+
+func containsPort(ports []int, port int) bool {
+	for _, p := range ports {
+		if p == port {
+			return true
+		}
+	}
+
+	return false
+}
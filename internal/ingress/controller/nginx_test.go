@@ -32,6 +32,7 @@ import (
 	apiv1 "k8s.io/api/core/v1"
 
 	"k8s.io/ingress-nginx/internal/ingress"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/upstreamkeepalive"
 	"k8s.io/ingress-nginx/internal/ingress/controller/config"
 	"k8s.io/ingress-nginx/internal/nginx"
 )
@@ -101,6 +102,17 @@ func TestIsDynamicConfigurationEnough(t *testing.T) {
 		t.Errorf("Expected to be dynamically configurable when only backends change")
 	}
 
+	newConfig = &ingress.Configuration{
+		Backends: []*ingress.Backend{{
+			Name:              "fakenamespace-myapp-80",
+			UpstreamKeepalive: upstreamkeepalive.Config{Connections: 16},
+		}},
+		Servers: servers,
+	}
+	if n.IsDynamicConfigurationEnough(newConfig) {
+		t.Errorf("Expected to not be dynamically configurable when the upstream keepalive of a backend changes")
+	}
+
 	newServers := []*ingress.Server{{
 		Hostname: "myapp1.fake",
 		Locations: []*ingress.Location{
@@ -551,6 +563,56 @@ func TestCleanTempNginxCfg(t *testing.T) {
 	}
 }
 
+func TestRedactConfig(t *testing.T) {
+	testCases := []struct {
+		line     string
+		expected string
+	}{
+		{`proxy_set_header Authorization "Basic dXNlcjpwYXNz";`, `proxy_set_header Authorization <redacted>;`},
+		{`    proxy_set_header X-Api-Key abc;`, `    proxy_set_header X-Api-Key <redacted>;`},
+		{`set $secret_token "s3cr3t";`, `set $secret_token <redacted>;`},
+		{`proxy_set_header Host $host;`, `proxy_set_header Host $host;`},
+		{`server_tokens off;`, `server_tokens off;`},
+		{`ssl_certificate_key /etc/ingress-controller/ssl/default-fake-certificate.pem;`, `ssl_certificate_key /etc/ingress-controller/ssl/default-fake-certificate.pem;`},
+	}
+
+	for _, tc := range testCases {
+		if actual := string(redactConfig([]byte(tc.line))); actual != tc.expected {
+			t.Errorf("expected %q to be redacted to %q but got %q", tc.line, tc.expected, actual)
+		}
+	}
+}
+
+func TestDiffConfig(t *testing.T) {
+	running := []byte("# Configuration checksum: 1\nserver_name a.example.com;\n")
+
+	diff, err := diffConfig(running, running)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if diff != "" {
+		t.Errorf("expected no diff for the same configuration but got %q", diff)
+	}
+
+	diff, err = diffConfig(running, []byte("# Configuration checksum: 2\nserver_name a.example.com;\n"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if diff != "" {
+		t.Errorf("expected the checksum to be ignored but got %q", diff)
+	}
+
+	diff, err = diffConfig(running, []byte("# Configuration checksum: 2\nserver_name b.example.com;\n"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, line := range []string{"--- running", "+++ candidate", "-server_name a.example.com;", "+server_name b.example.com;"} {
+		if !strings.Contains(diff, line) {
+			t.Errorf("expected the diff to contain %q but got %q", line, diff)
+		}
+	}
+}
+
 func TestHotreload(t *testing.T) {
 	listener, err := net.Listen("tcp", fmt.Sprintf(":%v", nginx.StatusPort))
 	if err != nil {
@@ -667,3 +729,31 @@ func TestHotreload(t *testing.T) {
 		t.Errorf("expected 'UseSinfo' disabled, got '%v'", cfg.UseSinfo)
 	}
 }
+
+func TestSyncRateLimiterBurst(t *testing.T) {
+	testCases := map[string]struct {
+		burst    int
+		expected int
+	}{
+		"default":      {0, 1},
+		"no burst":     {1, 1},
+		"burst tokens": {3, 3},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			limiter := newSyncRateLimiter(&Configuration{SyncRateLimit: 0.001, SyncBurst: tc.burst})
+
+			accepted := 0
+			for i := 0; i < tc.burst+2; i++ {
+				if limiter.TryAccept() {
+					accepted++
+				}
+			}
+
+			if accepted != tc.expected {
+				t.Errorf("expected %v syncs accepted at once but %v were", tc.expected, accepted)
+			}
+		})
+	}
+}
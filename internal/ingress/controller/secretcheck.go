@@ -35,15 +35,17 @@ const (
 	SecretFlag = ","
 )
 
-func secretCheck(secrets []*ingress.Secret, secretCheckSums []*secretcheckv1.SecretCheckSum) (bool, error) {
+// secretCheck compares the checksum of the secrets with the SecretCheckSum
+// objects and also returns the local and expected checksums that were compared.
+func secretCheck(secrets []*ingress.Secret, secretCheckSums []*secretcheckv1.SecretCheckSum) (bool, string, string, error) {
 	if len(secretCheckSums) == 0 {
 		klog.Infof("Check Secret ID ignored for empty SecretCheckSum")
-		return true, nil
+		return true, "", "", nil
 	}
 
 	if len(secrets) == 0 {
 		klog.Infof("Check Secret ID ignored for empty secrets")
-		return false, nil
+		return false, "", "", nil
 	}
 
 	secretIDs := make([]string, 0)
@@ -64,12 +66,12 @@ func secretCheck(secrets []*ingress.Secret, secretCheckSums []*secretcheckv1.Sec
 		klog.Infof("Check Secret ID: {md5[%v]} with SecretCheckSum [%v/%v]{checksum[%v], timestamp[%v]}", md5str, secretCheckSum.Namespace, secretCheckSum.Name, secretCheckSum.Spec.Checksum, secretCheckSum.Spec.Timestamp)
 		if md5str == secretCheckSum.Spec.Checksum {
 			klog.Infof("Check Secret ID: {md5[%v]} is same as the SecretCheckSum [%v/%v]{checksum[%v], timestamp[%v]}", md5str, secretCheckSum.Namespace, secretCheckSum.Name, secretCheckSum.Spec.Checksum, secretCheckSum.Spec.Timestamp)
-			return true, nil
+			return true, md5str, secretCheckSum.Spec.Checksum, nil
 		}
 	}
 
 	diff := secretDiff(md5str, secretIDs, secretCheckSums)
-	return false, errors.New(fmt.Sprintf("Check Secret ID: {md5[%v]} is wrong, diff: {%v}", md5str, diff))
+	return false, md5str, secretCheckSums[0].Spec.Checksum, errors.New(fmt.Sprintf("Check Secret ID: {md5[%v]} is wrong, diff: {%v}", md5str, diff))
 }
 
 func secretDiff(md5str string, secretIDs []string, secretCheckSums []*secretcheckv1.SecretCheckSum) string {
@@ -19,7 +19,9 @@ package store
 
 import (
 	"fmt"
+	"os"
 	"strings"
+	"time"
 
 	"k8s.io/klog"
 
@@ -34,6 +36,21 @@ import (
 	"k8s.io/ingress-nginx/internal/net/ssl"
 )
 
+var (
+	// certLoadRetries is the number of times the certificate of a secret with
+	// annotations is loaded again after a failure, before waiting for the
+	// secret to change
+	certLoadRetries = 5
+
+	// certLoadRetryDelay is the delay of the first retry, doubled in every retry
+	certLoadRetryDelay = 1 * time.Second
+)
+
+// certRetry is a pending retry of the certificate of a secret with annotations
+type certRetry struct {
+	timer *time.Timer
+}
+
 // syncSecret synchronizes the content of a TLS Secret (certificate(s), secret
 // key) with the filesystem. The resulting files can be used by NGINX.
 func (s *k8sStore) syncSecret(key string, mc metric.Collector) {
@@ -189,6 +206,147 @@ func (s *k8sStore) getPemCertificate(secretName string) (*ingress.SSLCert, error
 	return sslCert, nil
 }
 
+// hasCertificateData returns if the secret contains a certificate, a key or
+// a CA certificate, so a failure to load them can be transient
+func hasCertificateData(secret *apiv1.Secret) bool {
+	for _, key := range []string{apiv1.TLSCertKey, apiv1.TLSPrivateKeyKey, "ca.crt"} {
+		if _, ok := secret.Data[key]; ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// retryCertificate schedules the given retry to load the certificate of the
+// secret, replacing the pending one. It returns false when no retries are left.
+func (s *k8sStore) retryCertificate(key string, retry int) bool {
+	if retry > certLoadRetries {
+		return false
+	}
+
+	s.certRetriesMu.Lock()
+	defer s.certRetriesMu.Unlock()
+
+	if cur, ok := s.certRetries[key]; ok {
+		cur.timer.Stop()
+	}
+
+	r := &certRetry{}
+	r.timer = time.AfterFunc(certLoadRetryDelay<<(retry-1), func() {
+		s.certRetriesMu.Lock()
+		if s.certRetries[key] != r {
+			// canceled or replaced by a newer version of the secret
+			s.certRetriesMu.Unlock()
+			return
+		}
+		delete(s.certRetries, key)
+		s.certRetriesMu.Unlock()
+
+		secret, err := s.listers.Secret.ByKey(key)
+		if err != nil {
+			klog.Warningf("Secret %q no longer exists, not retrying its certificate: %v", key, err)
+			return
+		}
+
+		if s.loadSecretWithAnnotation(secret, retry) {
+			klog.Infof("Obtained X.509 certificate [%v] after %v retries", key, retry)
+			// the retry happens after the event of the secret, so it must trigger an update
+			s.sendDummyEvent()
+		}
+	})
+	s.certRetries[key] = r
+
+	return true
+}
+
+// cancelCertificateRetry cancels the pending retry of the certificate of the secret
+func (s *k8sStore) cancelCertificateRetry(key string) {
+	s.certRetriesMu.Lock()
+	defer s.certRetriesMu.Unlock()
+
+	if cur, ok := s.certRetries[key]; ok {
+		cur.timer.Stop()
+		delete(s.certRetries, key)
+	}
+}
+
+// sweepSSLCerts evicts from the local store the certificates not referenced
+// by any Ingress or Secret with annotations for longer than sslCertTTL and
+// removes their files. The default SSL certificate is never evicted.
+func (s *k8sStore) sweepSSLCerts() {
+	s.syncSecretMu.Lock()
+	defer s.syncSecretMu.Unlock()
+
+	now := time.Now()
+	keys := make(map[string]bool)
+	for _, key := range s.sslStore.ListKeys() {
+		keys[key] = true
+
+		if s.sslCertReferenced(key) {
+			delete(s.sslCertUnusedSince, key)
+			continue
+		}
+
+		since, ok := s.sslCertUnusedSince[key]
+		if !ok {
+			s.sslCertUnusedSince[key] = now
+			continue
+		}
+		if now.Sub(since) < s.sslCertTTL {
+			continue
+		}
+
+		cert, err := s.sslStore.ByKey(key)
+		if err != nil {
+			continue
+		}
+
+		klog.Infof("Evicting Secret %q from the local store, not referenced since %v", key, since.Format(time.RFC3339))
+		s.sslStore.Delete(key)
+		delete(s.sslCertUnusedSince, key)
+		removeSSLCertFiles(cert)
+		s.mc.IncSSLCertEvictedCount()
+	}
+
+	// forget the certificates removed from the store by other means
+	for key := range s.sslCertUnusedSince {
+		if !keys[key] {
+			delete(s.sslCertUnusedSince, key)
+		}
+	}
+}
+
+// sslCertReferenced returns true if the certificate of the secret key is the
+// default SSL certificate or is referenced by an Ingress or a Secret with annotations
+func (s *k8sStore) sslCertReferenced(key string) bool {
+	if key == s.defaultSSLCertificate {
+		return true
+	}
+
+	if s.secretIngressMap.Has(key) {
+		return true
+	}
+
+	_, err := s.listers.SecretWithAnnotation.ByKey(key)
+	return err == nil
+}
+
+// removeSSLCertFiles removes the files of a certificate from the filesystem
+func removeSSLCertFiles(cert *ingress.SSLCert) {
+	removed := make(map[string]bool)
+	for _, name := range []string{cert.PemFileName, cert.CAFileName, cert.CRLFileName} {
+		if name == "" || removed[name] {
+			continue
+		}
+		removed[name] = true
+
+		if err := os.Remove(name); err != nil && !os.IsNotExist(err) {
+			klog.Warningf("Error removing file %v: %v", name, err)
+		}
+	}
+}
+
 // sendDummyEvent sends a dummy event to trigger an update
 // This is used in when a secret change
 func (s *k8sStore) sendDummyEvent() {
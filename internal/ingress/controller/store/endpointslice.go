@@ -0,0 +1,145 @@
+/*
+Copyright 2023 The Alibaba Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package store
+
+import (
+	"fmt"
+	"sort"
+
+	apiv1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// endpointSliceServiceIndex is the name of the index of the EndpointSlices by Service
+const endpointSliceServiceIndex = "service"
+
+// EndpointSliceLister makes an Indexer that lists EndpointSlices by Service.
+type EndpointSliceLister struct {
+	cache.Indexer
+}
+
+// endpointSliceServiceIndexFunc indexes the EndpointSlices by the key of their Service.
+func endpointSliceServiceIndexFunc(obj interface{}) ([]string, error) {
+	slice, ok := obj.(*discoveryv1.EndpointSlice)
+	if !ok {
+		return nil, fmt.Errorf("unexpected object type %T", obj)
+	}
+
+	svcName := slice.Labels[discoveryv1.LabelServiceName]
+	if svcName == "" {
+		return nil, nil
+	}
+
+	return []string{fmt.Sprintf("%v/%v", slice.Namespace, svcName)}, nil
+}
+
+// MatchByKey returns the EndpointSlices of the Service matching key in the local EndpointSlice Store.
+func (s *EndpointSliceLister) MatchByKey(key string) ([]*discoveryv1.EndpointSlice, error) {
+	objs, err := s.ByIndex(endpointSliceServiceIndex, key)
+	if err != nil {
+		return nil, err
+	}
+	if len(objs) == 0 {
+		return nil, NotExistsError(key)
+	}
+
+	slices := make([]*discoveryv1.EndpointSlice, 0, len(objs))
+	for _, obj := range objs {
+		slices = append(slices, obj.(*discoveryv1.EndpointSlice))
+	}
+
+	return slices, nil
+}
+
+// endpointsFromSlices merges the EndpointSlices of a Service in Endpoints,
+// with a subset by slice containing its ready endpoints. FQDN slices are ignored.
+func endpointsFromSlices(slices []*discoveryv1.EndpointSlice) *apiv1.Endpoints {
+	eps := &apiv1.Endpoints{}
+	if len(slices) == 0 {
+		return eps
+	}
+
+	eps.Namespace = slices[0].Namespace
+	eps.Name = slices[0].Labels[discoveryv1.LabelServiceName]
+
+	// the order of the slices is not stable, sort them to avoid
+	// reloads when the endpoints did not change
+	sorted := make([]*discoveryv1.EndpointSlice, len(slices))
+	copy(sorted, slices)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Name < sorted[j].Name
+	})
+
+	for _, slice := range sorted {
+		if slice.AddressType == discoveryv1.AddressTypeFQDN {
+			continue
+		}
+
+		subset := apiv1.EndpointSubset{}
+		for _, port := range slice.Ports {
+			if port.Port == nil {
+				continue
+			}
+
+			epPort := apiv1.EndpointPort{
+				Port:     *port.Port,
+				Protocol: apiv1.ProtocolTCP,
+			}
+			if port.Name != nil {
+				epPort.Name = *port.Name
+			}
+			if port.Protocol != nil {
+				epPort.Protocol = *port.Protocol
+			}
+			subset.Ports = append(subset.Ports, epPort)
+		}
+
+		for _, ep := range slice.Endpoints {
+			// a nil ready condition must be interpreted as ready
+			if ep.Conditions.Ready != nil && !*ep.Conditions.Ready {
+				continue
+			}
+			if len(ep.Addresses) == 0 {
+				continue
+			}
+
+			subset.Addresses = append(subset.Addresses, apiv1.EndpointAddress{
+				IP:        ep.Addresses[0],
+				Hostname:  stringValue(ep.Hostname),
+				NodeName:  ep.NodeName,
+				TargetRef: ep.TargetRef,
+			})
+		}
+
+		if len(subset.Ports) == 0 || len(subset.Addresses) == 0 {
+			continue
+		}
+
+		eps.Subsets = append(eps.Subsets, subset)
+	}
+
+	return eps
+}
+
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+
+	return *s
+}
@@ -0,0 +1,144 @@
+/*
+Copyright 2023 The Alibaba Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package store
+
+import (
+	"reflect"
+	"testing"
+
+	apiv1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func newEndpointSlice(name, svc string, port int32, ready map[string]*bool) *discoveryv1.EndpointSlice {
+	portName := "http"
+	protocol := apiv1.ProtocolTCP
+
+	slice := &discoveryv1.EndpointSlice{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "default",
+			Name:      name,
+			Labels: map[string]string{
+				discoveryv1.LabelServiceName: svc,
+			},
+		},
+		AddressType: discoveryv1.AddressTypeIPv4,
+		Ports: []discoveryv1.EndpointPort{
+			{Name: &portName, Port: &port, Protocol: &protocol},
+		},
+	}
+
+	for _, ip := range []string{"10.0.0.1", "10.0.0.2", "10.0.0.3"} {
+		r, ok := ready[ip]
+		if !ok {
+			continue
+		}
+
+		slice.Endpoints = append(slice.Endpoints, discoveryv1.Endpoint{
+			Addresses:  []string{ip},
+			Conditions: discoveryv1.EndpointConditions{Ready: r},
+		})
+	}
+
+	return slice
+}
+
+func TestGetServiceEndpointsFromSlices(t *testing.T) {
+	ready, notReady := true, false
+
+	s := &k8sStore{
+		listers: &Lister{
+			EndpointSlice: EndpointSliceLister{cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{
+				endpointSliceServiceIndex: endpointSliceServiceIndexFunc,
+			})},
+		},
+	}
+
+	slices := []*discoveryv1.EndpointSlice{
+		newEndpointSlice("demo-b", "demo", 8080, map[string]*bool{"10.0.0.3": &ready}),
+		newEndpointSlice("demo-a", "demo", 8080, map[string]*bool{"10.0.0.1": &ready, "10.0.0.2": &notReady}),
+		newEndpointSlice("demo-c", "demo", 8080, map[string]*bool{"10.0.0.2": nil}),
+		newEndpointSlice("other-a", "other", 9090, map[string]*bool{"10.0.0.1": &ready}),
+	}
+
+	fqdn := newEndpointSlice("demo-d", "demo", 8080, map[string]*bool{"10.0.0.1": &ready})
+	fqdn.AddressType = discoveryv1.AddressTypeFQDN
+	slices = append(slices, fqdn)
+
+	for _, slice := range slices {
+		if err := s.listers.EndpointSlice.Add(slice); err != nil {
+			t.Fatalf("unexpected error adding EndpointSlice %v: %v", slice.Name, err)
+		}
+	}
+
+	eps, err := s.GetServiceEndpoints("default/demo")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ports := []apiv1.EndpointPort{{Name: "http", Port: 8080, Protocol: apiv1.ProtocolTCP}}
+	expected := []apiv1.EndpointSubset{
+		{Addresses: []apiv1.EndpointAddress{{IP: "10.0.0.1"}}, Ports: ports},
+		{Addresses: []apiv1.EndpointAddress{{IP: "10.0.0.3"}}, Ports: ports},
+		{Addresses: []apiv1.EndpointAddress{{IP: "10.0.0.2"}}, Ports: ports},
+	}
+	if !reflect.DeepEqual(eps.Subsets, expected) {
+		t.Errorf("expected subsets %v but returned %v", expected, eps.Subsets)
+	}
+	if eps.Namespace != "default" || eps.Name != "demo" {
+		t.Errorf("expected the Endpoints default/demo but returned %v/%v", eps.Namespace, eps.Name)
+	}
+
+	eps, err = s.GetServiceEndpoints("default/other")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(eps.Subsets) != 1 || eps.Subsets[0].Ports[0].Port != 9090 {
+		t.Errorf("expected the endpoints of the Service default/other but returned %v", eps.Subsets)
+	}
+
+	if _, err := s.GetServiceEndpoints("default/missing"); err == nil {
+		t.Errorf("expected an error for a Service without EndpointSlices")
+	}
+}
+
+func TestEndpointSliceServiceIndexFunc(t *testing.T) {
+	slice := newEndpointSlice("demo-a", "demo", 8080, nil)
+
+	keys, err := endpointSliceServiceIndexFunc(slice)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(keys, []string{"default/demo"}) {
+		t.Errorf("expected the key default/demo but returned %v", keys)
+	}
+
+	delete(slice.Labels, discoveryv1.LabelServiceName)
+	keys, err = endpointSliceServiceIndexFunc(slice)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(keys) != 0 {
+		t.Errorf("expected no key for an EndpointSlice without Service but returned %v", keys)
+	}
+
+	if _, err := endpointSliceServiceIndexFunc(&apiv1.Endpoints{}); err == nil {
+		t.Errorf("expected an error indexing an object that is not an EndpointSlice")
+	}
+}
@@ -17,6 +17,8 @@ limitations under the License.
 package store
 
 import (
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/tools/cache"
 )
 
@@ -24,3 +26,33 @@ import (
 type PodLister struct {
 	cache.Store
 }
+
+// ByKey returns the Pod matching key in the local Pod Store.
+func (pl *PodLister) ByKey(key string) (*apiv1.Pod, error) {
+	p, exists, err := pl.GetByKey(key)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, NotExistsError(key)
+	}
+	return p.(*apiv1.Pod), nil
+}
+
+// podLabelsTransform keeps only the name, the namespace and the labels of
+// the Pods cached to filter the endpoints of the backends
+func podLabelsTransform(obj interface{}) (interface{}, error) {
+	pod, ok := obj.(*apiv1.Pod)
+	if !ok {
+		return obj, nil
+	}
+
+	return &apiv1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            pod.Name,
+			Namespace:       pod.Namespace,
+			Labels:          pod.Labels,
+			ResourceVersion: pod.ResourceVersion,
+		},
+	}, nil
+}
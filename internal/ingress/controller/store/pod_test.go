@@ -0,0 +1,66 @@
+/*
+Copyright 2023 The Alibaba Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package store
+
+import (
+	"reflect"
+	"testing"
+
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestPodLister(t *testing.T) {
+	pod := &apiv1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "backend-1",
+			Namespace:   "default",
+			Labels:      map[string]string{"version": "v2"},
+			Annotations: map[string]string{"foo": "bar"},
+		},
+		Spec: apiv1.PodSpec{
+			Containers: []apiv1.Container{{Name: "backend", Image: "backend:v2"}},
+		},
+	}
+
+	obj, err := podLabelsTransform(pod)
+	if err != nil {
+		t.Fatalf("unexpected error transforming the Pod: %v", err)
+	}
+
+	pl := PodLister{cache.NewStore(cache.MetaNamespaceKeyFunc)}
+	if err := pl.Add(obj); err != nil {
+		t.Fatalf("unexpected error adding the Pod: %v", err)
+	}
+
+	cached, err := pl.ByKey("default/backend-1")
+	if err != nil {
+		t.Fatalf("unexpected error getting the Pod: %v", err)
+	}
+	if !reflect.DeepEqual(cached.Labels, pod.Labels) {
+		t.Errorf("expected the labels %v but got %v", pod.Labels, cached.Labels)
+	}
+	if cached.Annotations != nil || len(cached.Spec.Containers) != 0 {
+		t.Errorf("expected only the name, the namespace and the labels of the Pod to be cached but got %v", cached)
+	}
+
+	_, err = pl.ByKey("default/backend-2")
+	if _, ok := err.(NotExistsError); !ok {
+		t.Errorf("expected a NotExistsError for a missing Pod but got %v", err)
+	}
+}
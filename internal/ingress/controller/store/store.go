@@ -19,7 +19,9 @@ package store
 
 import (
 	"context"
+	"crypto/sha1"
 	"encoding/base64"
+	"encoding/hex"
 	"fmt"
 	"os"
 	"reflect"
@@ -32,11 +34,13 @@ import (
 
 	"github.com/eapache/channels"
 	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
 	networkingv1 "k8s.io/api/networking/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
 	k8sruntime "k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/client-go/informers"
 	clientset "k8s.io/client-go/kubernetes"
@@ -58,6 +62,7 @@ import (
 	"k8s.io/ingress-nginx/internal/ingress/annotations"
 	ing_gray "k8s.io/ingress-nginx/internal/ingress/annotations/gray"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/referrer"
 	ngx_config "k8s.io/ingress-nginx/internal/ingress/controller/config"
 	"k8s.io/ingress-nginx/internal/ingress/controller/ingressclass"
 	ngx_template "k8s.io/ingress-nginx/internal/ingress/controller/template"
@@ -86,6 +91,14 @@ type Storer interface {
 	// GetBackendConfiguration returns the nginx configuration stored in a configmap
 	GetBackendConfiguration() ngx_config.Configuration
 
+	// GetNamespaceConfiguration returns the nginx configuration with the configmap
+	// overlay of the namespace applied, <configmap name>-<namespace> in the namespace
+	// of the configmap
+	GetNamespaceConfiguration(namespace string) ngx_config.Configuration
+
+	// GetCanaryReferrerMatcher returns the matcher of the canary-referrer configmap key
+	GetCanaryReferrerMatcher() *referrer.Matcher
+
 	// GetConfigMap returns the ConfigMap matching key.
 	GetConfigMap(key string) (*corev1.ConfigMap, error)
 
@@ -98,6 +111,9 @@ type Storer interface {
 	// GetServiceEndpoints returns the Endpoints of a Service matching key.
 	GetServiceEndpoints(key string) (*corev1.Endpoints, error)
 
+	// GetPod returns the Pod matching key.
+	GetPod(key string) (*corev1.Pod, error)
+
 	// ListIngresses returns a list of all Ingresses in the store.
 	ListIngresses(IngressFilterFunc) []*ingress.Ingress
 
@@ -145,6 +161,9 @@ type Storer interface {
 	// GetDefaultBackend returns the default backend configuration
 	GetDefaultBackend() defaults.Backend
 
+	// GetCanaryLimits returns the maximum number of actions of every type a canary ingress can configure
+	GetCanaryLimits() resolver.CanaryLimits
+
 	// Run initiates the synchronization of the controllers
 	Run(stopCh chan struct{})
 
@@ -166,6 +185,13 @@ const (
 	ConfigurationEvent EventType = "CONFIGURATION"
 )
 
+// reasons of the ingresses ignored by the store, used as metric labels
+const (
+	ignoredReasonClass     = "class"
+	ignoredReasonNamespace = "namespace"
+	ignoredReasonCatchAll  = "catch-all-disabled"
+)
+
 // Event holds the context of an event.
 type Event struct {
 	Type EventType
@@ -177,11 +203,13 @@ type Informer struct {
 	Ingress         cache.SharedIndexInformer
 	IngressClass    cache.SharedIndexInformer
 	Endpoint        cache.SharedIndexInformer
+	EndpointSlice   cache.SharedIndexInformer
 	Service         cache.SharedIndexInformer
 	Secret          cache.SharedIndexInformer
 	ConfigMap       cache.SharedIndexInformer
 	Namespace       cache.SharedIndexInformer
 	Pod             cache.SharedIndexInformer
+	BackendPod      cache.SharedIndexInformer
 	IngressCheckSum cache.SharedIndexInformer
 	SecretCheckSum  cache.SharedIndexInformer
 }
@@ -192,11 +220,13 @@ type Lister struct {
 	IngressClass          IngressClassLister
 	Service               ServiceLister
 	Endpoint              EndpointLister
+	EndpointSlice         EndpointSliceLister
 	Secret                SecretLister
 	ConfigMap             ConfigMapLister
 	Namespace             NamespaceLister
 	IngressWithAnnotation IngressWithAnnotationsLister
 	Pod                   PodLister
+	BackendPod            PodLister
 	IngressCheckSum       IngressCheckSumLister
 	SecretCheckSum        SecretCheckSumLister
 	IngWithAnnotation     IngressWithAnnotationsLister
@@ -217,22 +247,34 @@ var useSecretCheckSum = false
 // Run initiates the synchronization of the informers against the API server.
 func (i *Informer) Run(stopCh chan struct{}) {
 	go i.Secret.Run(stopCh)
-	go i.Endpoint.Run(stopCh)
+	if i.Endpoint != nil {
+		go i.Endpoint.Run(stopCh)
+	}
+	if i.EndpointSlice != nil {
+		go i.EndpointSlice.Run(stopCh)
+	}
 	if i.IngressClass != nil {
 		go i.IngressClass.Run(stopCh)
 	}
 	go i.Service.Run(stopCh)
 	go i.ConfigMap.Run(stopCh)
 	go i.Pod.Run(stopCh)
+	go i.BackendPod.Run(stopCh)
 
 	// wait for all involved caches to be synced before processing items
 	// from the queue
+	endpointsSynced := i.Endpoint
+	if i.EndpointSlice != nil {
+		endpointsSynced = i.EndpointSlice
+	}
+
 	if !cache.WaitForCacheSync(stopCh,
-		i.Endpoint.HasSynced,
+		endpointsSynced.HasSynced,
 		i.Service.HasSynced,
 		i.Secret.HasSynced,
 		i.ConfigMap.HasSynced,
 		i.Pod.HasSynced,
+		i.BackendPod.HasSynced,
 	) {
 		runtime.HandleError(fmt.Errorf("timed out waiting for caches to sync"))
 	}
@@ -292,6 +334,22 @@ type k8sStore struct {
 	// operation to execute in each OnUpdate invocation
 	backendConfig ngx_config.Configuration
 
+	// configmap is the key of the configmap of the backendConfig
+	configmap string
+
+	// namespaceConfigs caches the backendConfig with the configmap overlay of
+	// a namespace applied, by namespace
+	namespaceConfigs map[string]ngx_config.Configuration
+
+	// ingressReferrers and canaryReferrers match the referrers allowed by
+	// the backendConfig, parsed once when the configmap changes
+	ingressReferrers *referrer.Matcher
+	canaryReferrers  *referrer.Matcher
+
+	// sslSessionTicketKeyHash is the SHA1 of the last ssl-session-ticket-key
+	// written to disk, used to skip rewriting an unchanged key
+	sslSessionTicketKeyHash string
+
 	// informer contains the cache Informers
 	informers *Informer
 
@@ -315,13 +373,47 @@ type k8sStore struct {
 	// syncSecretMu protects against simultaneous invocations of syncSecret
 	syncSecretMu *sync.Mutex
 
+	// sslCertTTL is the time a certificate of sslStore is kept after it is
+	// no longer referenced, 0 disables the cleanup
+	sslCertTTL time.Duration
+
+	// sslCertUnusedSince contains the time since the certificates of sslStore
+	// are not referenced, by secret key. Protected by syncSecretMu
+	sslCertUnusedSince map[string]time.Time
+
+	// certRetries contains the pending retries of the certificates of the
+	// secrets with annotations that failed to load, by secret key
+	certRetries map[string]*certRetry
+
+	// certRetriesMu protects against simultaneous access of certRetries
+	certRetriesMu *sync.Mutex
+
 	// backendConfigMu protects against simultaneous read/write of backendConfig
 	backendConfigMu *sync.RWMutex
 
+	// cfgMapSyncDelay is the time the changes of the configmaps are buffered
+	// before syncing the ingresses, 0 syncs them on every change
+	cfgMapSyncDelay time.Duration
+
+	// cfgMapSyncMu protects the pending sync of the changes of the configmaps
+	cfgMapSyncMu *sync.Mutex
+
+	// cfgMapSyncTimer fires the pending sync of the changes of the configmaps,
+	// nil when there is none
+	cfgMapSyncTimer *time.Timer
+
+	// cfgMapSyncUpdate is true when a pending change requires an update of
+	// the configuration, cfgMapSyncObj is the configmap of the last one
+	cfgMapSyncUpdate bool
+	cfgMapSyncObj    *corev1.ConfigMap
+
 	defaultSSLCertificate string
 
 	pod *k8s.PodInfo
 
+	// client is used to read the objects not cached by the informers
+	client clientset.Interface
+
 	// ingCheckSumStore local store of ingress checkesum
 	ingCheckSumStore *IngressCheckSumStore
 
@@ -342,6 +434,8 @@ func New(
 	namespaceSelector labels.Selector,
 	configmap, tcp, udp, defaultSSLCertificate string,
 	resyncPeriod time.Duration,
+	configmapSyncDelay time.Duration,
+	sslCertTTL time.Duration,
 	client clientset.Interface,
 	ClientIng clientset.Interface,
 	ClientIngCheck ingcheckclient.Interface,
@@ -350,6 +444,7 @@ func New(
 	updateCh *channels.RingChannel,
 	pod *k8s.PodInfo,
 	disableCatchAll bool,
+	useEndpointSlices bool,
 	checksumStatus *ingress.ChecksumStatus,
 	icConfig *ingressclass.IngressClassConfiguration) Storer {
 
@@ -359,7 +454,15 @@ func New(
 		sslStore:              NewSSLCertTracker(),
 		updateCh:              updateCh,
 		backendConfig:         ngx_config.NewDefault(),
+		configmap:             configmap,
+		namespaceConfigs:      make(map[string]ngx_config.Configuration),
+		ingressReferrers:      referrer.NewMatcher(""),
+		canaryReferrers:       referrer.NewMatcher(""),
 		syncSecretMu:          &sync.Mutex{},
+		sslCertTTL:            sslCertTTL,
+		sslCertUnusedSince:    make(map[string]time.Time),
+		certRetries:           make(map[string]*certRetry),
+		certRetriesMu:         &sync.Mutex{},
 		backendConfigMu:       &sync.RWMutex{},
 		secretIngressMap:      NewObjectRefMap(),
 		defaultSSLCertificate: defaultSSLCertificate,
@@ -368,6 +471,9 @@ func New(
 		secretCheckSumStore:   NewSecretCheckSumStore(),
 		mc:                    mc,
 		checksumStatus:        checksumStatus,
+		cfgMapSyncDelay:       configmapSyncDelay,
+		cfgMapSyncMu:          &sync.Mutex{},
+		client:                client,
 	}
 
 	eventBroadcaster := record.NewBroadcaster()
@@ -445,8 +551,19 @@ func New(
 	store.informers.SecretCheckSum = secretCheckCrdFactory.Tengine().V1().SecretCheckSums().Informer()
 	store.listers.SecretCheckSum.Store = store.informers.SecretCheckSum.GetStore()
 
-	store.informers.Endpoint = infFactory.Core().V1().Endpoints().Informer()
-	store.listers.Endpoint.Store = store.informers.Endpoint.GetStore()
+	if useEndpointSlices {
+		store.informers.EndpointSlice = infFactory.Discovery().V1().EndpointSlices().Informer()
+		err := store.informers.EndpointSlice.AddIndexers(cache.Indexers{
+			endpointSliceServiceIndex: endpointSliceServiceIndexFunc,
+		})
+		if err != nil {
+			klog.Fatalf("Unexpected error indexing EndpointSlices by Service: %v", err)
+		}
+		store.listers.EndpointSlice.Indexer = store.informers.EndpointSlice.GetIndexer()
+	} else {
+		store.informers.Endpoint = infFactory.Core().V1().Endpoints().Informer()
+		store.listers.Endpoint.Store = store.informers.Endpoint.GetStore()
+	}
 
 	// store.informers.Secret = infFactory.Core().V1().Secrets().Informer()
 	if useStorageCluster {
@@ -481,6 +598,14 @@ func New(
 	)
 	store.listers.Pod.Store = store.informers.Pod.GetStore()
 
+	// the labels of the Pods of the backends select the endpoints of the
+	// upstream-endpoint-filter annotation
+	store.informers.BackendPod = infFactory.Core().V1().Pods().Informer()
+	if err := store.informers.BackendPod.SetTransform(podLabelsTransform); err != nil {
+		klog.Fatalf("Unexpected error transforming Pods: %v", err)
+	}
+	store.listers.BackendPod.Store = store.informers.BackendPod.GetStore()
+
 	// avoid caching namespaces at cluster scope when watching single namespace
 	if namespaceSelector != nil && !namespaceSelector.Empty() {
 		// cache informers factory for namespaces
@@ -559,12 +684,14 @@ func New(
 			ing, _ := toIngress(obj)
 
 			if !watchedNamespace(ing.Namespace) {
+				store.mc.IncIngressIgnoredCount(ignoredReasonNamespace)
 				return
 			}
 
 			ic, err := store.GetIngressClass(ing, icConfig)
 			if err != nil {
 				klog.Infof("Ignoring ingress [%v/%v] because of error while validating ingress class: %v.", ing.Namespace, ing.Name, err)
+				store.mc.IncIngressIgnoredCount(ignoredReasonClass)
 				return
 			}
 
@@ -572,6 +699,7 @@ func New(
 
 			if isCatchAllIngress(ing.Spec) && disableCatchAll {
 				klog.Infof("ignoring add for catch-all ingress %v/%v because of --disable-catch-all", ing.Namespace, ing.Name)
+				store.mc.IncIngressIgnoredCount(ignoredReasonCatchAll)
 				return
 			}
 			recorder.Eventf(ing, corev1.EventTypeNormal, "CREATE", fmt.Sprintf("Ingress %s/%s", ing.Namespace, ing.Name))
@@ -605,6 +733,7 @@ func New(
 			if errOld != nil && errCur == nil {
 				if isCatchAllIngress(curIng.Spec) && disableCatchAll {
 					klog.Infof("ignoring update for catch-all ingress %v/%v because of --disable-catch-all", curIng.Namespace, curIng.Name)
+					store.mc.IncIngressIgnoredCount(ignoredReasonCatchAll)
 					return
 				}
 
@@ -617,6 +746,7 @@ func New(
 			} else if errCur == nil && !reflect.DeepEqual(old, cur) {
 				if isCatchAllIngress(curIng.Spec) && disableCatchAll {
 					klog.Infof("ignoring update for catch-all ingress %v/%v and delete old one because of --disable-catch-all", curIng.Namespace, curIng.Name)
+					store.mc.IncIngressIgnoredCount(ignoredReasonCatchAll)
 					ingDeleteHandler(old)
 					return
 				}
@@ -643,7 +773,7 @@ func New(
 		AddFunc: func(obj interface{}) {
 			ingressclass := obj.(*networkingv1.IngressClass)
 			foundClassByName := false
-			if icConfig.IngressClassByName && ingressclass.Name == icConfig.AnnotationValue {
+			if icConfig.IngressClassByName && icConfig.MatchAnnotation(ingressclass.Name) {
 				klog.Infof("adding ingressclass as ingress-class-by-name is configured: %v", ingressclass)
 				foundClassByName = true
 			}
@@ -788,6 +918,7 @@ func New(
 
 			store.listers.SecretWithAnnotation.Delete(sec)
 			store.sslStore.Delete(k8s.MetaNamespaceKey(sec))
+			store.cancelCertificateRetry(k8s.MetaNamespaceKey(sec))
 
 			key := k8s.MetaNamespaceKey(sec)
 			// find references in ingresses
@@ -897,6 +1028,31 @@ func New(
 		},
 	}
 
+	epsEventHandler := cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			updateCh.In() <- Event{
+				Type: CreateEvent,
+				Obj:  obj,
+			}
+		},
+		DeleteFunc: func(obj interface{}) {
+			updateCh.In() <- Event{
+				Type: DeleteEvent,
+				Obj:  obj,
+			}
+		},
+		UpdateFunc: func(old, cur interface{}) {
+			oeps := old.(*discoveryv1.EndpointSlice)
+			ceps := cur.(*discoveryv1.EndpointSlice)
+			if !reflect.DeepEqual(ceps.Endpoints, oeps.Endpoints) || !reflect.DeepEqual(ceps.Ports, oeps.Ports) {
+				updateCh.In() <- Event{
+					Type: UpdateEvent,
+					Obj:  cur,
+				}
+			}
+		},
+	}
+
 	// TODO: add e2e test to verify that changes to one or more configmap trigger an update
 	changeTriggerUpdate := func(name string) bool {
 		if name == configmap {
@@ -904,6 +1060,14 @@ func New(
 		return name == configmap || name == tcp || name == udp
 	}
 
+	// namespaceOverlay returns the namespace of a configmap overlay
+	namespaceOverlay := func(name string) (string, bool) {
+		if name == tcp || name == udp || !strings.HasPrefix(name, configmap+"-") {
+			return "", false
+		}
+		return strings.TrimPrefix(name, configmap+"-"), true
+	}
+
 	handleCfgMapEvent := func(key string, cfgMap *corev1.ConfigMap, eventName string) {
 		// updates to configuration configmaps can trigger an update
 		triggerUpdate := false
@@ -913,33 +1077,13 @@ func New(
 			if key == configmap {
 				store.setConfig(cfgMap)
 			}
+		} else if ns, ok := namespaceOverlay(key); ok {
+			triggerUpdate = true
+			recorder.Eventf(cfgMap, corev1.EventTypeNormal, eventName, fmt.Sprintf("ConfigMap %v", key))
+			store.invalidateNamespaceConfig(ns)
 		}
 
-		ings := store.listers.IngressWithAnnotation.List()
-		for _, ingKey := range ings {
-			key := k8s.MetaNamespaceKey(ingKey)
-			ing, err := store.getIngress(key)
-			if err != nil {
-				klog.Errorf("could not find Ingress %v in local store: %v", key, err)
-				continue
-			}
-
-			if parser.AnnotationsReferencesConfigmap(ing) {
-				store.syncIngress(ing)
-				continue
-			}
-
-			if triggerUpdate {
-				store.syncIngress(ing)
-			}
-		}
-
-		if triggerUpdate {
-			updateCh.In() <- Event{
-				Type: ConfigurationEvent,
-				Obj:  cfgMap,
-			}
-		}
+		store.queueConfigMapSync(cfgMap, triggerUpdate)
 	}
 
 	cmEventHandler := cache.ResourceEventHandlerFuncs{
@@ -957,6 +1101,27 @@ func New(
 			key := k8s.MetaNamespaceKey(cfgMap)
 			handleCfgMapEvent(key, cfgMap, "UPDATE")
 		},
+		DeleteFunc: func(obj interface{}) {
+			cfgMap, ok := obj.(*corev1.ConfigMap)
+			if !ok {
+				tombstone, ok := obj.(cache.DeletedFinalStateUnknown)
+				if !ok {
+					klog.Errorf("couldn't get object from tombstone %#v", obj)
+					return
+				}
+				cfgMap, ok = tombstone.Obj.(*corev1.ConfigMap)
+				if !ok {
+					klog.Errorf("Tombstone contained object that is not a ConfigMap: %#v", obj)
+					return
+				}
+			}
+
+			// only the overlays of the namespaces fall back to the configuration when deleted
+			key := k8s.MetaNamespaceKey(cfgMap)
+			if _, ok := namespaceOverlay(key); ok {
+				handleCfgMapEvent(key, cfgMap, "DELETE")
+			}
+		},
 	}
 
 	serviceHandler := cache.ResourceEventHandlerFuncs{
@@ -1028,7 +1193,11 @@ func New(
 
 	store.informers.IngressCheckSum.AddEventHandler(icEventHandler)
 	store.informers.SecretCheckSum.AddEventHandler(scEventHandler)
-	store.informers.Endpoint.AddEventHandler(epEventHandler)
+	if useEndpointSlices {
+		store.informers.EndpointSlice.AddEventHandler(epsEventHandler)
+	} else {
+		store.informers.Endpoint.AddEventHandler(epEventHandler)
+	}
 	store.informers.Secret.AddEventHandler(secrEventHandler)
 	store.informers.ConfigMap.AddEventHandler(cmEventHandler)
 	store.informers.Service.AddEventHandler(serviceHandler)
@@ -1117,6 +1286,7 @@ func (s *k8sStore) updateSecretIngressMap(ing *networkingv1.Ingress) {
 		"auth-tls-secret",
 		"proxy-ssl-secret",
 		"secure-verify-ca-secret",
+		"ssl-dh-param-secret",
 	}
 	for _, ann := range secretAnnotations {
 		secrKey, err := objectRefAnnotationNsKey(ann, ing)
@@ -1158,9 +1328,13 @@ func (s *k8sStore) syncSecrets(ing *networkingv1.Ingress) {
 	key := k8s.MetaNamespaceKey(ing)
 	for _, secrKey := range s.secretIngressMap.ReferencedBy(key) {
 		gray, _ := s.GetSecretGrayStatus(secrKey)
-		if gray.Type == ingress.InactiveGray {
+		switch gray.Type {
+		case ingress.InactiveGray:
 			klog.Infof("Secret %v is marked as inactive gray, ignoring", secrKey)
+			s.mc.IncSecretGrayInactiveCount()
 			continue
+		case ingress.ActiveGray:
+			s.mc.IncSecretGrayActiveCount()
 		}
 
 		s.syncSecret(secrKey, s.mc)
@@ -1190,7 +1364,16 @@ func (s *k8sStore) GetService(key string) (*corev1.Service, error) {
 }
 
 func (s *k8sStore) GetIngressClass(ing *networkingv1.Ingress, icConfig *ingressclass.IngressClassConfiguration) (string, error) {
-	// First we try ingressClassName
+	// First we try annotation, it takes precedence over ingressClassName
+	// for backward compatibility
+	if ingressclass, ok := ing.GetAnnotations()[ingressclass.IngressKey]; ok {
+		if !icConfig.MatchAnnotation(ingressclass) {
+			return "", fmt.Errorf("ingress class annotation is not equal to the expected by Ingress Controller")
+		}
+		return ingressclass, nil
+	}
+
+	// Then we try ingressClassName
 	if !icConfig.IgnoreIngressClass && ing.Spec.IngressClassName != nil {
 		iclass, err := s.listers.IngressClass.ByKey(*ing.Spec.IngressClassName)
 		if err != nil {
@@ -1199,14 +1382,6 @@ func (s *k8sStore) GetIngressClass(ing *networkingv1.Ingress, icConfig *ingressc
 		return iclass.Name, nil
 	}
 
-	// Then we try annotation
-	if ingressclass, ok := ing.GetAnnotations()[ingressclass.IngressKey]; ok {
-		if ingressclass != icConfig.AnnotationValue {
-			return "", fmt.Errorf("ingress class annotation is not equal to the expected by Ingress Controller")
-		}
-		return ingressclass, nil
-	}
-
 	// Then we accept if the WithoutClass is enabled
 	if icConfig.WatchWithoutClass {
 		// Reserving "_" as a "wildcard" name
@@ -1304,11 +1479,27 @@ func (s *k8sStore) GetConfigMap(key string) (*corev1.ConfigMap, error) {
 	return s.listers.ConfigMap.ByKey(key)
 }
 
-// GetServiceEndpoints returns the Endpoints of a Service matching key.
+// GetServiceEndpoints returns the Endpoints of a Service matching key,
+// merged from its EndpointSlices when they are used.
 func (s *k8sStore) GetServiceEndpoints(key string) (*corev1.Endpoints, error) {
+	if s.listers.EndpointSlice.Indexer != nil {
+		slices, err := s.listers.EndpointSlice.MatchByKey(key)
+		if err != nil {
+			return nil, err
+		}
+
+		return endpointsFromSlices(slices), nil
+	}
+
 	return s.listers.Endpoint.ByKey(key)
 }
 
+// GetPod returns the Pod matching key. Only the name, the namespace and the
+// labels of the Pods of the backends are cached.
+func (s *k8sStore) GetPod(key string) (*corev1.Pod, error) {
+	return s.listers.BackendPod.ByKey(key)
+}
+
 // GetAuthCertificate is used by the auth-tls annotations to get a cert from a secret
 func (s *k8sStore) GetAuthCertificate(name string) (*resolver.AuthSSLCert, error) {
 	if _, err := s.GetLocalSSLCert(name); err != nil {
@@ -1348,21 +1539,110 @@ func (s *k8sStore) writeSSLSessionTicketKey(cmap *corev1.ConfigMap, fileName str
 			return
 		}
 
+		hasher := sha1.New()
+		hasher.Write(decodedTicket)
+		ticketHash := hex.EncodeToString(hasher.Sum(nil))
+
+		// the key only changes on purpose, avoid touching the file (and a
+		// reload of tengine) on every update of an unrelated configmap key
+		if ticketHash == s.sslSessionTicketKeyHash && ticketHash == file.SHA1(fileName) {
+			klog.V(3).Infof("ssl-session-ticket-key in %s is up to date", fileName)
+			s.backendConfig.SSLSessionTicketKey = ticketString
+			return
+		}
+
 		err = os.WriteFile(fileName, decodedTicket, file.ReadWriteByUser)
 		if err != nil {
 			klog.Errorf("unexpected error writing ssl-session-ticket-key to %s: %v", fileName, err)
 			return
 		}
 
+		s.sslSessionTicketKeyHash = ticketHash
 		s.backendConfig.SSLSessionTicketKey = ticketString
 	}
 }
 
+// queueConfigMapSync syncs the ingresses after a change of a configmap. The
+// changes received within cfgMapSyncDelay of the first one are coalesced in
+// a single sync, the configuration itself is already updated by then.
+func (s *k8sStore) queueConfigMapSync(cfgMap *corev1.ConfigMap, triggerUpdate bool) {
+	if s.cfgMapSyncDelay <= 0 {
+		s.syncConfigMapChange(cfgMap, triggerUpdate)
+		return
+	}
+
+	s.cfgMapSyncMu.Lock()
+	defer s.cfgMapSyncMu.Unlock()
+
+	if triggerUpdate || !s.cfgMapSyncUpdate {
+		s.cfgMapSyncObj = cfgMap
+	}
+	s.cfgMapSyncUpdate = s.cfgMapSyncUpdate || triggerUpdate
+
+	if s.cfgMapSyncTimer == nil {
+		s.cfgMapSyncTimer = time.AfterFunc(s.cfgMapSyncDelay, s.flushConfigMapSync)
+	}
+}
+
+// flushConfigMapSync runs the pending sync of the changes of the configmaps
+func (s *k8sStore) flushConfigMapSync() {
+	s.cfgMapSyncMu.Lock()
+	cfgMap, triggerUpdate := s.cfgMapSyncObj, s.cfgMapSyncUpdate
+	s.cfgMapSyncTimer = nil
+	s.cfgMapSyncObj = nil
+	s.cfgMapSyncUpdate = false
+	s.cfgMapSyncMu.Unlock()
+
+	s.syncConfigMapChange(cfgMap, triggerUpdate)
+}
+
+// syncConfigMapChange syncs the ingresses affected by a change of the configmaps
+// and, if the configuration changed, notifies it
+func (s *k8sStore) syncConfigMapChange(cfgMap *corev1.ConfigMap, triggerUpdate bool) {
+	ings := s.listers.IngressWithAnnotation.List()
+	for _, ingKey := range ings {
+		key := k8s.MetaNamespaceKey(ingKey)
+		ing, err := s.getIngress(key)
+		if err != nil {
+			klog.Errorf("could not find Ingress %v in local store: %v", key, err)
+			continue
+		}
+
+		if parser.AnnotationsReferencesConfigmap(ing) {
+			s.syncIngress(ing)
+			continue
+		}
+
+		if triggerUpdate {
+			s.syncIngress(ing)
+		}
+	}
+
+	if triggerUpdate {
+		s.updateCh.In() <- Event{
+			Type: ConfigurationEvent,
+			Obj:  cfgMap,
+		}
+	}
+}
+
 // GetDefaultBackend returns the default backend
 func (s *k8sStore) GetDefaultBackend() defaults.Backend {
 	return s.GetBackendConfiguration().Backend
 }
 
+// GetCanaryLimits returns the maximum number of actions of every type a canary ingress can configure
+func (s *k8sStore) GetCanaryLimits() resolver.CanaryLimits {
+	cfg := s.GetBackendConfiguration()
+	return resolver.CanaryLimits{
+		ReqAddHeader:     cfg.MaxReqAddHeaderNum,
+		ReqAppendHeader:  cfg.MaxReqAppendHeaderNum,
+		ReqAddQuery:      cfg.MaxReqAddQueryNum,
+		RespAddHeader:    cfg.MaxRespAddHeaderNum,
+		RespAppendHeader: cfg.MaxRespAppendHeaderNum,
+	}
+}
+
 func (s *k8sStore) GetBackendConfiguration() ngx_config.Configuration {
 	s.backendConfigMu.RLock()
 	defer s.backendConfigMu.RUnlock()
@@ -1370,6 +1650,45 @@ func (s *k8sStore) GetBackendConfiguration() ngx_config.Configuration {
 	return s.backendConfig
 }
 
+// GetNamespaceConfiguration returns the nginx configuration with the configmap
+// overlay of the namespace applied, parsed once until the overlay or the
+// configmap changes
+func (s *k8sStore) GetNamespaceConfiguration(namespace string) ngx_config.Configuration {
+	s.backendConfigMu.RLock()
+	cfg, ok := s.namespaceConfigs[namespace]
+	s.backendConfigMu.RUnlock()
+	if ok {
+		return cfg
+	}
+
+	s.backendConfigMu.Lock()
+	defer s.backendConfigMu.Unlock()
+
+	cfg = s.backendConfig
+	if cmap, err := s.GetConfigMap(fmt.Sprintf("%v-%v", s.configmap, namespace)); err == nil {
+		cfg = ngx_template.ReadNamespaceConfig(cfg, cmap.Data)
+	}
+
+	s.namespaceConfigs[namespace] = cfg
+	return cfg
+}
+
+// invalidateNamespaceConfig removes the cached configuration of a namespace
+func (s *k8sStore) invalidateNamespaceConfig(namespace string) {
+	s.backendConfigMu.Lock()
+	defer s.backendConfigMu.Unlock()
+
+	delete(s.namespaceConfigs, namespace)
+}
+
+// GetCanaryReferrerMatcher returns the matcher of the canary-referrer configmap key
+func (s *k8sStore) GetCanaryReferrerMatcher() *referrer.Matcher {
+	s.backendConfigMu.RLock()
+	defer s.backendConfigMu.RUnlock()
+
+	return s.canaryReferrers
+}
+
 func (s *k8sStore) setConfig(cmap *corev1.ConfigMap) {
 	s.backendConfigMu.Lock()
 	defer s.backendConfigMu.Unlock()
@@ -1378,12 +1697,21 @@ func (s *k8sStore) setConfig(cmap *corev1.ConfigMap) {
 		return
 	}
 
+	for _, key := range ngx_template.UnknownConfigKeys(cmap.Data) {
+		klog.Warningf("Unknown key %q in ConfigMap %v/%v, it will be ignored", key, cmap.Namespace, cmap.Name)
+		s.mc.IncUnknownConfigKeyCount(key)
+	}
+
 	s.backendConfig = ngx_template.ReadConfig(cmap.Data)
+	s.namespaceConfigs = make(map[string]ngx_config.Configuration)
 	if s.backendConfig.UseGeoIP2 && !nginx.GeoLite2DBExists() {
 		klog.Warning("The GeoIP2 feature is enabled but the databases are missing. Disabling.")
 		s.backendConfig.UseGeoIP2 = false
 	}
 
+	s.ingressReferrers = referrer.NewMatcher(s.backendConfig.IngressReferrer)
+	s.canaryReferrers = referrer.NewMatcher(s.backendConfig.CanaryReferrer)
+
 	s.writeSSLSessionTicketKey(cmap, "/etc/nginx/tickets.key")
 }
 
@@ -1392,6 +1720,10 @@ func (s *k8sStore) setConfig(cmap *corev1.ConfigMap) {
 func (s *k8sStore) Run(stopCh chan struct{}) {
 	// start informers
 	s.informers.Run(stopCh)
+
+	if s.sslCertTTL > 0 {
+		go wait.Until(s.sweepSSLCerts, s.sslCertTTL/2, stopCh)
+	}
 }
 
 // GetRunningControllerPodsCount returns the number of Running ingress-nginx controller Pods
@@ -1434,10 +1766,33 @@ func (s *k8sStore) updateSecretWithAnnotation(secret *corev1.Secret) {
 	key := k8s.MetaNamespaceKey(secret)
 	klog.Infof("updating annotations information for secret [%v]", key)
 
+	// the new version of the secret replaces the retries of the previous one
+	s.cancelCertificateRetry(key)
+	s.loadSecretWithAnnotation(secret, 0)
+}
+
+// loadSecretWithAnnotation obtains the certificate of the secret, after the
+// given number of retries, and updates the secret with annotations.
+// The certificate and the key of a rotation can be written in two steps, so
+// the certificates that fail to load are retried a few times with a backoff.
+func (s *k8sStore) loadSecretWithAnnotation(secret *corev1.Secret, retries int) bool {
+	key := k8s.MetaNamespaceKey(secret)
+
 	cert, err := s.getPemCertificate(key)
 	if err != nil {
-		klog.Errorf("failed to obtain X.509 certificate [%v]: %v", key, err)
-		return
+		if !hasCertificateData(secret) {
+			klog.Errorf("failed to obtain X.509 certificate [%v]: %v", key, err)
+			return false
+		}
+
+		if s.retryCertificate(key, retries+1) {
+			klog.Warningf("failed to obtain X.509 certificate [%v], retry %v of %v: %v", key, retries+1, certLoadRetries, err)
+			return false
+		}
+
+		klog.Errorf("failed to obtain X.509 certificate [%v] after %v retries, waiting for the secret to change: %v", key, retries, err)
+		s.mc.IncSSLCertLoadFailCount()
+		return false
 	}
 
 	err = s.listers.SecretWithAnnotation.Update(&ingress.Secret{
@@ -1447,7 +1802,10 @@ func (s *k8sStore) updateSecretWithAnnotation(secret *corev1.Secret) {
 	})
 	if err != nil {
 		klog.Error("update secret with annotations failed: ", err)
+		return false
 	}
+
+	return true
 }
 
 // GetSecretWithAnnotation returns a secret with annotations in the store
@@ -1510,12 +1868,12 @@ func (s *k8sStore) verifyIngressReferrer(key string, anns *annotations.Ingress)
 		return true
 	}
 
-	cfg := s.GetBackendConfiguration()
-	ingReferrers := strings.Split(cfg.IngressReferrer, ",")
-	for _, ingReferrer := range ingReferrers {
-		if ingReferrer == anns.Referrer.IngReferrer {
-			return true
-		}
+	s.backendConfigMu.RLock()
+	ingReferrers := s.ingressReferrers
+	s.backendConfigMu.RUnlock()
+
+	if ingReferrers.Match(anns.Referrer.IngReferrer) {
+		return true
 	}
 
 	s.mc.IncIngReferInvalidCount()
@@ -1531,22 +1889,50 @@ func (s *k8sStore) GetIngressGrayStatus(key string, anns *annotations.Ingress) (
 
 	podOrdinal := astsutils.GetPodOrdinal(s.pod)
 	ingGrayIndex := int32(anns.IngGray.IngGrayIndex)
+	// the new ingress is rolled out to the pods with ordinal less than index,
+	// or rolled back from them keeping it in the pods with ordinal >= index
+	direction := "rollout"
+	if anns.IngGray.IngGrayRollback {
+		direction = "rollback"
+	}
+
 	if !anns.IngGray.IngGrayFlag {
 		gray.Type = ingress.Active
 	} else if ingGrayIndex == ing_gray.PodIndexDone {
 		gray.Type = ingress.Active
-	} else if ingGrayIndex > 0 && podOrdinal >= 0 && podOrdinal < ingGrayIndex {
+	} else if anns.IngGray.IngGrayPodLabel != "" {
+		if s.podMatchesLabel(anns.IngGray.IngGrayPodLabel) != anns.IngGray.IngGrayRollback {
+			gray.Type = ingress.ActiveGray
+		} else {
+			gray.Type = ingress.InactiveGray
+		}
+	} else if ingGrayIndex > 0 && podOrdinal >= 0 && (podOrdinal < ingGrayIndex) != anns.IngGray.IngGrayRollback {
 		gray.Type = ingress.ActiveGray
 	} else {
 		gray.Type = ingress.InactiveGray
 	}
 
-	klog.Infof("Get ingress %v status {Gray[type:%v],IngressGrayFlag[%v],IngressGrayCurVer[%v],IngressGrayNewVer[%v],IngressGrayIndex[%v],PodOrdinal[%v]}",
-		key, gray.Type, anns.IngGray.IngGrayFlag, anns.IngGray.IngGrayCurVer, anns.IngGray.IngGrayNewVer, anns.IngGray.IngGrayIndex, podOrdinal)
+	klog.Infof("Get ingress %v status {Gray[type:%v],IngressGrayFlag[%v],IngressGrayCurVer[%v],IngressGrayNewVer[%v],IngressGrayIndex[%v],IngressGrayPodLabel[%v],IngressGrayDirection[%v],PodOrdinal[%v]}",
+		key, gray.Type, anns.IngGray.IngGrayFlag, anns.IngGray.IngGrayCurVer, anns.IngGray.IngGrayNewVer, anns.IngGray.IngGrayIndex, anns.IngGray.IngGrayPodLabel, direction, podOrdinal)
 
 	return gray, nil
 }
 
+// podMatchesLabel returns true if the labels of the pod match the label selector
+func (s *k8sStore) podMatchesLabel(selector string) bool {
+	if s.pod == nil {
+		return false
+	}
+
+	sel, err := labels.Parse(selector)
+	if err != nil {
+		klog.Warningf("Invalid pod label selector %v: %v", selector, err)
+		return false
+	}
+
+	return sel.Matches(labels.Set(s.pod.Labels))
+}
+
 // GetSecretGrayStatus returns gray status of a secret
 func (s *k8sStore) GetSecretGrayStatus(key string) (ingress.SecretGray, error) {
 	gray := ingress.SecretGray{
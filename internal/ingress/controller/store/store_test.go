@@ -19,9 +19,17 @@ package store
 import (
 	"bytes"
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
 	"encoding/base64"
+	"encoding/pem"
 	"fmt"
+	"math/big"
 	"os"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"testing"
@@ -33,14 +41,19 @@ import (
 	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/cache"
 	"sigs.k8s.io/controller-runtime/pkg/envtest"
 
 	"k8s.io/ingress-nginx/internal/ingress"
+	"k8s.io/ingress-nginx/internal/ingress/annotations"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/class"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/gray"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
 	"k8s.io/ingress-nginx/internal/ingress/controller/ingressclass"
+	"k8s.io/ingress-nginx/internal/ingress/metric"
+	"k8s.io/ingress-nginx/internal/ingress/secannotations"
 	"k8s.io/ingress-nginx/internal/k8s"
 	"k8s.io/ingress-nginx/test/e2e/framework"
 )
@@ -1008,6 +1021,64 @@ func TestWriteSSLSessionTicketKey(t *testing.T) {
 	}
 }
 
+func TestWriteUnchangedSSLSessionTicketKey(t *testing.T) {
+	ticket := "9DyULjtYWz520d1rnTLbc4BOmN2nLAVfd3MES/P3IxWuwXkz9Fby0lnOZZUdNEMV"
+
+	s := newStore(t)
+
+	f, err := os.CreateTemp("", "ssl-session-ticket-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	defer os.Remove(f.Name())
+
+	cmap := &v1.ConfigMap{
+		Data: map[string]string{
+			"ssl-session-ticket-key": ticket,
+		},
+	}
+	s.writeSSLSessionTicketKey(cmap, f.Name())
+
+	// move the modification time back to detect a rewrite of the file
+	past := time.Now().Add(-time.Hour).Truncate(time.Second)
+	if err := os.Chtimes(f.Name(), past, past); err != nil {
+		t.Fatal(err)
+	}
+
+	cmap = &v1.ConfigMap{
+		Data: map[string]string{
+			"ssl-session-ticket-key": ticket,
+			"proxy-read-timeout":     "120",
+		},
+	}
+	s.writeSSLSessionTicketKey(cmap, f.Name())
+
+	fi, err := os.Stat(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !fi.ModTime().Equal(past) {
+		t.Errorf("expected the unchanged ssl-session-ticket-key not to be rewritten")
+	}
+	if s.backendConfig.SSLSessionTicketKey != ticket {
+		t.Errorf("expected ssl-session-ticket-key %v but returned %v", ticket, s.backendConfig.SSLSessionTicketKey)
+	}
+
+	// a new key must be written
+	newTicket := "9SvN1C9AB5DvNde5fMKoJwAwICpqdjiMyxR+cv6NpAWv22rFd3gKt4wMyGxCm7l9Wh6BQPG0+csyBZSHHr2NOWj52Wx8xCegXf4NsSMBUqA="
+	cmap.Data["ssl-session-ticket-key"] = newTicket
+	s.writeSSLSessionTicketKey(cmap, f.Name())
+
+	content, err := os.ReadFile(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if encoded := base64.StdEncoding.EncodeToString(content); encoded != newTicket {
+		t.Errorf("expected %v but returned %s", newTicket, encoded)
+	}
+}
+
 func TestGetRunningControllerPodsCount(t *testing.T) {
 	os.Setenv("POD_NAMESPACE", "testns")
 	os.Setenv("POD_NAME", "ingress-1")
@@ -1116,3 +1187,575 @@ func TestIngressConversion(t *testing.T) {
 		t.Fatalf("Expected marshalling of types should be equal")
 	}
 }
+
+func TestGetIngressGrayStatus(t *testing.T) {
+	testCases := []struct {
+		name     string
+		pod      *k8s.PodInfo
+		gray     gray.Config
+		expected ingress.GrayType
+	}{
+		{
+			name: "label present",
+			pod: &k8s.PodInfo{ObjectMeta: metav1.ObjectMeta{
+				Name:   "ingress-7d9c5b6f4-x2x5q",
+				Labels: map[string]string{"version": "canary"},
+			}},
+			gray:     gray.Config{IngGrayFlag: true, IngGrayPodLabel: "version=canary"},
+			expected: ingress.ActiveGray,
+		},
+		{
+			name: "label absent",
+			pod: &k8s.PodInfo{ObjectMeta: metav1.ObjectMeta{
+				Name:   "ingress-7d9c5b6f4-x2x5q",
+				Labels: map[string]string{"app": "ingress"},
+			}},
+			gray:     gray.Config{IngGrayFlag: true, IngGrayPodLabel: "version=canary"},
+			expected: ingress.InactiveGray,
+		},
+		{
+			name: "label present and rollback",
+			pod: &k8s.PodInfo{ObjectMeta: metav1.ObjectMeta{
+				Name:   "ingress-7d9c5b6f4-x2x5q",
+				Labels: map[string]string{"version": "canary"},
+			}},
+			gray:     gray.Config{IngGrayFlag: true, IngGrayPodLabel: "version=canary", IngGrayRollback: true},
+			expected: ingress.InactiveGray,
+		},
+		{
+			name:     "ordinal fallback less than index",
+			pod:      &k8s.PodInfo{ObjectMeta: metav1.ObjectMeta{Name: "ingress-1"}},
+			gray:     gray.Config{IngGrayFlag: true, IngGrayIndex: 2},
+			expected: ingress.ActiveGray,
+		},
+		{
+			name:     "ordinal fallback greater than index",
+			pod:      &k8s.PodInfo{ObjectMeta: metav1.ObjectMeta{Name: "ingress-3"}},
+			gray:     gray.Config{IngGrayFlag: true, IngGrayIndex: 2},
+			expected: ingress.InactiveGray,
+		},
+		{
+			name: "gray done",
+			pod: &k8s.PodInfo{ObjectMeta: metav1.ObjectMeta{
+				Name:   "ingress-3",
+				Labels: map[string]string{"version": "canary"},
+			}},
+			gray:     gray.Config{IngGrayFlag: true, IngGrayIndex: gray.PodIndexDone, IngGrayPodLabel: "version=canary"},
+			expected: ingress.Active,
+		},
+	}
+
+	for _, tc := range testCases {
+		s := &k8sStore{pod: tc.pod}
+		status, err := s.GetIngressGrayStatus("default/foo", &annotations.Ingress{IngGray: tc.gray})
+		if err != nil {
+			t.Errorf("%v: unexpected error: %v", tc.name, err)
+		}
+		if status.Type != tc.expected {
+			t.Errorf("%v: expected gray type %v but returned %v", tc.name, tc.expected, status.Type)
+		}
+	}
+}
+
+func TestGetIngressClass(t *testing.T) {
+	s := &k8sStore{listers: &Lister{}}
+	s.listers.IngressClass.Store = cache.NewStore(cache.MetaNamespaceKeyFunc)
+	s.listers.IngressClass.Add(&networking.IngressClass{
+		ObjectMeta: metav1.ObjectMeta{Name: "tengine"},
+	})
+
+	tengine := "tengine"
+	other := "other"
+	testCases := []struct {
+		name              string
+		annotation        *string
+		className         *string
+		watchWithoutClass bool
+		expected          string
+		expectedErr       bool
+	}{
+		{name: "annotation only", annotation: &tengine, expected: "tengine"},
+		{name: "other annotation only", annotation: &other, expectedErr: true},
+		{name: "field only", className: &tengine, expected: "tengine"},
+		{name: "other field only", className: &other, expectedErr: true},
+		{name: "both agree", annotation: &tengine, className: &tengine, expected: "tengine"},
+		{name: "annotation over other field", annotation: &tengine, className: &other, expected: "tengine"},
+		{name: "other annotation over field", annotation: &other, className: &tengine, expectedErr: true},
+		{name: "without class", watchWithoutClass: true, expected: "_"},
+		{name: "without class not watched", expectedErr: true},
+	}
+
+	for _, tc := range testCases {
+		ing := &networking.Ingress{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "foo",
+				Namespace:   v1.NamespaceDefault,
+				Annotations: map[string]string{},
+			},
+			Spec: networking.IngressSpec{
+				IngressClassName: tc.className,
+			},
+		}
+		if tc.annotation != nil {
+			ing.Annotations[ingressclass.IngressKey] = *tc.annotation
+		}
+
+		icConfig := &ingressclass.IngressClassConfiguration{
+			Controller:        ingressclass.DefaultControllerName,
+			AnnotationValue:   "tengine",
+			WatchWithoutClass: tc.watchWithoutClass,
+		}
+		ic, err := s.GetIngressClass(ing, icConfig)
+		if tc.expectedErr {
+			if err == nil {
+				t.Errorf("%v: expected an error but returned class %q", tc.name, ic)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%v: unexpected error: %v", tc.name, err)
+		}
+		if ic != tc.expected {
+			t.Errorf("%v: expected class %q but returned %q", tc.name, tc.expected, ic)
+		}
+	}
+}
+
+func TestSetConfigReferrers(t *testing.T) {
+	s := &k8sStore{
+		backendConfigMu: &sync.RWMutex{},
+		mc:              metric.DummyCollector{},
+	}
+
+	s.setConfig(&v1.ConfigMap{
+		Data: map[string]string{
+			"ingress-referrer": "tengine,team-a-*",
+			"canary-referrer":  "canary",
+		},
+	})
+
+	anns := &annotations.Ingress{}
+	for referrer, expected := range map[string]bool{"tengine": true, "team-a-web": true, "canary": false} {
+		anns.Referrer.IngReferrer = referrer
+		if v := s.verifyIngressReferrer("default/foo", anns); v != expected {
+			t.Errorf("expected %v verifying the ingress referrer %q but returned %v", expected, referrer, v)
+		}
+	}
+
+	if !s.GetCanaryReferrerMatcher().Match("canary") {
+		t.Errorf("expected the canary referrer to be allowed")
+	}
+}
+
+// BenchmarkVerifyIngressReferrer compares splitting the ingress-referrer key
+// in every verification with the referrers parsed once by setConfig
+func BenchmarkVerifyIngressReferrer(b *testing.B) {
+	var referrers []string
+	for i := 0; i < 100; i++ {
+		referrers = append(referrers, fmt.Sprintf("team-%v", i))
+	}
+
+	s := &k8sStore{
+		backendConfigMu: &sync.RWMutex{},
+		mc:              metric.DummyCollector{},
+	}
+	s.setConfig(&v1.ConfigMap{
+		Data: map[string]string{
+			"ingress-referrer": strings.Join(referrers, ","),
+		},
+	})
+
+	ingresses := make([]*annotations.Ingress, 1000)
+	for i := range ingresses {
+		ingresses[i] = &annotations.Ingress{}
+		ingresses[i].Referrer.IngReferrer = referrers[i%len(referrers)]
+	}
+
+	b.Run("split", func(b *testing.B) {
+		b.ReportAllocs()
+		for n := 0; n < b.N; n++ {
+			for _, anns := range ingresses {
+				found := false
+				for _, referrer := range strings.Split(s.GetBackendConfiguration().IngressReferrer, ",") {
+					if referrer == anns.Referrer.IngReferrer {
+						found = true
+						break
+					}
+				}
+				if !found {
+					b.Fatalf("expected referrer %v to be allowed", anns.Referrer.IngReferrer)
+				}
+			}
+		}
+	})
+
+	b.Run("cached", func(b *testing.B) {
+		b.ReportAllocs()
+		for n := 0; n < b.N; n++ {
+			for _, anns := range ingresses {
+				if !s.verifyIngressReferrer("default/foo", anns) {
+					b.Fatalf("expected referrer %v to be allowed", anns.Referrer.IngReferrer)
+				}
+			}
+		}
+	})
+}
+
+func fakeTLSKeyPair(t *testing.T) ([]byte, []byte) {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("unexpected error generating a private key: %v", err)
+	}
+
+	template := x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "rotating.example.com"},
+		DNSNames:     []string{"rotating.example.com"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &priv.PublicKey, priv)
+	if err != nil {
+		t.Fatalf("unexpected error creating a certificate: %v", err)
+	}
+
+	key, err := x509.MarshalECPrivateKey(priv)
+	if err != nil {
+		t.Fatalf("unexpected error encoding a private key: %v", err)
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: key})
+}
+
+type certLoadCollector struct {
+	metric.DummyCollector
+	failures int32
+}
+
+func (c *certLoadCollector) IncSSLCertLoadFailCount() {
+	atomic.AddInt32(&c.failures, 1)
+}
+
+func newCertRetryStore(mc metric.Collector) *k8sStore {
+	s := &k8sStore{
+		listers: &Lister{
+			Secret:               SecretLister{cache.NewStore(cache.MetaNamespaceKeyFunc)},
+			SecretWithAnnotation: SecretWithAnnotationsLister{cache.NewStore(cache.DeletionHandlingMetaNamespaceKeyFunc)},
+		},
+		updateCh:      channels.NewRingChannel(10),
+		certRetries:   make(map[string]*certRetry),
+		certRetriesMu: &sync.Mutex{},
+		mc:            mc,
+	}
+	s.secAnnotations = secannotations.NewAnnotationExtractor(s)
+
+	return s
+}
+
+func TestSecretCertificateRetry(t *testing.T) {
+	defer func(retries int, delay time.Duration) {
+		certLoadRetries = retries
+		certLoadRetryDelay = delay
+	}(certLoadRetries, certLoadRetryDelay)
+	certLoadRetries = 3
+	certLoadRetryDelay = 10 * time.Millisecond
+
+	cert, key := fakeTLSKeyPair(t)
+	_, otherKey := fakeTLSKeyPair(t)
+
+	newSecret := func(data map[string][]byte) *v1.Secret {
+		return &v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: v1.NamespaceDefault, Name: "rotating"},
+			Data:       data,
+		}
+	}
+	pendingRetries := func(s *k8sStore) int {
+		s.certRetriesMu.Lock()
+		defer s.certRetriesMu.Unlock()
+		return len(s.certRetries)
+	}
+
+	t.Run("retry then succeed", func(t *testing.T) {
+		mc := &certLoadCollector{}
+		s := newCertRetryStore(mc)
+
+		// the certificate of the rotation is written before the key
+		secret := newSecret(map[string][]byte{v1.TLSCertKey: cert, v1.TLSPrivateKeyKey: otherKey})
+		s.listers.Secret.Add(secret)
+		s.updateSecretWithAnnotation(secret)
+
+		if _, err := s.GetSecretWithAnnotation("default/rotating"); err == nil {
+			t.Fatalf("expected no secret with annotations for a mismatched key")
+		}
+		if pendingRetries(s) != 1 {
+			t.Fatalf("expected a pending retry of the certificate")
+		}
+
+		s.listers.Secret.Update(newSecret(map[string][]byte{v1.TLSCertKey: cert, v1.TLSPrivateKeyKey: key}))
+
+		err := wait.PollImmediate(5*time.Millisecond, time.Second, func() (bool, error) {
+			_, err := s.GetSecretWithAnnotation("default/rotating")
+			return err == nil, nil
+		})
+		if err != nil {
+			t.Fatalf("expected the certificate loaded by a retry")
+		}
+
+		if f := atomic.LoadInt32(&mc.failures); f != 0 {
+			t.Errorf("expected no certificate load failures but got %v", f)
+		}
+		if s.updateCh.Len() != 1 {
+			t.Errorf("expected an update event after the retry but got %v", s.updateCh.Len())
+		}
+	})
+
+	t.Run("retry then fail", func(t *testing.T) {
+		mc := &certLoadCollector{}
+		s := newCertRetryStore(mc)
+
+		secret := newSecret(map[string][]byte{v1.TLSCertKey: cert, v1.TLSPrivateKeyKey: otherKey})
+		s.listers.Secret.Add(secret)
+		s.updateSecretWithAnnotation(secret)
+
+		err := wait.PollImmediate(5*time.Millisecond, time.Second, func() (bool, error) {
+			return atomic.LoadInt32(&mc.failures) > 0, nil
+		})
+		if err != nil {
+			t.Fatalf("expected a certificate load failure after the retries")
+		}
+
+		// 10ms, 20ms and 40ms retries, no more after giving up
+		time.Sleep(100 * time.Millisecond)
+		if f := atomic.LoadInt32(&mc.failures); f != 1 {
+			t.Errorf("expected 1 certificate load failure but got %v", f)
+		}
+		if pendingRetries(s) != 0 {
+			t.Errorf("expected no pending retries after giving up")
+		}
+		if _, err := s.GetSecretWithAnnotation("default/rotating"); err == nil {
+			t.Errorf("expected no secret with annotations for a mismatched key")
+		}
+		if s.updateCh.Len() != 0 {
+			t.Errorf("expected no update events but got %v", s.updateCh.Len())
+		}
+	})
+
+	t.Run("new version cancels the retry", func(t *testing.T) {
+		mc := &certLoadCollector{}
+		s := newCertRetryStore(mc)
+
+		secret := newSecret(map[string][]byte{v1.TLSCertKey: cert, v1.TLSPrivateKeyKey: otherKey})
+		s.listers.Secret.Add(secret)
+		s.updateSecretWithAnnotation(secret)
+
+		secret = newSecret(map[string][]byte{v1.TLSCertKey: cert, v1.TLSPrivateKeyKey: key})
+		s.listers.Secret.Update(secret)
+		s.updateSecretWithAnnotation(secret)
+
+		if pendingRetries(s) != 0 {
+			t.Errorf("expected no pending retries after loading the new version")
+		}
+		if _, err := s.GetSecretWithAnnotation("default/rotating"); err != nil {
+			t.Errorf("unexpected error obtaining the secret with annotations: %v", err)
+		}
+	})
+
+	t.Run("secret without certificate", func(t *testing.T) {
+		mc := &certLoadCollector{}
+		s := newCertRetryStore(mc)
+
+		secret := newSecret(map[string][]byte{"auth": []byte("foo:bar")})
+		s.listers.Secret.Add(secret)
+		s.updateSecretWithAnnotation(secret)
+
+		if pendingRetries(s) != 0 {
+			t.Errorf("expected no retries for a secret without certificate")
+		}
+		if f := atomic.LoadInt32(&mc.failures); f != 0 {
+			t.Errorf("expected no certificate load failures but got %v", f)
+		}
+	})
+}
+
+func TestQueueConfigMapSync(t *testing.T) {
+	newSyncStore := func(delay time.Duration) *k8sStore {
+		return &k8sStore{
+			listers: &Lister{
+				IngressWithAnnotation: IngressWithAnnotationsLister{cache.NewStore(cache.DeletionHandlingMetaNamespaceKeyFunc)},
+			},
+			updateCh:        channels.NewRingChannel(10),
+			cfgMapSyncDelay: delay,
+			cfgMapSyncMu:    &sync.Mutex{},
+		}
+	}
+
+	cfgMaps := []*v1.ConfigMap{
+		{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "config"}},
+		{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "config-team-a"}},
+		{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "unrelated"}},
+	}
+
+	t.Run("burst of updates is coalesced", func(t *testing.T) {
+		s := newSyncStore(50 * time.Millisecond)
+
+		for i := 0; i < 3; i++ {
+			s.queueConfigMapSync(cfgMaps[0], true)
+			s.queueConfigMapSync(cfgMaps[1], true)
+			s.queueConfigMapSync(cfgMaps[2], false)
+		}
+
+		if s.updateCh.Len() != 0 {
+			t.Fatalf("expected no event before the end of the delay but got %v", s.updateCh.Len())
+		}
+
+		time.Sleep(200 * time.Millisecond)
+
+		if s.updateCh.Len() != 1 {
+			t.Fatalf("expected a single event but got %v", s.updateCh.Len())
+		}
+		evt := (<-s.updateCh.Out()).(Event)
+		if evt.Type != ConfigurationEvent {
+			t.Errorf("expected event of type %v but got %v", ConfigurationEvent, evt.Type)
+		}
+		if evt.Obj != cfgMaps[1] {
+			t.Errorf("expected the event of the last configmap changing the configuration but got %v", evt.Obj)
+		}
+
+		s.queueConfigMapSync(cfgMaps[0], true)
+		time.Sleep(200 * time.Millisecond)
+
+		if s.updateCh.Len() != 1 {
+			t.Errorf("expected a new event after a new update but got %v", s.updateCh.Len())
+		}
+	})
+
+	t.Run("updates not changing the configuration", func(t *testing.T) {
+		s := newSyncStore(50 * time.Millisecond)
+
+		s.queueConfigMapSync(cfgMaps[2], false)
+		s.queueConfigMapSync(cfgMaps[2], false)
+		time.Sleep(200 * time.Millisecond)
+
+		if s.updateCh.Len() != 0 {
+			t.Errorf("expected no event but got %v", s.updateCh.Len())
+		}
+	})
+
+	t.Run("no delay", func(t *testing.T) {
+		s := newSyncStore(0)
+
+		s.queueConfigMapSync(cfgMaps[0], true)
+		s.queueConfigMapSync(cfgMaps[1], true)
+
+		// the ring channel moves the events to its output asynchronously
+		time.Sleep(50 * time.Millisecond)
+
+		if s.updateCh.Len() != 2 {
+			t.Errorf("expected an event for every update but got %v", s.updateCh.Len())
+		}
+	})
+}
+
+type certEvictedCollector struct {
+	metric.DummyCollector
+	evicted int
+}
+
+func (c *certEvictedCollector) IncSSLCertEvictedCount() {
+	c.evicted++
+}
+
+func TestSweepSSLCerts(t *testing.T) {
+	dir := t.TempDir()
+
+	mc := &certEvictedCollector{}
+	s := &k8sStore{
+		listers: &Lister{
+			SecretWithAnnotation: SecretWithAnnotationsLister{cache.NewStore(cache.DeletionHandlingMetaNamespaceKeyFunc)},
+		},
+		sslStore:              NewSSLCertTracker(),
+		secretIngressMap:      NewObjectRefMap(),
+		syncSecretMu:          &sync.Mutex{},
+		sslCertTTL:            time.Hour,
+		sslCertUnusedSince:    make(map[string]time.Time),
+		defaultSSLCertificate: "default/default-cert",
+		mc:                    mc,
+	}
+
+	addCert := func(key string) *ingress.SSLCert {
+		name := strings.Replace(key, "/", "-", -1)
+		cert := &ingress.SSLCert{
+			PemFileName: fmt.Sprintf("%v/%v.pem", dir, name),
+			CAFileName:  fmt.Sprintf("%v/%v-ca.pem", dir, name),
+		}
+		for _, f := range []string{cert.PemFileName, cert.CAFileName} {
+			if err := os.WriteFile(f, []byte("cert"), 0644); err != nil {
+				t.Fatalf("unexpected error writing %v: %v", f, err)
+			}
+		}
+		s.sslStore.Add(key, cert)
+		return cert
+	}
+	localCerts := func() map[string]bool {
+		certs := make(map[string]bool)
+		for _, cert := range s.ListLocalSSLCerts() {
+			certs[cert.PemFileName] = true
+		}
+		return certs
+	}
+
+	defaultCert := addCert("default/default-cert")
+	ingCert := addCert("default/ing-cert")
+	annCert := addCert("default/ann-cert")
+	staleCert := addCert("default/stale-cert")
+
+	s.secretIngressMap.Insert("default/ing", "default/ing-cert")
+	s.listers.SecretWithAnnotation.Add(&ingress.Secret{
+		Secret: v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: v1.NamespaceDefault, Name: "ann-cert"}},
+	})
+
+	s.sweepSSLCerts()
+	if len(localCerts()) != 4 {
+		t.Fatalf("expected no certificate evicted before the TTL but got %v", localCerts())
+	}
+	if _, ok := s.sslCertUnusedSince["default/stale-cert"]; !ok || len(s.sslCertUnusedSince) != 1 {
+		t.Fatalf("expected only default/stale-cert tracked as unused but got %v", s.sslCertUnusedSince)
+	}
+
+	s.sslCertUnusedSince["default/stale-cert"] = time.Now().Add(-2 * time.Hour)
+	s.sweepSSLCerts()
+
+	certs := localCerts()
+	for _, cert := range []*ingress.SSLCert{defaultCert, ingCert, annCert} {
+		if !certs[cert.PemFileName] {
+			t.Errorf("expected %v in the local store", cert.PemFileName)
+		}
+		if _, err := os.Stat(cert.PemFileName); err != nil {
+			t.Errorf("expected %v to exist: %v", cert.PemFileName, err)
+		}
+	}
+	if certs[staleCert.PemFileName] {
+		t.Errorf("expected %v evicted from the local store", staleCert.PemFileName)
+	}
+	for _, f := range []string{staleCert.PemFileName, staleCert.CAFileName} {
+		if _, err := os.Stat(f); !os.IsNotExist(err) {
+			t.Errorf("expected %v removed but got %v", f, err)
+		}
+	}
+	if mc.evicted != 1 {
+		t.Errorf("expected 1 evicted certificate but got %v", mc.evicted)
+	}
+	if len(s.sslCertUnusedSince) != 0 {
+		t.Errorf("expected no certificate tracked as unused but got %v", s.sslCertUnusedSince)
+	}
+
+	// a certificate referenced again is no longer tracked
+	s.secretIngressMap.Delete("default/ing")
+	s.sweepSSLCerts()
+	s.secretIngressMap.Insert("default/ing", "default/ing-cert")
+	s.sweepSSLCerts()
+	if len(s.sslCertUnusedSince) != 0 {
+		t.Errorf("expected no certificate tracked as unused but got %v", s.sslCertUnusedSince)
+	}
+}
@@ -20,6 +20,9 @@ package template
 import (
 	"fmt"
 	"net"
+	"regexp"
+	goruntime "runtime"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -30,8 +33,10 @@ import (
 	"github.com/mitchellh/mapstructure"
 
 	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/validation"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/authreq"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/sendtimeout"
 	"k8s.io/ingress-nginx/internal/ingress/controller/config"
 	ing_net "k8s.io/ingress-nginx/internal/net"
 	"k8s.io/ingress-nginx/internal/runtime"
@@ -47,12 +52,15 @@ const (
 	blockCIDRs                = "block-cidrs"
 	blockUserAgents           = "block-user-agents"
 	blockReferers             = "block-referers"
+	blockCountries            = "block-countries"
 	proxyStreamResponses      = "proxy-stream-responses"
 	hideHeaders               = "hide-headers"
+	reservedLocations         = "reserved-locations"
 	nginxStatusIpv4Whitelist  = "nginx-status-ipv4-whitelist"
 	nginxStatusIpv6Whitelist  = "nginx-status-ipv6-whitelist"
 	proxyHeaderTimeout        = "proxy-protocol-header-timeout"
 	workerProcesses           = "worker-processes"
+	workerCPUAffinity         = "worker-cpu-affinity"
 	globalAuthURL             = "global-auth-url"
 	globalAuthMethod          = "global-auth-method"
 	globalAuthSignin          = "global-auth-signin"
@@ -63,10 +71,41 @@ const (
 	globalAuthCacheDuration   = "global-auth-cache-duration"
 	luaSharedDictsKey         = "lua-shared-dicts"
 	customPortDomainKey       = "custom-port-domain"
+	sendTimeout               = "send-timeout"
+	sslCertExpiryWarnHours    = "ssl-cert-expiry-warn-hours"
+	additionalReservedPorts   = "additional-reserved-ports"
+	requestIDHeader           = "request-id-header"
+	useGzip                   = "use-gzip"
+	gzipLevel                 = "gzip-level"
+	sslProtocols              = "ssl-protocols"
+	proxyConnectTimeout       = "proxy-connect-timeout"
+	proxySendTimeout          = "proxy-send-timeout"
+	proxyReadTimeout          = "proxy-read-timeout"
+)
+
+// ReservedPorts contains the ports used by the controller, which cannot be
+// mapped to a domain in custom-port-domain. The HTTPS port is not reserved.
+var ReservedPorts = sets.NewInt()
+
+// AllowedConfigKeys contains the configmap keys that are not fields of
+// config.Configuration but are read by the controller, so they are not
+// reported as unknown
+var AllowedConfigKeys = sets.NewString(
+	bindAddress,
+	globalAuthURL,
+	globalAuthMethod,
+	globalAuthSignin,
+	globalAuthResponseHeaders,
+	globalAuthRequestRedirect,
+	globalAuthSnippet,
+	globalAuthCacheKey,
+	globalAuthCacheDuration,
 )
 
 var (
 	validRedirectCodes    = sets.NewInt([]int{301, 302, 307, 308}...)
+	countryCodeRegex      = regexp.MustCompile(`^[A-Z]{2}$`)
+	cpuAffinityMaskRegex  = regexp.MustCompile(`^[01 ]*[01][01 ]*$`)
 	defaultLuaSharedDicts = map[string]int{
 		"configuration_data":            20,
 		"certificate_data":              20,
@@ -92,6 +131,7 @@ func ReadConfig(src map[string]string) config.Configuration {
 
 	to := config.NewDefault()
 	errors := make([]int, 0)
+	reservedPorts := make([]int, 0)
 	skipUrls := make([]string, 0)
 	whiteList := make([]string, 0)
 	proxyList := make([]string, 0)
@@ -103,6 +143,7 @@ func ReadConfig(src map[string]string) config.Configuration {
 	blockCIDRList := make([]string, 0)
 	blockUserAgentList := make([]string, 0)
 	blockRefererList := make([]string, 0)
+	blockCountryList := make([]string, 0)
 	responseHeaders := make([]string, 0)
 	luaSharedDicts := make(map[string]int)
 	customPortDomain := make(map[string]string)
@@ -146,9 +187,32 @@ func ReadConfig(src map[string]string) config.Configuration {
 		cpd := strings.Split(val, ",")
 		for _, v := range cpd {
 			v = strings.Replace(v, " ", "", -1)
+			if v == "" {
+				continue
+			}
+
 			results := strings.SplitN(v, ":", 2)
+			if len(results) != 2 {
+				klog.Warningf("Ignoring %v for custom-port-domain: expected the format port:domain.", v)
+				continue
+			}
+
 			serverPort := results[0]
 			rootDomain := results[1]
+			port, err := strconv.Atoi(serverPort)
+			if err != nil || port < 1 || port > 65535 {
+				klog.Warningf("Ignoring %v for custom-port-domain: %v is not a valid port.", v, serverPort)
+				continue
+			}
+			if ReservedPorts.Has(port) {
+				klog.Warningf("Ignoring %v for custom-port-domain: port %v is reserved by the controller.", v, port)
+				continue
+			}
+			if errs := validation.IsDNS1123Subdomain(strings.TrimPrefix(strings.ToLower(rootDomain), "*.")); len(errs) > 0 {
+				klog.Warningf("Ignoring %v for custom-port-domain: %v is not a valid domain.", v, rootDomain)
+				continue
+			}
+
 			customPortDomain[serverPort] = rootDomain
 		}
 	}
@@ -164,10 +228,32 @@ func ReadConfig(src map[string]string) config.Configuration {
 			}
 		}
 	}
+	if val, ok := conf[additionalReservedPorts]; ok {
+		delete(conf, additionalReservedPorts)
+		for _, i := range strings.Split(val, ",") {
+			j, err := strconv.Atoi(strings.TrimSpace(i))
+			if err != nil || j < 1 || j > 65535 {
+				klog.Warningf("Ignoring %q for additional-reserved-ports: not a valid port number", i)
+			} else {
+				reservedPorts = append(reservedPorts, j)
+			}
+		}
+	}
 	if val, ok := conf[hideHeaders]; ok {
 		delete(conf, hideHeaders)
 		hideHeadersList = strings.Split(val, ",")
 	}
+	if val, ok := conf[reservedLocations]; ok {
+		delete(conf, reservedLocations)
+		reservedLocationList := make([]string, 0)
+		for _, location := range strings.Split(val, ",") {
+			location = strings.TrimSpace(location)
+			if location != "" {
+				reservedLocationList = append(reservedLocationList, location)
+			}
+		}
+		to.ReservedLocations = reservedLocationList
+	}
 	if val, ok := conf[skipAccessLogUrls]; ok {
 		delete(conf, skipAccessLogUrls)
 		skipUrls = strings.Split(val, ",")
@@ -210,6 +296,22 @@ func ReadConfig(src map[string]string) config.Configuration {
 		delete(conf, blockReferers)
 		blockRefererList = strings.Split(val, ",")
 	}
+	if val, ok := conf[blockCountries]; ok {
+		delete(conf, blockCountries)
+		for _, country := range strings.Split(val, ",") {
+			country = strings.TrimSpace(country)
+			if country == "" {
+				continue
+			}
+
+			if !countryCodeRegex.MatchString(country) {
+				klog.Warningf("Ignoring country %q of %v: expected a two-letter uppercase ISO 3166-1 code", country, blockCountries)
+				continue
+			}
+
+			blockCountryList = append(blockCountryList, country)
+		}
+	}
 
 	if val, ok := conf[httpRedirectCode]; ok {
 		delete(conf, httpRedirectCode)
@@ -281,6 +383,16 @@ func ReadConfig(src map[string]string) config.Configuration {
 		to.GlobalExternalAuth.ResponseHeaders = responseHeaders
 	}
 
+	if val, ok := conf[requestIDHeader]; ok {
+		delete(conf, requestIDHeader)
+		header := strings.TrimSpace(val)
+		if !authreq.ValidHeader(header) {
+			klog.Warningf("%v is not a valid request-id-header. Switching to use default value instead.", val)
+		} else {
+			to.RequestIDHeader = header
+		}
+	}
+
 	if val, ok := conf[globalAuthRequestRedirect]; ok {
 		delete(conf, globalAuthRequestRedirect)
 
@@ -321,6 +433,27 @@ func ReadConfig(src map[string]string) config.Configuration {
 		}
 	}
 
+	// Verify that the configured send timeout uses the NGINX time syntax. if not, set the default value
+	if val, ok := conf[sendTimeout]; ok {
+		delete(conf, sendTimeout)
+		if sendtimeout.ValidTime(val) {
+			to.SendTimeout = val
+		} else {
+			klog.Warningf("%v is not a valid send-timeout. Switching to use default value instead.", val)
+		}
+	}
+
+	// Verify that the configured expiry warning threshold is positive. if not, set the default value
+	if val, ok := conf[sslCertExpiryWarnHours]; ok {
+		delete(conf, sslCertExpiryWarnHours)
+		hours, err := strconv.Atoi(val)
+		if err != nil || hours <= 0 {
+			klog.Warningf("%v is not a valid ssl-cert-expiry-warn-hours. Switching to use default value instead.", val)
+		} else {
+			to.SSLCertExpiryWarnHours = hours
+		}
+	}
+
 	streamResponses := 1
 	if val, ok := conf[proxyStreamResponses]; ok {
 		delete(conf, proxyStreamResponses)
@@ -358,6 +491,24 @@ func ReadConfig(src map[string]string) config.Configuration {
 		delete(conf, workerProcesses)
 	}
 
+	if val, ok := conf[workerCPUAffinity]; ok {
+		val = strings.TrimSpace(val)
+		switch {
+		case val == "auto":
+			workers, err := strconv.Atoi(to.WorkerProcesses)
+			if err != nil || workers < 1 {
+				workers = goruntime.NumCPU()
+			}
+			to.WorkerCPUAffinity = buildCPUAffinityMask(workers, goruntime.NumCPU())
+		case val == "" || cpuAffinityMaskRegex.MatchString(val):
+			to.WorkerCPUAffinity = val
+		default:
+			klog.Warningf("%v is not a valid CPU affinity mask, it can only contain 0, 1 and spaces, or be auto. Ignoring it", val)
+		}
+
+		delete(conf, workerCPUAffinity)
+	}
+
 	to.CustomHTTPErrors = filterErrors(errors)
 	to.SkipAccessLogURLs = skipUrls
 	to.WhitelistSourceRange = whiteList
@@ -367,11 +518,13 @@ func ReadConfig(src map[string]string) config.Configuration {
 	to.BlockCIDRs = blockCIDRList
 	to.BlockUserAgents = blockUserAgentList
 	to.BlockReferers = blockRefererList
+	to.BlockCountries = blockCountryList
 	to.HideHeaders = hideHeadersList
 	to.ProxyStreamResponses = streamResponses
 	to.DisableIpv6DNS = !ing_net.IsIPv6Enabled()
 	to.LuaSharedDicts = luaSharedDicts
 	to.CustomPortDomain = customPortDomain
+	to.AdditionalReservedPorts = reservedPorts
 
 	config := &mapstructure.DecoderConfig{
 		Metadata:         nil,
@@ -389,6 +542,23 @@ func ReadConfig(src map[string]string) config.Configuration {
 		klog.Warningf("unexpected error merging defaults: %v", err)
 	}
 
+	if len(to.BlockCountries) > 0 && !to.UseGeoIP2 {
+		klog.Warningf("Ignoring %v: it requires use-geoip2", blockCountries)
+		to.BlockCountries = make([]string, 0)
+	}
+
+	// the method of the global authentication subrequest depends on reject-unsupported-auth-methods
+	if to.GlobalExternalAuth.Method != "" {
+		method, err := authreq.SubrequestMethod(to.GlobalExternalAuth.Method, to.RejectUnsupportedAuthMethods)
+		if err != nil {
+			klog.Warningf("Global auth location denied - %v.", err)
+		} else if method != to.GlobalExternalAuth.Method {
+			klog.Warningf("HTTP method %v cannot be used by the global authentication subrequest, using %v",
+				to.GlobalExternalAuth.Method, method)
+		}
+		to.GlobalExternalAuth.Method = method
+	}
+
 	hash, err := hashstructure.Hash(to, &hashstructure.HashOptions{
 		TagName: "json",
 	})
@@ -401,6 +571,116 @@ func ReadConfig(src map[string]string) config.Configuration {
 	return to
 }
 
+// buildCPUAffinityMask returns a worker_cpu_affinity mask binding every
+// worker process to a CPU, round robin when there are more workers than CPUs
+func buildCPUAffinityMask(workers, cpus int) string {
+	if workers < 1 || cpus < 1 {
+		return ""
+	}
+
+	masks := make([]string, 0, workers)
+	for i := 0; i < workers; i++ {
+		mask := []byte(strings.Repeat("0", cpus))
+		// the CPU 0 is the rightmost bit of the mask
+		mask[cpus-1-i%cpus] = '1'
+		masks = append(masks, string(mask))
+	}
+
+	return strings.Join(masks, " ")
+}
+
+// NamespaceConfigKeys contains the configmap keys the configmap overlay of a
+// namespace can override for the ingresses of the namespace
+var NamespaceConfigKeys = sets.NewString(
+	useGzip,
+	gzipLevel,
+	sslProtocols,
+	proxyConnectTimeout,
+	proxySendTimeout,
+	proxyReadTimeout,
+)
+
+// namespaceSSLProtocols contains the protocols allowed in the ssl-protocols of a namespace
+var namespaceSSLProtocols = sets.NewString("TLSv1", "TLSv1.1", "TLSv1.2", "TLSv1.3")
+
+// ReadNamespaceConfig returns the configuration with the keys of the configmap
+// overlay of a namespace applied. Keys not in NamespaceConfigKeys and invalid
+// values are ignored.
+func ReadNamespaceConfig(cfg config.Configuration, src map[string]string) config.Configuration {
+	keys := make([]string, 0, len(src))
+	for k := range src {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		v := strings.TrimSpace(src[k])
+		if !NamespaceConfigKeys.Has(k) {
+			klog.Warningf("%v cannot be overridden in a namespace, ignoring", k)
+			continue
+		}
+
+		switch k {
+		case useGzip:
+			enabled, err := strconv.ParseBool(v)
+			if err != nil {
+				klog.Warningf("%v is not a valid value for %v, ignoring", v, k)
+				continue
+			}
+			cfg.UseGzip = enabled
+		case gzipLevel:
+			level, err := strconv.Atoi(v)
+			if err != nil || level < 1 || level > 9 {
+				klog.Warningf("%v is not a valid value for %v, expected 1-9, ignoring", v, k)
+				continue
+			}
+			cfg.GzipLevel = level
+		case sslProtocols:
+			protocols := strings.Fields(v)
+			if len(protocols) == 0 || !namespaceSSLProtocols.HasAll(protocols...) {
+				klog.Warningf("%v is not a valid value for %v, expected %v, ignoring", v, k, namespaceSSLProtocols.List())
+				continue
+			}
+			cfg.SSLProtocols = strings.Join(protocols, " ")
+		default:
+			timeout, err := strconv.Atoi(v)
+			if err != nil || timeout <= 0 {
+				klog.Warningf("%v is not a valid value for %v, expected a positive number of seconds, ignoring", v, k)
+				continue
+			}
+
+			switch k {
+			case proxyConnectTimeout:
+				cfg.ProxyConnectTimeout = timeout
+			case proxySendTimeout:
+				cfg.ProxySendTimeout = timeout
+			case proxyReadTimeout:
+				cfg.ProxyReadTimeout = timeout
+			}
+		}
+	}
+
+	return cfg
+}
+
+// UnknownConfigKeys returns the sorted configmap keys that are neither a key
+// of config.Configuration nor in AllowedConfigKeys
+func UnknownConfigKeys(src map[string]string) []string {
+	known := sets.NewString()
+	for _, k := range config.Schema() {
+		known.Insert(k.Name)
+	}
+
+	unknown := sets.NewString()
+	for k := range src {
+		if !known.Has(k) && !AllowedConfigKeys.Has(k) {
+			unknown.Insert(k)
+		}
+	}
+
+	return unknown.List()
+}
+
 func filterErrors(codes []int) []int {
 	var fa []int
 	for _, code := range codes {
@@ -19,11 +19,13 @@ package template
 import (
 	"fmt"
 	"reflect"
+	goruntime "runtime"
 	"testing"
 	"time"
 
 	"github.com/kylelemons/godebug/pretty"
 	"github.com/mitchellh/hashstructure"
+	"k8s.io/apimachinery/pkg/util/sets"
 
 	"k8s.io/ingress-nginx/internal/ingress/annotations/authreq"
 	"k8s.io/ingress-nginx/internal/ingress/controller/config"
@@ -197,6 +199,8 @@ func TestGlobalExternalAuthMethodParsing(t *testing.T) {
 	}{
 		"invalid method": {"FOO", ""},
 		"valid method":   {"POST", "POST"},
+		"CONNECT method": {"CONNECT", "GET"},
+		"TRACE method":   {"TRACE", "GET"},
 	}
 
 	for n, tc := range testCases {
@@ -205,6 +209,14 @@ func TestGlobalExternalAuthMethodParsing(t *testing.T) {
 			t.Errorf("Testing %v. Expected \"%v\" but \"%v\" was returned", n, tc.expect, cfg.GlobalExternalAuth.Method)
 		}
 	}
+
+	cfg := ReadConfig(map[string]string{
+		"global-auth-method":              "CONNECT",
+		"reject-unsupported-auth-methods": "true",
+	})
+	if cfg.GlobalExternalAuth.Method != "" {
+		t.Errorf("Expected no method when unsupported methods are rejected but \"%v\" was returned", cfg.GlobalExternalAuth.Method)
+	}
 }
 
 func TestGlobalExternalAuthSigninParsing(t *testing.T) {
@@ -360,3 +372,304 @@ func TestLuaSharedDictsParsing(t *testing.T) {
 		}
 	}
 }
+
+func TestReservedLocationsParsing(t *testing.T) {
+	testsCases := []struct {
+		name   string
+		entry  map[string]string
+		expect []string
+	}{
+		{
+			name:   "default reserved locations",
+			entry:  map[string]string{},
+			expect: []string{"/robots.txt", "/healthz", "/nginx_status", "/traffic_status"},
+		},
+		{
+			name:   "custom reserved locations",
+			entry:  map[string]string{"reserved-locations": "/robots.txt, /status,,"},
+			expect: []string{"/robots.txt", "/status"},
+		},
+		{
+			name:   "no reserved locations",
+			entry:  map[string]string{"reserved-locations": ""},
+			expect: []string{},
+		},
+	}
+
+	for _, tc := range testsCases {
+		cfg := ReadConfig(tc.entry)
+		if !reflect.DeepEqual(cfg.ReservedLocations, tc.expect) {
+			t.Errorf("Testing %v. Expected \"%v\" but \"%v\" was returned", tc.name, tc.expect, cfg.ReservedLocations)
+		}
+	}
+}
+
+func TestSendTimeoutParsing(t *testing.T) {
+	testsCases := []struct {
+		name   string
+		entry  map[string]string
+		expect string
+	}{
+		{"default send timeout", map[string]string{}, "60s"},
+		{"seconds", map[string]string{"send-timeout": "30s"}, "30s"},
+		{"no unit", map[string]string{"send-timeout": "90"}, "90"},
+		{"compound", map[string]string{"send-timeout": "1m30s"}, "1m30s"},
+		{"invalid", map[string]string{"send-timeout": "30 seconds"}, "60s"},
+		{"injection", map[string]string{"send-timeout": "30s; return 200"}, "60s"},
+	}
+
+	for _, tc := range testsCases {
+		cfg := ReadConfig(tc.entry)
+		if cfg.SendTimeout != tc.expect {
+			t.Errorf("Testing %v. Expected \"%v\" but \"%v\" was returned", tc.name, tc.expect, cfg.SendTimeout)
+		}
+	}
+}
+
+func TestSSLCertExpiryWarnHoursParsing(t *testing.T) {
+	testsCases := []struct {
+		name   string
+		entry  map[string]string
+		expect int
+	}{
+		{"default threshold", map[string]string{}, 240},
+		{"hours", map[string]string{"ssl-cert-expiry-warn-hours": "48"}, 48},
+		{"zero", map[string]string{"ssl-cert-expiry-warn-hours": "0"}, 240},
+		{"negative", map[string]string{"ssl-cert-expiry-warn-hours": "-24"}, 240},
+		{"invalid", map[string]string{"ssl-cert-expiry-warn-hours": "10d"}, 240},
+	}
+
+	for _, tc := range testsCases {
+		cfg := ReadConfig(tc.entry)
+		if cfg.SSLCertExpiryWarnHours != tc.expect {
+			t.Errorf("Testing %v. Expected \"%v\" but \"%v\" was returned", tc.name, tc.expect, cfg.SSLCertExpiryWarnHours)
+		}
+	}
+}
+
+func TestRequestIDHeaderParsing(t *testing.T) {
+	testsCases := []struct {
+		name   string
+		entry  map[string]string
+		expect string
+	}{
+		{"default header", map[string]string{}, "X-Request-ID"},
+		{"custom header", map[string]string{"request-id-header": "X-Corp-Trace-Id"}, "X-Corp-Trace-Id"},
+		{"invalid header", map[string]string{"request-id-header": "X Trace: 1"}, "X-Request-ID"},
+		{"empty header", map[string]string{"request-id-header": ""}, "X-Request-ID"},
+	}
+
+	for _, tc := range testsCases {
+		cfg := ReadConfig(tc.entry)
+		if cfg.RequestIDHeader != tc.expect {
+			t.Errorf("Testing %v. Expected \"%v\" but \"%v\" was returned", tc.name, tc.expect, cfg.RequestIDHeader)
+		}
+	}
+}
+
+func TestAdditionalReservedPortsParsing(t *testing.T) {
+	testsCases := []struct {
+		name   string
+		entry  map[string]string
+		expect []int
+	}{
+		{"no reserved ports", map[string]string{}, []int{}},
+		{"ports", map[string]string{"additional-reserved-ports": "8181, 15000,15001"}, []int{8181, 15000, 15001}},
+		{"non numeric port", map[string]string{"additional-reserved-ports": "8181,abc"}, []int{8181}},
+		{"port out of range", map[string]string{"additional-reserved-ports": "0,65536,8181"}, []int{8181}},
+	}
+
+	for _, tc := range testsCases {
+		cfg := ReadConfig(tc.entry)
+		if !reflect.DeepEqual(cfg.AdditionalReservedPorts, tc.expect) {
+			t.Errorf("Testing %v. Expected \"%v\" but \"%v\" was returned", tc.name, tc.expect, cfg.AdditionalReservedPorts)
+		}
+	}
+}
+
+func TestBlockCountriesParsing(t *testing.T) {
+	testsCases := []struct {
+		name   string
+		entry  map[string]string
+		expect []string
+	}{
+		{"no countries", map[string]string{"use-geoip2": "true"}, []string{}},
+		{"countries", map[string]string{"use-geoip2": "true", "block-countries": "CN, US,,RU"}, []string{"CN", "US", "RU"}},
+		{"invalid codes", map[string]string{"use-geoip2": "true", "block-countries": "cn,USA,U1,DE"}, []string{"DE"}},
+		{"geoip2 disabled", map[string]string{"block-countries": "CN,US"}, []string{}},
+	}
+
+	for _, tc := range testsCases {
+		cfg := ReadConfig(tc.entry)
+		if !reflect.DeepEqual(cfg.BlockCountries, tc.expect) {
+			t.Errorf("Testing %v. Expected \"%v\" but \"%v\" was returned", tc.name, tc.expect, cfg.BlockCountries)
+		}
+	}
+}
+
+func TestWorkerCPUAffinityParsing(t *testing.T) {
+	testsCases := []struct {
+		name   string
+		entry  map[string]string
+		expect string
+	}{
+		{"default", map[string]string{}, ""},
+		{"valid mask", map[string]string{"worker-cpu-affinity": "0101 1010"}, "0101 1010"},
+		{"invalid mask", map[string]string{"worker-cpu-affinity": "0x3 0x5"}, ""},
+		{"only spaces", map[string]string{"worker-cpu-affinity": "   "}, ""},
+		{"auto", map[string]string{"worker-cpu-affinity": "auto", "worker-processes": "2"}, buildCPUAffinityMask(2, goruntime.NumCPU())},
+	}
+
+	for _, tc := range testsCases {
+		cfg := ReadConfig(tc.entry)
+		if cfg.WorkerCPUAffinity != tc.expect {
+			t.Errorf("Testing %v. Expected \"%v\" but \"%v\" was returned", tc.name, tc.expect, cfg.WorkerCPUAffinity)
+		}
+	}
+}
+
+func TestBuildCPUAffinityMask(t *testing.T) {
+	testsCases := []struct {
+		workers int
+		cpus    int
+		expect  string
+	}{
+		{0, 4, ""},
+		{1, 1, "1"},
+		{4, 4, "0001 0010 0100 1000"},
+		{2, 4, "0001 0010"},
+		{3, 2, "01 10 01"},
+	}
+
+	for _, tc := range testsCases {
+		mask := buildCPUAffinityMask(tc.workers, tc.cpus)
+		if mask != tc.expect {
+			t.Errorf("Testing %v workers and %v CPUs. Expected \"%v\" but \"%v\" was returned", tc.workers, tc.cpus, tc.expect, mask)
+		}
+	}
+}
+
+func TestCustomPortDomainParsing(t *testing.T) {
+	reservedPorts := ReservedPorts
+	defer func() {
+		ReservedPorts = reservedPorts
+	}()
+	ReservedPorts = sets.NewInt(80, 10254)
+
+	testsCases := []struct {
+		name   string
+		entry  map[string]string
+		expect map[string]string
+	}{
+		{
+			name:   "no custom port domain",
+			entry:  map[string]string{},
+			expect: map[string]string{},
+		},
+		{
+			name:   "valid entries",
+			entry:  map[string]string{"custom-port-domain": "443: xxx.com, 2443: *.yyy.com"},
+			expect: map[string]string{"443": "xxx.com", "2443": "*.yyy.com"},
+		},
+		{
+			name:   "non numeric port",
+			entry:  map[string]string{"custom-port-domain": "abc: foo, 2443: yyy.com"},
+			expect: map[string]string{"2443": "yyy.com"},
+		},
+		{
+			name:   "port out of range",
+			entry:  map[string]string{"custom-port-domain": "0: xxx.com, 65536: yyy.com"},
+			expect: map[string]string{},
+		},
+		{
+			name:   "reserved port",
+			entry:  map[string]string{"custom-port-domain": "80: xxx.com, 10254: yyy.com, 2443: zzz.com"},
+			expect: map[string]string{"2443": "zzz.com"},
+		},
+		{
+			name:   "invalid domain",
+			entry:  map[string]string{"custom-port-domain": "2443: xxx_com, 3443: -yyy.com, 4443:"},
+			expect: map[string]string{},
+		},
+		{
+			name:   "missing separator",
+			entry:  map[string]string{"custom-port-domain": "2443, 3443: yyy.com,"},
+			expect: map[string]string{"3443": "yyy.com"},
+		},
+	}
+
+	for _, tc := range testsCases {
+		cfg := ReadConfig(tc.entry)
+		if !reflect.DeepEqual(cfg.CustomPortDomain, tc.expect) {
+			t.Errorf("Testing %v. Expected \"%v\" but \"%v\" was returned", tc.name, tc.expect, cfg.CustomPortDomain)
+		}
+	}
+}
+
+func TestUnknownConfigKeys(t *testing.T) {
+	testsCases := []struct {
+		name   string
+		entry  map[string]string
+		expect []string
+	}{
+		{
+			name:   "known keys",
+			entry:  map[string]string{"ssl-protocols": "TLSv1.2", "proxy-body-size": "2m", "custom-port-domain": ""},
+			expect: []string{},
+		},
+		{
+			name:   "allowed keys",
+			entry:  map[string]string{"bind-address": "1.1.1.1", "global-auth-url": "http://auth"},
+			expect: []string{},
+		},
+		{
+			name:   "misspelled keys",
+			entry:  map[string]string{"ssl-protcols": "TLSv1.2", "use-gzip": "true", "proxy-body-sise": "2m"},
+			expect: []string{"proxy-body-sise", "ssl-protcols"},
+		},
+	}
+
+	for _, tc := range testsCases {
+		unknown := UnknownConfigKeys(tc.entry)
+		if !reflect.DeepEqual(unknown, tc.expect) {
+			t.Errorf("Testing %v. Expected \"%v\" but \"%v\" was returned", tc.name, tc.expect, unknown)
+		}
+	}
+}
+
+func TestReadNamespaceConfig(t *testing.T) {
+	global := ReadConfig(map[string]string{
+		"use-gzip":           "true",
+		"gzip-level":         "5",
+		"proxy-read-timeout": "60",
+		"worker-processes":   "4",
+	})
+
+	cfg := ReadNamespaceConfig(global, map[string]string{
+		"use-gzip":              "false",
+		"gzip-level":            "10",
+		"ssl-protocols":         " TLSv1.2  TLSv1.3 ",
+		"proxy-connect-timeout": "2",
+		"proxy-send-timeout":    "-1",
+		"proxy-read-timeout":    "300",
+		"worker-processes":      "1",
+	})
+
+	expected := global
+	expected.UseGzip = false
+	expected.SSLProtocols = "TLSv1.2 TLSv1.3"
+	expected.ProxyConnectTimeout = 2
+	expected.ProxyReadTimeout = 300
+
+	if !reflect.DeepEqual(cfg, expected) {
+		t.Errorf("expected the namespace configuration %+v but got %+v", expected, cfg)
+	}
+
+	if cfg := ReadNamespaceConfig(global, map[string]string{"ssl-protocols": "SSLv3 TLSv1.3"}); cfg.SSLProtocols != global.SSLProtocols {
+		t.Errorf("expected an invalid ssl-protocols to be ignored but got %q", cfg.SSLProtocols)
+	}
+
+	if !reflect.DeepEqual(ReadNamespaceConfig(global, nil), global) {
+		t.Errorf("expected a namespace without overlay to use the global configuration")
+	}
+}
@@ -26,6 +26,7 @@ import (
 	"fmt"
 	"math/rand"
 	"net"
+	"net/http"
 	"net/url"
 	"os"
 	"os/exec"
@@ -44,8 +45,12 @@ import (
 	"k8s.io/klog"
 
 	"k8s.io/ingress-nginx/internal/ingress"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/authreq"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/earlyhints"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/influxdb"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/ratelimit"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/securityheaders"
 	"k8s.io/ingress-nginx/internal/ingress/controller/config"
 	ing_net "k8s.io/ingress-nginx/internal/net"
 )
@@ -138,8 +143,11 @@ var (
 		"buildAuthLocation":               buildAuthLocation,
 		"shouldApplyGlobalAuth":           shouldApplyGlobalAuth,
 		"buildAuthResponseHeaders":        buildAuthResponseHeaders,
+		"buildAuthResponseCookies":        buildAuthResponseCookies,
+		"buildAuthResponseCookieNames":    buildAuthResponseCookieNames,
 		"buildAuthProxySetHeaders":        buildAuthProxySetHeaders,
 		"buildProxyPass":                  buildProxyPass,
+		"buildUpstreamBalancerName":       buildUpstreamBalancerName,
 		"filterRateLimits":                filterRateLimits,
 		"buildRateLimitZones":             buildRateLimitZones,
 		"buildRateLimit":                  buildRateLimit,
@@ -168,6 +176,16 @@ var (
 		"buildForwardedFor":                  buildForwardedFor,
 		"buildAuthSignURL":                   buildAuthSignURL,
 		"buildAuthSignURLLocation":           buildAuthSignURLLocation,
+		"buildAuthTargets":                   buildAuthTargets,
+		"buildAuthCacheDurations":            buildAuthCacheDurations,
+		"needsMisdirectedRequest":            needsMisdirectedRequest,
+		"buildMisdirectedRequest":            buildMisdirectedRequest,
+		"buildRobotsContent":                 buildRobotsContent,
+		"buildSecurityHeaders":               buildSecurityHeaders,
+		"buildEarlyHints":                    buildEarlyHints,
+		"buildEarlyHintsLinks":               buildEarlyHintsLinks,
+		"buildAltSvc":                        buildAltSvc,
+		"buildLargeClientHeaderBuffers":      buildLargeClientHeaderBuffers,
 		"buildOpentracing":                   buildOpentracing,
 		"proxySetHeader":                     proxySetHeader,
 		"buildInfluxDB":                      buildInfluxDB,
@@ -186,6 +204,9 @@ var (
 		"buildCorsOriginRegex":               buildCorsOriginRegex,
 		"buildDefaultListener":               buildDefaultListener,
 		"buildHTTPSCustomListener":           buildHTTPSCustomListener,
+		"buildHeaderVariable":                buildHeaderVariable,
+		"buildDefaultBackendJSONErrors":      buildDefaultBackendJSONErrors,
+		"buildServerGzip":                    buildServerGzip,
 	}
 )
 
@@ -218,6 +239,12 @@ func formatIP(input string) string {
 	return fmt.Sprintf("[%s]", input)
 }
 
+// buildHeaderVariable returns the NGINX variable with the value of the
+// request header, e.g. "X-Request-ID" -> "$http_x_request_id"
+func buildHeaderVariable(header string) string {
+	return "$http_" + strings.ToLower(strings.Replace(header, "-", "_", -1))
+}
+
 func quote(input interface{}) string {
 	var inputStr string
 	switch input := input.(type) {
@@ -290,6 +317,14 @@ func configForLua(input interface{}) string {
 		hsts_max_age = %v,
 		hsts_include_subdomains = %t,
 		hsts_preload = %t,
+
+		canary_action_limits = {
+			req_add_header = %v,
+			req_append_header = %v,
+			req_add_query = %v,
+			resp_add_header = %v,
+			resp_append_header = %v,
+		},
 	}`,
 		all.Cfg.UseForwardedHeaders,
 		all.Cfg.UseProxyProtocol,
@@ -302,6 +337,12 @@ func configForLua(input interface{}) string {
 		all.Cfg.HSTSMaxAge,
 		all.Cfg.HSTSIncludeSubdomains,
 		all.Cfg.HSTSPreload,
+
+		all.Cfg.MaxReqAddHeaderNum,
+		all.Cfg.MaxReqAppendHeaderNum,
+		all.Cfg.MaxReqAddQueryNum,
+		all.Cfg.MaxRespAddHeaderNum,
+		all.Cfg.MaxRespAppendHeaderNum,
 	)
 }
 
@@ -324,11 +365,15 @@ func locationConfigForLua(l interface{}, a interface{}) string {
 		ssl_redirect = %t,
 		force_no_ssl_redirect = %t,
 		use_port_in_redirects = %t,
+		request_body_md5 = %t,
+		request_body_md5_buffer_size = %d,
 	}`,
 		location.Rewrite.ForceSSLRedirect,
 		location.Rewrite.SSLRedirect,
 		isLocationInLocationList(l, all.Cfg.NoTLSRedirectLocations),
 		location.UsePortInRedirects,
+		location.RequestBodyMD5.Enabled,
+		location.RequestBodyMD5.BufferSize,
 	)
 }
 
@@ -473,6 +518,32 @@ func buildAuthResponseHeaders(headers []string) []string {
 	return res
 }
 
+// buildAuthResponseCookies returns the directives used to keep the Set-Cookie
+// headers returned by the authentication service. The cookies forwarded to the
+// client are filtered by name from these headers by the header filter of the
+// location, see buildAuthResponseCookieNames.
+func buildAuthResponseCookies(cookies []string) []string {
+	res := []string{}
+
+	if len(cookies) == 0 {
+		return res
+	}
+
+	res = append(res, "auth_request_set $auth_response_set_cookie $upstream_http_set_cookie;")
+	return res
+}
+
+// buildAuthResponseCookieNames returns the Lua table of the names of the
+// cookies forwarded from the authentication service to the client
+func buildAuthResponseCookieNames(cookies []string) string {
+	names := []string{}
+	for _, c := range cookies {
+		names = append(names, fmt.Sprintf("%q", c))
+	}
+
+	return fmt.Sprintf("{ %v }", strings.Join(names, ", "))
+}
+
 func buildAuthProxySetHeaders(headers map[string]string) []string {
 	res := []string{}
 
@@ -530,6 +601,8 @@ func buildProxyPass(host string, b interface{}, loc interface{}, reload bool) st
 
 	for _, backend := range backends {
 		if backend.Name == location.Backend {
+			upstreamName = buildUpstreamBalancerName(backend)
+
 			if backend.SSLPassthrough {
 				proto = "https://"
 
@@ -573,6 +646,17 @@ rewrite "(?i)%s" %s break;
 	return defProxyPass
 }
 
+// buildUpstreamBalancerName returns the name of the upstream serving a backend.
+// Backends with keepalive connections configured per ingress are served by a
+// dedicated upstream holding their own pool of keepalive connections.
+func buildUpstreamBalancerName(backend *ingress.Backend) string {
+	if backend.UpstreamKeepalive.Connections > 0 {
+		return fmt.Sprintf("upstream_balancer_%v", backend.Name)
+	}
+
+	return "upstream_balancer"
+}
+
 // TODO: Needs Unit Tests
 func filterRateLimits(input interface{}) []ratelimit.Config {
 	ratelimits := []ratelimit.Config{}
@@ -786,12 +870,6 @@ func buildNextUpstream(i, r interface{}) string {
 	return strings.Join(nextUpstreamCodes, " ")
 }
 
-// refer to http://nginx.org/en/docs/syntax.html
-// Nginx differentiates between size and offset
-// offset directives support gigabytes in addition
-var nginxSizeRegex = regexp.MustCompile("^[0-9]+[kKmM]{0,1}$")
-var nginxOffsetRegex = regexp.MustCompile("^[0-9]+[kKmMgG]{0,1}$")
-
 // isValidByteSize validates size units valid in nginx
 // http://nginx.org/en/docs/syntax.html
 func isValidByteSize(input interface{}, isOffset bool) bool {
@@ -807,11 +885,7 @@ func isValidByteSize(input interface{}, isOffset bool) bool {
 		return false
 	}
 
-	if isOffset {
-		return nginxOffsetRegex.MatchString(s)
-	}
-
-	return nginxSizeRegex.MatchString(s)
+	return parser.IsValidByteSize(s, isOffset)
 }
 
 type ingressInformation struct {
@@ -966,6 +1040,324 @@ func buildAuthSignURLLocation(location, authSignURL string) string {
 	return "@" + hex.EncodeToString(hasher.Sum(nil))
 }
 
+// authTarget describes an internal location proxying the authentication
+// request to one of the URLs of an external authentication
+type authTarget struct {
+	Path string
+	URL  string
+	// Host contains the hostname defined in the URL
+	Host string
+	// Next is the location used when this one is unavailable
+	Next string
+}
+
+// buildAuthTargets returns the locations of an external authentication. The
+// first one proxies to the main URL and each of the other ones to a fallback
+// URL tried when the previous location is unavailable. When all of them are
+// unavailable and a sign in URL is configured, the last fallback forwards
+// to the "-unavailable" location which rejects the request so that users
+// are redirected to the sign in URL.
+func buildAuthTargets(authPath string, input interface{}) []authTarget {
+	targets := []authTarget{}
+	if authPath == "" {
+		return targets
+	}
+
+	var urls []string
+	var signinURL string
+	switch externalAuth := input.(type) {
+	case authreq.Config:
+		targets = append(targets, authTarget{Path: authPath, URL: externalAuth.URL, Host: externalAuth.Host})
+		if len(externalAuth.URLs) > 1 {
+			urls = externalAuth.URLs[1:]
+		}
+		signinURL = externalAuth.SigninURL
+	case config.GlobalExternalAuth:
+		targets = append(targets, authTarget{Path: authPath, URL: externalAuth.URL, Host: externalAuth.Host})
+	default:
+		klog.Errorf("expected an 'authreq.Config' or 'config.GlobalExternalAuth' type but %T was returned", input)
+		return targets
+	}
+
+	for i, u := range urls {
+		host := ""
+		if parsedURL, err := url.Parse(u); err == nil {
+			host = parsedURL.Hostname()
+		}
+
+		targets = append(targets, authTarget{
+			Path: fmt.Sprintf("%v-fallback-%v", authPath, i+1),
+			URL:  u,
+			Host: host,
+		})
+	}
+
+	if len(targets) == 1 {
+		return targets
+	}
+
+	for i := range targets {
+		if i+1 < len(targets) {
+			targets[i].Next = targets[i+1].Path
+		} else if signinURL != "" {
+			targets[i].Next = fmt.Sprintf("%v-unavailable", authPath)
+		}
+	}
+
+	return targets
+}
+
+// buildAuthCacheDurations returns the proxy_cache_valid values of an external
+// authentication. The durations by status code class are expanded to the
+// status codes known in the class and precede the plain durations.
+func buildAuthCacheDurations(input interface{}) []string {
+	switch externalAuth := input.(type) {
+	case authreq.Config:
+		durations := []string{}
+
+		classes := []string{}
+		for class := range externalAuth.AuthCacheDurationMap {
+			classes = append(classes, class)
+		}
+		sort.Strings(classes)
+
+		for _, class := range classes {
+			base, err := strconv.Atoi(class[:1])
+			if err != nil {
+				klog.Warningf("invalid status code class %v", class)
+				continue
+			}
+
+			codes := []string{}
+			for code := base * 100; code < (base+1)*100; code++ {
+				if http.StatusText(code) != "" {
+					codes = append(codes, strconv.Itoa(code))
+				}
+			}
+
+			for _, duration := range externalAuth.AuthCacheDurationMap[class] {
+				durations = append(durations, fmt.Sprintf("%v %v", strings.Join(codes, " "), duration))
+			}
+		}
+
+		return append(durations, externalAuth.AuthCacheDuration...)
+	case config.GlobalExternalAuth:
+		return externalAuth.AuthCacheDuration
+	default:
+		klog.Errorf("expected an 'authreq.Config' or 'config.GlobalExternalAuth' type but %T was returned", input)
+		return []string{}
+	}
+}
+
+// needsMisdirectedRequest returns true if any server rejects the requests
+// sent on connections established for other hosts
+func needsMisdirectedRequest(input interface{}) bool {
+	servers, ok := input.([]*ingress.Server)
+	if !ok {
+		klog.Errorf("expected a '[]*ingress.Server' type but %T was returned", input)
+		return false
+	}
+
+	for _, server := range servers {
+		if server.DisableCoalescing {
+			return true
+		}
+	}
+
+	return false
+}
+
+// buildMisdirectedRequest returns the directives rejecting the requests whose
+// Host does not match the SNI of the TLS connection with a 421 status code, so
+// that clients coalescing HTTP/2 connections across hosts sharing a certificate
+// retry the request on a new connection.
+func buildMisdirectedRequest(input interface{}) []string {
+	server, ok := input.(*ingress.Server)
+	if !ok {
+		klog.Errorf("expected an '*ingress.Server' type but %T was returned", input)
+		return []string{}
+	}
+
+	if !server.DisableCoalescing {
+		return []string{}
+	}
+
+	return []string{
+		"if ($misdirected_request) {",
+		"    return 421;",
+		"}",
+	}
+}
+
+// buildSecurityHeaders returns the directives adding the default security
+// headers to the responses of the location, skipping the headers disabled by
+// the location or hidden with hide-headers
+func buildSecurityHeaders(cfg config.Configuration, location *ingress.Location) []string {
+	if !cfg.DefaultSecurityHeaders || location.SecurityHeaders.Disable {
+		return []string{}
+	}
+
+	hidden := sets.New[string]()
+	for _, header := range cfg.HideHeaders {
+		hidden.Insert(strings.ToLower(header))
+	}
+
+	directives := []string{}
+	for _, header := range securityheaders.DefaultHeaders {
+		if hidden.Has(strings.ToLower(header.Name)) {
+			continue
+		}
+
+		value := header.Value
+		if override, ok := location.SecurityHeaders.Headers[header.Name]; ok {
+			value = override
+		}
+		if value == securityheaders.Off {
+			continue
+		}
+
+		directives = append(directives, fmt.Sprintf("more_set_headers \"%v: %v\";", header.Name, value))
+	}
+
+	return directives
+}
+
+// buildAltSvc returns the value of the Alt-Svc header advertising HTTP/3 on the
+// port clients use for QUIC, or an empty string if the server does not advertise it.
+// The annotation of the server overrides the global configuration.
+func buildAltSvc(cfg config.Configuration, server *ingress.Server) string {
+	if !cfg.UseHTTP3xQUIC {
+		return ""
+	}
+
+	if server.EnableHTTP3 != nil && !*server.EnableHTTP3 {
+		return ""
+	}
+
+	return fmt.Sprintf(`h3=":%v"; ma=2592000,h3-29=":%v"; ma=2592000`, cfg.HTTP3xQUICDefaultPort, cfg.HTTP3xQUICDefaultPort)
+}
+
+// buildServerGzip returns the gzip directives of a server overriding use-gzip,
+// or nil if the server follows the global configuration. The other gzip
+// directives of the http block are only set when gzip is enabled globally.
+func buildServerGzip(cfg config.Configuration, server *ingress.Server) []string {
+	if server.UseGzip == nil {
+		return nil
+	}
+
+	if !*server.UseGzip {
+		return []string{"gzip off;"}
+	}
+
+	return []string{
+		"gzip on;",
+		fmt.Sprintf("gzip_comp_level %v;", server.GzipLevel),
+		"gzip_http_version 1.1;",
+		fmt.Sprintf("gzip_min_length %v;", cfg.GzipMinLength),
+		fmt.Sprintf("gzip_types %v;", cfg.GzipTypes),
+		"gzip_proxied any;",
+		"gzip_vary on;",
+	}
+}
+
+// requestLineOverhead is the room left in the request line buffers for the
+// method and the protocol of the requests, besides the URI
+const requestLineOverhead = 1024
+
+// buildLargeClientHeaderBuffers returns the large_client_header_buffers of a
+// server with buffers fitting the request line of its longest URIs, or an empty
+// string if the global buffers are big enough.
+func buildLargeClientHeaderBuffers(cfg config.Configuration, server *ingress.Server) string {
+	if server.MaxURILength == 0 {
+		return ""
+	}
+
+	number, size := 4, 8*1024
+	if fields := strings.Fields(cfg.LargeClientHeaderBuffers); len(fields) == 2 {
+		n, err := strconv.Atoi(fields[0])
+		if err == nil {
+			number = n
+		}
+		s, err := sizeInBytes(fields[1])
+		if err == nil {
+			size = s
+		}
+	}
+
+	required := server.MaxURILength + requestLineOverhead
+	if required <= size {
+		return ""
+	}
+
+	return fmt.Sprintf("%v %vk", number, (required+1023)/1024)
+}
+
+// sizeInBytes parses a size of the NGINX configuration, e.g. 8k
+func sizeInBytes(size string) (int, error) {
+	unit := 1
+	switch strings.ToLower(size[len(size)-1:]) {
+	case "k":
+		unit = 1024
+	case "m":
+		unit = 1024 * 1024
+	}
+	if unit != 1 {
+		size = size[:len(size)-1]
+	}
+
+	n, err := strconv.Atoi(size)
+	if err != nil {
+		return 0, err
+	}
+
+	return n * unit, nil
+}
+
+// buildEarlyHints returns the directive passing the 103 Early Hints responses
+// of the backends to HTTP/2 clients, or none if Tengine does not support early
+// hints. The support is checked once when the controller starts.
+func buildEarlyHints(supported bool, location *ingress.Location) []string {
+	if !supported || len(location.EarlyHints) == 0 {
+		return []string{}
+	}
+
+	return []string{"early_hints $http2;"}
+}
+
+// buildEarlyHintsLinks returns the Lua table of the preload links of the
+// resources hinted by the location, appended to the Link headers of the
+// responses by the header filter of the location
+func buildEarlyHintsLinks(supported bool, location *ingress.Location) string {
+	if !supported || len(location.EarlyHints) == 0 {
+		return ""
+	}
+
+	links := []string{}
+	for _, resource := range location.EarlyHints {
+		destination := earlyhints.Destination(resource)
+		link := fmt.Sprintf("<%v>; rel=preload; as=%v", resource, destination)
+		if destination == "font" {
+			// fonts are always fetched in CORS mode
+			link += "; crossorigin"
+		}
+
+		links = append(links, fmt.Sprintf("%q", link))
+	}
+
+	return fmt.Sprintf("{ %v }", strings.Join(links, ", "))
+}
+
+// buildRobotsContent returns the robots.txt content as a Lua long string,
+// using a level of long brackets not contained in the content
+func buildRobotsContent(content string) string {
+	level := ""
+	for strings.Contains(content, "]"+level+"]") {
+		level += "="
+	}
+
+	return fmt.Sprintf("[%v[%v]%v]", level, content, level)
+}
+
 var letters = []rune("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ")
 
 func init() {
@@ -1207,7 +1599,7 @@ func buildHTTPListener(t interface{}, s interface{}) string {
 	return strings.Join(out, "\n")
 }
 
-func buildHTTPSListener(t interface{}, s interface{}) string {
+func buildHTTPSListener(t interface{}, s interface{}, disableHTTP2 bool) string {
 	var out []string
 
 	tc, ok := t.(config.TemplateConfig)
@@ -1222,6 +1614,9 @@ func buildHTTPSListener(t interface{}, s interface{}) string {
 		return ""
 	}
 
+	// tc is a copy, the server can only turn HTTP/2 off
+	tc.Cfg.UseHTTP2 = tc.Cfg.UseHTTP2 && !disableHTTP2
+
 	co := commonListenOptions(tc, hostname)
 
 	addrV4 := []string{""}
@@ -1283,7 +1678,7 @@ func buildHTTP3Listener(t interface{}, s interface{}) string {
 	return strings.Join(out, "\n")
 }
 
-func buildDefaultListener(t interface{}, s interface{}, port int) string {
+func buildDefaultListener(t interface{}, s interface{}, port int, disableHTTP2 bool) string {
 	var out []string
 
 	tc, ok := t.(config.TemplateConfig)
@@ -1298,6 +1693,9 @@ func buildDefaultListener(t interface{}, s interface{}, port int) string {
 		return ""
 	}
 
+	// tc is a copy, the server can only turn HTTP/2 off
+	tc.Cfg.UseHTTP2 = tc.Cfg.UseHTTP2 && !disableHTTP2
+
 	co := commonListenOptions(tc, hostname)
 
 	addrV4 := []string{""}
@@ -1674,3 +2072,28 @@ func buildHTTPSCustomListener(t interface{}, s interface{}) string {
 
 	return strings.Join(out, "\n")
 }
+
+// defaultBackendErrorCodes contains the responses of the default backend
+// served as a JSON body when default-backend-json-errors is enabled
+var defaultBackendErrorCodes = []int{500, 502, 503, 504}
+
+// buildDefaultBackendJSONErrors returns the error codes of the location served
+// as a JSON body. The codes handled by custom-http-errors are left to the
+// custom error pages.
+func buildDefaultBackendJSONErrors(cfg config.Configuration, location *ingress.Location) []int {
+	if !cfg.DefaultBackendJSONErrors || location.Backend != "upstream-default-backend" {
+		return nil
+	}
+
+	customErrors := sets.NewInt(cfg.CustomHTTPErrors...)
+	customErrors.Insert(location.CustomHTTPErrors...)
+
+	var codes []int
+	for _, code := range defaultBackendErrorCodes {
+		if !customErrors.Has(code) {
+			codes = append(codes, code)
+		}
+	}
+
+	return codes
+}
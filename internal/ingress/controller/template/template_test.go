@@ -35,11 +35,17 @@ import (
 
 	"k8s.io/ingress-nginx/internal/ingress"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/authreq"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/bodytoolarge"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/cacheconverthead"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/influxdb"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/ipwhitelist"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/modsecurity"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/opentracing"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/proxycachelock"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/ratelimit"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/rewrite"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/securityheaders"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/upstreamkeepalive"
 	"k8s.io/ingress-nginx/internal/ingress/controller/config"
 	"k8s.io/ingress-nginx/internal/nginx"
 )
@@ -269,6 +275,20 @@ func TestQuote(t *testing.T) {
 	}
 }
 
+func TestBuildHeaderVariable(t *testing.T) {
+	cases := map[string]string{
+		"X-Request-ID":     "$http_x_request_id",
+		"X-Corp-Trace-Id":  "$http_x_corp_trace_id",
+		"x_custom_request": "$http_x_custom_request",
+	}
+	for input, output := range cases {
+		actual := buildHeaderVariable(input)
+		if actual != output {
+			t.Errorf("buildHeaderVariable('%s'): expected '%v' but returned '%v'", input, output, actual)
+		}
+	}
+}
+
 func TestBuildLocation(t *testing.T) {
 	invalidType := &ingress.Ingress{}
 	expected := "/"
@@ -382,6 +402,51 @@ func TestBuildAuthLocation(t *testing.T) {
 	}
 }
 
+func TestBuildAuthTargets(t *testing.T) {
+	authPath := "/_external-auth-Lw"
+
+	targets := buildAuthTargets("", authreq.Config{URL: "http://foo.com/auth"})
+	if len(targets) != 0 {
+		t.Errorf("expected no targets without an authentication location but returned %v", targets)
+	}
+
+	expected := []authTarget{
+		{Path: authPath, URL: "http://foo.com/auth", Host: "foo.com"},
+	}
+	targets = buildAuthTargets(authPath, authreq.Config{URL: "http://foo.com/auth", Host: "foo.com", URLs: []string{"http://foo.com/auth"}})
+	if !reflect.DeepEqual(targets, expected) {
+		t.Errorf("expected '%v' but returned '%v'", expected, targets)
+	}
+
+	targets = buildAuthTargets(authPath, config.GlobalExternalAuth{URL: "http://foo.com/auth", Host: "foo.com"})
+	if !reflect.DeepEqual(targets, expected) {
+		t.Errorf("expected '%v' but returned '%v'", expected, targets)
+	}
+
+	externalAuth := authreq.Config{
+		URL:  "http://foo.com/auth",
+		Host: "foo.com",
+		URLs: []string{"http://foo.com/auth", "http://bar.com/auth", "http://baz.com:8080/auth"},
+	}
+
+	expected = []authTarget{
+		{Path: authPath, URL: "http://foo.com/auth", Host: "foo.com", Next: "/_external-auth-Lw-fallback-1"},
+		{Path: "/_external-auth-Lw-fallback-1", URL: "http://bar.com/auth", Host: "bar.com", Next: "/_external-auth-Lw-fallback-2"},
+		{Path: "/_external-auth-Lw-fallback-2", URL: "http://baz.com:8080/auth", Host: "baz.com", Next: ""},
+	}
+	targets = buildAuthTargets(authPath, externalAuth)
+	if !reflect.DeepEqual(targets, expected) {
+		t.Errorf("expected '%v' but returned '%v'", expected, targets)
+	}
+
+	externalAuth.SigninURL = "http://foo.com/signin"
+	expected[2].Next = "/_external-auth-Lw-unavailable"
+	targets = buildAuthTargets(authPath, externalAuth)
+	if !reflect.DeepEqual(targets, expected) {
+		t.Errorf("expected '%v' but returned '%v'", expected, targets)
+	}
+}
+
 func TestShouldApplyGlobalAuth(t *testing.T) {
 
 	authURL := "foo.com/auth"
@@ -439,6 +504,55 @@ func TestBuildAuthResponseHeaders(t *testing.T) {
 	}
 }
 
+func TestBuildAuthCacheDurations(t *testing.T) {
+	externalAuth := authreq.Config{
+		AuthCacheDuration: []string{"401 5m"},
+		AuthCacheDurationMap: map[string][]string{
+			"2xx": {"10m"},
+			"1xx": {"30s"},
+		},
+	}
+	expected := []string{
+		"100 101 102 103 30s",
+		"200 201 202 203 204 205 206 207 208 226 10m",
+		"401 5m",
+	}
+
+	durations := buildAuthCacheDurations(externalAuth)
+	if !reflect.DeepEqual(expected, durations) {
+		t.Errorf("Expected \n'%v'\nbut returned \n'%v'", expected, durations)
+	}
+
+	globalExternalAuth := config.GlobalExternalAuth{AuthCacheDuration: []string{authreq.DefaultCacheDuration}}
+	durations = buildAuthCacheDurations(globalExternalAuth)
+	if !reflect.DeepEqual(globalExternalAuth.AuthCacheDuration, durations) {
+		t.Errorf("Expected \n'%v'\nbut returned \n'%v'", globalExternalAuth.AuthCacheDuration, durations)
+	}
+}
+
+func TestBuildAuthResponseCookies(t *testing.T) {
+	expected := []string{
+		"auth_request_set $auth_response_set_cookie $upstream_http_set_cookie;",
+	}
+
+	cookies := buildAuthResponseCookies([]string{"session", "token"})
+	if !reflect.DeepEqual(expected, cookies) {
+		t.Errorf("Expected \n'%v'\nbut returned \n'%v'", expected, cookies)
+	}
+
+	if len(buildAuthResponseCookies([]string{})) != 0 {
+		t.Errorf("Expected no directives without cookies")
+	}
+}
+
+func TestBuildAuthResponseCookieNames(t *testing.T) {
+	expected := `{ "session", "token" }`
+	names := buildAuthResponseCookieNames([]string{"session", "token"})
+	if names != expected {
+		t.Errorf("Expected '%v' but returned '%v'", expected, names)
+	}
+}
+
 func TestBuildAuthProxySetHeaders(t *testing.T) {
 	proxySetHeaders := map[string]string{
 		"header1": "value1",
@@ -456,6 +570,71 @@ func TestBuildAuthProxySetHeaders(t *testing.T) {
 	}
 }
 
+func TestTemplateCacheConvertHead(t *testing.T) {
+	pwd, _ := os.Getwd()
+	data, err := os.ReadFile(path.Join(pwd, "../../../../test/data/config.json"))
+	if err != nil {
+		t.Fatalf("unexpected error reading json file: %v", err)
+	}
+	var dat config.TemplateConfig
+	if err := jsoniter.ConfigCompatibleWithStandardLibrary.Unmarshal(data, &dat); err != nil {
+		t.Fatalf("unexpected error unmarshalling json: %v", err)
+	}
+	if dat.ListenPorts == nil {
+		dat.ListenPorts = &config.ListenPorts{}
+	}
+	dat.Cfg.DefaultSSLCertificate = &ingress.SSLCert{}
+	dat.Servers = []*ingress.Server{
+		{
+			Hostname: "cache.example.com",
+			Locations: []*ingress.Location{
+				{
+					Path:                 "/",
+					Backend:              "default-cache-80",
+					ConfigurationSnippet: "proxy_cache static;",
+					CacheConvertHead:     cacheconverthead.Config{Enabled: true, Convert: true},
+				},
+				{
+					Path:                 "/head",
+					Backend:              "default-cache-80",
+					ConfigurationSnippet: "proxy_cache static;",
+					CacheConvertHead:     cacheconverthead.Config{Enabled: true},
+				},
+				{
+					Path:    "/nocache",
+					Backend: "default-cache-80",
+				},
+			},
+		},
+	}
+
+	ngxTpl, err := NewTemplate(nginx.TemplatePath)
+	if err != nil {
+		t.Fatalf("invalid NGINX template: %v", err)
+	}
+
+	rt, err := ngxTpl.Write(dat)
+	if err != nil {
+		t.Fatalf("invalid NGINX template: %v", err)
+	}
+
+	conf := string(rt)
+	if c := strings.Count(conf, "proxy_cache_convert_head                on;"); c != 1 {
+		t.Errorf("expected proxy_cache_convert_head on in one location but got %v", c)
+	}
+	if c := strings.Count(conf, "proxy_cache_convert_head                off;"); c != 1 {
+		t.Errorf("expected proxy_cache_convert_head off in one location but got %v", c)
+	}
+
+	start := strings.Index(conf, "location /nocache")
+	if start == -1 {
+		t.Fatalf("expected the location /nocache in the configuration")
+	}
+	if strings.Contains(conf[start:], "proxy_cache_convert_head") {
+		t.Errorf("expected no proxy_cache_convert_head in the location /nocache")
+	}
+}
+
 func TestTemplateWithData(t *testing.T) {
 	pwd, _ := os.Getwd()
 	f, err := os.Open(path.Join(pwd, "../../../../test/data/config.json"))
@@ -1431,3 +1610,1179 @@ modsecurity_rules_file /etc/nginx/modsecurity/modsecurity.conf;
 		}
 	}
 }
+
+func TestNeedsMisdirectedRequest(t *testing.T) {
+	if needsMisdirectedRequest(&ingress.Ingress{}) {
+		t.Errorf("expected false for an invalid argument type")
+	}
+
+	servers := []*ingress.Server{
+		{Hostname: "foo.bar"},
+	}
+	if needsMisdirectedRequest(servers) {
+		t.Errorf("expected false when no server disables connection coalescing")
+	}
+
+	servers = append(servers, &ingress.Server{Hostname: "bar.foo", DisableCoalescing: true})
+	if !needsMisdirectedRequest(servers) {
+		t.Errorf("expected true when a server disables connection coalescing")
+	}
+}
+
+func TestBuildMisdirectedRequest(t *testing.T) {
+	if actual := buildMisdirectedRequest(&ingress.Ingress{}); len(actual) != 0 {
+		t.Errorf("expected no directives for an invalid argument type but returned %v", actual)
+	}
+
+	if actual := buildMisdirectedRequest(&ingress.Server{Hostname: "foo.bar"}); len(actual) != 0 {
+		t.Errorf("expected no directives but returned %v", actual)
+	}
+
+	expected := []string{
+		"if ($misdirected_request) {",
+		"    return 421;",
+		"}",
+	}
+	actual := buildMisdirectedRequest(&ingress.Server{Hostname: "foo.bar", DisableCoalescing: true})
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("expected %v but returned %v", expected, actual)
+	}
+}
+
+func TestBuildRobotsContent(t *testing.T) {
+	testCases := map[string]struct {
+		content  string
+		expected string
+	}{
+		"plain content":            {"User-agent: *\nDisallow: /private\n", "[[User-agent: *\nDisallow: /private\n]]"},
+		"closing long bracket":     {"Disallow: /a]]b\n", "[=[Disallow: /a]]b\n]=]"},
+		"closing level 1 bracket":  {"Disallow: /a]]b]=]c\n", "[==[Disallow: /a]]b]=]c\n]==]"},
+		"variables are not parsed": {"Disallow: /*.php$\n", "[[Disallow: /*.php$\n]]"},
+	}
+
+	for title, tc := range testCases {
+		actual := buildRobotsContent(tc.content)
+		if actual != tc.expected {
+			t.Errorf("%v: expected '%v' but returned '%v'", title, tc.expected, actual)
+		}
+	}
+}
+
+func TestBuildSecurityHeaders(t *testing.T) {
+	defaults := []string{
+		`more_set_headers "X-Frame-Options: SAMEORIGIN";`,
+		`more_set_headers "X-Content-Type-Options: nosniff";`,
+		`more_set_headers "Referrer-Policy: strict-origin-when-cross-origin";`,
+	}
+
+	testCases := []struct {
+		title    string
+		cfg      config.Configuration
+		location *ingress.Location
+		expected []string
+	}{
+		{"disabled in the configmap", config.Configuration{}, &ingress.Location{}, []string{}},
+		{"enabled in the configmap", config.Configuration{DefaultSecurityHeaders: true}, &ingress.Location{}, defaults},
+		{"disabled in the location", config.Configuration{DefaultSecurityHeaders: true}, &ingress.Location{
+			SecurityHeaders: securityheaders.Config{Disable: true},
+		}, []string{}},
+		{"hidden headers", config.Configuration{DefaultSecurityHeaders: true, HideHeaders: []string{"x-frame-options"}}, &ingress.Location{}, defaults[1:]},
+		{"overridden headers", config.Configuration{DefaultSecurityHeaders: true}, &ingress.Location{
+			SecurityHeaders: securityheaders.Config{Headers: map[string]string{
+				"X-Frame-Options": "DENY",
+				"Referrer-Policy": "off",
+			}},
+		}, []string{
+			`more_set_headers "X-Frame-Options: DENY";`,
+			`more_set_headers "X-Content-Type-Options: nosniff";`,
+		}},
+	}
+
+	for _, tc := range testCases {
+		actual := buildSecurityHeaders(tc.cfg, tc.location)
+		if !reflect.DeepEqual(tc.expected, actual) {
+			t.Errorf("%v: expected %v but returned %v", tc.title, tc.expected, actual)
+		}
+	}
+}
+
+func TestBuildEarlyHints(t *testing.T) {
+	location := &ingress.Location{
+		Path:       "/",
+		EarlyHints: []string{"/app.css", "/fonts/a.woff2"},
+	}
+
+	if actual := buildEarlyHints(false, location); len(actual) != 0 {
+		t.Errorf("expected no directives without early hints support but returned %v", actual)
+	}
+
+	if actual := buildEarlyHints(true, &ingress.Location{Path: "/"}); len(actual) != 0 {
+		t.Errorf("expected no directives without early hints but returned %v", actual)
+	}
+
+	expected := []string{"early_hints $http2;"}
+	if actual := buildEarlyHints(true, location); !reflect.DeepEqual(expected, actual) {
+		t.Errorf("expected %v but returned %v", expected, actual)
+	}
+}
+
+func TestBuildEarlyHintsLinks(t *testing.T) {
+	location := &ingress.Location{
+		Path:       "/",
+		EarlyHints: []string{"/app.css", "/fonts/a.woff2"},
+	}
+
+	if actual := buildEarlyHintsLinks(false, location); actual != "" {
+		t.Errorf("expected no links without early hints support but returned %v", actual)
+	}
+
+	if actual := buildEarlyHintsLinks(true, &ingress.Location{Path: "/"}); actual != "" {
+		t.Errorf("expected no links without early hints but returned %v", actual)
+	}
+
+	expected := `{ "</app.css>; rel=preload; as=style", "</fonts/a.woff2>; rel=preload; as=font; crossorigin" }`
+	if actual := buildEarlyHintsLinks(true, location); actual != expected {
+		t.Errorf("expected %v but returned %v", expected, actual)
+	}
+}
+
+func TestBuildHTTPSListenerDisableHTTP2(t *testing.T) {
+	tc := config.TemplateConfig{
+		Cfg:         config.Configuration{UseHTTP2: true},
+		ListenPorts: &config.ListenPorts{HTTPS: 443},
+	}
+
+	if actual := buildHTTPSListener(tc, "example.com", false); !strings.Contains(actual, " http2 ") {
+		t.Errorf("expected http2 in the listen directive but returned %v", actual)
+	}
+
+	if actual := buildHTTPSListener(tc, "example.com", true); strings.Contains(actual, "http2") {
+		t.Errorf("expected no http2 in the listen directive but returned %v", actual)
+	}
+
+	if actual := buildDefaultListener(tc, "example.com", 443, true); strings.Contains(actual, "http2") {
+		t.Errorf("expected no http2 in the default listen directive but returned %v", actual)
+	}
+
+	if !tc.Cfg.UseHTTP2 {
+		t.Errorf("expected the global configuration to be unchanged")
+	}
+
+	tc.Cfg.UseHTTP2 = false
+	if actual := buildHTTPSListener(tc, "example.com", false); strings.Contains(actual, "http2") {
+		t.Errorf("expected no http2 in the listen directive when disabled globally but returned %v", actual)
+	}
+}
+
+func TestTemplateSatisfyAnyWithWhitelistAndExternalAuth(t *testing.T) {
+	pwd, _ := os.Getwd()
+	data, err := os.ReadFile(path.Join(pwd, "../../../../test/data/config.json"))
+	if err != nil {
+		t.Fatalf("unexpected error reading json file: %v", err)
+	}
+	var dat config.TemplateConfig
+	if err := jsoniter.ConfigCompatibleWithStandardLibrary.Unmarshal(data, &dat); err != nil {
+		t.Fatalf("unexpected error unmarshalling json: %v", err)
+	}
+	if dat.ListenPorts == nil {
+		dat.ListenPorts = &config.ListenPorts{}
+	}
+	dat.Cfg.DefaultSSLCertificate = &ingress.SSLCert{}
+	dat.Cfg.BlockCIDRs = []string{"10.1.0.0/16"}
+
+	testCases := map[string]struct {
+		satisfy  string
+		expected []string
+	}{
+		"satisfy any": {
+			"any",
+			[]string{"satisfy any;", "deny 10.1.0.0/16;", "allow 10.0.0.0/8;", "deny all;", "auth_request"},
+		},
+		"satisfy all": {
+			"all",
+			[]string{"satisfy all;", "deny 10.1.0.0/16;", "allow 10.0.0.0/8;", "deny all;", "auth_request"},
+		},
+		"default": {
+			"",
+			[]string{"deny 10.1.0.0/16;", "allow 10.0.0.0/8;", "deny all;", "auth_request"},
+		},
+	}
+
+	ngxTpl, err := NewTemplate(nginx.TemplatePath)
+	if err != nil {
+		t.Fatalf("invalid NGINX template: %v", err)
+	}
+
+	for title, tc := range testCases {
+		t.Run(title, func(t *testing.T) {
+			tpl := dat
+			tpl.Servers = []*ingress.Server{
+				{
+					Hostname: "satisfy.example.com",
+					Locations: []*ingress.Location{
+						{
+							Path:    "/satisfy",
+							Backend: "default-satisfy-80",
+							Satisfy: tc.satisfy,
+							Whitelist: ipwhitelist.SourceRange{
+								CIDR: []string{"10.0.0.0/8"},
+							},
+							ExternalAuth: authreq.Config{
+								URL:  "http://auth.example.com/verify",
+								Host: "auth.example.com",
+							},
+						},
+					},
+				},
+			}
+
+			rt, err := ngxTpl.Write(tpl)
+			if err != nil {
+				t.Fatalf("invalid NGINX template: %v", err)
+			}
+
+			conf := string(rt)
+			start := strings.Index(conf, "location /satisfy/")
+			if start == -1 {
+				start = strings.Index(conf, "location /satisfy")
+			}
+			if start == -1 {
+				t.Fatalf("expected the location /satisfy in the configuration")
+			}
+			conf = conf[start:]
+
+			last := -1
+			for n, directive := range tc.expected {
+				i := strings.Index(conf, directive)
+				if i == -1 {
+					t.Fatalf("expected %q in the location", directive)
+				}
+				if i < last {
+					t.Errorf("expected %q after %q", directive, tc.expected[n-1])
+				}
+				last = i
+			}
+
+			if tc.satisfy == "" && (strings.Contains(conf[:last], "satisfy any;") || strings.Contains(conf[:last], "satisfy all;")) {
+				t.Errorf("unexpected satisfy directive without the annotation")
+			}
+		})
+	}
+}
+
+func TestBuildAltSvc(t *testing.T) {
+	enabled, disabled := true, false
+	altSvc := `h3=":8443"; ma=2592000,h3-29=":8443"; ma=2592000`
+
+	testCases := []struct {
+		title       string
+		useHTTP3    bool
+		enableHTTP3 *bool
+		expected    string
+	}{
+		{"global configuration", true, nil, altSvc},
+		{"enabled in the server", true, &enabled, altSvc},
+		{"disabled in the server", true, &disabled, ""},
+		{"disabled globally", false, nil, ""},
+		{"enabled in the server but disabled globally", false, &enabled, ""},
+	}
+
+	for _, tc := range testCases {
+		cfg := config.Configuration{
+			UseHTTP3xQUIC:         tc.useHTTP3,
+			HTTP3xQUICDefaultPort: 8443,
+		}
+		server := &ingress.Server{Hostname: "example.com", EnableHTTP3: tc.enableHTTP3}
+
+		if actual := buildAltSvc(cfg, server); actual != tc.expected {
+			t.Errorf("%v: expected %q but returned %q", tc.title, tc.expected, actual)
+		}
+	}
+}
+
+func TestBuildServerGzip(t *testing.T) {
+	enabled, disabled := true, false
+	cfg := config.Configuration{GzipMinLength: 256, GzipTypes: "text/plain"}
+
+	if directives := buildServerGzip(cfg, &ingress.Server{}); directives != nil {
+		t.Errorf("expected no gzip directives following the global configuration but got %v", directives)
+	}
+
+	directives := buildServerGzip(cfg, &ingress.Server{UseGzip: &disabled})
+	if !reflect.DeepEqual(directives, []string{"gzip off;"}) {
+		t.Errorf("expected gzip to be disabled but got %v", directives)
+	}
+
+	directives = buildServerGzip(cfg, &ingress.Server{UseGzip: &enabled, GzipLevel: 3})
+	for _, expected := range []string{"gzip on;", "gzip_comp_level 3;", "gzip_min_length 256;", "gzip_types text/plain;"} {
+		if !strings.Contains(strings.Join(directives, "\n"), expected) {
+			t.Errorf("expected the gzip directives to contain %q but got %v", expected, directives)
+		}
+	}
+}
+
+func TestTemplateNormalizePath(t *testing.T) {
+	pwd, _ := os.Getwd()
+	data, err := os.ReadFile(path.Join(pwd, "../../../../test/data/config.json"))
+	if err != nil {
+		t.Fatalf("unexpected error reading json file: %v", err)
+	}
+	var dat config.TemplateConfig
+	if err := jsoniter.ConfigCompatibleWithStandardLibrary.Unmarshal(data, &dat); err != nil {
+		t.Fatalf("unexpected error unmarshalling json: %v", err)
+	}
+	if dat.ListenPorts == nil {
+		dat.ListenPorts = &config.ListenPorts{}
+	}
+	dat.Cfg.DefaultSSLCertificate = &ingress.SSLCert{}
+	dat.Servers = []*ingress.Server{
+		{
+			Hostname: "raw.example.com",
+			Locations: []*ingress.Location{
+				{Path: "/admin", Backend: "default-raw-80"},
+			},
+		},
+		{
+			Hostname:      "normalized.example.com",
+			NormalizePath: true,
+			Locations: []*ingress.Location{
+				{Path: "/admin", Backend: "default-normalized-80"},
+			},
+		},
+	}
+
+	ngxTpl, err := NewTemplate(nginx.TemplatePath)
+	if err != nil {
+		t.Fatalf("invalid NGINX template: %v", err)
+	}
+
+	rt, err := ngxTpl.Write(dat)
+	if err != nil {
+		t.Fatalf("invalid NGINX template: %v", err)
+	}
+
+	conf := string(rt)
+	raw := strings.Index(conf, "## start server raw.example.com")
+	normalized := strings.Index(conf, "## start server normalized.example.com")
+	if raw == -1 || normalized == -1 {
+		t.Fatalf("expected both servers in the configuration")
+	}
+
+	rewrite := "rewrite ^(.*)$ $1;"
+	if strings.Count(conf, rewrite) != 1 {
+		t.Fatalf("expected the normalization rewrite only once")
+	}
+
+	normalizedConf := conf[normalized:]
+	if end := strings.Index(normalizedConf, "## end server"); end != -1 {
+		normalizedConf = normalizedConf[:end]
+	}
+	i := strings.Index(normalizedConf, rewrite)
+	if i == -1 {
+		t.Fatalf("expected the normalization rewrite in the server normalized.example.com")
+	}
+	if l := strings.Index(normalizedConf, "location "); l != -1 && l < i {
+		t.Errorf("expected the normalization rewrite before the locations")
+	}
+}
+
+func TestTemplateSuppressDefaultRobots(t *testing.T) {
+	pwd, _ := os.Getwd()
+	data, err := os.ReadFile(path.Join(pwd, "../../../../test/data/config.json"))
+	if err != nil {
+		t.Fatalf("unexpected error reading json file: %v", err)
+	}
+	var dat config.TemplateConfig
+	if err := jsoniter.ConfigCompatibleWithStandardLibrary.Unmarshal(data, &dat); err != nil {
+		t.Fatalf("unexpected error unmarshalling json: %v", err)
+	}
+	if dat.ListenPorts == nil {
+		dat.ListenPorts = &config.ListenPorts{}
+	}
+	dat.Cfg.DefaultSSLCertificate = &ingress.SSLCert{}
+	dat.Servers = []*ingress.Server{
+		{
+			Hostname: "default-robots.example.com",
+			Locations: []*ingress.Location{
+				{Path: "/", Backend: "default-robots-80"},
+			},
+		},
+		{
+			Hostname:              "custom-robots.example.com",
+			SuppressDefaultRobots: true,
+			ServerSnippet:         "location /robots.txt { return 200; }",
+			Locations: []*ingress.Location{
+				{Path: "/", Backend: "custom-robots-80"},
+			},
+		},
+	}
+
+	ngxTpl, err := NewTemplate(nginx.TemplatePath)
+	if err != nil {
+		t.Fatalf("invalid NGINX template: %v", err)
+	}
+
+	rt, err := ngxTpl.Write(dat)
+	if err != nil {
+		t.Fatalf("invalid NGINX template: %v", err)
+	}
+
+	serverConf := func(conf, hostname string) string {
+		start := strings.Index(conf, "## start server "+hostname)
+		if start == -1 {
+			t.Fatalf("expected the server %v in the configuration", hostname)
+		}
+		conf = conf[start:]
+		if end := strings.Index(conf, "## end server"); end != -1 {
+			conf = conf[:end]
+		}
+		return conf
+	}
+
+	conf := string(rt)
+	if !strings.Contains(serverConf(conf, "default-robots.example.com"), "location /robots.txt  {") {
+		t.Errorf("expected the default robots.txt location in the server default-robots.example.com")
+	}
+
+	custom := serverConf(conf, "custom-robots.example.com")
+	if strings.Contains(custom, "location /robots.txt  {") {
+		t.Errorf("expected no default robots.txt location in the server custom-robots.example.com")
+	}
+	if strings.Count(custom, "location /robots.txt") != 1 {
+		t.Errorf("expected only the robots.txt location of the server snippet in the server custom-robots.example.com")
+	}
+}
+
+func TestTemplateAuthFallbacks(t *testing.T) {
+	pwd, _ := os.Getwd()
+	data, err := os.ReadFile(path.Join(pwd, "../../../../test/data/config.json"))
+	if err != nil {
+		t.Fatalf("unexpected error reading json file: %v", err)
+	}
+	var dat config.TemplateConfig
+	if err := jsoniter.ConfigCompatibleWithStandardLibrary.Unmarshal(data, &dat); err != nil {
+		t.Fatalf("unexpected error unmarshalling json: %v", err)
+	}
+	if dat.ListenPorts == nil {
+		dat.ListenPorts = &config.ListenPorts{}
+	}
+	dat.Cfg.DefaultSSLCertificate = &ingress.SSLCert{}
+	dat.Servers = []*ingress.Server{
+		{
+			Hostname: "auth.example.com",
+			Locations: []*ingress.Location{
+				{
+					Path:    "/",
+					Backend: "auth-80",
+					ExternalAuth: authreq.Config{
+						URL:             "http://foo.com/auth",
+						Host:            "foo.com",
+						URLs:            []string{"http://foo.com/auth", "http://bar.com/auth"},
+						RequestRedirect: "http://foo.com/redirect",
+						AuthSnippet:     "proxy_set_header X-Snippet on;",
+						ProxySetHeaders: map[string]string{"X-Auth-Extra": "extra"},
+					},
+				},
+			},
+		},
+	}
+
+	ngxTpl, err := NewTemplate(nginx.TemplatePath)
+	if err != nil {
+		t.Fatalf("invalid NGINX template: %v", err)
+	}
+
+	rt, err := ngxTpl.Write(dat)
+	if err != nil {
+		t.Fatalf("invalid NGINX template: %v", err)
+	}
+
+	conf := string(rt)
+	start := strings.Index(conf, "location = /_external-auth-Lw-fallback-1 {")
+	if start == -1 {
+		t.Fatalf("expected the fallback authentication location in the configuration")
+	}
+	fallback := conf[start:]
+	fallback = fallback[:strings.Index(fallback, "\n        }\n")]
+
+	for _, directive := range []string{
+		"Host                    bar.com;",
+		"X-Forwarded-For",
+		"X-Auth-Request-Redirect http://foo.com/redirect;",
+		"proxy_set_header X-Snippet on;",
+		"proxy_buffer_size",
+		"set $target http://bar.com/auth;",
+	} {
+		if !strings.Contains(fallback, directive) {
+			t.Errorf("expected %q in the fallback authentication location but got:\n%v", directive, fallback)
+		}
+	}
+}
+
+func TestTemplateGlobalExternalAuth(t *testing.T) {
+	pwd, _ := os.Getwd()
+	data, err := os.ReadFile(path.Join(pwd, "../../../../test/data/config.json"))
+	if err != nil {
+		t.Fatalf("unexpected error reading json file: %v", err)
+	}
+	var dat config.TemplateConfig
+	if err := jsoniter.ConfigCompatibleWithStandardLibrary.Unmarshal(data, &dat); err != nil {
+		t.Fatalf("unexpected error unmarshalling json: %v", err)
+	}
+	if dat.ListenPorts == nil {
+		dat.ListenPorts = &config.ListenPorts{}
+	}
+	dat.Cfg.DefaultSSLCertificate = &ingress.SSLCert{}
+	dat.Cfg.GlobalExternalAuth = config.GlobalExternalAuth{
+		URL:               "http://foo.com/auth",
+		Host:              "foo.com",
+		AuthCacheDuration: []string{authreq.DefaultCacheDuration},
+	}
+	dat.Servers = []*ingress.Server{
+		{
+			Hostname: "auth.example.com",
+			Locations: []*ingress.Location{
+				{
+					Path:             "/",
+					Backend:          "auth-80",
+					EnableGlobalAuth: true,
+					ExternalAuth: authreq.Config{
+						ResponseCookies: []string{"session"},
+					},
+				},
+			},
+		},
+	}
+
+	ngxTpl, err := NewTemplate(nginx.TemplatePath)
+	if err != nil {
+		t.Fatalf("invalid NGINX template: %v", err)
+	}
+
+	rt, err := ngxTpl.Write(dat)
+	if err != nil {
+		t.Fatalf("invalid NGINX template: %v", err)
+	}
+
+	conf := string(rt)
+	if !strings.Contains(conf, "auth_request        /_external-auth-Lw;") {
+		t.Errorf("expected the global authentication in the configuration")
+	}
+	if strings.Contains(conf, "forward_auth_cookies") {
+		t.Errorf("unexpected location response cookies with the global authentication")
+	}
+}
+
+func TestBuildLargeClientHeaderBuffers(t *testing.T) {
+	testCases := map[string]struct {
+		buffers      string
+		maxURILength int
+		expected     string
+	}{
+		"without annotation":       {"4 8k", 0, ""},
+		"fits the global buffers":  {"4 8k", 4096, ""},
+		"larger than the buffers":  {"4 8k", 16384, "4 17k"},
+		"keeps the buffers number": {"8 16k", 32768, "8 33k"},
+		"megabyte buffers":         {"2 1m", 32768, ""},
+		"invalid global buffers":   {"invalid", 8192, "4 9k"},
+	}
+
+	for title, tc := range testCases {
+		t.Run(title, func(t *testing.T) {
+			cfg := config.Configuration{LargeClientHeaderBuffers: tc.buffers}
+			server := &ingress.Server{MaxURILength: tc.maxURILength}
+			if buffers := buildLargeClientHeaderBuffers(cfg, server); buffers != tc.expected {
+				t.Errorf("expected %q but got %q", tc.expected, buffers)
+			}
+		})
+	}
+}
+
+func TestTemplateMaxURILength(t *testing.T) {
+	pwd, _ := os.Getwd()
+	data, err := os.ReadFile(path.Join(pwd, "../../../../test/data/config.json"))
+	if err != nil {
+		t.Fatalf("unexpected error reading json file: %v", err)
+	}
+	var dat config.TemplateConfig
+	if err := jsoniter.ConfigCompatibleWithStandardLibrary.Unmarshal(data, &dat); err != nil {
+		t.Fatalf("unexpected error unmarshalling json: %v", err)
+	}
+	if dat.ListenPorts == nil {
+		dat.ListenPorts = &config.ListenPorts{}
+	}
+	dat.Cfg.DefaultSSLCertificate = &ingress.SSLCert{}
+	dat.Cfg.LargeClientHeaderBuffers = "4 8k"
+	dat.Servers = []*ingress.Server{
+		{
+			Hostname: "default.example.com",
+			Locations: []*ingress.Location{
+				{Path: "/", Backend: "default-default-80"},
+			},
+		},
+		{
+			Hostname:     "signed.example.com",
+			MaxURILength: 16384,
+			Locations: []*ingress.Location{
+				{Path: "/", Backend: "default-signed-80"},
+			},
+		},
+	}
+
+	ngxTpl, err := NewTemplate(nginx.TemplatePath)
+	if err != nil {
+		t.Fatalf("invalid NGINX template: %v", err)
+	}
+
+	rt, err := ngxTpl.Write(dat)
+	if err != nil {
+		t.Fatalf("invalid NGINX template: %v", err)
+	}
+
+	conf := string(rt)
+	start := strings.Index(conf, "## start server signed.example.com")
+	if start == -1 {
+		t.Fatalf("expected the server signed.example.com in the configuration")
+	}
+	server := conf[start:]
+	if end := strings.Index(server, "## end server"); end != -1 {
+		server = server[:end]
+	}
+
+	for _, directive := range []string{
+		"large_client_header_buffers             4 17k;",
+		`if ($request_uri ~ "^.{16384}.") {`,
+		"return 414;",
+	} {
+		if !strings.Contains(server, directive) {
+			t.Errorf("expected %q in the server signed.example.com", directive)
+		}
+	}
+
+	if strings.Count(conf, "return 414;") != 1 {
+		t.Errorf("expected the URI length guard only in the server signed.example.com")
+	}
+}
+
+func TestTemplateXForwardedProto(t *testing.T) {
+	pwd, _ := os.Getwd()
+	data, err := os.ReadFile(path.Join(pwd, "../../../../test/data/config.json"))
+	if err != nil {
+		t.Fatalf("unexpected error reading json file: %v", err)
+	}
+	var dat config.TemplateConfig
+	if err := jsoniter.ConfigCompatibleWithStandardLibrary.Unmarshal(data, &dat); err != nil {
+		t.Fatalf("unexpected error unmarshalling json: %v", err)
+	}
+	if dat.ListenPorts == nil {
+		dat.ListenPorts = &config.ListenPorts{}
+	}
+	dat.Cfg.DefaultSSLCertificate = &ingress.SSLCert{}
+	dat.Servers = []*ingress.Server{
+		{
+			Hostname: "default.example.com",
+			Locations: []*ingress.Location{
+				{Path: "/", Backend: "default-default-80"},
+			},
+		},
+		{
+			Hostname: "https.example.com",
+			Locations: []*ingress.Location{
+				{Path: "/", Backend: "default-https-80", XForwardedProto: "https"},
+			},
+		},
+		{
+			Hostname: "http.example.com",
+			Locations: []*ingress.Location{
+				{Path: "/", Backend: "default-http-80", XForwardedProto: "http"},
+			},
+		},
+	}
+
+	ngxTpl, err := NewTemplate(nginx.TemplatePath)
+	if err != nil {
+		t.Fatalf("invalid NGINX template: %v", err)
+	}
+
+	rt, err := ngxTpl.Write(dat)
+	if err != nil {
+		t.Fatalf("invalid NGINX template: %v", err)
+	}
+
+	conf := string(rt)
+	testCases := map[string]string{
+		"default.example.com": "$pass_access_scheme",
+		"https.example.com":   "https",
+		"http.example.com":    "http",
+	}
+	for host, expected := range testCases {
+		start := strings.Index(conf, "## start server "+host)
+		if start == -1 {
+			t.Fatalf("expected the server %v in the configuration", host)
+		}
+		server := conf[start:]
+		if end := strings.Index(server, "## end server"); end != -1 {
+			server = server[:end]
+		}
+
+		directive := "X-Forwarded-Proto      " + expected + ";"
+		if !strings.Contains(server, directive) {
+			t.Errorf("expected %q in the server %v", directive, host)
+		}
+		if strings.Count(server, "X-Forwarded-Proto      ") != 1 {
+			t.Errorf("expected a single X-Forwarded-Proto header in the server %v", host)
+		}
+	}
+}
+
+func TestTemplateBlockCountries(t *testing.T) {
+	pwd, _ := os.Getwd()
+	data, err := os.ReadFile(path.Join(pwd, "../../../../test/data/config.json"))
+	if err != nil {
+		t.Fatalf("unexpected error reading json file: %v", err)
+	}
+	var dat config.TemplateConfig
+	if err := jsoniter.ConfigCompatibleWithStandardLibrary.Unmarshal(data, &dat); err != nil {
+		t.Fatalf("unexpected error unmarshalling json: %v", err)
+	}
+	if dat.ListenPorts == nil {
+		dat.ListenPorts = &config.ListenPorts{}
+	}
+	dat.Cfg.DefaultSSLCertificate = &ingress.SSLCert{}
+	dat.Servers = []*ingress.Server{
+		{
+			Hostname: "example.com",
+			Locations: []*ingress.Location{
+				{Path: "/", Backend: "default-example-80"},
+			},
+		},
+	}
+
+	ngxTpl, err := NewTemplate(nginx.TemplatePath)
+	if err != nil {
+		t.Fatalf("invalid NGINX template: %v", err)
+	}
+
+	for _, useGeoIP2 := range []bool{true, false} {
+		dat.Cfg.UseGeoIP2 = useGeoIP2
+		dat.Cfg.BlockCountries = []string{"CN", "US"}
+
+		rt, err := ngxTpl.Write(dat)
+		if err != nil {
+			t.Fatalf("invalid NGINX template: %v", err)
+		}
+
+		conf := string(rt)
+		fragments := []string{
+			"map $geoip2_city_country_code $block_country {",
+			"CN 1;",
+			"US 1;",
+			"if ($block_country) {",
+		}
+		for _, fragment := range fragments {
+			if strings.Contains(conf, fragment) != useGeoIP2 {
+				t.Errorf("expected %q in the configuration to be %v when use-geoip2 is %v", fragment, useGeoIP2, useGeoIP2)
+			}
+		}
+	}
+}
+
+func TestTemplateProxyCacheLock(t *testing.T) {
+	pwd, _ := os.Getwd()
+	data, err := os.ReadFile(path.Join(pwd, "../../../../test/data/config.json"))
+	if err != nil {
+		t.Fatalf("unexpected error reading json file: %v", err)
+	}
+	var dat config.TemplateConfig
+	if err := jsoniter.ConfigCompatibleWithStandardLibrary.Unmarshal(data, &dat); err != nil {
+		t.Fatalf("unexpected error unmarshalling json: %v", err)
+	}
+	if dat.ListenPorts == nil {
+		dat.ListenPorts = &config.ListenPorts{}
+	}
+	dat.Cfg.DefaultSSLCertificate = &ingress.SSLCert{}
+	dat.Servers = []*ingress.Server{
+		{
+			Hostname: "cache.example.com",
+			Locations: []*ingress.Location{
+				{
+					Path:                 "/",
+					Backend:              "default-cache-80",
+					ConfigurationSnippet: "proxy_cache static;",
+					ProxyCacheLock:       proxycachelock.Config{Enabled: true, Timeout: "10s"},
+				},
+				{
+					Path:                 "/default-timeout",
+					Backend:              "default-cache-80",
+					ConfigurationSnippet: "proxy_cache static;",
+					ProxyCacheLock:       proxycachelock.Config{Enabled: true},
+				},
+				{
+					Path:    "/nocache",
+					Backend: "default-cache-80",
+				},
+			},
+		},
+	}
+
+	ngxTpl, err := NewTemplate(nginx.TemplatePath)
+	if err != nil {
+		t.Fatalf("invalid NGINX template: %v", err)
+	}
+
+	rt, err := ngxTpl.Write(dat)
+	if err != nil {
+		t.Fatalf("invalid NGINX template: %v", err)
+	}
+
+	conf := string(rt)
+	if c := strings.Count(conf, "proxy_cache_lock                        on;"); c != 2 {
+		t.Errorf("expected proxy_cache_lock in two locations but got %v", c)
+	}
+	if c := strings.Count(conf, "proxy_cache_lock_timeout                10s;"); c != 1 {
+		t.Errorf("expected proxy_cache_lock_timeout in one location but got %v", c)
+	}
+
+	start := strings.Index(conf, "location /nocache")
+	if start == -1 {
+		t.Fatalf("expected the location /nocache in the configuration")
+	}
+	if strings.Contains(conf[start:], "proxy_cache_lock") {
+		t.Errorf("expected no proxy_cache_lock in the location /nocache")
+	}
+}
+
+func TestTemplateBodyTooLarge(t *testing.T) {
+	pwd, _ := os.Getwd()
+	data, err := os.ReadFile(path.Join(pwd, "../../../../test/data/config.json"))
+	if err != nil {
+		t.Fatalf("unexpected error reading json file: %v", err)
+	}
+	var dat config.TemplateConfig
+	if err := jsoniter.ConfigCompatibleWithStandardLibrary.Unmarshal(data, &dat); err != nil {
+		t.Fatalf("unexpected error unmarshalling json: %v", err)
+	}
+	if dat.ListenPorts == nil {
+		dat.ListenPorts = &config.ListenPorts{}
+	}
+	dat.Cfg.DefaultSSLCertificate = &ingress.SSLCert{}
+
+	ngxTpl, err := NewTemplate(nginx.TemplatePath)
+	if err != nil {
+		t.Fatalf("invalid NGINX template: %v", err)
+	}
+
+	testCases := map[string]struct {
+		config     bodytoolarge.Config
+		expected   []string
+		unexpected []string
+	}{
+		"default": {
+			config:     bodytoolarge.Config{},
+			unexpected: []string{"lingering_close", "error_page 413"},
+		},
+		"413": {
+			config:     bodytoolarge.Config{Action: bodytoolarge.ActionReject},
+			unexpected: []string{"lingering_close", "error_page 413"},
+		},
+		"413-drain": {
+			config:     bodytoolarge.Config{Action: bodytoolarge.ActionDrain},
+			expected:   []string{"lingering_close                         always;"},
+			unexpected: []string{"error_page 413"},
+		},
+		"redirect": {
+			config: bodytoolarge.Config{Action: bodytoolarge.ActionRedirect, URL: "https://example.com/upload-limits"},
+			expected: []string{
+				"lingering_close                         always;",
+				"error_page 413 =303 https://example.com/upload-limits;",
+			},
+		},
+	}
+
+	for title, tc := range testCases {
+		t.Run(title, func(t *testing.T) {
+			dat.Servers = []*ingress.Server{
+				{
+					Hostname: "upload.example.com",
+					Locations: []*ingress.Location{
+						{Path: "/upload", Backend: "default-upload-80", BodyTooLarge: tc.config},
+					},
+				},
+			}
+
+			rt, err := ngxTpl.Write(dat)
+			if err != nil {
+				t.Fatalf("invalid NGINX template: %v", err)
+			}
+
+			conf := string(rt)
+			start := strings.Index(conf, "location /upload")
+			if start == -1 {
+				t.Fatalf("expected the location /upload in the configuration")
+			}
+			location := conf[start:]
+
+			for _, directive := range tc.expected {
+				if !strings.Contains(location, directive) {
+					t.Errorf("expected %q in the location /upload", directive)
+				}
+			}
+			for _, directive := range tc.unexpected {
+				if strings.Contains(location, directive) {
+					t.Errorf("unexpected %q in the location /upload", directive)
+				}
+			}
+		})
+	}
+}
+
+func TestTemplateProxyRealIPCIDR(t *testing.T) {
+	pwd, _ := os.Getwd()
+	data, err := os.ReadFile(path.Join(pwd, "../../../../test/data/config.json"))
+	if err != nil {
+		t.Fatalf("unexpected error reading json file: %v", err)
+	}
+
+	ngxTpl, err := NewTemplate(nginx.TemplatePath)
+	if err != nil {
+		t.Fatalf("invalid NGINX template: %v", err)
+	}
+
+	testCases := map[string]struct {
+		useForwardedHeaders bool
+		useProxyProtocol    bool
+		expected            bool
+	}{
+		"forwarded headers": {true, false, true},
+		"proxy protocol":    {false, true, true},
+		"real IP disabled":  {false, false, false},
+	}
+
+	for title, tc := range testCases {
+		t.Run(title, func(t *testing.T) {
+			var dat config.TemplateConfig
+			if err := jsoniter.ConfigCompatibleWithStandardLibrary.Unmarshal(data, &dat); err != nil {
+				t.Fatalf("unexpected error unmarshalling json: %v", err)
+			}
+			if dat.ListenPorts == nil {
+				dat.ListenPorts = &config.ListenPorts{}
+			}
+			dat.Cfg.DefaultSSLCertificate = &ingress.SSLCert{}
+			dat.Cfg.UseForwardedHeaders = tc.useForwardedHeaders
+			dat.Cfg.UseProxyProtocol = tc.useProxyProtocol
+			dat.Cfg.ProxyRealIPCIDR = []string{"0.0.0.0/0"}
+			dat.Servers = []*ingress.Server{
+				{
+					Hostname: "default.example.com",
+					Locations: []*ingress.Location{
+						{Path: "/", Backend: "default-default-80"},
+					},
+				},
+				{
+					Hostname:        "internal.example.com",
+					ProxyRealIPCIDR: []string{"10.0.0.0/8", "192.168.1.1"},
+					Locations: []*ingress.Location{
+						{Path: "/", Backend: "default-internal-80"},
+					},
+				},
+			}
+
+			rt, err := ngxTpl.Write(dat)
+			if err != nil {
+				t.Fatalf("invalid NGINX template: %v", err)
+			}
+
+			conf := string(rt)
+			start := strings.Index(conf, "## start server internal.example.com")
+			if start == -1 {
+				t.Fatalf("expected the server internal.example.com in the configuration")
+			}
+			server := conf[start:]
+			if end := strings.Index(server, "## end server"); end != -1 {
+				server = server[:end]
+			}
+
+			for _, directive := range []string{
+				"set_real_ip_from    10.0.0.0/8;",
+				"set_real_ip_from    192.168.1.1;",
+			} {
+				if strings.Contains(server, directive) != tc.expected {
+					t.Errorf("expected %q in the server internal.example.com: %v", directive, tc.expected)
+				}
+				if strings.Count(conf, directive) > 1 {
+					t.Errorf("expected %q only in the server internal.example.com", directive)
+				}
+			}
+			if strings.Contains(server, "set_real_ip_from    0.0.0.0/0;") {
+				t.Errorf("expected the global trusted proxies overridden in the server internal.example.com")
+			}
+		})
+	}
+}
+
+func TestTemplateUpstreamKeepalive(t *testing.T) {
+	pwd, _ := os.Getwd()
+	data, err := os.ReadFile(path.Join(pwd, "../../../../test/data/config.json"))
+	if err != nil {
+		t.Fatalf("unexpected error reading json file: %v", err)
+	}
+
+	ngxTpl, err := NewTemplate(nginx.TemplatePath)
+	if err != nil {
+		t.Fatalf("invalid NGINX template: %v", err)
+	}
+
+	var dat config.TemplateConfig
+	if err := jsoniter.ConfigCompatibleWithStandardLibrary.Unmarshal(data, &dat); err != nil {
+		t.Fatalf("unexpected error unmarshalling json: %v", err)
+	}
+	if dat.ListenPorts == nil {
+		dat.ListenPorts = &config.ListenPorts{}
+	}
+	dat.Cfg.DefaultSSLCertificate = &ingress.SSLCert{}
+	dat.Backends = []*ingress.Backend{
+		{Name: "default-default-80"},
+		{
+			Name:              "default-keepalive-80",
+			UpstreamKeepalive: upstreamkeepalive.Config{Connections: 16, Timeout: 30, Requests: 1000},
+		},
+	}
+	dat.Servers = []*ingress.Server{
+		{
+			Hostname: "default.example.com",
+			Locations: []*ingress.Location{
+				{Path: "/", Backend: "default-default-80"},
+			},
+		},
+		{
+			Hostname: "keepalive.example.com",
+			Locations: []*ingress.Location{
+				{Path: "/", Backend: "default-keepalive-80"},
+			},
+		},
+	}
+
+	rt, err := ngxTpl.Write(dat)
+	if err != nil {
+		t.Fatalf("invalid NGINX template: %v", err)
+	}
+
+	conf := string(rt)
+	start := strings.Index(conf, "upstream upstream_balancer_default-keepalive-80 {")
+	if start == -1 {
+		t.Fatalf("expected the upstream of the backend default-keepalive-80 in the configuration")
+	}
+	upstream := conf[start:]
+	upstream = upstream[:strings.Index(upstream, "\n    }\n")]
+	for _, directive := range []string{
+		"keepalive 16;",
+		"keepalive_timeout  30s;",
+		"keepalive_requests 1000;",
+	} {
+		if !strings.Contains(upstream, directive) {
+			t.Errorf("expected %q in the upstream of the backend default-keepalive-80", directive)
+		}
+	}
+	if strings.Contains(conf, "upstream upstream_balancer_default-default-80") {
+		t.Errorf("expected no dedicated upstream for the backend default-default-80")
+	}
+
+	for host, proxyPass := range map[string]string{
+		"default.example.com":   "proxy_pass http://upstream_balancer;",
+		"keepalive.example.com": "proxy_pass http://upstream_balancer_default-keepalive-80;",
+	} {
+		start := strings.Index(conf, "## start server "+host)
+		if start == -1 {
+			t.Fatalf("expected the server %v in the configuration", host)
+		}
+		server := conf[start:]
+		if end := strings.Index(server, "## end server"); end != -1 {
+			server = server[:end]
+		}
+		if !strings.Contains(server, proxyPass) {
+			t.Errorf("expected %q in the server %v", proxyPass, host)
+		}
+	}
+}
+
+func TestBuildDefaultBackendJSONErrors(t *testing.T) {
+	testCases := map[string]struct {
+		enabled        bool
+		customErrors   []int
+		locationErrors []int
+		backend        string
+		expected       []int
+	}{
+		"disabled":                  {false, nil, nil, "upstream-default-backend", nil},
+		"service backend":           {true, nil, nil, "default-http-svc-80", nil},
+		"default backend":           {true, nil, nil, "upstream-default-backend", []int{500, 502, 503, 504}},
+		"global custom http errors": {true, []int{404, 503}, nil, "upstream-default-backend", []int{500, 502, 504}},
+		"location custom errors":    {true, nil, []int{502}, "upstream-default-backend", []int{500, 503, 504}},
+		"all custom errors":         {true, []int{500, 502}, []int{503, 504}, "upstream-default-backend", nil},
+	}
+
+	for title, tc := range testCases {
+		cfg := config.NewDefault()
+		cfg.DefaultBackendJSONErrors = tc.enabled
+		cfg.CustomHTTPErrors = tc.customErrors
+		location := &ingress.Location{Backend: tc.backend, CustomHTTPErrors: tc.locationErrors}
+
+		codes := buildDefaultBackendJSONErrors(cfg, location)
+		if !reflect.DeepEqual(codes, tc.expected) {
+			t.Errorf("%v: expected the error codes %v but got %v", title, tc.expected, codes)
+		}
+	}
+}
+
+func TestTemplateDefaultBackendJSONErrors(t *testing.T) {
+	pwd, _ := os.Getwd()
+	data, err := os.ReadFile(path.Join(pwd, "../../../../test/data/config.json"))
+	if err != nil {
+		t.Fatalf("unexpected error reading json file: %v", err)
+	}
+
+	ngxTpl, err := NewTemplate(nginx.TemplatePath)
+	if err != nil {
+		t.Fatalf("invalid NGINX template: %v", err)
+	}
+
+	var dat config.TemplateConfig
+	if err := jsoniter.ConfigCompatibleWithStandardLibrary.Unmarshal(data, &dat); err != nil {
+		t.Fatalf("unexpected error unmarshalling json: %v", err)
+	}
+	if dat.ListenPorts == nil {
+		dat.ListenPorts = &config.ListenPorts{}
+	}
+	dat.Cfg.DefaultSSLCertificate = &ingress.SSLCert{}
+	dat.Cfg.DefaultBackendJSONErrors = true
+	dat.Cfg.DefBackendJSONErrorsType = "application/problem+json"
+	dat.Cfg.CustomHTTPErrors = []int{404, 503}
+	dat.Backends = []*ingress.Backend{{Name: "upstream-default-backend"}}
+	dat.Servers = []*ingress.Server{
+		{
+			Hostname: "_",
+			Locations: []*ingress.Location{
+				{Path: "/", Backend: "upstream-default-backend"},
+			},
+		},
+	}
+
+	rt, err := ngxTpl.Write(dat)
+	if err != nil {
+		t.Fatalf("invalid NGINX template: %v", err)
+	}
+
+	conf := string(rt)
+	for _, directive := range []string{
+		"error_page 404 = @custom_upstream-default-backend_404;",
+		"error_page 503 = @custom_upstream-default-backend_503;",
+		"error_page 500 502 504 @default_backend_json_error;",
+		"location @default_backend_json_error {",
+		"default_type application/problem+json;",
+		`return 503 '{"error":"$status","request_id":"$req_id"}';`,
+	} {
+		if !strings.Contains(conf, directive) {
+			t.Errorf("expected %q in the configuration", directive)
+		}
+	}
+}
@@ -158,4 +158,19 @@ type Backend struct {
 	// Sets the maximum temp file size when proxy-buffers capacity is exceeded.
 	// http://nginx.org/en/docs/http/ngx_http_proxy_module.html#proxy_max_temp_file_size
 	ProxyMaxTempFileSize string `json:"proxy-max-temp-file-size"`
+
+	// ReservedLocations defines additional locations defined in every server,
+	// e.g. by a custom template, that cannot be defined again using the
+	// server-snippet annotation. The built-in locations are checked against
+	// the snippet of the servers rendering them.
+	ReservedLocations []string `json:"reserved-locations"`
+
+	// OverrideReservedLocations allows the paths of the ingresses to replace
+	// the built-in robots.txt location of a server instead of being skipped
+	OverrideReservedLocations bool `json:"override-reserved-locations"`
+
+	// RejectUnsupportedAuthMethods rejects the external authentication with the
+	// methods CONNECT and TRACE, which the authentication subrequest cannot use,
+	// instead of sending the subrequest with the method GET
+	RejectUnsupportedAuthMethods bool `json:"reject-unsupported-auth-methods"`
 }
@@ -101,6 +101,13 @@ func IsInvalidContent(e error) bool {
 	return ok
 }
 
+// IsInvalidConfiguration checks if the err is an error which
+// indicates an annotation is not correctly configured
+func IsInvalidConfiguration(e error) bool {
+	_, ok := e.(InvalidConfiguration)
+	return ok
+}
+
 // New returns a new error
 func New(m string) error {
 	return errors.New(m)
@@ -50,3 +50,20 @@ func TestInvalidContent(t *testing.T) {
 		t.Error("expected false")
 	}
 }
+
+func TestInvalidConfiguration(t *testing.T) {
+	if IsInvalidConfiguration(ErrMissingAnnotations) {
+		t.Error("expected false")
+	}
+	err := NewInvalidAnnotationConfiguration("demo", "reason")
+	if !IsInvalidConfiguration(err) {
+		t.Error("expected true")
+	}
+	if IsInvalidConfiguration(nil) {
+		t.Error("expected false")
+	}
+	err = NewInvalidAnnotationContent("demo", "")
+	if IsInvalidConfiguration(err) {
+		t.Error("expected false")
+	}
+}
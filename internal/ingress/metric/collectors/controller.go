@@ -29,6 +29,7 @@ import (
 
 var (
 	operation        = []string{"controller_namespace", "controller_class", "controller_pod"}
+	reloadLabels     = []string{"controller_namespace", "controller_class", "controller_pod", "reason"}
 	ingressOperation = []string{"controller_namespace", "controller_class", "controller_pod", "namespace", "ingress"}
 	sslLabelHost     = []string{"namespace", "class", "host"}
 )
@@ -43,9 +44,14 @@ type Controller struct {
 
 	reloadOperation             *prometheus.CounterVec
 	reloadOperationErrors       *prometheus.CounterVec
+	reloadConsecutiveErrors     *prometheus.GaugeVec
+	configStuck                 *prometheus.GaugeVec
+	configStale                 *prometheus.GaugeVec
 	checkIngressOperation       *prometheus.CounterVec
 	checkIngressOperationErrors *prometheus.CounterVec
 	sslExpireTime               *prometheus.GaugeVec
+	sslExpireSoon               *prometheus.GaugeVec
+	serverLocations             *prometheus.GaugeVec
 
 	constLabels prometheus.Labels
 	labels      prometheus.Labels
@@ -55,11 +61,20 @@ type Controller struct {
 	ingressChecksumOperation       *prometheus.CounterVec
 	ingressChecksumOperationErrors *prometheus.GaugeVec
 	sslCertVerifyFail              *prometheus.CounterVec
+	sslCertLoadFail                *prometheus.CounterVec
+	sslCertEvicted                 *prometheus.CounterVec
 	ingressReferrerInvalid         *prometheus.CounterVec
 	canaryReferrerInvalid          *prometheus.CounterVec
 	canaryNumLimitExceeded         *prometheus.CounterVec
+	hostPathLimitExceeded          *prometheus.CounterVec
 	secretChecksumOperation        *prometheus.CounterVec
 	secretChecksumOperationErrors  *prometheus.GaugeVec
+	secretGrayInactive             *prometheus.CounterVec
+	secretGrayActive               *prometheus.CounterVec
+	unknownConfigKeys              *prometheus.CounterVec
+	streamServicesDropped          *prometheus.CounterVec
+	ingressesIgnored               *prometheus.CounterVec
+	streamServicesSkipped          *prometheus.GaugeVec
 }
 
 // NewController creates a new prometheus collector for the
@@ -107,7 +122,7 @@ func NewController(pod, namespace, class string) *Controller {
 				Name:      "success",
 				Help:      `Cumulative number of Ingress controller reload operations`,
 			},
-			operation,
+			reloadLabels,
 		),
 		reloadOperationErrors: prometheus.NewCounterVec(
 			prometheus.CounterOpts{
@@ -117,6 +132,30 @@ func NewController(pod, namespace, class string) *Controller {
 			},
 			operation,
 		),
+		reloadConsecutiveErrors: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: PrometheusNamespace,
+				Name:      "reload_consecutive_errors",
+				Help:      `Number of consecutive Ingress controller syncs that failed to reload the configuration`,
+			},
+			operation,
+		),
+		configStuck: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: PrometheusNamespace,
+				Name:      "config_stuck",
+				Help:      `Whether the configuration failed to reload for too many consecutive syncs, 1 indicates stuck`,
+			},
+			operation,
+		),
+		configStale: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: PrometheusNamespace,
+				Name:      "config_stale",
+				Help:      `Whether the last configuration failed to reload and the previous one is still live, 1 indicates stale`,
+			},
+			operation,
+		),
 		checkIngressOperationErrors: prometheus.NewCounterVec(
 			prometheus.CounterOpts{
 				Namespace: PrometheusNamespace,
@@ -142,6 +181,22 @@ func NewController(pod, namespace, class string) *Controller {
 			},
 			sslLabelHost,
 		),
+		sslExpireSoon: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: PrometheusNamespace,
+				Name:      "ssl_expire_soon",
+				Help:      `Whether the SSL Certificate expires within ssl-cert-expiry-warn-hours, 1 indicates it does, 0 indicates it does not`,
+			},
+			sslLabelHost,
+		),
+		serverLocations: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: PrometheusNamespace,
+				Name:      "server_locations",
+				Help:      `Number of locations of the server of a host in the running configuration`,
+			},
+			sslLabelHost,
+		),
 		leaderElection: prometheus.NewGaugeVec(
 			prometheus.GaugeOpts{
 				Namespace:   PrometheusNamespace,
@@ -175,6 +230,22 @@ func NewController(pod, namespace, class string) *Controller {
 			},
 			operation,
 		),
+		sslCertLoadFail: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: PrometheusNamespace,
+				Name:      "sslcert_load_fail",
+				Help:      `Cumulative number of certificates of Secrets that failed to load after every retry`,
+			},
+			operation,
+		),
+		sslCertEvicted: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: PrometheusNamespace,
+				Name:      "sslcert_evicted",
+				Help:      `Cumulative number of certificates evicted from the local store after not being referenced for the TTL`,
+			},
+			operation,
+		),
 		ingressReferrerInvalid: prometheus.NewCounterVec(
 			prometheus.CounterOpts{
 				Namespace: PrometheusNamespace,
@@ -199,6 +270,14 @@ func NewController(pod, namespace, class string) *Controller {
 			},
 			operation,
 		),
+		hostPathLimitExceeded: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: PrometheusNamespace,
+				Name:      "host_path_limit_exceeded",
+				Help:      `Cumulative number of Ingress paths skipped because their host exceeded the path limit`,
+			},
+			operation,
+		),
 		secretChecksumOperation: prometheus.NewCounterVec(
 			prometheus.CounterOpts{
 				Namespace: PrometheusNamespace,
@@ -215,14 +294,62 @@ func NewController(pod, namespace, class string) *Controller {
 			},
 			operation,
 		),
+		secretGrayInactive: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: PrometheusNamespace,
+				Name:      "secret_gray_inactive",
+				Help:      `Cumulative number of secrets held back as inactive gray during a rollout`,
+			},
+			operation,
+		),
+		secretGrayActive: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: PrometheusNamespace,
+				Name:      "secret_gray_active",
+				Help:      `Cumulative number of secrets synchronized as active gray during a rollout`,
+			},
+			operation,
+		),
+		unknownConfigKeys: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: PrometheusNamespace,
+				Name:      "config_unknown_keys",
+				Help:      `Cumulative number of unknown keys found in the configuration configmap`,
+			},
+			append(operation, "key"),
+		),
+		streamServicesDropped: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: PrometheusNamespace,
+				Name:      "stream_services_dropped",
+				Help:      `Cumulative number of TCP and UDP services dropped because their port is already used by another service of the same protocol`,
+			},
+			append(operation, "protocol"),
+		),
+		ingressesIgnored: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: PrometheusNamespace,
+				Name:      "ingresses_ignored",
+				Help:      `Cumulative number of ingresses ignored by the controller, by reason`,
+			},
+			append(operation, "reason"),
+		),
+		streamServicesSkipped: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: PrometheusNamespace,
+				Name:      "stream_services_skipped",
+				Help:      `Number of entries of the TCP and UDP services configmaps skipped in the last sync, by reason`,
+			},
+			append(operation, "protocol", "reason"),
+		),
 	}
 
 	return cm
 }
 
-// IncReloadCount increment the reload counter
-func (cm *Controller) IncReloadCount() {
-	cm.reloadOperation.With(cm.constLabels).Inc()
+// IncReloadCount increment the reload counter of the given reason
+func (cm *Controller) IncReloadCount(reason string) {
+	cm.reloadOperation.MustCurryWith(cm.constLabels).With(prometheus.Labels{"reason": reason}).Inc()
 }
 
 // IncReloadErrorCount increment the reload error counter
@@ -230,6 +357,29 @@ func (cm *Controller) IncReloadErrorCount() {
 	cm.reloadOperationErrors.With(cm.constLabels).Inc()
 }
 
+// SetReloadConsecutiveErrorCount sets the number of consecutive reload errors
+func (cm *Controller) SetReloadConsecutiveErrorCount(count int) {
+	cm.reloadConsecutiveErrors.With(cm.constLabels).Set(float64(count))
+}
+
+// SetConfigStuck sets whether the configuration is stuck after too many reload errors
+func (cm *Controller) SetConfigStuck(stuck bool) {
+	var v float64
+	if stuck {
+		v = 1
+	}
+	cm.configStuck.With(cm.constLabels).Set(v)
+}
+
+// SetConfigStale sets whether the previous configuration is still live after a failed reload
+func (cm *Controller) SetConfigStale(stale bool) {
+	var v float64
+	if stale {
+		v = 1
+	}
+	cm.configStale.With(cm.constLabels).Set(v)
+}
+
 // OnStartedLeading indicates the pod was elected as the leader
 func (cm *Controller) OnStartedLeading(electionID string) {
 	cm.leaderElection.WithLabelValues(electionID).Set(1.0)
@@ -280,18 +430,32 @@ func (cm Controller) Describe(ch chan<- *prometheus.Desc) {
 	cm.configSuccessTime.Describe(ch)
 	cm.reloadOperation.Describe(ch)
 	cm.reloadOperationErrors.Describe(ch)
+	cm.reloadConsecutiveErrors.Describe(ch)
+	cm.configStuck.Describe(ch)
+	cm.configStale.Describe(ch)
 	cm.checkIngressOperation.Describe(ch)
 	cm.checkIngressOperationErrors.Describe(ch)
 	cm.sslExpireTime.Describe(ch)
+	cm.sslExpireSoon.Describe(ch)
+	cm.serverLocations.Describe(ch)
 	cm.leaderElection.Describe(ch)
 	cm.ingressChecksumOperation.Describe(ch)
 	cm.ingressChecksumOperationErrors.Describe(ch)
 	cm.sslCertVerifyFail.Describe(ch)
+	cm.sslCertLoadFail.Describe(ch)
+	cm.sslCertEvicted.Describe(ch)
 	cm.ingressReferrerInvalid.Describe(ch)
 	cm.canaryReferrerInvalid.Describe(ch)
 	cm.canaryNumLimitExceeded.Describe(ch)
+	cm.hostPathLimitExceeded.Describe(ch)
 	cm.secretChecksumOperation.Describe(ch)
 	cm.secretChecksumOperationErrors.Describe(ch)
+	cm.secretGrayInactive.Describe(ch)
+	cm.secretGrayActive.Describe(ch)
+	cm.unknownConfigKeys.Describe(ch)
+	cm.streamServicesDropped.Describe(ch)
+	cm.ingressesIgnored.Describe(ch)
+	cm.streamServicesSkipped.Describe(ch)
 }
 
 // Collect implements the prometheus.Collector interface.
@@ -301,22 +465,37 @@ func (cm Controller) Collect(ch chan<- prometheus.Metric) {
 	cm.configSuccessTime.Collect(ch)
 	cm.reloadOperation.Collect(ch)
 	cm.reloadOperationErrors.Collect(ch)
+	cm.reloadConsecutiveErrors.Collect(ch)
+	cm.configStuck.Collect(ch)
+	cm.configStale.Collect(ch)
 	cm.checkIngressOperation.Collect(ch)
 	cm.checkIngressOperationErrors.Collect(ch)
 	cm.sslExpireTime.Collect(ch)
+	cm.sslExpireSoon.Collect(ch)
+	cm.serverLocations.Collect(ch)
 	cm.leaderElection.Collect(ch)
 	cm.ingressChecksumOperation.Collect(ch)
 	cm.ingressChecksumOperationErrors.Collect(ch)
 	cm.sslCertVerifyFail.Collect(ch)
+	cm.sslCertLoadFail.Collect(ch)
+	cm.sslCertEvicted.Collect(ch)
 	cm.ingressReferrerInvalid.Collect(ch)
 	cm.canaryReferrerInvalid.Collect(ch)
 	cm.canaryNumLimitExceeded.Collect(ch)
+	cm.hostPathLimitExceeded.Collect(ch)
 	cm.secretChecksumOperation.Collect(ch)
 	cm.secretChecksumOperationErrors.Collect(ch)
+	cm.secretGrayInactive.Collect(ch)
+	cm.secretGrayActive.Collect(ch)
+	cm.unknownConfigKeys.Collect(ch)
+	cm.streamServicesDropped.Collect(ch)
+	cm.ingressesIgnored.Collect(ch)
+	cm.streamServicesSkipped.Collect(ch)
 }
 
-// SetSSLExpireTime sets the expiration time of SSL Certificates
-func (cm *Controller) SetSSLExpireTime(servers []*ingress.Server) {
+// SetSSLExpireTime sets the expiration time of SSL Certificates and whether
+// they expire within warnThreshold
+func (cm *Controller) SetSSLExpireTime(servers []*ingress.Server, warnThreshold time.Duration) {
 	for _, s := range servers {
 		if s.Hostname != "" {
 			var sslCert *ingress.SSLCert
@@ -329,6 +508,12 @@ func (cm *Controller) SetSSLExpireTime(servers []*ingress.Server) {
 					labels["host"] = s.Hostname
 
 					cm.sslExpireTime.With(labels).Set(float64(sslCert.ExpireTime.Unix()))
+
+					expireSoon := 0.0
+					if sslCert.ExpireTime.Before(time.Now().Add(warnThreshold)) {
+						expireSoon = 1
+					}
+					cm.sslExpireSoon.With(labels).Set(expireSoon)
 					break
 				}
 			}
@@ -336,9 +521,39 @@ func (cm *Controller) SetSSLExpireTime(servers []*ingress.Server) {
 	}
 }
 
+// SetServerLocationCounts sets the number of locations of the server of each host
+func (cm *Controller) SetServerLocationCounts(servers []*ingress.Server) {
+	for _, s := range servers {
+		if s.Hostname == "" {
+			continue
+		}
+
+		cm.serverLocations.With(cm.hostLabels(s.Hostname)).Set(float64(len(s.Locations)))
+	}
+}
+
+// RemoveAllServerLocationMetrics removes the number of locations of every host
+func (cm *Controller) RemoveAllServerLocationMetrics() {
+	cm.serverLocations.Reset()
+}
+
+func (cm *Controller) hostLabels(host string) prometheus.Labels {
+	labels := make(prometheus.Labels, len(cm.labels)+1)
+	for k, v := range cm.labels {
+		labels[k] = v
+	}
+	labels["host"] = host
+
+	return labels
+}
+
 // RemoveMetrics removes metrics for hostnames not available anymore
 func (cm *Controller) RemoveMetrics(hosts []string, registry prometheus.Gatherer) {
 	cm.removeSSLExpireMetrics(true, hosts, registry)
+
+	for _, host := range hosts {
+		cm.serverLocations.Delete(cm.hostLabels(host))
+	}
 }
 
 // RemoveAllSSLExpireMetrics removes metrics for expiration of SSL Certificates
@@ -381,6 +596,7 @@ func (cm *Controller) removeSSLExpireMetrics(onlyDefinedHosts bool, hosts []stri
 
 			klog.V(2).Infof("Removing prometheus metric from gauge %v for host %v", metricName, host)
 			removed := cm.sslExpireTime.Delete(labels)
+			cm.sslExpireSoon.Delete(labels)
 			if !removed {
 				klog.V(2).Infof("metric %v for host %v with labels not removed: %v", metricName, host, labels)
 			}
@@ -408,6 +624,16 @@ func (cm *Controller) IncSSLCertVerifyFailCount() {
 	cm.sslCertVerifyFail.With(cm.constLabels).Inc()
 }
 
+// IncSSLCertLoadFailCount increment the counter of certificates that failed to load after every retry
+func (cm *Controller) IncSSLCertLoadFailCount() {
+	cm.sslCertLoadFail.With(cm.constLabels).Inc()
+}
+
+// IncSSLCertEvictedCount increment the counter of certificates evicted from the local store
+func (cm *Controller) IncSSLCertEvictedCount() {
+	cm.sslCertEvicted.With(cm.constLabels).Inc()
+}
+
 // IncIngReferInvalidCount increment the invalid referrer of ingress counter
 func (cm *Controller) IncIngReferInvalidCount() {
 	cm.ingressReferrerInvalid.With(cm.constLabels).Inc()
@@ -423,6 +649,11 @@ func (cm *Controller) IncCanaryNumLimitExCount() {
 	cm.canaryNumLimitExceeded.With(cm.constLabels).Inc()
 }
 
+// IncHostPathLimitExCount increment the host path limit exceeded counter
+func (cm *Controller) IncHostPathLimitExCount() {
+	cm.hostPathLimitExceeded.With(cm.constLabels).Inc()
+}
+
 // IncSecretChecksumCount increment the secret checksum counter
 func (cm *Controller) IncSecretChecksumCount() {
 	cm.secretChecksumOperation.With(cm.constLabels).Inc()
@@ -437,3 +668,60 @@ func (cm *Controller) IncSecretChecksumErrorCount() {
 func (cm *Controller) ClearSecretChecksumErrorCount() {
 	cm.secretChecksumOperationErrors.With(cm.constLabels).Set(0)
 }
+
+// IncSecretGrayInactiveCount increment the inactive gray secret counter
+func (cm *Controller) IncSecretGrayInactiveCount() {
+	cm.secretGrayInactive.With(cm.constLabels).Inc()
+}
+
+// IncSecretGrayActiveCount increment the active gray secret counter
+func (cm *Controller) IncSecretGrayActiveCount() {
+	cm.secretGrayActive.With(cm.constLabels).Inc()
+}
+
+// IncUnknownConfigKeyCount increment the counter of an unknown configmap key
+func (cm *Controller) IncUnknownConfigKeyCount(key string) {
+	labels := make(prometheus.Labels, len(cm.constLabels)+1)
+	for k, v := range cm.constLabels {
+		labels[k] = v
+	}
+	labels["key"] = key
+
+	cm.unknownConfigKeys.With(labels).Inc()
+}
+
+// IncStreamServiceDroppedCount increment the counter of dropped stream services
+func (cm *Controller) IncStreamServiceDroppedCount(protocol string) {
+	labels := make(prometheus.Labels, len(cm.constLabels)+1)
+	for k, v := range cm.constLabels {
+		labels[k] = v
+	}
+	labels["protocol"] = protocol
+
+	cm.streamServicesDropped.With(labels).Inc()
+}
+
+// IncIngressIgnoredCount increment the counter of ignored ingresses
+func (cm *Controller) IncIngressIgnoredCount(reason string) {
+	labels := make(prometheus.Labels, len(cm.constLabels)+1)
+	for k, v := range cm.constLabels {
+		labels[k] = v
+	}
+	labels["reason"] = reason
+
+	cm.ingressesIgnored.With(labels).Inc()
+}
+
+// SetStreamServicesSkipped sets the number of skipped stream services of a protocol by reason
+func (cm *Controller) SetStreamServicesSkipped(protocol string, skipped map[string]int) {
+	for reason, count := range skipped {
+		labels := make(prometheus.Labels, len(cm.constLabels)+2)
+		for k, v := range cm.constLabels {
+			labels[k] = v
+		}
+		labels["protocol"] = protocol
+		labels["reason"] = reason
+
+		cm.streamServicesSkipped.With(labels).Set(float64(count))
+	}
+}
@@ -49,12 +49,12 @@ func TestControllerCounters(t *testing.T) {
 		{
 			name: "single increase in reload count should return 1",
 			test: func(cm *Controller) {
-				cm.IncReloadCount()
+				cm.IncReloadCount("configmap")
 				cm.ConfigSuccess(0, true)
 			},
 			want: metadata + `
 				nginx_ingress_controller_config_last_reload_successful{controller_class="nginx",controller_namespace="default",controller_pod="pod"} 1
-				nginx_ingress_controller_success{controller_class="nginx",controller_namespace="default",controller_pod="pod"} 1
+				nginx_ingress_controller_success{controller_class="nginx",controller_namespace="default",controller_pod="pod",reason="configmap"} 1
 			`,
 			metrics: []string{"nginx_ingress_controller_config_last_reload_successful", "nginx_ingress_controller_success"},
 		},
@@ -70,6 +70,18 @@ func TestControllerCounters(t *testing.T) {
 			`,
 			metrics: []string{"nginx_ingress_controller_errors"},
 		},
+		{
+			name: "failed reload should set the stale configuration gauge",
+			test: func(cm *Controller) {
+				cm.SetConfigStale(true)
+			},
+			want: `
+				# HELP nginx_ingress_controller_config_stale Whether the last configuration failed to reload and the previous one is still live, 1 indicates stale
+				# TYPE nginx_ingress_controller_config_stale gauge
+				nginx_ingress_controller_config_stale{controller_class="nginx",controller_namespace="default",controller_pod="pod"} 1
+			`,
+			metrics: []string{"nginx_ingress_controller_config_stale"},
+		},
 		{
 			name: "should set SSL certificates metrics",
 			test: func(cm *Controller) {
@@ -95,7 +107,7 @@ func TestControllerCounters(t *testing.T) {
 						},
 					},
 				}
-				cm.SetSSLExpireTime(servers)
+				cm.SetSSLExpireTime(servers, 240*time.Hour)
 			},
 			want: `
 				# HELP nginx_ingress_controller_ssl_expire_time_seconds Number of seconds since 1970 to the SSL Certificate expire.\n			An example to check if this certificate will expire in 10 days is: "nginx_ingress_controller_ssl_expire_time_seconds < (time() + (10 * 24 * 3600))"
@@ -104,6 +116,97 @@ func TestControllerCounters(t *testing.T) {
 			`,
 			metrics: []string{"nginx_ingress_controller_ssl_expire_time_seconds"},
 		},
+		{
+			name: "should set SSL certificates expiring soon metrics",
+			test: func(cm *Controller) {
+				servers := []*ingress.Server{
+					{
+						Hostname: "soon",
+						SSLCerts: []*ingress.SSLCert{
+							{
+								ExpireTime: time.Now().Add(48 * time.Hour),
+							},
+						},
+					},
+					{
+						Hostname: "later",
+						SSLCerts: []*ingress.SSLCert{
+							{
+								ExpireTime: time.Now().Add(96 * time.Hour),
+							},
+						},
+					},
+				}
+				cm.SetSSLExpireTime(servers, 72*time.Hour)
+			},
+			want: `
+				# HELP nginx_ingress_controller_ssl_expire_soon Whether the SSL Certificate expires within ssl-cert-expiry-warn-hours, 1 indicates it does, 0 indicates it does not
+				# TYPE nginx_ingress_controller_ssl_expire_soon gauge
+				nginx_ingress_controller_ssl_expire_soon{class="nginx",host="later",namespace="default"} 0
+				nginx_ingress_controller_ssl_expire_soon{class="nginx",host="soon",namespace="default"} 1
+			`,
+			metrics: []string{"nginx_ingress_controller_ssl_expire_soon"},
+		},
+		{
+			name: "should count the ignored ingresses by reason",
+			test: func(cm *Controller) {
+				cm.IncIngressIgnoredCount("class")
+				cm.IncIngressIgnoredCount("class")
+				cm.IncIngressIgnoredCount("catch-all-disabled")
+			},
+			want: `
+				# HELP nginx_ingress_controller_ingresses_ignored Cumulative number of ingresses ignored by the controller, by reason
+				# TYPE nginx_ingress_controller_ingresses_ignored counter
+				nginx_ingress_controller_ingresses_ignored{controller_class="nginx",controller_namespace="default",controller_pod="pod",reason="catch-all-disabled"} 1
+				nginx_ingress_controller_ingresses_ignored{controller_class="nginx",controller_namespace="default",controller_pod="pod",reason="class"} 2
+			`,
+			metrics: []string{"nginx_ingress_controller_ingresses_ignored"},
+		},
+		{
+			name: "should set the skipped stream services by reason",
+			test: func(cm *Controller) {
+				cm.SetStreamServicesSkipped("TCP", map[string]int{"bad-port": 2, "no-endpoints": 1})
+				cm.SetStreamServicesSkipped("TCP", map[string]int{"bad-port": 0, "no-endpoints": 1})
+			},
+			want: `
+				# HELP nginx_ingress_controller_stream_services_skipped Number of entries of the TCP and UDP services configmaps skipped in the last sync, by reason
+				# TYPE nginx_ingress_controller_stream_services_skipped gauge
+				nginx_ingress_controller_stream_services_skipped{controller_class="nginx",controller_namespace="default",controller_pod="pod",protocol="TCP",reason="bad-port"} 0
+				nginx_ingress_controller_stream_services_skipped{controller_class="nginx",controller_namespace="default",controller_pod="pod",protocol="TCP",reason="no-endpoints"} 1
+			`,
+			metrics: []string{"nginx_ingress_controller_stream_services_skipped"},
+		},
+		{
+			name: "should set the number of locations of the servers",
+			test: func(cm *Controller) {
+				servers := []*ingress.Server{
+					{
+						Hostname: "demo",
+						Locations: []*ingress.Location{
+							{Path: "/"},
+							{Path: "/api"},
+						},
+					},
+					{
+						Hostname:  "empty",
+						Locations: []*ingress.Location{},
+					},
+					{
+						Locations: []*ingress.Location{
+							{Path: "/"},
+						},
+					},
+				}
+				cm.SetServerLocationCounts(servers)
+			},
+			want: `
+				# HELP nginx_ingress_controller_server_locations Number of locations of the server of a host in the running configuration
+				# TYPE nginx_ingress_controller_server_locations gauge
+				nginx_ingress_controller_server_locations{class="nginx",host="demo",namespace="default"} 2
+				nginx_ingress_controller_server_locations{class="nginx",host="empty",namespace="default"} 0
+			`,
+			metrics: []string{"nginx_ingress_controller_server_locations"},
+		},
 	}
 
 	for _, c := range cases {
@@ -154,7 +257,7 @@ func TestRemoveMetrics(t *testing.T) {
 			},
 		},
 	}
-	cm.SetSSLExpireTime(servers)
+	cm.SetSSLExpireTime(servers, 240*time.Hour)
 
 	cm.RemoveMetrics([]string{"demo"}, reg)
 
@@ -164,3 +267,42 @@ func TestRemoveMetrics(t *testing.T) {
 
 	reg.Unregister(cm)
 }
+
+func TestRemoveServerLocationMetrics(t *testing.T) {
+	cm := NewController("pod", "default", "nginx")
+	reg := prometheus.NewPedanticRegistry()
+	if err := reg.Register(cm); err != nil {
+		t.Errorf("registering collector failed: %s", err)
+	}
+
+	servers := []*ingress.Server{
+		{
+			Hostname:  "demo",
+			Locations: []*ingress.Location{{Path: "/"}},
+		},
+		{
+			Hostname:  "stale",
+			Locations: []*ingress.Location{{Path: "/"}},
+		},
+	}
+	cm.SetServerLocationCounts(servers)
+
+	cm.RemoveMetrics([]string{"stale"}, reg)
+
+	want := `
+		# HELP nginx_ingress_controller_server_locations Number of locations of the server of a host in the running configuration
+		# TYPE nginx_ingress_controller_server_locations gauge
+		nginx_ingress_controller_server_locations{class="nginx",host="demo",namespace="default"} 1
+	`
+	if err := GatherAndCompare(cm, want, []string{"nginx_ingress_controller_server_locations"}, reg); err != nil {
+		t.Errorf("unexpected collecting result:\n%s", err)
+	}
+
+	cm.RemoveAllServerLocationMetrics()
+
+	if err := GatherAndCompare(cm, "", []string{"nginx_ingress_controller_server_locations"}, reg); err != nil {
+		t.Errorf("unexpected collecting result:\n%s", err)
+	}
+
+	reg.Unregister(cm)
+}
@@ -54,6 +54,10 @@ type socketData struct {
 	Ingress   string `json:"ingress"`
 	Service   string `json:"service"`
 	Path      string `json:"path"`
+
+	// StreamPort is the port of the stream (L4) service that rejected a
+	// connection exceeding its conn-limit, empty for HTTP requests
+	StreamPort string `json:"streamPort"`
 }
 
 // SocketCollector stores prometheus metrics and ingress meta-data
@@ -71,13 +75,21 @@ type SocketCollector struct {
 
 	requests *prometheus.CounterVec
 
+	streamConnRejected *prometheus.CounterVec
+
 	listener net.Listener
 
 	metricMapping map[string]interface{}
 
 	hosts sets.Set[string]
 
+	// tenants maps the hostnames to the tenant their metrics are attributed to
+	tenants map[string]string
+
 	metricsPerHost bool
+
+	metricsPerTenant bool
+	maxTenants       int
 }
 
 var (
@@ -93,13 +105,16 @@ var (
 	}
 )
 
+// overflowTenant is the tenant of the hostnames exceeding the maximum number of tenants
+const overflowTenant = "other"
+
 // DefObjectives was removed in https://github.com/prometheus/client_golang/pull/262
 // updating the library to latest version changed the output of the metrics
 var defObjectives = map[float64]float64{0.5: 0.05, 0.9: 0.01, 0.99: 0.001}
 
 // NewSocketCollector creates a new SocketCollector instance using
 // the ingress watch namespace and class used by the controller
-func NewSocketCollector(pod, namespace, class string, metricsPerHost bool) (*SocketCollector, error) {
+func NewSocketCollector(pod, namespace, class string, metricsPerHost, metricsPerTenant bool, maxTenants int) (*SocketCollector, error) {
 	socket := "/tmp/prometheus-nginx.socket"
 	// unix sockets must be unlink()ed before being used
 	_ = syscall.Unlink(socket)
@@ -125,11 +140,20 @@ func NewSocketCollector(pod, namespace, class string, metricsPerHost bool) (*Soc
 		requestTags = append(requestTags, "host")
 	}
 
+	collectorTags := []string{"ingress", "namespace", "status", "service"}
+	if metricsPerTenant {
+		requestTags = append(requestTags, "tenant")
+		collectorTags = append(collectorTags, "tenant")
+	}
+
 	sc := &SocketCollector{
 		listener: listener,
 
 		metricsPerHost: metricsPerHost,
 
+		metricsPerTenant: metricsPerTenant,
+		maxTenants:       maxTenants,
+
 		responseTime: prometheus.NewHistogramVec(
 			prometheus.HistogramOpts{
 				Name:        "response_duration_seconds",
@@ -175,7 +199,7 @@ func NewSocketCollector(pod, namespace, class string, metricsPerHost bool) (*Soc
 				Namespace:   PrometheusNamespace,
 				ConstLabels: constLabels,
 			},
-			[]string{"ingress", "namespace", "status", "service"},
+			collectorTags,
 		),
 		bytesSent: prometheus.NewHistogramVec(
 			prometheus.HistogramOpts{
@@ -187,6 +211,15 @@ func NewSocketCollector(pod, namespace, class string, metricsPerHost bool) (*Soc
 			},
 			requestTags,
 		),
+		streamConnRejected: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name:        "stream_connections_rejected",
+				Help:        "The number of connections to stream services rejected by the conn-limit of the service.",
+				Namespace:   PrometheusNamespace,
+				ConstLabels: constLabels,
+			},
+			[]string{"namespace", "service", "port"},
+		),
 		upstreamLatency: prometheus.NewSummaryVec(
 			prometheus.SummaryOpts{
 				Name:        "ingress_upstream_latency_seconds",
@@ -225,6 +258,20 @@ func (sc *SocketCollector) handleMessage(msg []byte) {
 	}
 
 	for _, stats := range statsBatch {
+		if stats.StreamPort != "" {
+			rejectedMetric, err := sc.streamConnRejected.GetMetricWith(prometheus.Labels{
+				"namespace": stats.Namespace,
+				"service":   stats.Service,
+				"port":      stats.StreamPort,
+			})
+			if err != nil {
+				klog.Errorf("Error fetching stream connections rejected metric: %v", err)
+			} else {
+				rejectedMetric.Inc()
+			}
+			continue
+		}
+
 		if !sc.hosts.Has(stats.Host) {
 			klog.V(3).Infof("skiping metric for host %v that is not being served", stats.Host)
 			continue
@@ -250,6 +297,12 @@ func (sc *SocketCollector) handleMessage(msg []byte) {
 			"service":   stats.Service,
 		}
 
+		if sc.metricsPerTenant {
+			tenant := sc.tenants[stats.Host]
+			requestLabels["tenant"] = tenant
+			collectorLabels["tenant"] = tenant
+		}
+
 		latencyLabels := prometheus.Labels{
 			"namespace": stats.Namespace,
 			"ingress":   stats.Ingress,
@@ -406,6 +459,7 @@ func (sc SocketCollector) Describe(ch chan<- *prometheus.Desc) {
 
 	sc.requests.Describe(ch)
 	sc.upstreamLatency.Describe(ch)
+	sc.streamConnRejected.Describe(ch)
 
 	sc.responseTime.Describe(ch)
 	sc.responseLength.Describe(ch)
@@ -420,6 +474,7 @@ func (sc SocketCollector) Collect(ch chan<- prometheus.Metric) {
 
 	sc.requests.Collect(ch)
 	sc.upstreamLatency.Collect(ch)
+	sc.streamConnRejected.Collect(ch)
 
 	sc.responseTime.Collect(ch)
 	sc.responseLength.Collect(ch)
@@ -433,6 +488,41 @@ func (sc *SocketCollector) SetHosts(hosts sets.Set[string]) {
 	sc.hosts = hosts
 }
 
+// SetHostTenants sets the tenant the metrics of each hostname are attributed to.
+// To bound the cardinality of the metrics, the hostnames of the tenants exceeding
+// the maximum number of tenants are attributed to the "other" tenant
+func (sc *SocketCollector) SetHostTenants(hostTenants map[string]string) {
+	if !sc.metricsPerTenant {
+		return
+	}
+
+	tenants := sets.New[string]()
+	for _, tenant := range hostTenants {
+		tenants.Insert(tenant)
+	}
+
+	allowed := sets.New[string]()
+	for _, tenant := range sets.List(tenants) {
+		if sc.maxTenants > 0 && allowed.Len() >= sc.maxTenants {
+			klog.Warningf("Number of metrics tenants %v exceeds the maximum of %v, tenant %q is reported as %q",
+				tenants.Len(), sc.maxTenants, tenant, overflowTenant)
+			continue
+		}
+		allowed.Insert(tenant)
+	}
+
+	bounded := make(map[string]string, len(hostTenants))
+	for host, tenant := range hostTenants {
+		if allowed.Has(tenant) {
+			bounded[host] = tenant
+		} else {
+			bounded[host] = overflowTenant
+		}
+	}
+
+	sc.tenants = bounded
+}
+
 // handleMessages process the content received in a network connection
 func handleMessages(conn io.ReadCloser, fn func([]byte)) {
 	defer conn.Close()
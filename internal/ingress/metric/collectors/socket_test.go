@@ -19,6 +19,7 @@ package collectors
 import (
 	"fmt"
 	"net"
+	"reflect"
 	"sync/atomic"
 	"testing"
 	"time"
@@ -282,13 +283,34 @@ func TestCollector(t *testing.T) {
 			wantAfter: `
 			`,
 		},
+
+		{
+			name: "rejected stream connections should be counted per service",
+			data: []string{`[
+			{
+				"namespace":"default",
+				"service":"ssh",
+				"streamPort":"2222"
+			},
+			{
+				"namespace":"default",
+				"service":"ssh",
+				"streamPort":"2222"
+			}]`},
+			metrics: []string{"nginx_ingress_controller_stream_connections_rejected"},
+			wantBefore: `
+				# HELP nginx_ingress_controller_stream_connections_rejected The number of connections to stream services rejected by the conn-limit of the service.
+				# TYPE nginx_ingress_controller_stream_connections_rejected counter
+				nginx_ingress_controller_stream_connections_rejected{controller_class="ingress",controller_namespace="default",controller_pod="pod",namespace="default",port="2222",service="ssh"} 2
+			`,
+		},
 	}
 
 	for _, c := range cases {
 		t.Run(c.name, func(t *testing.T) {
 			registry := prometheus.NewPedanticRegistry()
 
-			sc, err := NewSocketCollector("pod", "default", "ingress", true)
+			sc, err := NewSocketCollector("pod", "default", "ingress", true, false, 0)
 			if err != nil {
 				t.Errorf("%v: unexpected error creating new SocketCollector: %v", c.name, err)
 			}
@@ -322,3 +344,28 @@ func TestCollector(t *testing.T) {
 		})
 	}
 }
+
+func TestSetHostTenants(t *testing.T) {
+	sc := &SocketCollector{
+		metricsPerTenant: true,
+		maxTenants:       2,
+	}
+
+	sc.SetHostTenants(map[string]string{
+		"a.example.com": "team-a",
+		"b.example.com": "team-b",
+		"c.example.com": "team-c",
+		"d.example.com": "team-a",
+	})
+
+	expected := map[string]string{
+		"a.example.com": "team-a",
+		"b.example.com": "team-b",
+		"c.example.com": overflowTenant,
+		"d.example.com": "team-a",
+	}
+
+	if !reflect.DeepEqual(sc.tenants, expected) {
+		t.Errorf("expected tenants %v but got %v", expected, sc.tenants)
+	}
+}
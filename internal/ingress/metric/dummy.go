@@ -18,6 +18,8 @@ limitations under the License.
 package metric
 
 import (
+	"time"
+
 	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/ingress-nginx/internal/ingress"
 )
@@ -34,11 +36,20 @@ type DummyCollector struct{}
 func (dc DummyCollector) ConfigSuccess(uint64, bool) {}
 
 // IncReloadCount ...
-func (dc DummyCollector) IncReloadCount() {}
+func (dc DummyCollector) IncReloadCount(string) {}
 
 // IncReloadErrorCount ...
 func (dc DummyCollector) IncReloadErrorCount() {}
 
+// SetReloadConsecutiveErrorCount ...
+func (dc DummyCollector) SetReloadConsecutiveErrorCount(int) {}
+
+// SetConfigStuck ...
+func (dc DummyCollector) SetConfigStuck(bool) {}
+
+// SetConfigStale ...
+func (dc DummyCollector) SetConfigStale(bool) {}
+
 // IncCheckCount ...
 func (dc DummyCollector) IncCheckCount(string, string) {}
 
@@ -55,11 +66,17 @@ func (dc DummyCollector) Start() {}
 func (dc DummyCollector) Stop() {}
 
 // SetSSLExpireTime ...
-func (dc DummyCollector) SetSSLExpireTime([]*ingress.Server) {}
+func (dc DummyCollector) SetSSLExpireTime([]*ingress.Server, time.Duration) {}
+
+// SetServerLocationCounts ...
+func (dc DummyCollector) SetServerLocationCounts([]*ingress.Server) {}
 
 // SetHosts ...
 func (dc DummyCollector) SetHosts(hosts sets.Set[string]) {}
 
+// SetHostTenants ...
+func (dc DummyCollector) SetHostTenants(hostTenants map[string]string) {}
+
 // OnStartedLeading indicates the pod is not the current leader
 func (dc DummyCollector) OnStartedLeading(electionID string) {}
 
@@ -78,6 +95,12 @@ func (dc DummyCollector) ClearIngChecksumErrorCount() {}
 // IncSSLCertVerifyFailCount ...
 func (dc DummyCollector) IncSSLCertVerifyFailCount() {}
 
+// IncSSLCertLoadFailCount ...
+func (dc DummyCollector) IncSSLCertLoadFailCount() {}
+
+// IncSSLCertEvictedCount ...
+func (dc DummyCollector) IncSSLCertEvictedCount() {}
+
 // IncIngReferInvalidCount ...
 func (dc DummyCollector) IncIngReferInvalidCount() {}
 
@@ -87,6 +110,9 @@ func (dc DummyCollector) IncCanaryReferInvalidCount() {}
 // IncCanaryNumLimitExCount ...
 func (dc DummyCollector) IncCanaryNumLimitExCount() {}
 
+// IncHostPathLimitExCount ...
+func (dc DummyCollector) IncHostPathLimitExCount() {}
+
 // IncSecretChecksumCount ...
 func (dc DummyCollector) IncSecretChecksumCount() {}
 
@@ -95,3 +121,21 @@ func (dc DummyCollector) IncSecretChecksumErrorCount() {}
 
 // ClearSecretChecksumErrorCount ...
 func (dc DummyCollector) ClearSecretChecksumErrorCount() {}
+
+// IncSecretGrayInactiveCount ...
+func (dc DummyCollector) IncSecretGrayInactiveCount() {}
+
+// IncSecretGrayActiveCount ...
+func (dc DummyCollector) IncSecretGrayActiveCount() {}
+
+// IncUnknownConfigKeyCount ...
+func (dc DummyCollector) IncUnknownConfigKeyCount(string) {}
+
+// IncStreamServiceDroppedCount ...
+func (dc DummyCollector) IncStreamServiceDroppedCount(string) {}
+
+// IncIngressIgnoredCount ...
+func (dc DummyCollector) IncIngressIgnoredCount(string) {}
+
+// SetStreamServicesSkipped ...
+func (dc DummyCollector) SetStreamServicesSkipped(string, map[string]int) {}
@@ -35,8 +35,11 @@ import (
 type Collector interface {
 	ConfigSuccess(uint64, bool)
 
-	IncReloadCount()
+	IncReloadCount(string)
 	IncReloadErrorCount()
+	SetReloadConsecutiveErrorCount(int)
+	SetConfigStuck(bool)
+	SetConfigStale(bool)
 
 	OnStartedLeading(string)
 	OnStoppedLeading(string)
@@ -48,20 +51,35 @@ type Collector interface {
 	IncIngChecksumErrorCount()
 	ClearIngChecksumErrorCount()
 	IncSSLCertVerifyFailCount()
+	IncSSLCertLoadFailCount()
+	IncSSLCertEvictedCount()
 	IncIngReferInvalidCount()
 	IncCanaryReferInvalidCount()
 	IncCanaryNumLimitExCount()
+	IncHostPathLimitExCount()
 	IncSecretChecksumCount()
 	IncSecretChecksumErrorCount()
 	ClearSecretChecksumErrorCount()
+	IncSecretGrayInactiveCount()
+	IncSecretGrayActiveCount()
+	IncUnknownConfigKeyCount(string)
+	IncStreamServiceDroppedCount(string)
+	IncIngressIgnoredCount(string)
+	SetStreamServicesSkipped(string, map[string]int)
 
 	RemoveMetrics(ingresses, endpoints []string)
 
-	SetSSLExpireTime([]*ingress.Server)
+	SetSSLExpireTime([]*ingress.Server, time.Duration)
+
+	// SetServerLocationCounts sets the number of locations of the server of each host
+	SetServerLocationCounts([]*ingress.Server)
 
 	// SetHosts sets the hostnames that are being served by the ingress controller
 	SetHosts(set sets.Set[string])
 
+	// SetHostTenants sets the tenant the metrics of each hostname are attributed to
+	SetHostTenants(hostTenants map[string]string)
+
 	Start()
 	Stop()
 }
@@ -78,7 +96,7 @@ type collector struct {
 }
 
 // NewCollector creates a new metric collector the for ingress controller
-func NewCollector(metricsPerHost bool, registry *prometheus.Registry) (Collector, error) {
+func NewCollector(metricsPerHost, metricsPerTenant bool, maxTenants int, registry *prometheus.Registry) (Collector, error) {
 	podNamespace := os.Getenv("POD_NAMESPACE")
 	if podNamespace == "" {
 		podNamespace = "default"
@@ -86,22 +104,22 @@ func NewCollector(metricsPerHost bool, registry *prometheus.Registry) (Collector
 
 	podName := os.Getenv("POD_NAME")
 
-	nc, err := collectors.NewNGINXStatus(podName, podNamespace, class.IngressClass)
+	nc, err := collectors.NewNGINXStatus(podName, podNamespace, class.Canonical())
 	if err != nil {
 		return nil, err
 	}
 
-	pc, err := collectors.NewNGINXProcess(podName, podNamespace, class.IngressClass)
+	pc, err := collectors.NewNGINXProcess(podName, podNamespace, class.Canonical())
 	if err != nil {
 		return nil, err
 	}
 
-	s, err := collectors.NewSocketCollector(podName, podNamespace, class.IngressClass, metricsPerHost)
+	s, err := collectors.NewSocketCollector(podName, podNamespace, class.Canonical(), metricsPerHost, metricsPerTenant, maxTenants)
 	if err != nil {
 		return nil, err
 	}
 
-	ic := collectors.NewController(podName, podNamespace, class.IngressClass)
+	ic := collectors.NewController(podName, podNamespace, class.Canonical())
 
 	return Collector(&collector{
 		nginxStatus:  nc,
@@ -127,14 +145,26 @@ func (c *collector) IncCheckErrorCount(namespace string, name string) {
 	c.ingressController.IncCheckErrorCount(namespace, name)
 }
 
-func (c *collector) IncReloadCount() {
-	c.ingressController.IncReloadCount()
+func (c *collector) IncReloadCount(reason string) {
+	c.ingressController.IncReloadCount(reason)
 }
 
 func (c *collector) IncReloadErrorCount() {
 	c.ingressController.IncReloadErrorCount()
 }
 
+func (c *collector) SetReloadConsecutiveErrorCount(count int) {
+	c.ingressController.SetReloadConsecutiveErrorCount(count)
+}
+
+func (c *collector) SetConfigStuck(stuck bool) {
+	c.ingressController.SetConfigStuck(stuck)
+}
+
+func (c *collector) SetConfigStale(stale bool) {
+	c.ingressController.SetConfigStale(stale)
+}
+
 func (c *collector) RemoveMetrics(ingresses, hosts []string) {
 	c.socket.RemoveMetrics(ingresses, c.registry)
 	c.ingressController.RemoveMetrics(hosts, c.registry)
@@ -167,19 +197,31 @@ func (c *collector) Stop() {
 	c.socket.Stop()
 }
 
-func (c *collector) SetSSLExpireTime(servers []*ingress.Server) {
+func (c *collector) SetSSLExpireTime(servers []*ingress.Server, warnThreshold time.Duration) {
 	if !isLeader() {
 		return
 	}
 
 	klog.V(2).Infof("Updating ssl expiration metrics.")
-	c.ingressController.SetSSLExpireTime(servers)
+	c.ingressController.SetSSLExpireTime(servers, warnThreshold)
+}
+
+func (c *collector) SetServerLocationCounts(servers []*ingress.Server) {
+	if !isLeader() {
+		return
+	}
+
+	c.ingressController.SetServerLocationCounts(servers)
 }
 
 func (c *collector) SetHosts(hosts sets.Set[string]) {
 	c.socket.SetHosts(hosts)
 }
 
+func (c *collector) SetHostTenants(hostTenants map[string]string) {
+	c.socket.SetHostTenants(hostTenants)
+}
+
 // OnStartedLeading indicates the pod was elected as the leader
 func (c *collector) OnStartedLeading(electionID string) {
 	setLeader(true)
@@ -191,6 +233,7 @@ func (c *collector) OnStoppedLeading(electionID string) {
 	setLeader(false)
 	c.ingressController.OnStoppedLeading(electionID)
 	c.ingressController.RemoveAllSSLExpireMetrics(c.registry)
+	c.ingressController.RemoveAllServerLocationMetrics()
 }
 
 var (
@@ -226,6 +269,14 @@ func (c *collector) IncSSLCertVerifyFailCount() {
 	c.ingressController.IncSSLCertVerifyFailCount()
 }
 
+func (c *collector) IncSSLCertLoadFailCount() {
+	c.ingressController.IncSSLCertLoadFailCount()
+}
+
+func (c *collector) IncSSLCertEvictedCount() {
+	c.ingressController.IncSSLCertEvictedCount()
+}
+
 func (c *collector) IncIngReferInvalidCount() {
 	c.ingressController.IncIngReferInvalidCount()
 }
@@ -238,6 +289,10 @@ func (c *collector) IncCanaryNumLimitExCount() {
 	c.ingressController.IncCanaryNumLimitExCount()
 }
 
+func (c *collector) IncHostPathLimitExCount() {
+	c.ingressController.IncHostPathLimitExCount()
+}
+
 func (c *collector) IncSecretChecksumCount() {
 	c.ingressController.IncSecretChecksumCount()
 }
@@ -249,3 +304,27 @@ func (c *collector) IncSecretChecksumErrorCount() {
 func (c *collector) ClearSecretChecksumErrorCount() {
 	c.ingressController.ClearSecretChecksumErrorCount()
 }
+
+func (c *collector) IncSecretGrayInactiveCount() {
+	c.ingressController.IncSecretGrayInactiveCount()
+}
+
+func (c *collector) IncSecretGrayActiveCount() {
+	c.ingressController.IncSecretGrayActiveCount()
+}
+
+func (c *collector) IncUnknownConfigKeyCount(key string) {
+	c.ingressController.IncUnknownConfigKeyCount(key)
+}
+
+func (c *collector) IncStreamServiceDroppedCount(protocol string) {
+	c.ingressController.IncStreamServiceDroppedCount(protocol)
+}
+
+func (c *collector) IncIngressIgnoredCount(reason string) {
+	c.ingressController.IncIngressIgnoredCount(reason)
+}
+
+func (c *collector) SetStreamServicesSkipped(protocol string, skipped map[string]int) {
+	c.ingressController.SetStreamServicesSkipped(protocol, skipped)
+}
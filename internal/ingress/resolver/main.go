@@ -41,6 +41,19 @@ type Resolver interface {
 
 	// GetService searches for services containing the namespace and name using a the character /
 	GetService(string) (*apiv1.Service, error)
+
+	// GetCanaryLimits returns the maximum number of actions of every type a canary ingress can configure
+	GetCanaryLimits() CanaryLimits
+}
+
+// CanaryLimits contains the maximum number of actions of every type
+// a canary ingress can configure
+type CanaryLimits struct {
+	ReqAddHeader     int
+	ReqAppendHeader  int
+	ReqAddQuery      int
+	RespAddHeader    int
+	RespAppendHeader int
 }
 
 // AuthSSLCert contains the necessary information to do certificate based
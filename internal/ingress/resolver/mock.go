@@ -26,7 +26,8 @@ import (
 
 // Mock implements the Resolver interface
 type Mock struct {
-	ConfigMaps map[string]*apiv1.ConfigMap
+	ConfigMaps   map[string]*apiv1.ConfigMap
+	CanaryLimits CanaryLimits
 }
 
 // GetDefaultBackend returns the backend that must be used as default
@@ -58,3 +59,8 @@ func (m Mock) GetConfigMap(name string) (*apiv1.ConfigMap, error) {
 	}
 	return nil, errors.New("no configmap")
 }
+
+// GetCanaryLimits returns the maximum number of actions of every type a canary ingress can configure
+func (m Mock) GetCanaryLimits() CanaryLimits {
+	return m.CanaryLimits
+}
@@ -26,6 +26,8 @@ import (
 	"k8s.io/ingress-nginx/internal/ingress/annotations/auth"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/authreq"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/authtls"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/bodytoolarge"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/cacheconverthead"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/connection"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/cors"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/fastcgi"
@@ -36,10 +38,15 @@ import (
 	"k8s.io/ingress-nginx/internal/ingress/annotations/modsecurity"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/opentracing"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/proxy"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/proxycachelock"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/proxyssl"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/ratelimit"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/redirect"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/requestbodymd5"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/rewrite"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/securityheaders"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/ssldhparam"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/upstreamkeepalive"
 	"k8s.io/ingress-nginx/internal/ingress/secannotations"
 )
 
@@ -100,6 +107,9 @@ type Backend struct {
 	UpstreamHashBy UpstreamHashByConfig `json:"upstreamHashByConfig,omitempty"`
 	// LB algorithm configuration per ingress
 	LoadBalancing string `json:"load-balance,omitempty"`
+	// UpstreamKeepalive contains the keepalive configuration of the connections to the endpoints
+	// when it is configured per ingress. Such backends are served by a dedicated upstream.
+	UpstreamKeepalive upstreamkeepalive.Config `json:"upstreamKeepalive,omitempty"`
 	// Denotes if a backend has no server. The backend instead shares a server with another backend and acts as an
 	// alternative backend.
 	// This can be used to share multiple upstreams in the sam nginx server block.
@@ -123,6 +133,12 @@ type TrafficShapingPolicy struct {
 	// redirected to the backend and 99.8% will remain with the other backend.
 	// 0 weight will not send any traffic to this backend
 	Weight int `json:"weight"`
+	// SplitKey contains the variables of the request whose value selects the
+	// backend of the traffic split by weight, instead of a random choice
+	SplitKey []string `json:"splitKey,omitempty"`
+	// WeightMode is deterministic when the backend of the traffic split by
+	// weight is selected by the mod of the hash of the split key instead
+	WeightMode string `json:"weightMode,omitempty"`
 	// Header on which to redirect requests to this backend
 	Header string `json:"header"`
 	// HeaderValue on which to redirect requests to this backend
@@ -131,6 +147,9 @@ type TrafficShapingPolicy struct {
 	Cookie string `json:"cookie"`
 	// CookieValue on which to redirect requests to this backend
 	CookieValue string `json:"cookieValue"`
+	// CookiePattern is a regular expression matching the values of the
+	// cookie on which to redirect requests to this backend
+	CookiePattern string `json:"cookiePattern,omitempty"`
 	// Query on on which to redirect requests to this backend
 	Query string `json:"query"`
 	// QueryValue on which to redirect requests to this backend
@@ -151,6 +170,8 @@ type TrafficShapingPolicy struct {
 	RespAddHeader string `json:"respAddHeader"`
 	// Append header value to response header based on canary ingress
 	RespAppendHeader string `json:"respAppendHeader"`
+	// Strip the session affinity cookies of the backends not serving the request from the response
+	DedupeSetCookie bool `json:"dedupeSetCookie"`
 }
 
 // HashInclude defines if a field should be used or not to calculate the hash
@@ -169,6 +190,7 @@ type SessionAffinityConfig struct {
 	AffinityType          string                `json:"name"`
 	AffinityMode          string                `json:"mode"`
 	CookieSessionAffinity CookieSessionAffinity `json:"cookieSessionAffinity"`
+	HeaderSessionAffinity HeaderSessionAffinity `json:"headerSessionAffinity"`
 }
 
 // CookieSessionAffinity defines the structure used in Affinity configured by Cookies.
@@ -184,6 +206,12 @@ type CookieSessionAffinity struct {
 	ChangeOnFailure         bool                `json:"change_on_failure,omitempty"`
 }
 
+// HeaderSessionAffinity defines the structure used in Affinity configured by request Headers.
+// +k8s:deepcopy-gen=true
+type HeaderSessionAffinity struct {
+	Name string `json:"name"`
+}
+
 // UpstreamHashByConfig described setting from the upstream-hash-by* annotations.
 type UpstreamHashByConfig struct {
 	UpstreamHashBy           string `json:"upstream-hash-by,omitempty"`
@@ -246,14 +274,52 @@ type Server struct {
 	ServerSnippet string `json:"serverSnippet"`
 	// SSLCiphers returns list of ciphers to be enabled
 	SSLCiphers string `json:"sslCiphers,omitempty"`
+	// SSLCiphersTLS13 returns list of TLS 1.3 ciphersuites to be enabled
+	SSLCiphersTLS13 string `json:"sslCiphersTLS13,omitempty"`
 	// AuthTLSError contains the reason why the access to a server should be denied
 	AuthTLSError string `json:"authTLSError,omitempty"`
 	// NeedDefaultCert indicates whether the server requires a default cert
 	NeedDefaultCert bool `json:"needDefaultCert,omitempty"`
+	// DefaultCertPorts restricts the default cert to these ports, empty means any port
+	DefaultCertPorts []int `json:"defaultCertPorts,omitempty"`
 	// DefaultCertPort contains a default cert
 	DefaultCertPort int `json:"defaultCertPort,omitempty"`
 	// SSLProtocols indicates ssl protocols for the server
 	SSLProtocols string `json:"ssl-protocols"`
+	// MetricsTenant indicates the tenant the metrics of the server are attributed to
+	MetricsTenant string `json:"metricsTenant,omitempty"`
+	// DisableCoalescing indicates the server rejects the requests sent on
+	// connections established for other hosts (HTTP/2 connection coalescing)
+	DisableCoalescing bool `json:"disableCoalescing,omitempty"`
+	// SendTimeout sets the timeout for transmitting a response to the client
+	SendTimeout string `json:"sendTimeout,omitempty"`
+	// DisableHTTP2 omits http2 from the HTTPS listen directives of the server
+	DisableHTTP2 bool `json:"disableHTTP2,omitempty"`
+	// EnableHTTP3 overrides advertising HTTP/3 with the Alt-Svc header in the server.
+	// nil follows the global configuration.
+	EnableHTTP3 *bool `json:"enableHTTP3,omitempty"`
+	// SSLDHParam contains the DH parameters of the server, overriding the global ssl-dh-param
+	SSLDHParam ssldhparam.Config `json:"sslDHParam,omitempty"`
+	// NormalizePath indicates the server proxies the normalized path of the
+	// requests, with merged slashes and resolved dot segments
+	NormalizePath bool `json:"normalizePath,omitempty"`
+	// MaxURILength is the maximum length of the URIs of the requests, 0 uses
+	// the global large-client-header-buffers
+	MaxURILength int `json:"maxURILength,omitempty"`
+	// ProxyRealIPCIDR contains the addresses of the proxies trusted to send
+	// the client address of the requests, overriding the global proxy-real-ip-cidr
+	ProxyRealIPCIDR []string `json:"proxyRealIPCIDR,omitempty"`
+	// SingleKeyType indicates that the SSL certificates of the server use
+	// only one key type, e.g. ECC without RSA
+	SingleKeyType bool `json:"singleKeyType,omitempty"`
+	// UseGzip overrides use-gzip in the server with the configmap overlay of
+	// the namespace of its ingress. nil follows the global configuration.
+	UseGzip *bool `json:"useGzip,omitempty"`
+	// GzipLevel is the gzip-level of the server when UseGzip is enabled
+	GzipLevel int `json:"gzipLevel,omitempty"`
+	// SuppressDefaultRobots indicates the server omits the built-in
+	// /robots.txt location, e.g. to define it in the server snippet
+	SuppressDefaultRobots bool `json:"suppressDefaultRobots,omitempty"`
 }
 
 type Servers []*Server
@@ -357,6 +423,10 @@ type Location struct {
 	// DefaultBackend allows the use of a custom default backend for this location.
 	// +optional
 	DefaultBackend *apiv1.Service `json:"-"`
+	// CustomDefaultBackend indicates the DefaultBackend is configured by the
+	// custom-default-backend-service annotation, which applies even when
+	// use-custom-default-backend is disabled
+	CustomDefaultBackend bool `json:"-"`
 	// DefaultBackendUpstreamName is the upstream-formatted string for the name of
 	// this location's custom default backend
 	DefaultBackendUpstreamName string `json:"defaultBackendUpstreamName,omitempty"`
@@ -364,6 +434,10 @@ type Location struct {
 	// original location.
 	// +optional
 	XForwardedPrefix string `json:"xForwardedPrefix,omitempty"`
+	// XForwardedProto overrides the value of the X-Forwarded-Proto header
+	// passed to the upstream, either http or https.
+	// +optional
+	XForwardedProto string `json:"xForwardedProto,omitempty"`
 	// Logs allows to enable or disable the nginx logs
 	// By default access logs are enabled and rewrite logs are disabled
 	Logs log.Config `json:"logs,omitempty"`
@@ -385,6 +459,9 @@ type Location struct {
 	// DisableRobots allows to disable the robots
 	// By default this is false
 	DisableRobots bool `json:"disable-robots"`
+	// RobotsContent is the custom robots.txt served by the location
+	// By default this is ""
+	RobotsContent string `json:"robots-txt-content"`
 	// Canaries describes the canary rules that will be used on the location
 	Canaries []*Canary `json:"canaries"`
 	// Default range: [100, 10000]
@@ -403,9 +480,27 @@ type Location struct {
 	// Mirror allows you to mirror traffic to a "test" backend
 	// +optional
 	Mirror mirror.Config `json:"mirror,omitempty"`
+	// CacheConvertHead handles the HEAD requests of the proxy cache
+	// +optional
+	CacheConvertHead cacheconverthead.Config `json:"cacheConvertHead"`
 	// Opentracing allows the global opentracing setting to be overridden for a location
 	// +optional
 	Opentracing opentracing.Config `json:"opentracing"`
+	// SecurityHeaders customizes the default security headers added to the responses
+	// +optional
+	SecurityHeaders securityheaders.Config `json:"securityHeaders"`
+	// EarlyHints contains the resources hinted to the clients with a 103 Early Hints response
+	// +optional
+	EarlyHints []string `json:"earlyHints,omitempty"`
+	// RequestBodyMD5 adds the checksum of the request body as a header before proxying
+	// +optional
+	RequestBodyMD5 requestbodymd5.Config `json:"requestBodyMD5"`
+	// ProxyCacheLock collapses the identical requests missing the proxy cache into one upstream request
+	// +optional
+	ProxyCacheLock proxycachelock.Config `json:"proxyCacheLock"`
+	// BodyTooLarge handles the requests with a body larger than client_max_body_size
+	// +optional
+	BodyTooLarge bodytoolarge.Config `json:"bodyTooLarge"`
 }
 
 // SSLPassthroughBackend describes a SSL upstream server configured
@@ -441,6 +536,22 @@ type L4Backend struct {
 	Protocol  apiv1.Protocol     `json:"protocol"`
 	// +optional
 	ProxyProtocol ProxyProtocol `json:"proxyProtocol"`
+	// +optional
+	HealthCheck *L4HealthCheck `json:"healthCheck,omitempty"`
+	// ConnLimit is the maximum number of connections of a client address, 0 means no limit
+	// +optional
+	ConnLimit int `json:"connLimit,omitempty"`
+}
+
+// L4HealthCheck describes the passive health check of the endpoints of a L4 service.
+// An endpoint that fails Fall consecutive connections is skipped for Interval seconds.
+type L4HealthCheck struct {
+	// Interval seconds an unhealthy endpoint is skipped before it is tried again
+	Interval int `json:"interval"`
+	// Timeout seconds to establish a connection with an endpoint
+	Timeout int `json:"timeout"`
+	// Fall number of consecutive failures to consider an endpoint unhealthy
+	Fall int `json:"fall"`
 }
 
 // ProxyProtocol describes the proxy protocol configuration
@@ -126,6 +126,9 @@ func (b1 *Backend) Equal(b2 *Backend) bool {
 	if b1.LoadBalancing != b2.LoadBalancing {
 		return false
 	}
+	if !(&b1.UpstreamKeepalive).Equal(&b2.UpstreamKeepalive) {
+		return false
+	}
 
 	match := compareEndpoints(b1.Endpoints, b2.Endpoints)
 	if !match {
@@ -156,6 +159,9 @@ func (sac1 *SessionAffinityConfig) Equal(sac2 *SessionAffinityConfig) bool {
 	if !(&sac1.CookieSessionAffinity).Equal(&sac2.CookieSessionAffinity) {
 		return false
 	}
+	if !(&sac1.HeaderSessionAffinity).Equal(&sac2.HeaderSessionAffinity) {
+		return false
+	}
 
 	return true
 }
@@ -190,6 +196,21 @@ func (csa1 *CookieSessionAffinity) Equal(csa2 *CookieSessionAffinity) bool {
 	return true
 }
 
+// Equal tests for equality between two HeaderSessionAffinity types
+func (hsa1 *HeaderSessionAffinity) Equal(hsa2 *HeaderSessionAffinity) bool {
+	if hsa1 == hsa2 {
+		return true
+	}
+	if hsa1 == nil || hsa2 == nil {
+		return false
+	}
+	if hsa1.Name != hsa2.Name {
+		return false
+	}
+
+	return true
+}
+
 // Equal checks the equality between UpstreamByConfig types
 func (u1 *UpstreamHashByConfig) Equal(u2 *UpstreamHashByConfig) bool {
 	if u1 == u2 {
@@ -258,6 +279,9 @@ func (tsp1 TrafficShapingPolicy) Equal(tsp2 TrafficShapingPolicy) bool {
 	if tsp1.CookieValue != tsp2.CookieValue {
 		return false
 	}
+	if tsp1.CookiePattern != tsp2.CookiePattern {
+		return false
+	}
 	if tsp1.Query != tsp2.Query {
 		return false
 	}
@@ -288,6 +312,21 @@ func (tsp1 TrafficShapingPolicy) Equal(tsp2 TrafficShapingPolicy) bool {
 	if tsp1.RespAppendHeader != tsp2.RespAppendHeader {
 		return false
 	}
+	if tsp1.DedupeSetCookie != tsp2.DedupeSetCookie {
+		return false
+	}
+	if tsp1.WeightMode != tsp2.WeightMode {
+		return false
+	}
+	// the order of the variables changes the split
+	if len(tsp1.SplitKey) != len(tsp2.SplitKey) {
+		return false
+	}
+	for i := range tsp1.SplitKey {
+		if tsp1.SplitKey[i] != tsp2.SplitKey[i] {
+			return false
+		}
+	}
 
 	return true
 }
@@ -344,6 +383,9 @@ func (s1 *Server) Equal(s2 *Server) bool {
 	if s1.SSLCiphers != s2.SSLCiphers {
 		return false
 	}
+	if s1.SSLCiphersTLS13 != s2.SSLCiphersTLS13 {
+		return false
+	}
 	if s1.AuthTLSError != s2.AuthTLSError {
 		return false
 	}
@@ -366,9 +408,58 @@ func (s1 *Server) Equal(s2 *Server) bool {
 	if s1.DefaultCertPort != s2.DefaultCertPort {
 		return false
 	}
+
+	if !compareInts(s1.DefaultCertPorts, s2.DefaultCertPorts) {
+		return false
+	}
 	if s1.SSLProtocols != s2.SSLProtocols {
 		return false
 	}
+	if s1.MetricsTenant != s2.MetricsTenant {
+		return false
+	}
+	if s1.DisableCoalescing != s2.DisableCoalescing {
+		return false
+	}
+	if s1.SendTimeout != s2.SendTimeout {
+		return false
+	}
+	if s1.DisableHTTP2 != s2.DisableHTTP2 {
+		return false
+	}
+	if (s1.EnableHTTP3 == nil) != (s2.EnableHTTP3 == nil) {
+		return false
+	}
+	if s1.EnableHTTP3 != nil && *s1.EnableHTTP3 != *s2.EnableHTTP3 {
+		return false
+	}
+	if !(&s1.SSLDHParam).Equal(&s2.SSLDHParam) {
+		return false
+	}
+	if s1.NormalizePath != s2.NormalizePath {
+		return false
+	}
+	if s1.MaxURILength != s2.MaxURILength {
+		return false
+	}
+	if !sets.StringElementsMatch(s1.ProxyRealIPCIDR, s2.ProxyRealIPCIDR) {
+		return false
+	}
+	if s1.SingleKeyType != s2.SingleKeyType {
+		return false
+	}
+	if (s1.UseGzip == nil) != (s2.UseGzip == nil) {
+		return false
+	}
+	if s1.UseGzip != nil && *s1.UseGzip != *s2.UseGzip {
+		return false
+	}
+	if s1.GzipLevel != s2.GzipLevel {
+		return false
+	}
+	if s1.SuppressDefaultRobots != s2.SuppressDefaultRobots {
+		return false
+	}
 
 	return true
 }
@@ -454,6 +545,9 @@ func (l1 *Location) Equal(l2 *Location) bool {
 	if l1.XForwardedPrefix != l2.XForwardedPrefix {
 		return false
 	}
+	if l1.XForwardedProto != l2.XForwardedProto {
+		return false
+	}
 	if !(&l1.Connection).Equal(&l2.Connection) {
 		return false
 	}
@@ -489,6 +583,10 @@ func (l1 *Location) Equal(l2 *Location) bool {
 		return false
 	}
 
+	if l1.RobotsContent != l2.RobotsContent {
+		return false
+	}
+
 	match := compareCanaries(l1.Canaries, l2.Canaries)
 	if !match {
 		return false
@@ -523,6 +621,30 @@ func (l1 *Location) Equal(l2 *Location) bool {
 		return false
 	}
 
+	if !l1.CacheConvertHead.Equal(&l2.CacheConvertHead) {
+		return false
+	}
+
+	if !l1.SecurityHeaders.Equal(&l2.SecurityHeaders) {
+		return false
+	}
+
+	if !sets.StringElementsMatch(l1.EarlyHints, l2.EarlyHints) {
+		return false
+	}
+
+	if !l1.RequestBodyMD5.Equal(&l2.RequestBodyMD5) {
+		return false
+	}
+
+	if !l1.ProxyCacheLock.Equal(&l2.ProxyCacheLock) {
+		return false
+	}
+
+	if !l1.BodyTooLarge.Equal(&l2.BodyTooLarge) {
+		return false
+	}
+
 	return true
 }
 
@@ -597,6 +719,33 @@ func (l4b1 *L4Backend) Equal(l4b2 *L4Backend) bool {
 	if l4b1.Protocol != l4b2.Protocol {
 		return false
 	}
+	if !l4b1.HealthCheck.Equal(l4b2.HealthCheck) {
+		return false
+	}
+	if l4b1.ConnLimit != l4b2.ConnLimit {
+		return false
+	}
+
+	return true
+}
+
+// Equal tests for equality between two L4HealthCheck types
+func (hc1 *L4HealthCheck) Equal(hc2 *L4HealthCheck) bool {
+	if hc1 == hc2 {
+		return true
+	}
+	if hc1 == nil || hc2 == nil {
+		return false
+	}
+	if hc1.Interval != hc2.Interval {
+		return false
+	}
+	if hc1.Timeout != hc2.Timeout {
+		return false
+	}
+	if hc1.Fall != hc2.Fall {
+		return false
+	}
 
 	return true
 }
@@ -165,6 +165,25 @@ func NetworkingIngressAvailable(client clientset.Interface) bool {
 	return runningVersion.AtLeast(version119)
 }
 
+// DiscoveryEndpointSliceAvailable checks if the package "k8s.io/api/discovery/v1"
+// is available or not and if EndpointSlice V1 is supported (k8s >= v1.21.0)
+func DiscoveryEndpointSliceAvailable(client clientset.Interface) bool {
+	version121, _ := version.ParseGeneric("v1.21.0")
+
+	serverVersion, err := client.Discovery().ServerVersion()
+	if err != nil {
+		return false
+	}
+
+	runningVersion, err := version.ParseGeneric(serverVersion.String())
+	if err != nil {
+		klog.ErrorS(err, "unexpected error parsing running Kubernetes version")
+		return false
+	}
+
+	return runningVersion.AtLeast(version121)
+}
+
 // default path type is Prefix to not break existing definitions
 var defaultPathType = networkingv1.PathTypePrefix
 
@@ -25,6 +25,8 @@ import (
 	"net/http"
 	"os"
 	"os/exec"
+	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
@@ -159,6 +161,28 @@ func Version() string {
 	return string(out)
 }
 
+// nginxVersionRegex matches the version of nginx Tengine is based on
+var nginxVersionRegex = regexp.MustCompile(`nginx/(\d+)\.(\d+)\.(\d+)`)
+
+// SupportsEarlyHints returns true if Tengine is based on a version of nginx
+// supporting the early_hints directive (1.29.0 or newer)
+func SupportsEarlyHints() bool {
+	out, err := exec.Command("tengine", "-v").CombinedOutput()
+	if err != nil {
+		klog.Errorf("unexpected error obtaining Tengine version: %v", err)
+		return false
+	}
+
+	match := nginxVersionRegex.FindStringSubmatch(string(out))
+	if match == nil {
+		return false
+	}
+
+	major, _ := strconv.Atoi(match[1])
+	minor, _ := strconv.Atoi(match[2])
+	return major > 1 || (major == 1 && minor >= 29)
+}
+
 // IsRunning returns true if a process with the name 'tengine' is found
 func IsRunning() bool {
 	processes, _ := ps.Processes()
@@ -8,6 +8,7 @@ local chash = require("balancer.chash")
 local chashsubset = require("balancer.chashsubset")
 local sticky_balanced = require("balancer.sticky_balanced")
 local sticky_persistent = require("balancer.sticky_persistent")
+local sticky_header = require("balancer.sticky_header")
 local ewma = require("balancer.ewma")
 local string = string
 local ipairs = ipairs
@@ -16,6 +17,8 @@ local getmetatable = getmetatable
 local tostring = tostring
 local pairs = pairs
 local math = math
+local type = type
+local next = next
 
 
 -- measured in seconds
@@ -31,11 +34,15 @@ local IMPLEMENTATIONS = {
   chashsubset = chashsubset,
   sticky_balanced = sticky_balanced,
   sticky_persistent = sticky_persistent,
+  sticky_header = sticky_header,
   ewma = ewma,
 }
 
 local _M = {}
 local balancers = {}
+-- the maximum number of actions of every type, like the controller applies
+-- them, e.g. { req_add_header = 2 }, a missing type is not limited
+local action_limits = {}
 
 local function get_implementation(backend)
   local name = backend["load-balance"] or DEFAULT_LB_ALG
@@ -48,6 +55,11 @@ local function get_implementation(backend)
       name = "sticky_balanced"
     end
 
+  elseif backend["sessionAffinityConfig"] and
+         backend["sessionAffinityConfig"]["name"] == "header" and
+         backend["sessionAffinityConfig"]["headerSessionAffinity"]["name"] ~= "" then
+    name = "sticky_header"
+
   elseif backend["upstreamHashByConfig"] and
          backend["upstreamHashByConfig"]["upstream-hash-by"] then
     if backend["upstreamHashByConfig"]["upstream-hash-by-subset"] then
@@ -157,6 +169,55 @@ local function sync_backends()
   end
 end
 
+-- returns the bucket, from 0 to buckets - 1, of the request in the traffic
+-- split by weight of the backend, hashing the values of the variables of the
+-- split key with the name of the backend, so the canaries of a path split
+-- independently
+local function split_bucket(backend_name, split_key, buckets)
+  local values = { backend_name }
+  for _, name in ipairs(split_key) do
+    table.insert(values, ngx.var[name] or "")
+  end
+
+  return ngx.crc32_long(table.concat(values, "|")) % buckets
+end
+
+local mod_operators = {
+  ["=="] = function(a, b) return a == b end,
+  [">"] = function(a, b) return a > b end,
+  [">="] = function(a, b) return a >= b end,
+  ["<"] = function(a, b) return a < b end,
+  ["<="] = function(a, b) return a <= b end,
+}
+
+-- returns true if the bucket of the request, from 0 to modDivisor - 1, matches
+-- the remainder of the policy. The split key defaults to the client address.
+local function mod_bucket_matches(backend_name, traffic_shaping_policy)
+  local split_key = traffic_shaping_policy.splitKey
+  if not split_key or #split_key == 0 then
+    split_key = { "remote_addr" }
+  end
+
+  local operator = mod_operators[traffic_shaping_policy.modRelationalOpr]
+                   or mod_operators["=="]
+  local bucket = split_bucket(backend_name, split_key,
+                              traffic_shaping_policy.modDivisor)
+
+  return operator(bucket, traffic_shaping_policy.modRemainder)
+end
+
+-- returns true if the value of the query argument is one of the values,
+-- separated by "||", of the canary-by-query-value annotation
+local function query_value_matches(query_values, query)
+  for value in string.gmatch(query_values .. "||", "(.-)||") do
+    if value ~= "" and value == query then
+      return true
+    end
+  end
+
+  return false
+end
+
 local function route_to_alternative_balancer(balancer)
   if not balancer.alternative_backends then
     return false
@@ -204,13 +265,53 @@ local function route_to_alternative_balancer(balancer)
   local target_cookie = traffic_shaping_policy.cookie
   local cookie = ngx.var["cookie_" .. target_cookie]
   if cookie then
-    if cookie == "always" then
+    if traffic_shaping_policy.cookiePattern
+       and #traffic_shaping_policy.cookiePattern > 0 then
+      local from, _, err = ngx.re.find(cookie,
+                                       traffic_shaping_policy.cookiePattern, "jo")
+      if err then
+        ngx.log(ngx.ERR, "error matching the cookie pattern of backend ",
+                tostring(backend_name), ": ", err)
+      elseif from then
+        return true
+      end
+
+    elseif cookie == "always" then
       return true
     elseif cookie == "never" then
       return false
     end
   end
 
+  local target_query = traffic_shaping_policy.query
+  if target_query and #target_query > 0 then
+    local query = ngx.var["arg_" .. target_query]
+    if query then
+      if traffic_shaping_policy.queryValue
+         and #traffic_shaping_policy.queryValue > 0 then
+        if query_value_matches(traffic_shaping_policy.queryValue, query) then
+          return true
+        end
+
+      elseif query == "always" then
+        return true
+
+      elseif query == "never" then
+        return false
+      end
+    end
+  end
+
+  if traffic_shaping_policy.weightMode == "deterministic"
+     and (traffic_shaping_policy.modDivisor or 0) > 0 then
+    return mod_bucket_matches(backend_name, traffic_shaping_policy)
+  end
+
+  local split_key = traffic_shaping_policy.splitKey
+  if split_key and #split_key > 0 then
+    return split_bucket(backend_name, split_key, 100) < traffic_shaping_policy.weight
+  end
+
   if math.random(100) <= traffic_shaping_policy.weight then
     return true
   end
@@ -242,6 +343,208 @@ local function get_balancer()
   return balancer
 end
 
+-- returns the name of the session affinity cookie of the balancer,
+-- only the cookie based sticky balancers have one
+local function affinity_cookie_name(backend_balancer)
+  if not backend_balancer or not backend_balancer.cookie_name then
+    return nil
+  end
+
+  return backend_balancer:cookie_name()
+end
+
+-- dedupe_set_cookie strips the session affinity cookies of the backends of
+-- the location which did not serve the request from the response, so the
+-- client does not receive conflicting affinity cookies from the primary and
+-- the canary backend. Other cookies are passed through.
+local function dedupe_set_cookie()
+  local serving_balancer = ngx.ctx.balancer
+  if not serving_balancer then
+    return
+  end
+
+  local primary_balancer = balancers[ngx.var.proxy_upstream_name]
+  if not primary_balancer or not primary_balancer.alternative_backends then
+    return
+  end
+
+  local dedupe = false
+  local location_balancers = { primary_balancer }
+  for _, backend_name in ipairs(primary_balancer.alternative_backends) do
+    local alternative_balancer = balancers[backend_name]
+    if alternative_balancer then
+      local policy = alternative_balancer.traffic_shaping_policy
+      if policy and policy.dedupeSetCookie then
+        dedupe = true
+      end
+      table.insert(location_balancers, alternative_balancer)
+    end
+  end
+
+  if not dedupe then
+    return
+  end
+
+  local serving_cookie = affinity_cookie_name(serving_balancer)
+  local stale_cookies = {}
+  for _, location_balancer in ipairs(location_balancers) do
+    if location_balancer ~= serving_balancer then
+      local cookie = affinity_cookie_name(location_balancer)
+      if cookie and cookie ~= serving_cookie then
+        stale_cookies[cookie] = true
+      end
+    end
+  end
+
+  if next(stale_cookies) == nil then
+    return
+  end
+
+  local set_cookie = ngx.header["Set-Cookie"]
+  if not set_cookie then
+    return
+  end
+
+  if type(set_cookie) == "string" then
+    set_cookie = { set_cookie }
+  end
+
+  local kept = {}
+  for _, value in ipairs(set_cookie) do
+    local name = string.match(value, "^%s*([^=;%s]+)")
+    if not (name and stale_cookies[name]) then
+      table.insert(kept, value)
+    end
+  end
+
+  if #kept == #set_cookie then
+    return
+  end
+
+  if #kept == 0 then
+    ngx.header["Set-Cookie"] = nil
+  else
+    ngx.header["Set-Cookie"] = kept
+  end
+end
+
+-- returns the traffic shaping policy of the canary backend serving the
+-- request, or nil when the primary backend serves it
+local function serving_canary_policy()
+  local serving_balancer = ngx.ctx.balancer
+  if not serving_balancer
+     or serving_balancer == balancers[ngx.var.proxy_upstream_name] then
+    return nil
+  end
+
+  return serving_balancer.traffic_shaping_policy
+end
+
+-- returns the name and value pairs of the actions of a canary annotation,
+-- e.g. "x-a:1||x-b:2", skipping the malformed ones and the ones over max
+-- like the controller does
+local function parse_actions(actions, delimiter, item_delimiter, max)
+  local items = {}
+  if not actions or #actions == 0 then
+    return items
+  end
+
+  local item_pattern = "^([^" .. item_delimiter .. "]+)" .. item_delimiter ..
+                       "([^" .. item_delimiter .. "]+)$"
+  for action in string.gmatch(actions .. delimiter, "(.-)" .. delimiter) do
+    local name, value = string.match(action, item_pattern)
+    if name then
+      if max and #items >= max then
+        ngx.log(ngx.WARN, "the actions exceed the limit ", max, ", ",
+                action, " ignored")
+        break
+      end
+      table.insert(items, { name, value })
+    end
+  end
+
+  return items
+end
+
+-- adds the value to the values of a header or query argument
+local function add_value(current, value)
+  if not current then
+    return value
+  end
+
+  if type(current) == "string" then
+    return { current, value }
+  end
+
+  table.insert(current, value)
+  return current
+end
+
+-- appends the value to the value of a header, separated by a comma
+local function append_value(current, value)
+  if not current then
+    return value
+  end
+
+  if type(current) == "table" then
+    current = table.concat(current, ",")
+  end
+
+  return current .. "," .. value
+end
+
+-- applies the request actions of the canary backend serving the request
+local function apply_request_actions()
+  local policy = serving_canary_policy()
+  if not policy then
+    return
+  end
+
+  for _, action in ipairs(parse_actions(policy.reqAddHeader, "||", ":",
+                                        action_limits.req_add_header)) do
+    ngx.req.set_header(action[1],
+                       add_value(ngx.req.get_headers()[action[1]], action[2]))
+  end
+
+  for _, action in ipairs(parse_actions(policy.reqAppendHeader, "||", ":",
+                                        action_limits.req_append_header)) do
+    ngx.req.set_header(action[1],
+                       append_value(ngx.req.get_headers()[action[1]], action[2]))
+  end
+
+  local queries = parse_actions(policy.reqAddQuery, "&", "=",
+                                action_limits.req_add_query)
+  if #queries > 0 then
+    local args = ngx.req.get_uri_args()
+    for _, action in ipairs(queries) do
+      args[action[1]] = add_value(args[action[1]], action[2])
+    end
+    ngx.req.set_uri_args(args)
+  end
+end
+
+-- applies the response actions of the canary backend serving the request
+local function apply_response_actions()
+  local policy = serving_canary_policy()
+  if not policy then
+    return
+  end
+
+  for _, action in ipairs(parse_actions(policy.respAddHeader, "||", ":",
+                                        action_limits.resp_add_header)) do
+    ngx.header[action[1]] = add_value(ngx.header[action[1]], action[2])
+  end
+
+  for _, action in ipairs(parse_actions(policy.respAppendHeader, "||", ":",
+                                        action_limits.resp_append_header)) do
+    ngx.header[action[1]] = append_value(ngx.header[action[1]], action[2])
+  end
+end
+
+function _M.set_config(new_config)
+  action_limits = new_config.canary_action_limits or {}
+end
+
 function _M.init_worker()
   sync_backends() -- when worker starts, sync backends without delay
   local _, err = ngx.timer.every(BACKENDS_SYNC_INTERVAL, sync_backends)
@@ -257,6 +560,8 @@ function _M.rewrite()
     ngx.status = ngx.HTTP_SERVICE_UNAVAILABLE
     return ngx.exit(ngx.status)
   end
+
+  apply_request_actions()
 end
 
 function _M.balance()
@@ -280,6 +585,11 @@ function _M.balance()
   end
 end
 
+function _M.header()
+  dedupe_set_cookie()
+  apply_response_actions()
+end
+
 function _M.log()
   local balancer = get_balancer()
   if not balancer then
@@ -298,6 +608,9 @@ if _TEST then
   _M.sync_backend = sync_backend
   _M.route_to_alternative_balancer = route_to_alternative_balancer
   _M.get_balancer = get_balancer
+  _M.dedupe_set_cookie = dedupe_set_cookie
+  _M.apply_request_actions = apply_request_actions
+  _M.apply_response_actions = apply_response_actions
 end
 
 return _M
@@ -0,0 +1,44 @@
+local balancer_resty = require("balancer.resty")
+local resty_chash = require("resty.chash")
+local resty_roundrobin = require("resty.roundrobin")
+local util = require("util")
+
+local ngx = ngx
+local string = string
+
+local _M = balancer_resty:new({ factory = resty_chash, name = "sticky_header" })
+
+function _M.new(self, backend)
+  local nodes = util.get_nodes(backend.endpoints)
+  local header_name = backend["sessionAffinityConfig"]["headerSessionAffinity"]["name"]
+  local o = {
+    instance = self.factory:new(nodes),
+    fallback = resty_roundrobin:new(nodes),
+    hash_by = "http_" .. string.gsub(string.lower(header_name), "-", "_"),
+    traffic_shaping_policy = backend.trafficShapingPolicy,
+    alternative_backends = backend.alternativeBackends,
+  }
+  setmetatable(o, self)
+  self.__index = self
+  return o
+end
+
+function _M.sync(self, backend)
+  local nodes = util.get_nodes(backend.endpoints)
+  if not util.deep_compare(self.instance.nodes, nodes) then
+    self.fallback:reinit(nodes)
+  end
+
+  balancer_resty.sync(self, backend)
+end
+
+function _M.balance(self)
+  local key = ngx.var[self.hash_by]
+  if not key or key == "" then
+    return self.fallback:find()
+  end
+
+  return self.instance:find(key)
+end
+
+return _M
@@ -5,6 +5,11 @@ local certificate_configured_for_current_request = require("certificate").config
 local original_randomseed = math.randomseed
 local string_format = string.format
 local ngx_redirect = ngx.redirect
+local io_open = io.open
+local tonumber = tonumber
+local ipairs = ipairs
+local type = type
+local table_insert = table.insert
 
 local _M = {}
 
@@ -92,6 +97,82 @@ local function parse_x_forwarded_host()
   return hosts[1]
 end
 
+local function read_body_file(path)
+  local file, err = io_open(path, "rb")
+  if not file then
+    ngx.log(ngx.WARN, "failed to open request body file: ", err)
+    return nil
+  end
+
+  local body = file:read("*a")
+  file:close()
+
+  return body
+end
+
+-- add_request_body_md5 sets the Content-MD5 header with the checksum of
+-- the request body. It fails open: when the body is streamed or larger than
+-- the buffer the request is proxied without checksum and the reason is sent
+-- in the X-Request-Body-MD5-Skipped header.
+local function add_request_body_md5(location_config)
+  -- never trust the values sent by the client
+  ngx.req.clear_header("Content-MD5")
+  ngx.req.clear_header("X-Request-Body-MD5-Skipped")
+
+  local content_length = tonumber(ngx.var.http_content_length)
+  if not content_length then
+    if ngx.var.http_transfer_encoding then
+      ngx.req.set_header("X-Request-Body-MD5-Skipped", "streamed")
+    end
+    return
+  end
+
+  if content_length > location_config.request_body_md5_buffer_size then
+    ngx.req.set_header("X-Request-Body-MD5-Skipped", "too-large")
+    return
+  end
+
+  local body = ""
+  if content_length > 0 then
+    ngx.req.read_body()
+
+    body = ngx.req.get_body_data()
+    if not body then
+      -- the body did not fit into client_body_buffer_size
+      local path = ngx.req.get_body_file()
+      if path then
+        body = read_body_file(path)
+      end
+    end
+  end
+
+  if not body then
+    ngx.req.set_header("X-Request-Body-MD5-Skipped", "unavailable")
+    return
+  end
+
+  ngx.req.set_header("Content-MD5", ngx.encode_base64(ngx.md5_bin(body)))
+end
+
+-- split_set_cookie splits the Set-Cookie headers joined with commas by
+-- $upstream_http_set_cookie. A comma starts a new cookie only when it is
+-- followed by a cookie name, so the commas of the Expires dates are kept.
+local function split_set_cookie(value)
+  local cookies = {}
+  local from = 1
+
+  while true do
+    local sep = value:find(",%s*[^=;,%s]+=", from)
+    if not sep then
+      table_insert(cookies, value:sub(from))
+      return cookies
+    end
+
+    table_insert(cookies, value:sub(from, sep - 1))
+    from = value:find("[^,%s]", sep)
+  end
+end
+
 function _M.init_worker()
   randomseed()
 end
@@ -147,6 +228,54 @@ function _M.rewrite(location_config)
 
     ngx_redirect(uri, config.http_redirect_code)
   end
+
+  if location_config.request_body_md5 then
+    add_request_body_md5(location_config)
+  end
+end
+
+-- forward_auth_cookies appends to the response the Set-Cookie headers of
+-- the given cookies returned by the authentication service, keeping their
+-- attributes and the Set-Cookie headers of the backend.
+function _M.forward_auth_cookies(names)
+  local set_cookie = ngx.var.auth_response_set_cookie
+  if not set_cookie or set_cookie == "" then
+    return
+  end
+
+  local forwarded = {}
+  for _, name in ipairs(names) do
+    forwarded[name] = true
+  end
+
+  local cookies = ngx.header["Set-Cookie"] or {}
+  if type(cookies) == "string" then
+    cookies = { cookies }
+  end
+
+  for _, cookie in ipairs(split_set_cookie(set_cookie)) do
+    local name = cookie:match("^%s*([^=;%s]+)=")
+    if name and forwarded[name] then
+      table_insert(cookies, cookie)
+    end
+  end
+
+  ngx.header["Set-Cookie"] = cookies
+end
+
+-- append_links appends the given links to the Link headers of the response,
+-- keeping the ones of the backend.
+function _M.append_links(links)
+  local values = ngx.header["Link"] or {}
+  if type(values) == "string" then
+    values = { values }
+  end
+
+  for _, link in ipairs(links) do
+    table_insert(values, link)
+  end
+
+  ngx.header["Link"] = values
 end
 
 function _M.header()
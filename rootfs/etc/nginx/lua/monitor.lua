@@ -80,6 +80,22 @@ function _M.call()
   metrics_batch[metrics_size + 1] = metrics()
 end
 
+-- stream_conn_rejected records a connection to a stream service rejected by
+-- its conn-limit, called in the log phase of the stream server
+function _M.stream_conn_rejected(namespace, service, port)
+  local metrics_size = nkeys(metrics_batch)
+  if metrics_size >= MAX_BATCH_SIZE then
+    ngx.log(ngx.WARN, "omitting metrics for the rejected connection, current batch is full")
+    return
+  end
+
+  metrics_batch[metrics_size + 1] = {
+    namespace = namespace,
+    service = service,
+    streamPort = tostring(port),
+  }
+end
+
 if _TEST then
   _M.flush = flush
   _M.get_metrics_batch = function() return metrics_batch end
@@ -18,6 +18,10 @@ local IMPLEMENTATIONS = {
 local _M = {}
 local balancers = {}
 
+-- passive health check state of the peers, per worker
+-- keyed by "<backend name>|<peer>"
+local peer_states = {}
+
 local function get_implementation(backend)
   local name = backend["load-balance"] or DEFAULT_LB_ALG
 
@@ -136,18 +140,77 @@ function _M.init_worker()
   end
 end
 
+local function peer_key(peer)
+  return ngx.var.proxy_upstream_name .. "|" .. peer
+end
+
+local function is_peer_down(peer)
+  local state = peer_states[peer_key(peer)]
+  return state ~= nil and state.down_until ~= nil and state.down_until > ngx.now()
+end
+
+local function record_peer_failure(peer, health_check)
+  local key = peer_key(peer)
+  local state = peer_states[key] or { fails = 0 }
+
+  state.fails = state.fails + 1
+  if state.fails >= health_check.fall then
+    ngx.log(ngx.WARN, string.format("peer %s of %s failed %d times, skipping it for %d seconds",
+      peer, ngx.var.proxy_upstream_name, state.fails, health_check.interval))
+    state.fails = 0
+    state.down_until = ngx.now() + health_check.interval
+  end
+
+  peer_states[key] = state
+end
+
+local function record_peer_success(peer)
+  peer_states[peer_key(peer)] = nil
+end
+
+-- returns the next peer that is not marked as down by the health check,
+-- or the first returned peer when every peer is down
+local function balance_healthy_peer(balancer)
+  local first_peer = balancer:balance()
+  local peer = first_peer
+  local seen = {}
+
+  while peer and is_peer_down(peer) and not seen[peer] do
+    seen[peer] = true
+    peer = balancer:balance()
+  end
+
+  if not peer or seen[peer] then
+    return first_peer
+  end
+
+  return peer
+end
+
 function _M.balance()
   local balancer = get_balancer()
   if not balancer then
     return
   end
 
-  local peer = balancer:balance()
+  local health_check = ngx.ctx.health_check
+  local peer
+  if health_check then
+    -- balancer is called again only when the previous peer failed
+    if ngx.ctx.balancer_peer then
+      record_peer_failure(ngx.ctx.balancer_peer, health_check)
+    end
+    peer = balance_healthy_peer(balancer)
+  else
+    peer = balancer:balance()
+  end
+
   if not peer then
     ngx.log(ngx.WARN, "no peer was returned, balancer: " .. balancer.name)
     return
   end
 
+  ngx.ctx.balancer_peer = peer
   ngx_balancer.set_more_tries(1)
 
   local ok, err = ngx_balancer.set_current_peer(peer)
@@ -162,6 +225,15 @@ function _M.log()
     return
   end
 
+  local peer = ngx.ctx.balancer_peer
+  if ngx.ctx.health_check and peer then
+    if ngx.var.status == "502" then
+      record_peer_failure(peer, ngx.ctx.health_check)
+    else
+      record_peer_success(peer)
+    end
+  end
+
   if not balancer.after_balance then
     return
   end
@@ -172,6 +244,8 @@ end
 if _TEST then
   _M.get_implementation = get_implementation
   _M.sync_backend = sync_backend
+  _M.is_peer_down = is_peer_down
+  _M.balance_healthy_peer = balance_healthy_peer
 end
 
 return _M
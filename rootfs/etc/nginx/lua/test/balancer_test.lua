@@ -169,6 +169,100 @@ describe("Balancer", function()
       end)
     end)
 
+    context("canary by weight in the deterministic mode", function()
+      it("returns the same result for the same client", function()
+        backend.trafficShapingPolicy.weightMode = "deterministic"
+        backend.trafficShapingPolicy.modDivisor = 10
+        backend.trafficShapingPolicy.modRelationalOpr = "<"
+        backend.trafficShapingPolicy.modRemainder = 5
+        balancer.sync_backend(backend)
+
+        for i = 1,20,1 do
+          mock_ngx({ var = { remote_addr = "10.0.0." .. i } })
+          local expected = balancer.route_to_alternative_balancer(_balancer)
+          for _ = 1,10,1 do
+            assert.equal(expected, balancer.route_to_alternative_balancer(_balancer))
+          end
+        end
+      end)
+
+      it("splits the clients by the mod of the divisor", function()
+        backend.trafficShapingPolicy.weightMode = "deterministic"
+        backend.trafficShapingPolicy.splitKey = { "cookie_uid" }
+        backend.trafficShapingPolicy.modDivisor = 10
+        backend.trafficShapingPolicy.modRelationalOpr = "<"
+        backend.trafficShapingPolicy.modRemainder = 2
+        balancer.sync_backend(backend)
+
+        local canary = 0
+        for i = 1,1000,1 do
+          mock_ngx({ var = { cookie_uid = "user-" .. i } })
+          if balancer.route_to_alternative_balancer(_balancer) then
+            canary = canary + 1
+          end
+        end
+
+        assert.is_true(canary > 150 and canary < 250)
+      end)
+
+      it("ignores the weight", function()
+        backend.trafficShapingPolicy.weightMode = "deterministic"
+        backend.trafficShapingPolicy.weight = 100
+        backend.trafficShapingPolicy.modDivisor = 2
+        backend.trafficShapingPolicy.modRelationalOpr = ">"
+        backend.trafficShapingPolicy.modRemainder = 1
+        balancer.sync_backend(backend)
+
+        mock_ngx({ var = { remote_addr = "10.0.0.1" } })
+        assert.equal(false, balancer.route_to_alternative_balancer(_balancer))
+      end)
+    end)
+
+    context("canary by weight with split key", function()
+      it("returns the same result for the same value of the key", function()
+        backend.trafficShapingPolicy.weight = 50
+        backend.trafficShapingPolicy.splitKey = { "remote_addr" }
+        balancer.sync_backend(backend)
+
+        for i = 1,20,1 do
+          mock_ngx({ var = { remote_addr = "10.0.0." .. i } })
+          local expected = balancer.route_to_alternative_balancer(_balancer)
+          for _ = 1,10,1 do
+            assert.equal(expected, balancer.route_to_alternative_balancer(_balancer))
+          end
+        end
+      end)
+
+      it("splits the values of the key by weight", function()
+        backend.trafficShapingPolicy.weight = 20
+        backend.trafficShapingPolicy.splitKey = { "cookie_uid", "remote_addr" }
+        balancer.sync_backend(backend)
+
+        local canary = 0
+        for i = 1,1000,1 do
+          mock_ngx({ var = { cookie_uid = "user-" .. i, remote_addr = "10.0.0.1" } })
+          if balancer.route_to_alternative_balancer(_balancer) then
+            canary = canary + 1
+          end
+        end
+
+        assert.is_true(canary > 150 and canary < 250)
+      end)
+
+      it("returns true when weight is 100 and false when weight is 0", function()
+        backend.trafficShapingPolicy.splitKey = { "http_x_user_id" }
+        mock_ngx({ var = { http_x_user_id = "42" } })
+
+        backend.trafficShapingPolicy.weight = 100
+        balancer.sync_backend(backend)
+        assert.equal(true, balancer.route_to_alternative_balancer(_balancer))
+
+        backend.trafficShapingPolicy.weight = 0
+        balancer.sync_backend(backend)
+        assert.equal(false, balancer.route_to_alternative_balancer(_balancer))
+      end)
+    end)
+
     context("canary by cookie", function()
       it("returns correct result for given cookies", function()
         backend.trafficShapingPolicy.cookie = "canaryCookie"
@@ -211,6 +305,114 @@ describe("Balancer", function()
       end)
     end)
 
+    context("canary by cookie pattern", function()
+      it("returns correct result for given cookies", function()
+        local test_patterns = {
+          {
+            case_title = "cookie value matches the pattern",
+            request_cookie_name = "canaryCookie",
+            request_cookie_value = "bucket-07",
+            expected_result = true,
+          },
+          {
+            case_title = "cookie value does not match the pattern",
+            request_cookie_name = "canaryCookie",
+            request_cookie_value = "bucket-42",
+            expected_result = false,
+          },
+          {
+            case_title = "cookie value is 'always'",
+            request_cookie_name = "canaryCookie",
+            request_cookie_value = "always",
+            expected_result = false,
+          },
+          {
+            case_title = "cookie_name is undefined",
+            request_cookie_name = "foo",
+            request_cookie_value = "bucket-07",
+            expected_result = false,
+          },
+        }
+
+        for _, test_pattern in pairs(test_patterns) do
+          reset_balancer()
+          backend.trafficShapingPolicy.cookie = "canaryCookie"
+          backend.trafficShapingPolicy.cookiePattern = "^bucket-0[0-9]$"
+          balancer.sync_backend(backend)
+          mock_ngx({ var = {
+            ["cookie_" .. test_pattern.request_cookie_name] = test_pattern.request_cookie_value,
+            request_uri = "/"
+          }})
+          assert.message("\nTest data pattern: " .. test_pattern.case_title)
+            .equal(test_pattern.expected_result, balancer.route_to_alternative_balancer(_balancer))
+          reset_ngx()
+        end
+      end)
+    end)
+
+    context("canary by query", function()
+      it("returns correct result for given query arguments", function()
+        local test_patterns = {
+          {
+            case_title = "no custom query value and query value is 'always'",
+            query_value = "",
+            request_query_name = "canaryQuery",
+            request_query_value = "always",
+            expected_result = true,
+          },
+          {
+            case_title = "no custom query value and query value is 'never'",
+            query_value = "",
+            request_query_name = "canaryQuery",
+            request_query_value = "never",
+            expected_result = false,
+          },
+          {
+            case_title = "no custom query value and query name is undefined",
+            query_value = "",
+            request_query_name = "foo",
+            request_query_value = "always",
+            expected_result = false,
+          },
+          {
+            case_title = "custom query value is set and query value is 'always'",
+            query_value = "foo||bar",
+            request_query_name = "canaryQuery",
+            request_query_value = "always",
+            expected_result = false,
+          },
+          {
+            case_title = "custom query value is set and query value matches one of the values",
+            query_value = "foo||bar",
+            request_query_name = "canaryQuery",
+            request_query_value = "bar",
+            expected_result = true,
+          },
+          {
+            case_title = "custom query value is set and query value does not match",
+            query_value = "foo||bar",
+            request_query_name = "canaryQuery",
+            request_query_value = "foobar",
+            expected_result = false,
+          },
+        }
+
+        for _, test_pattern in pairs(test_patterns) do
+          reset_balancer()
+          backend.trafficShapingPolicy.query = "canaryQuery"
+          backend.trafficShapingPolicy.queryValue = test_pattern.query_value
+          balancer.sync_backend(backend)
+          mock_ngx({ var = {
+            ["arg_" .. test_pattern.request_query_name] = test_pattern.request_query_value,
+            request_uri = "/"
+          }})
+          assert.message("\nTest data pattern: " .. test_pattern.case_title)
+            .equal(test_pattern.expected_result, balancer.route_to_alternative_balancer(_balancer))
+          reset_ngx()
+        end
+      end)
+    end)
+
     context("canary by header", function()
       it("returns correct result for given headers", function()
         local test_patterns = {
@@ -291,6 +493,144 @@ describe("Balancer", function()
     end)
   end)
 
+  describe("dedupe_set_cookie()", function()
+    local backend, canary_backend, set_cookie
+
+    before_each(function()
+      backend = {
+        name = "my-dummy-app-7", ["load-balance"] = "round_robin",
+        alternativeBackends = { "my-dummy-canary-app-7" },
+        endpoints = { { address = "10.184.7.40", port = "8080", maxFails = 0, failTimeout = 0 } },
+        sessionAffinityConfig = { name = "cookie", cookieSessionAffinity = { name = "route" } },
+      }
+      canary_backend = {
+        name = "my-dummy-canary-app-7", ["load-balance"] = "round_robin",
+        endpoints = { { address = "11.184.7.40", port = "8080", maxFails = 0, failTimeout = 0 } },
+        sessionAffinityConfig = { name = "cookie", cookieSessionAffinity = { name = "canary_route" } },
+        trafficShapingPolicy = {
+          weight = 0,
+          header = "",
+          headerValue = "",
+          cookie = "",
+          dedupeSetCookie = true,
+        },
+      }
+      set_cookie = { "route=abc; Path=/", "canary_route=def; Path=/", "session=xyz; Path=/" }
+    end)
+
+    local function run_dedupe(header)
+      balancer.sync_backend(backend)
+      balancer.sync_backend(canary_backend)
+
+      mock_ngx({ var = { proxy_upstream_name = backend.name }, ctx = {}, header = { ["Set-Cookie"] = header } })
+      balancer.get_balancer()
+      balancer.dedupe_set_cookie()
+
+      return ngx.header["Set-Cookie"]
+    end
+
+    it("strips the affinity cookie of the canary when the primary backend serves the request", function()
+      assert.are.same({ "route=abc; Path=/", "session=xyz; Path=/" }, run_dedupe(set_cookie))
+    end)
+
+    it("strips the affinity cookie of the primary backend when the canary serves the request", function()
+      canary_backend.trafficShapingPolicy.weight = 100
+      assert.are.same({ "canary_route=def; Path=/", "session=xyz; Path=/" }, run_dedupe(set_cookie))
+    end)
+
+    it("removes the header when only a stale affinity cookie is set", function()
+      assert.is_nil(run_dedupe("canary_route=def; Path=/"))
+    end)
+
+    it("does not change the cookies when dedupe is disabled", function()
+      canary_backend.trafficShapingPolicy.dedupeSetCookie = false
+      assert.are.same(set_cookie, run_dedupe(set_cookie))
+    end)
+
+    it("does not strip a cookie shared by both backends", function()
+      canary_backend.sessionAffinityConfig.cookieSessionAffinity.name = "route"
+      assert.are.same(set_cookie, run_dedupe(set_cookie))
+    end)
+  end)
+
+  describe("canary actions", function()
+    local backend, canary_backend, request_headers, uri_args, response_headers
+
+    before_each(function()
+      backend = {
+        name = "my-dummy-app-8", ["load-balance"] = "round_robin",
+        alternativeBackends = { "my-dummy-canary-app-8" },
+        endpoints = { { address = "10.184.7.40", port = "8080", maxFails = 0, failTimeout = 0 } },
+      }
+      canary_backend = {
+        name = "my-dummy-canary-app-8", ["load-balance"] = "round_robin",
+        endpoints = { { address = "11.184.7.40", port = "8080", maxFails = 0, failTimeout = 0 } },
+        trafficShapingPolicy = {
+          weight = 100,
+          header = "",
+          headerValue = "",
+          cookie = "",
+          reqAddHeader = "x-a:1||x-b:2",
+          reqAppendHeader = "x-c:3||malformed",
+          reqAddQuery = "a=1&b=2",
+          respAddHeader = "x-d:4",
+          respAppendHeader = "x-e:5",
+        },
+      }
+      request_headers = { ["x-a"] = "0", ["x-c"] = "0" }
+      uri_args = { a = "0" }
+      response_headers = { ["x-e"] = "0" }
+    end)
+
+    local function run_actions()
+      balancer.sync_backend(backend)
+      balancer.sync_backend(canary_backend)
+
+      mock_ngx({
+        var = { proxy_upstream_name = backend.name },
+        ctx = {},
+        header = response_headers,
+        req = {
+          get_headers = function() return request_headers end,
+          set_header = function(name, value) request_headers[name] = value end,
+          get_uri_args = function() return uri_args end,
+          set_uri_args = function(args) uri_args = args end,
+        },
+      })
+      balancer.get_balancer()
+      balancer.apply_request_actions()
+      balancer.apply_response_actions()
+    end
+
+    it("applies the actions when the canary serves the request", function()
+      run_actions()
+      assert.are.same({ ["x-a"] = { "0", "1" }, ["x-b"] = "2", ["x-c"] = "0,3" }, request_headers)
+      assert.are.same({ a = { "0", "1" }, b = "2" }, uri_args)
+      assert.are.same({ ["x-d"] = "4", ["x-e"] = "0,5" }, response_headers)
+    end)
+
+    it("applies at most the configured number of actions of every type", function()
+      balancer.set_config({
+        canary_action_limits = {
+          req_add_header = 1, req_append_header = 0, req_add_query = 1,
+          resp_add_header = 1, resp_append_header = 0,
+        },
+      })
+      run_actions()
+      assert.are.same({ ["x-a"] = { "0", "1" }, ["x-c"] = "0" }, request_headers)
+      assert.are.same({ a = { "0", "1" } }, uri_args)
+      assert.are.same({ ["x-d"] = "4", ["x-e"] = "0" }, response_headers)
+    end)
+
+    it("does not apply the actions when the primary backend serves the request", function()
+      canary_backend.trafficShapingPolicy.weight = 0
+      run_actions()
+      assert.are.same({ ["x-a"] = "0", ["x-c"] = "0" }, request_headers)
+      assert.are.same({ a = "0" }, uri_args)
+      assert.are.same({ ["x-e"] = "0" }, response_headers)
+    end)
+  end)
+
   describe("sync_backend()", function()
     local backend, implementation
 
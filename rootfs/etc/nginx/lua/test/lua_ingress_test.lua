@@ -1,3 +1,14 @@
+local original_ngx = ngx
+local function reset_ngx()
+  _G.ngx = original_ngx
+end
+
+local function mock_ngx(mock)
+  local _ngx = mock
+  setmetatable(_ngx, { __index = ngx })
+  _G.ngx = _ngx
+end
+
 describe("lua_ingress", function()
   it("patches math.randomseed to not be called more than once per worker", function()
     local s = spy.on(ngx, "log")
@@ -6,4 +17,42 @@ describe("lua_ingress", function()
     assert.spy(s).was_called_with(ngx.WARN,
       string.format("ignoring math.randomseed(%d) since PRNG is already seeded for worker %d", 100, ngx.worker.pid()))
   end)
+
+  describe("forward_auth_cookies()", function()
+    local lua_ingress = require("lua_ingress")
+
+    after_each(function()
+      reset_ngx()
+    end)
+
+    local function forward(auth_set_cookie, backend_set_cookie, names)
+      mock_ngx({ var = { auth_response_set_cookie = auth_set_cookie }, header = { ["Set-Cookie"] = backend_set_cookie } })
+      lua_ingress.forward_auth_cookies(names)
+      return ngx.header["Set-Cookie"]
+    end
+
+    it("forwards the Set-Cookie headers of the given cookies with their attributes", function()
+      local auth = "session=abc; Path=/; Expires=Wed, 21 Oct 2015 07:28:00 GMT; HttpOnly, token=def; Secure, other=ghi"
+      assert.are.same({ "backend=xyz", "session=abc; Path=/; Expires=Wed, 21 Oct 2015 07:28:00 GMT; HttpOnly", "token=def; Secure" },
+        forward(auth, "backend=xyz", { "session", "token" }))
+    end)
+
+    it("does not change the response without cookies from the authentication service", function()
+      assert.are.same("backend=xyz", forward("", "backend=xyz", { "session" }))
+    end)
+  end)
+
+  describe("append_links()", function()
+    local lua_ingress = require("lua_ingress")
+
+    after_each(function()
+      reset_ngx()
+    end)
+
+    it("appends the links after the Link headers of the backend", function()
+      mock_ngx({ header = { ["Link"] = "</backend.js>; rel=preload; as=script" } })
+      lua_ingress.append_links({ "</app.css>; rel=preload; as=style" })
+      assert.are.same({ "</backend.js>; rel=preload; as=script", "</app.css>; rel=preload; as=style" }, ngx.header["Link"])
+    end)
+  end)
 end)
@@ -41,6 +41,16 @@ describe("Monitor", function()
     assert.equal(10, #monitor.get_metrics_batch())
   end)
 
+  it("batches rejected stream connections", function()
+    local monitor = require("monitor")
+
+    monitor.stream_conn_rejected("default", "ssh", 2222)
+
+    local metrics_batch = monitor.get_metrics_batch()
+    assert.equal(1, #metrics_batch)
+    assert.are.same({ namespace = "default", service = "ssh", streamPort = "2222" }, metrics_batch[1])
+  end)
+
   describe("flush", function()
     it("short circuits when premmature is true (when worker is shutting down)", function()
       local tcp_mock = mock_ngx_socket_tcp()
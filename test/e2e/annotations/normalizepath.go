@@ -0,0 +1,77 @@
+/*
+Copyright 2023 The Alibaba Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package annotations
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/onsi/ginkgo"
+
+	"k8s.io/ingress-nginx/test/e2e/framework"
+)
+
+var _ = framework.DescribeAnnotation("normalize-path", func() {
+	f := framework.NewDefaultFramework("normalizepath")
+
+	ginkgo.BeforeEach(func() {
+		f.NewEchoDeployment()
+	})
+
+	ginkgo.It("should proxy the normalized path when the annotation is true", func() {
+		host := "normalizepath.foo.com"
+		annotations := map[string]string{
+			"nginx.ingress.kubernetes.io/normalize-path": "true",
+		}
+
+		ing := framework.NewSingleIngress(host, "/", host, f.Namespace, framework.EchoService, 80, annotations)
+		f.EnsureIngress(ing)
+
+		f.WaitForNginxServer(host,
+			func(server string) bool {
+				return strings.Contains(server, "rewrite ^(.*)$ $1;")
+			})
+
+		f.HTTPTestClient().
+			GET("/public//static/../admin").
+			WithHeader("Host", host).
+			Expect().
+			Status(http.StatusOK).
+			Body().Contains(fmt.Sprintf("request_uri=http://%v:80/public/admin", host))
+	})
+
+	ginkgo.It("should proxy the raw path without the annotation", func() {
+		host := "normalizepath.foo.com"
+
+		ing := framework.NewSingleIngress(host, "/", host, f.Namespace, framework.EchoService, 80, nil)
+		f.EnsureIngress(ing)
+
+		f.WaitForNginxServer(host,
+			func(server string) bool {
+				return strings.Contains(server, fmt.Sprintf("server_name %v", host)) &&
+					!strings.Contains(server, "rewrite ^(.*)$ $1;")
+			})
+
+		f.HTTPTestClient().
+			GET("/public//static/../admin").
+			WithHeader("Host", host).
+			Expect().
+			Status(http.StatusOK).
+			Body().Contains(fmt.Sprintf("request_uri=http://%v:80/public//static/../admin", host))
+	})
+})
@@ -0,0 +1,87 @@
+/*
+Copyright 2023 The Alibaba Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package settings
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/onsi/ginkgo"
+	"github.com/stretchr/testify/assert"
+
+	"k8s.io/ingress-nginx/test/e2e/framework"
+)
+
+var _ = framework.DescribeSetting("strip-trailing-host-dot", func() {
+	f := framework.NewDefaultFramework("strip-trailing-host-dot")
+
+	setting := "strip-trailing-host-dot"
+
+	ginkgo.BeforeEach(func() {
+		f.NewEchoDeployment()
+		f.UpdateNginxConfigMapData(setting, "false")
+	})
+
+	ginkgo.It("should route a Host with a trailing dot to the server when setting is true", func() {
+		host := "trailing-dot.foo.com"
+
+		f.UpdateNginxConfigMapData(setting, "true")
+
+		ing := framework.NewSingleIngress(host, "/", host, f.Namespace, framework.EchoService, 80, nil)
+		f.EnsureIngress(ing)
+
+		f.WaitForNginxServer(host,
+			func(server string) bool {
+				return strings.Contains(server, fmt.Sprintf("server_name %v", host)) &&
+					strings.Contains(server, "set $best_http_host      $normalized_http_host;")
+			})
+
+		body := f.HTTPTestClient().
+			GET("/").
+			WithHeader("Host", fmt.Sprintf("%v.", host)).
+			Expect().
+			Status(http.StatusOK).
+			Body().
+			Raw()
+
+		assert.Contains(ginkgo.GinkgoT(), body, fmt.Sprintf("host=%v\n", host))
+	})
+
+	ginkgo.It("should not normalize the Host when setting is false", func() {
+		host := "trailing-dot.foo.com"
+
+		ing := framework.NewSingleIngress(host, "/", host, f.Namespace, framework.EchoService, 80, nil)
+		f.EnsureIngress(ing)
+
+		f.WaitForNginxServer(host,
+			func(server string) bool {
+				return strings.Contains(server, fmt.Sprintf("server_name %v", host)) &&
+					strings.Contains(server, "set $best_http_host      $http_host;")
+			})
+
+		body := f.HTTPTestClient().
+			GET("/").
+			WithHeader("Host", fmt.Sprintf("%v.", host)).
+			Expect().
+			Status(http.StatusOK).
+			Body().
+			Raw()
+
+		assert.Contains(ginkgo.GinkgoT(), body, fmt.Sprintf("host=%v.", host))
+	})
+})